@@ -3,70 +3,637 @@
 // @description REST API for aggregating user subscriptions
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description Per-tenant API key as "Bearer <key>"
 package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/go-chi/cors"
 	"github.com/pkg/errors"
 
+	"github.com/100bench/subscription_aggregator/internal/adapters/events"
+	"github.com/100bench/subscription_aggregator/internal/adapters/notify"
 	"github.com/100bench/subscription_aggregator/internal/adapters/storage/postgres"
+	"github.com/100bench/subscription_aggregator/internal/adapters/webhook"
+	"github.com/100bench/subscription_aggregator/internal/auth"
 	"github.com/100bench/subscription_aggregator/internal/cases"
+	"github.com/100bench/subscription_aggregator/internal/config"
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/jobs"
+	"github.com/100bench/subscription_aggregator/internal/logging"
+	"github.com/100bench/subscription_aggregator/internal/metrics"
+	"github.com/100bench/subscription_aggregator/internal/ports/graphql"
+	grpcport "github.com/100bench/subscription_aggregator/internal/ports/grpc"
 	"github.com/100bench/subscription_aggregator/internal/ports/http/public"
+	"github.com/100bench/subscription_aggregator/internal/ports/telegram"
+	"github.com/100bench/subscription_aggregator/internal/ratelimit"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
 
 	_ "github.com/100bench/subscription_aggregator/docs"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 func main() {
-	ctx := context.Background()
+	name, args, mode := os.Args[0], os.Args[1:], config.ModeServe
+	if len(args) > 0 && args[0] == "migrate" {
+		name, args, mode = name+" migrate", args[1:], config.ModeMigrate
+	}
+	migrateMode := mode == config.ModeMigrate
+	cfg, opts, err := config.Load(name, mode, args, os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.PrintConfig {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	var migrateCmd migrateCommand
+	if migrateMode {
+		if migrateCmd, err = parseMigrateCommand(opts.Args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	} else if len(opts.Args) > 0 {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", opts.Args[0])
+		os.Exit(2)
+	}
+
+	logger := newLogger(cfg.Log)
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL environment variable is not set")
+	if migrateMode {
+		if err := runMigrate(ctx, cfg, logger, migrateCmd); err != nil {
+			logger.Error("migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	m, err := migrate.New(
-		"file://migrations",
-		dbURL)
+	if err := run(ctx, cfg, logger); err != nil {
+		logger.Error("service stopped with error", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("service stopped")
+}
+
+// run wires the service and serves HTTP until ctx is cancelled, then drains
+// and shuts everything down in reverse order.
+func run(ctx context.Context, cfg config.Config, logger *slog.Logger) error {
+	shutdownTracing, err := newTracing(ctx, cfg.Tracing)
 	if err != nil {
-		log.Fatalf("failed to create migrate instance: %v", errors.Wrap(err, "migrate.New"))
+		return errors.Wrap(err, "configure tracing")
 	}
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Fatalf("failed to apply migrations: %v", errors.Wrap(err, "migrate.Up"))
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to flush traces", "error", err)
+		}
+	}()
+
+	migrator, err := postgres.NewMigrator(cfg.Database.URL, cfg.Migrations.Source, logger)
+	if err != nil {
+		return errors.Wrap(err, "postgres.NewMigrator")
+	}
+	if cfg.Migrations.AutoMigrate {
+		if err := migrator.Up(ctx); err != nil {
+			migrator.Close()
+			return err
+		}
+		logger.Info("database migrations applied")
+	}
+	schemaVersion, err := migrator.LatestVersion()
+	migrator.Close()
+	if err != nil {
+		return err
 	}
-	log.Println("Database migrations applied successfully!")
 
-	storage, err := postgres.NewPgxClient(ctx, dbURL)
+	storage, err := postgres.NewPgxClient(ctx, cfg.Database.URL, postgres.PoolConfig{
+		MaxConns:        cfg.Database.MaxConns,
+		MinConns:        cfg.Database.MinConns,
+		MaxConnLifetime: cfg.Database.MaxConnLifetime,
+		MaxConnIdleTime: cfg.Database.MaxConnIdleTime,
+	}, logger)
 	if err != nil {
-		log.Fatalf("failed to connect to postgres: %v", errors.Wrap(err, "postgres.NewPgxClient"))
+		return errors.Wrap(err, "postgres.NewPgxClient")
 	}
 	defer storage.Close()
 
-	subscriptionService, err := cases.NewServiceProvider(storage)
-	if err != nil{
-		log.Fatalf("failed to create subscription service: %v", err)
+	appMetrics := metrics.New()
+	if err := appMetrics.Register(metrics.NewPoolCollector(storage.PoolStat)); err != nil {
+		return errors.Wrap(err, "register pool metrics")
+	}
+	if err := appMetrics.Register(metrics.NewActiveSubscriptionsCollector(storage.CountActiveSubs, logger, cfg.Metrics.CacheTTL)); err != nil {
+		return errors.Wrap(err, "register business metrics")
+	}
+	repository := metrics.InstrumentRepository(storage, appMetrics)
+
+	scheduler, err := jobs.NewScheduler(storage.JobStore(), logger)
+	if err != nil {
+		return errors.Wrap(err, "jobs.NewScheduler")
+	}
+	overrides, err := jobs.ParseSchedules(cfg.Jobs.Schedules)
+	if err != nil {
+		return errors.Wrap(err, "jobs.schedules")
+	}
+	registry := &jobRegistry{scheduler: scheduler, overrides: overrides, registered: make(map[string]bool)}
+	if err := registry.add("jobs.purge_history", "@daily", scheduler.PurgeHistory(cfg.Jobs.HistoryRetention)); err != nil {
+		return err
+	}
+
+	if retention := cfg.Retention.SoftDeleted; retention > 0 {
+		purger, err := cases.NewRetentionPurger(repository, logger, retention)
+		if err != nil {
+			return errors.Wrap(err, "cases.NewRetentionPurger")
+		}
+		err = registry.add("subscriptions.purge_deleted", every(cfg.Retention.PurgeInterval), func(ctx context.Context, now time.Time) error {
+			_, err := purger.PurgeOnce(ctx, now)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info("soft-deleted subscriptions are purged after retention", "retention", retention.String())
+	}
+
+	lifecycle, err := cases.NewSubscriptionLifecycle(repository, logger)
+	if err != nil {
+		return errors.Wrap(err, "cases.NewSubscriptionLifecycle")
+	}
+	err = registry.add("subscriptions.lifecycle", "@daily", func(ctx context.Context, now time.Time) error {
+		_, _, err := lifecycle.ProcessOnce(ctx, now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	webhookService, err := cases.NewWebhookService(storage, logger)
+	if err != nil {
+		return errors.Wrap(err, "cases.NewWebhookService")
+	}
+	dispatcher, err := cases.NewWebhookDispatcher(storage, webhook.NewSender(cfg.Webhooks.Timeout), logger, cases.DispatchOptions{
+		Interval:       cfg.Webhooks.DispatchInterval,
+		BatchSize:      cfg.Webhooks.BatchSize,
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		InitialBackoff: cfg.Webhooks.InitialBackoff,
+		MaxBackoff:     cfg.Webhooks.MaxBackoff,
+		Lease:          2 * cfg.Webhooks.Timeout,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cases.NewWebhookDispatcher")
+	}
+	go dispatcher.Run(ctx)
+
+	publisher, err := newEventPublisher(cfg.Outbox, webhookService, logger)
+	if err != nil {
+		return errors.Wrap(err, "configure event publishers")
+	}
+	relay, err := cases.NewOutboxRelay(storage, publisher, logger, cases.RelayOptions{
+		Interval:       cfg.Outbox.RelayInterval,
+		BatchSize:      cfg.Outbox.BatchSize,
+		InitialBackoff: cfg.Outbox.InitialBackoff,
+		MaxBackoff:     cfg.Outbox.MaxBackoff,
+		Retention:      cfg.Outbox.Retention,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cases.NewOutboxRelay")
+	}
+	go relay.Run(ctx)
+
+	feedListener := storage.FeedListener()
+	go feedListener.Run(ctx)
+	feedService, err := cases.NewFeedService(storage, feedListener, logger, cfg.Feed.Retention)
+	if err != nil {
+		return errors.Wrap(err, "cases.NewFeedService")
+	}
+	err = registry.add("feed.purge", "@hourly", func(ctx context.Context, now time.Time) error {
+		_, err := feedService.PurgeOnce(ctx, now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if interval := cfg.Webhooks.RenewalScanInterval; interval > 0 {
+		announcer, err := cases.NewRenewalAnnouncer(repository, events.Fanout{publisher, feedService}, logger)
+		if err != nil {
+			return errors.Wrap(err, "cases.NewRenewalAnnouncer")
+		}
+		err = registry.add("renewals.announce", every(interval), func(ctx context.Context, now time.Time) error {
+			_, err := announcer.AnnounceOnce(ctx, now)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	notificationService, err := cases.NewNotificationService(storage, logger)
+	if err != nil {
+		return errors.Wrap(err, "cases.NewNotificationService")
+	}
+	if cfg.Reminders.Interval > 0 && cfg.Reminders.SMTPAddr != "" {
+		reminders, err := newEmailReminders(cfg.Reminders, storage, logger)
+		if err != nil {
+			return errors.Wrap(err, "configure email reminders")
+		}
+		if err := registry.add("reminders.email", every(cfg.Reminders.Interval), sendReminders(reminders)); err != nil {
+			return err
+		}
+	}
+
+	subscriptionService, err := cases.NewServiceProvider(repository, logger, cases.WithBudgetAlerts())
+	if err != nil {
+		return errors.Wrap(err, "cases.NewServiceProvider")
+	}
+
+	var chatLinks *cases.ChatLinkService
+	if cfg.Telegram.BotToken != "" {
+		chatLinks, err = cases.NewChatLinkService(storage, logger, cfg.Telegram.LinkCodeTTL)
+		if err != nil {
+			return errors.Wrap(err, "cases.NewChatLinkService")
+		}
+		if err := startTelegram(ctx, cfg, storage, subscriptionService, chatLinks, registry, logger); err != nil {
+			return errors.Wrap(err, "configure telegram bot")
+		}
+	}
+
+	if cfg.RateLimit.Store == "postgres" {
+		store := storage.RateLimitStore()
+		err = registry.add("ratelimit.purge_idle", every(ratelimit.IdleBucketTTL), func(ctx context.Context, now time.Time) error {
+			_, err := store.PurgeIdle(ctx, now.Add(-ratelimit.IdleBucketTTL))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	idempotencyStore := storage.IdempotencyStore()
+	err = registry.add("idempotency.purge", "@hourly", func(ctx context.Context, now time.Time) error {
+		_, err := idempotencyStore.Purge(ctx, now.Add(-cfg.Idempotency.TTL))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range registry.unused() {
+		logger.Warn("schedule override for unknown or disabled job ignored", "job", name)
+	}
+	go scheduler.Run(ctx)
+
+	apiKeys, err := auth.ParseKeys(cfg.Auth.APIKeys)
+	if err != nil {
+		return errors.Wrap(err, "parse API keys")
+	}
+
+	limiter, err := newRateLimiter(cfg.RateLimit, storage)
+	if err != nil {
+		return errors.Wrap(err, "configure rate limiting")
+	}
+
+	graphqlHandler, err := graphql.NewHandler(subscriptionService,
+		graphql.WithLogger(logger),
+		graphql.WithAdminToken(cfg.Auth.AdminToken),
+	)
+	if err != nil {
+		return errors.Wrap(err, "graphql.NewHandler")
 	}
 
-	httpServer, err := public.NewServer(subscriptionService)
+	serverOpts := []public.Option{
+		public.WithLogger(logger),
+		public.WithMetrics(appMetrics),
+		public.WithAdminToken(cfg.Auth.AdminToken),
+		public.WithAPIKeys(apiKeys),
+		public.WithRateLimiter(limiter),
+		public.WithIdempotency(idempotencyStore),
+		public.WithWebhooks(webhookService),
+		public.WithFeed(feedService, cfg.Feed.Heartbeat),
+		public.WithNotifications(notificationService),
+		public.WithJobs(scheduler),
+		public.WithMount("/graphql", graphqlHandler),
+		public.WithReadinessCheck("postgres", storage.Ping),
+		public.WithReadinessCheck("schema", schemaCheck(storage, schemaVersion)),
+	}
+	if chatLinks != nil {
+		serverOpts = append(serverOpts, public.WithChatLinks(chatLinks))
+	}
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		serverOpts = append(serverOpts, public.WithCORS(cors.Options{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			ExposedHeaders:   []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           int(cfg.CORS.MaxAge.Seconds()),
+		}))
+	}
+	httpServer, err := public.NewServer(subscriptionService, serverOpts...)
 	if err != nil {
-		log.Fatalf("failed to init http server: %v", err)
+		return errors.Wrap(err, "public.NewServer")
 	}
 	r := httpServer.GetRouter()
 
 	r.Handle("/swagger/*", httpSwagger.WrapHandler)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	srv := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.HTTP.Port),
+		Handler:           r,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	srv.RegisterOnShutdown(httpServer.CloseStreams)
+
+	serveErr := make(chan error, 3)
+	go func() {
+		logger.Info("server listening", "addr", srv.Addr)
+		serveErr <- errors.Wrap(srv.ListenAndServe(), "http.ListenAndServe")
+	}()
+
+	// /metrics is kept off the public listener: it has neither API keys nor
+	// rate limits, so it must only be reachable by the scraper.
+	var metricsSrv *http.Server
+	if cfg.Metrics.Port != 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", appMetrics.Handler())
+		metricsSrv = &http.Server{
+			Addr:              ":" + strconv.Itoa(cfg.Metrics.Port),
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+			ReadTimeout:       cfg.HTTP.ReadTimeout,
+			WriteTimeout:      cfg.HTTP.WriteTimeout,
+			IdleTimeout:       cfg.HTTP.IdleTimeout,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		}
+		go func() {
+			logger.Info("metrics server listening", "addr", metricsSrv.Addr)
+			serveErr <- errors.Wrap(metricsSrv.ListenAndServe(), "metrics http.ListenAndServe")
+		}()
+	}
+
+	var grpcServer *grpcport.Server
+	if cfg.GRPC.Port != 0 {
+		grpcServer, err = grpcport.NewServer(subscriptionService,
+			grpcport.WithLogger(logger),
+			grpcport.WithAdminToken(cfg.Auth.AdminToken),
+			grpcport.WithAPIKeys(apiKeys),
+		)
+		if err != nil {
+			return errors.Wrap(err, "grpc.NewServer")
+		}
+		lis, err := net.Listen("tcp", ":"+strconv.Itoa(cfg.GRPC.Port))
+		if err != nil {
+			return errors.Wrap(err, "listen grpc")
+		}
+		go func() {
+			logger.Info("grpc server listening", "addr", lis.Addr().String())
+			serveErr <- errors.Wrap(grpcServer.GRPCServer().Serve(lis), "grpc.Serve")
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutdown requested, draining", "drain_period", cfg.HTTP.DrainPeriod.String())
+	httpServer.StartDraining()
+	if grpcServer != nil {
+		grpcServer.StartDraining()
+	}
+	time.Sleep(cfg.HTTP.DrainPeriod)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if grpcServer != nil {
+		go func() {
+			<-shutdownCtx.Done()
+			grpcServer.GRPCServer().Stop()
+		}()
+		grpcServer.GRPCServer().GracefulStop()
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return errors.Wrap(err, "http.Server.Shutdown")
+	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			return errors.Wrap(err, "metrics http.Server.Shutdown")
+		}
+	}
+	return nil
+}
+
+// schemaCheck reports the service unready when the database schema is dirty or
+// differs from the latest migration shipped with this binary.
+func schemaCheck(storage *postgres.PgxStorage, want uint) public.ReadinessCheck {
+	return func(ctx context.Context) error {
+		version, dirty, err := storage.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return errors.Errorf("schema version %d is dirty", version)
+		}
+		if version != want {
+			return errors.Errorf("schema version %d, want %d", version, want)
+		}
+		return nil
+	}
+}
+
+func newLogger(cfg config.Log) *slog.Logger {
+	// The level was checked by config.Validate.
+	level, _ := logging.ParseLevel(cfg.Level)
+	return logging.New(os.Stdout, logging.Options{Level: level, RedactUserIDs: cfg.RedactUserIDs})
+}
+
+// newTracing configures the exporter; the standard OTEL_EXPORTER_OTLP_*
+// variables are honoured too.
+func newTracing(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	return tracing.Setup(ctx, tracing.Options{
+		Exporter:    cfg.Exporter,
+		ServiceName: "subscription-aggregator",
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.OTLPInsecure,
+	})
+}
+
+// newEventPublisher combines the configured sinks of the outbox relay.
+func newEventPublisher(cfg config.Outbox, webhooks *cases.WebhookService, logger *slog.Logger) (cases.EventPublisher, error) {
+	var publishers events.Fanout
+	for _, name := range cfg.Publishers {
+		switch name {
+		case config.PublisherLog:
+			p, err := events.NewLogPublisher(logger)
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, p)
+		case config.PublisherWebhook:
+			publishers = append(publishers, webhooks)
+		case config.PublisherKafkaREST:
+			producer, err := events.NewKafkaRESTProducer(cfg.KafkaRESTURL, 10*time.Second)
+			if err != nil {
+				return nil, err
+			}
+			p, err := events.NewBrokerPublisher(producer, cfg.Topic)
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, p)
+		default:
+			return nil, errors.Errorf("unknown publisher %q", name)
+		}
+	}
+	return publishers, nil
+}
+
+// newEmailReminders sends renewal reminders through the configured SMTP server.
+func newEmailReminders(cfg config.Reminders, storage *postgres.PgxStorage, logger *slog.Logger) (*cases.ReminderSender, error) {
+	renderer, err := notify.NewRenderer()
+	if err != nil {
+		return nil, err
+	}
+	var opts []notify.SMTPOption
+	if cfg.SMTPUsername != "" {
+		opts = append(opts, notify.WithAuth(cfg.SMTPUsername, cfg.SMTPPassword))
+	}
+	notifier, err := notify.NewSMTPNotifier(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPTimeout, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cases.NewReminderSender(storage, renderer, notifier, entities.ChannelEmail, logger)
+}
+
+func sendReminders(sender *cases.ReminderSender) jobs.Func {
+	return func(ctx context.Context, now time.Time) error {
+		_, err := sender.SendOnce(ctx, now)
+		return err
+	}
+}
+
+// jobRegistry registers jobs with the scheduler under their default schedule
+// or the one configured for them in jobs.schedules.
+type jobRegistry struct {
+	scheduler  *jobs.Scheduler
+	overrides  map[string]string
+	registered map[string]bool
+}
+
+func (r *jobRegistry) add(name, spec string, fn jobs.Func) error {
+	if override, ok := r.overrides[name]; ok {
+		spec = override
+	}
+	if err := r.scheduler.Register(name, spec, fn); err != nil {
+		return errors.Wrap(err, "register job")
+	}
+	r.registered[name] = true
+	return nil
+}
+
+// unused returns the names of overrides that match no registered job.
+func (r *jobRegistry) unused() []string {
+	var names []string
+	for name := range r.overrides {
+		if !r.registered[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// every turns one of the interval settings into a schedule.
+func every(interval time.Duration) string {
+	return "@every " + interval.String()
+}
+
+// startTelegram runs the bot and, when reminders are enabled, registers the
+// job sending renewal reminders to linked chats.
+func startTelegram(ctx context.Context, cfg config.Config, storage *postgres.PgxStorage, service *cases.ServiceProvider, links *cases.ChatLinkService, registry *jobRegistry, logger *slog.Logger) error {
+	api, err := telegram.NewClient(cfg.Telegram.APIURL, cfg.Telegram.BotToken,
+		telegram.WithHTTPClient(&http.Client{Timeout: cfg.Telegram.PollTimeout + 30*time.Second}),
+	)
+	if err != nil {
+		return err
+	}
+	bot, err := telegram.NewBot(api, service, links,
+		telegram.WithLogger(logger),
+		telegram.WithPollTimeout(cfg.Telegram.PollTimeout),
+	)
+	if err != nil {
+		return err
+	}
+	go bot.Run(ctx)
+
+	if cfg.Reminders.Interval <= 0 {
+		return nil
+	}
+	renderer, err := notify.NewRenderer()
+	if err != nil {
+		return err
+	}
+	notifier, err := telegram.NewNotifier(api, links)
+	if err != nil {
+		return err
+	}
+	reminders, err := cases.NewReminderSender(storage, renderer, notifier, entities.ChannelTelegram, logger)
+	if err != nil {
+		return err
+	}
+	return registry.add("reminders.telegram", every(cfg.Reminders.Interval), sendReminders(reminders))
+}
+
+func newRateLimiter(cfg config.RateLimit, storage *postgres.PgxStorage) (*ratelimit.Limiter, error) {
+	def, err := ratelimit.ParseLimit(cfg.Default)
+	if err != nil {
+		return nil, errors.Wrap(err, "rate_limit.default")
+	}
+	routes, err := ratelimit.ParseRouteLimits(cfg.Routes)
+	if err != nil {
+		return nil, errors.Wrap(err, "rate_limit.routes")
+	}
+
+	var store ratelimit.Store
+	switch cfg.Store {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		store = storage.RateLimitStore()
+	default:
+		return nil, errors.Errorf("rate_limit.store: unknown store %q", cfg.Store)
 	}
-	addr := ":" + port
-	log.Printf("Server listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, r))
+	return ratelimit.NewLimiter(store, def, routes)
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/adapters/storage/postgres"
+	"github.com/100bench/subscription_aggregator/internal/config"
+)
+
+const migrateUsage = `usage: main migrate [flags] <command>
+
+commands:
+  up          apply all pending migrations
+  down N      roll back the last N migrations
+  goto V      migrate up or down to version V
+  version     print the applied version
+  force V     mark version V as applied and clear the dirty flag`
+
+type migrateCommand struct {
+	name string
+	// arg is the step count for down and the version for goto and force.
+	arg int
+}
+
+// parseMigrateCommand validates the positional arguments of the migrate subcommand.
+func parseMigrateCommand(args []string) (migrateCommand, error) {
+	if len(args) == 0 {
+		return migrateCommand{}, errors.New(migrateUsage)
+	}
+	cmd := migrateCommand{name: args[0]}
+	switch cmd.name {
+	case "up", "version":
+		if len(args) != 1 {
+			return migrateCommand{}, errors.Errorf("migrate %s takes no arguments\n%s", cmd.name, migrateUsage)
+		}
+	case "down", "goto", "force":
+		if len(args) != 2 {
+			return migrateCommand{}, errors.Errorf("migrate %s takes exactly one argument\n%s", cmd.name, migrateUsage)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || (cmd.name == "down" && n == 0) {
+			return migrateCommand{}, errors.Errorf("migrate %s: invalid argument %q", cmd.name, args[1])
+		}
+		cmd.arg = n
+	default:
+		return migrateCommand{}, errors.Errorf("unknown migrate command %q\n%s", cmd.name, migrateUsage)
+	}
+	return cmd, nil
+}
+
+// runMigrate executes a migrate subcommand against the configured database and
+// prints the resulting schema version.
+func runMigrate(ctx context.Context, cfg config.Config, logger *slog.Logger, cmd migrateCommand) error {
+	migrator, err := postgres.NewMigrator(cfg.Database.URL, cfg.Migrations.Source, logger)
+	if err != nil {
+		return errors.Wrap(err, "postgres.NewMigrator")
+	}
+	defer migrator.Close()
+
+	switch cmd.name {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx, cmd.arg)
+	case "goto":
+		err = migrator.Goto(ctx, uint(cmd.arg))
+	case "force":
+		err = migrator.Force(ctx, cmd.arg)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, dirty, ok, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	switch {
+	case !ok:
+		fmt.Println("no migrations applied")
+	case dirty:
+		fmt.Printf("%d (dirty)\n", version)
+	default:
+		fmt.Println(version)
+	}
+	return nil
+}
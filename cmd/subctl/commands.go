@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/pkg/client"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+type command func(ctx context.Context, g globals, args []string) error
+
+var commands = map[string]command{
+	"list":   listCmd,
+	"get":    getCmd,
+	"add":    addCmd,
+	"update": updateCmd,
+	"rm":     rmCmd,
+	"total":  totalCmd,
+	"import": importCmd,
+	"export": exportCmd,
+}
+
+func listCmd(ctx context.Context, g globals, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	includeDeleted := fs.Bool("include-deleted", false, "include soft-deleted subscriptions (admin token required)")
+	pos, err := parseArgs(fs, args, "USER_ID")
+	if err != nil {
+		return err
+	}
+	subs, err := g.client.ListSubscriptions(ctx, pos[0], client.ListOptions{IncludeDeleted: *includeDeleted})
+	if err != nil {
+		return err
+	}
+	return printSubscriptions(g.stdout, g.format, subs)
+}
+
+func getCmd(ctx context.Context, g globals, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	pos, err := parseArgs(fs, args, "USER_ID", "SERVICE")
+	if err != nil {
+		return err
+	}
+	sub, err := g.client.GetSubscription(ctx, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	return printSubscriptions(g.stdout, g.format, []pkg.SubscriptionDTO{sub})
+}
+
+func addCmd(ctx context.Context, g globals, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	var req pkg.CreateSubRequest
+	fs.StringVar(&req.UserId, "user", "", "user ID (required)")
+	fs.StringVar(&req.ServiceName, "service", "", "service name (required)")
+	fs.IntVar(&req.Price, "price", 0, "monthly price (required)")
+	fs.StringVar(&req.StartDate, "start", "", "start month MM-YYYY (required)")
+	fs.StringVar(&req.EndDate, "end", "", "end month MM-YYYY")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if req.UserId == "" || req.ServiceName == "" || req.Price <= 0 || req.StartDate == "" {
+		return errors.New("add: --user, --service, --price and --start are required")
+	}
+	sub, err := g.client.CreateSubscription(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printSubscriptions(g.stdout, g.format, []pkg.SubscriptionDTO{sub})
+}
+
+func updateCmd(ctx context.Context, g globals, args []string) error {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	price := fs.Int("price", 0, "new monthly price")
+	start := fs.String("start", "", "new start month MM-YYYY")
+	end := fs.String("end", "", "new end month MM-YYYY")
+	pos, err := parseArgs(fs, args, "USER_ID", "SERVICE")
+	if err != nil {
+		return err
+	}
+
+	// Only flags given on the command line are sent, so the rest stay unchanged.
+	var req pkg.UpdateSubRequest
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "price":
+			req.Price = price
+		case "start":
+			req.StartDate = start
+		case "end":
+			req.EndDate = end
+		}
+	})
+	if req.Price == nil && req.StartDate == nil && req.EndDate == nil {
+		return errors.New("update: nothing to change, pass --price, --start or --end")
+	}
+	sub, err := g.client.UpdateSubscription(ctx, pos[0], pos[1], req)
+	if err != nil {
+		return err
+	}
+	return printSubscriptions(g.stdout, g.format, []pkg.SubscriptionDTO{sub})
+}
+
+func rmCmd(ctx context.Context, g globals, args []string) error {
+	fs := flag.NewFlagSet("rm", flag.ContinueOnError)
+	pos, err := parseArgs(fs, args, "USER_ID", "SERVICE")
+	if err != nil {
+		return err
+	}
+	return g.client.DeleteSubscription(ctx, pos[0], pos[1])
+}
+
+func totalCmd(ctx context.Context, g globals, args []string) error {
+	fs := flag.NewFlagSet("total", flag.ContinueOnError)
+	var req pkg.GetTotalCostRequest
+	fs.StringVar(&req.UserId, "user", "", "user ID (required)")
+	fs.StringVar(&req.StartDate, "from", "", "first month MM-YYYY (required)")
+	fs.StringVar(&req.EndDate, "to", "", "last month MM-YYYY (required)")
+	fs.StringVar(&req.Mode, "mode", "", "expected (default) or actual: what was paid")
+	service := fs.String("service", "", "only this service")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if req.UserId == "" || req.StartDate == "" || req.EndDate == "" {
+		return errors.New("total: --user, --from and --to are required")
+	}
+	if *service != "" {
+		req.ServiceName = service
+	}
+	total, err := g.client.TotalCost(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printTotal(g.stdout, g.format, total)
+}
+
+// importCmd creates every subscription listed in the file. Subscriptions that
+// already exist are reported and skipped so an import can be re-run safely.
+func importCmd(ctx context.Context, g globals, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "input format json or yaml, guessed from the file extension by default")
+	pos, err := parseArgs(fs, args, "FILE")
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if pos[0] == "-" {
+		data, err = io.ReadAll(g.stdin)
+	} else {
+		data, err = os.ReadFile(pos[0])
+	}
+	if err != nil {
+		return errors.Wrap(err, "read input")
+	}
+	if *format == "" {
+		*format = formatJSON
+		if ext := strings.ToLower(filepath.Ext(pos[0])); ext == ".yaml" || ext == ".yml" {
+			*format = formatYAML
+		}
+	}
+	if *format != formatJSON && *format != formatYAML {
+		return errors.Errorf("import: unknown format %q", *format)
+	}
+
+	var reqs []pkg.CreateSubRequest
+	if err := readData(data, *format, &reqs); err != nil {
+		return err
+	}
+
+	var created, skipped int
+	for i, req := range reqs {
+		_, err := g.client.CreateSubscription(ctx, req)
+		switch {
+		case errors.Is(err, client.ErrSubscriptionExists):
+			skipped++
+			fmt.Fprintf(os.Stderr, "skip #%d %s/%s: already exists\n", i+1, req.UserId, req.ServiceName)
+		case err != nil:
+			return errors.Wrapf(err, "import #%d %s/%s (%d created before the failure)", i+1, req.UserId, req.ServiceName, created)
+		default:
+			created++
+		}
+	}
+	fmt.Fprintf(g.stdout, "%d created, %d skipped\n", created, skipped)
+	return nil
+}
+
+// exportCmd writes a user's subscriptions in the format import reads back.
+func exportCmd(ctx context.Context, g globals, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	pos, err := parseArgs(fs, args, "USER_ID")
+	if err != nil {
+		return err
+	}
+	subs, err := g.client.ListSubscriptions(ctx, pos[0], client.ListOptions{})
+	if err != nil {
+		return err
+	}
+	reqs := make([]pkg.CreateSubRequest, 0, len(subs))
+	for _, s := range subs {
+		reqs = append(reqs, pkg.CreateSubRequest{
+			UserId:      s.UserId,
+			ServiceName: s.ServiceName,
+			Price:       s.Price,
+			StartDate:   s.StartDate,
+			EndDate:     s.EndDate,
+		})
+	}
+	format := g.format
+	if format == formatTable {
+		format = formatJSON
+	}
+	return writeData(g.stdout, format, reqs)
+}
+
+// parseArgs parses flags that may appear before, between or after the
+// positional arguments and checks that exactly the named positionals are given.
+func parseArgs(fs *flag.FlagSet, args []string, names ...string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(pos) != len(names) {
+		return nil, errors.Errorf("%s: expected arguments %s", fs.Name(), strings.Join(names, " "))
+	}
+	return pos, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/ports/http/public"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	"github.com/100bench/subscription_aggregator/pkg/client"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// subctl runs commands against the real router over a testutil.Storage.
+type subctl struct {
+	t      *testing.T
+	client *client.Client
+}
+
+func newSubctl(t *testing.T) *subctl {
+	t.Helper()
+	server, err := public.NewServer(testutil.NewService(t, nil),
+		public.WithLogger(testutil.Logger()),
+		public.WithAPIKeys(testutil.APIKeys(t)),
+	)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+	c, err := client.New(ts.URL, "acme", client.WithAPIKey(testutil.AcmeKey), client.WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatalf("client.New: %v", err)
+	}
+	return &subctl{t: t, client: c}
+}
+
+// run executes the command line and returns what it wrote.
+func (s *subctl) run(format, stdin string, args ...string) (string, error) {
+	s.t.Helper()
+	var out bytes.Buffer
+	g := globals{format: format, client: s.client, stdout: &out, stdin: strings.NewReader(stdin)}
+	err := commands[args[0]](context.Background(), g, args[1:])
+	return out.String(), err
+}
+
+func (s *subctl) mustRun(format string, args ...string) string {
+	s.t.Helper()
+	out, err := s.run(format, "", args...)
+	if err != nil {
+		s.t.Fatalf("subctl %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
+
+func TestCommands(t *testing.T) {
+	s := newSubctl(t)
+	user := testutil.UserID
+
+	s.mustRun(formatTable, "add", "--user", user, "--service", "Netflix", "--price", "400", "--start", "01-2025", "--end", "12-2025")
+	s.mustRun(formatTable, "add", "--user", user, "--service", "Spotify", "--price", "300", "--start", "01-2025")
+
+	table := s.mustRun(formatTable, "list", user)
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") ||
+		!strings.Contains(lines[1], "Netflix") || !strings.Contains(lines[2], "Spotify") {
+		t.Fatalf("list table =\n%s", table)
+	}
+	if fields := strings.Fields(lines[2]); fields[len(fields)-1] != "-" || fields[len(fields)-2] != "-" {
+		t.Errorf("Spotify row %q, want dashes for no end and not deleted", lines[2])
+	}
+
+	// Flags after the positionals are parsed too.
+	updated := s.mustRun(formatJSON, "update", user, "Netflix", "--price", "500")
+	var subs []pkg.SubscriptionDTO
+	if err := json.Unmarshal([]byte(updated), &subs); err != nil || len(subs) != 1 || subs[0].Price != 500 || subs[0].EndDate != "12-2025" {
+		t.Errorf("update = %s, %v; want Netflix for 500 with its end month kept", updated, err)
+	}
+
+	// The new price applies from the current month on, so 2025 keeps 400.
+	if out := s.mustRun(formatTable, "total", "--user", user, "--from", "01-2025", "--to", "02-2025"); strings.TrimSpace(out) != "1400" {
+		t.Errorf("total = %q, want 1400", out)
+	}
+	if out := s.mustRun(formatYAML, "total", "--user", user, "--from", "01-2025", "--to", "01-2025", "--service", "Spotify"); strings.TrimSpace(out) != "total_cost: 300" {
+		t.Errorf("total as yaml = %q, want total_cost: 300", out)
+	}
+
+	s.mustRun(formatTable, "rm", user, "Spotify")
+	if out := s.mustRun(formatJSON, "get", user, "Netflix"); !strings.Contains(out, `"service_name": "Netflix"`) {
+		t.Errorf("get = %s", out)
+	}
+	if _, err := s.run(formatTable, "", "get", user, "Spotify"); !errors.Is(err, client.ErrSubscriptionNotFound) {
+		t.Errorf("get of a removed subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{formatJSON, formatYAML} {
+		t.Run(format, func(t *testing.T) {
+			from, to := newSubctl(t), newSubctl(t)
+			user := testutil.UserID
+			from.mustRun(formatTable, "add", "--user", user, "--service", "Netflix", "--price", "400", "--start", "01-2025", "--end", "12-2025")
+			from.mustRun(formatTable, "add", "--user", user, "--service", "Spotify", "--price", "300", "--start", "01-2025")
+
+			exported := from.mustRun(format, "export", user)
+			path := filepath.Join(t.TempDir(), "subs."+format)
+			if err := os.WriteFile(path, []byte(exported), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if out := to.mustRun(formatTable, "import", path); out != "2 created, 0 skipped\n" {
+				t.Errorf("import = %q", out)
+			}
+			if out, err := to.run(formatTable, exported, "import", "--format", format, "-"); err != nil || out != "0 created, 2 skipped\n" {
+				t.Errorf("second import from stdin = %q, %v; want everything skipped", out, err)
+			}
+			if again := to.mustRun(format, "export", user); again != exported {
+				t.Errorf("export after import =\n%s\nwant\n%s", again, exported)
+			}
+		})
+	}
+}
+
+func TestCommandArgumentErrors(t *testing.T) {
+	s := newSubctl(t)
+	for name, args := range map[string][]string{
+		"list without user":      {"list"},
+		"get with one argument":  {"get", testutil.UserID},
+		"add without price":      {"add", "--user", testutil.UserID, "--service", "Netflix", "--start", "01-2025"},
+		"update without changes": {"update", testutil.UserID, "Netflix"},
+		"total without period":   {"total", "--user", testutil.UserID},
+		"unknown flag":           {"rm", "--force", testutil.UserID, "Netflix"},
+		"unknown import format":  {"import", "--format", "csv", "-"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.run(formatTable, "", args...); err == nil {
+				t.Errorf("subctl %s succeeded", strings.Join(args, " "))
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	price := fs.Int("price", 0, "")
+	pos, err := parseArgs(fs, []string{"user", "--price", "5", "Netflix"}, "USER_ID", "SERVICE")
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if len(pos) != 2 || pos[0] != "user" || pos[1] != "Netflix" || *price != 5 {
+		t.Errorf("parseArgs = %q, price %d; want [user Netflix], 5", pos, *price)
+	}
+}
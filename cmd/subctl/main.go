@@ -0,0 +1,146 @@
+// Command subctl manages subscriptions through the subscription aggregator API.
+//
+//	subctl [global flags] <command> [flags] [args]
+//
+// Connection settings come from a profile in the config file
+// (~/.config/subctl/config.yaml by default), overridden by SUBCTL_* environment
+// variables and then by global flags.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/pkg/client"
+)
+
+const usage = `usage: subctl [global flags] <command> [flags] [args]
+
+commands:
+  list    USER_ID                      list a user's subscriptions
+  get     USER_ID SERVICE              show one subscription
+  add     --user --service --price --start [--end]
+                                       create a subscription
+  update  USER_ID SERVICE [--price] [--start] [--end]
+                                       change a subscription
+  rm      USER_ID SERVICE              delete a subscription
+  total   --user --from --to [--service] [--mode expected|actual]
+                                       total cost for a period (MM-YYYY)
+  import  FILE                         create subscriptions from a JSON/YAML file ("-" for stdin)
+  export  USER_ID                      write a user's subscriptions in import format
+
+global flags:`
+
+// globals are the settings shared by every command.
+type globals struct {
+	format string
+	client *client.Client
+	stdout io.Writer
+	stdin  io.Reader
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "subctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("subctl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	var (
+		configPath = fs.String("config", envOr("SUBCTL_CONFIG", defaultConfigPath()), "config file with profiles (env SUBCTL_CONFIG)")
+		profileArg = fs.String("profile", os.Getenv("SUBCTL_PROFILE"), "profile name, default_profile from the config file when empty (env SUBCTL_PROFILE)")
+		baseURL    = fs.String("base-url", "", "API base URL (env SUBCTL_BASE_URL)")
+		tenant     = fs.String("tenant", "", "tenant ID (env SUBCTL_TENANT)")
+		apiKey     = fs.String("api-key", "", "tenant API key (env SUBCTL_API_KEY)")
+		actor      = fs.String("actor", "", "actor recorded in the audit log (env SUBCTL_ACTOR)")
+		token      = fs.String("token", "", "admin token (env SUBCTL_ADMIN_TOKEN)")
+		format     = fs.String("o", formatTable, "output format: table, json or yaml")
+		timeout    = fs.Duration("timeout", 30*time.Second, "timeout for the whole command")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if !validFormat(*format) {
+		return errors.Errorf("unknown output format %q", *format)
+	}
+
+	p, err := loadProfile(*configPath, *profileArg)
+	if err != nil {
+		return err
+	}
+	override(&p.BaseURL, os.Getenv("SUBCTL_BASE_URL"), *baseURL)
+	override(&p.Tenant, os.Getenv("SUBCTL_TENANT"), *tenant)
+	override(&p.APIKey, os.Getenv("SUBCTL_API_KEY"), *apiKey)
+	override(&p.Actor, os.Getenv("SUBCTL_ACTOR"), *actor)
+	override(&p.AdminToken, os.Getenv("SUBCTL_ADMIN_TOKEN"), *token)
+	if p.BaseURL == "" {
+		p.BaseURL = "http://localhost:8080"
+	}
+	if p.Actor == "" {
+		p.Actor = os.Getenv("USER")
+	}
+
+	c, err := client.New(p.BaseURL, p.Tenant,
+		client.WithAPIKey(p.APIKey),
+		client.WithActor(p.Actor),
+		client.WithAdminToken(p.AdminToken),
+		client.WithUserAgent("subctl"),
+	)
+	if errors.Is(err, client.ErrTenantRequired) {
+		return errors.New("no tenant configured: set it in the profile, SUBCTL_TENANT or --tenant")
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	g := globals{format: *format, client: c, stdout: os.Stdout, stdin: os.Stdin}
+	name, rest := fs.Arg(0), fs.Args()[1:]
+	cmd, ok := commands[name]
+	if !ok {
+		return errors.Errorf("unknown command %q, run subctl -h for help", name)
+	}
+	return cmd(ctx, g, rest)
+}
+
+// override applies the environment value and then the flag value, each only when set.
+func override(dst *string, env, flagValue string) {
+	if env != "" {
+		*dst = env
+	}
+	if flagValue != "" {
+		*dst = flagValue
+	}
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
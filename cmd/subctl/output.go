@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatYAML
+}
+
+// writeData encodes v as JSON or YAML. YAML goes through JSON first so field
+// names follow the json tags of pkg/dto, exactly as the API spells them.
+func writeData(w io.Writer, format string, v interface{}) error {
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "encode")
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return errors.Wrap(err, "encode")
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(generic)
+}
+
+// readData decodes JSON or YAML into v, using the json tags of v in both cases.
+func readData(data []byte, format string, v interface{}) error {
+	if format == formatYAML {
+		var generic interface{}
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return errors.Wrap(err, "parse yaml")
+		}
+		var err error
+		if data, err = json.Marshal(generic); err != nil {
+			return errors.Wrap(err, "parse yaml")
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.Wrap(err, "parse json")
+	}
+	return nil
+}
+
+func printSubscriptions(w io.Writer, format string, subs []pkg.SubscriptionDTO) error {
+	if format != formatTable {
+		return writeData(w, format, subs)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSER\tSERVICE\tPRICE\tSTART\tEND\tDELETED")
+	for _, s := range subs {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			s.ID, s.UserId, s.ServiceName, s.Price, s.StartDate, dash(s.EndDate), dash(s.DeletedAt))
+	}
+	return tw.Flush()
+}
+
+func printTotal(w io.Writer, format string, total int) error {
+	if format != formatTable {
+		return writeData(w, format, pkg.GetTotalCostResponse{TotalCost: total})
+	}
+	_, err := fmt.Fprintln(w, strconv.Itoa(total))
+	return err
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
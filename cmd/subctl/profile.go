@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// profile holds the connection settings selected with --profile.
+type profile struct {
+	BaseURL    string `yaml:"base_url"`
+	Tenant     string `yaml:"tenant"`
+	APIKey     string `yaml:"api_key,omitempty"`
+	Actor      string `yaml:"actor,omitempty"`
+	AdminToken string `yaml:"admin_token,omitempty"`
+}
+
+// profilesFile is the on-disk format of the subctl configuration:
+//
+//	default_profile: prod
+//	profiles:
+//	  prod:
+//	    base_url: https://subs.example.com
+//	    tenant: acme
+//	    api_key: ...
+//	    actor: support@example.com
+//	    admin_token: ...
+type profilesFile struct {
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]profile `yaml:"profiles"`
+}
+
+// defaultConfigPath is $XDG_CONFIG_HOME/subctl/config.yaml or its platform equivalent.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "subctl", "config.yaml")
+}
+
+// loadProfile reads name from the config file at path. A missing file yields an
+// empty profile so everything can come from flags and environment instead.
+func loadProfile(path, name string) (profile, error) {
+	if path == "" {
+		return profile{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if name != "" {
+			return profile{}, errors.Errorf("profile %q requested but %s does not exist", name, path)
+		}
+		return profile{}, nil
+	}
+	if err != nil {
+		return profile{}, errors.Wrap(err, "read config")
+	}
+
+	var file profilesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return profile{}, errors.Wrapf(err, "parse %s", path)
+	}
+	if name == "" {
+		name = file.DefaultProfile
+	}
+	if name == "" {
+		return profile{}, nil
+	}
+	p, ok := file.Profiles[name]
+	if !ok {
+		return profile{}, errors.Errorf("profile %q not found in %s", name, path)
+	}
+	return p, nil
+}
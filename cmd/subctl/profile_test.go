@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const profilesYAML = `default_profile: prod
+profiles:
+  prod:
+    base_url: https://subs.example.com
+    tenant: acme
+    api_key: prod-key
+  staging:
+    base_url: https://staging.example.com
+    tenant: acme
+    actor: support@example.com
+`
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(profilesYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.yaml")
+	if err := os.WriteFile(broken, []byte("profiles: [prod"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.yaml")
+
+	tests := map[string]struct {
+		path, name string
+		want       profile
+		wantErr    bool
+	}{
+		"default profile":          {path: path, want: profile{BaseURL: "https://subs.example.com", Tenant: "acme", APIKey: "prod-key"}},
+		"named profile":            {path: path, name: "staging", want: profile{BaseURL: "https://staging.example.com", Tenant: "acme", Actor: "support@example.com"}},
+		"unknown profile":          {path: path, name: "dev", wantErr: true},
+		"no config path":           {path: ""},
+		"missing file":             {path: missing},
+		"missing file with a name": {path: missing, name: "prod", wantErr: true},
+		"broken file":              {path: broken, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := loadProfile(tt.path, tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadProfile = %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("profile = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	for name, tt := range map[string]struct {
+		profile, env, flag, want string
+	}{
+		"profile only":     {"profile", "", "", "profile"},
+		"env over profile": {"profile", "env", "", "env"},
+		"flag over env":    {"profile", "env", "flag", "flag"},
+		"flag only":        {"", "", "flag", "flag"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := tt.profile
+			override(&got, tt.env, tt.flag)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// KafkaRESTProducer produces to Kafka through the Confluent REST Proxy v2 API,
+// which Redpanda's HTTP Proxy implements too, so no broker client library is
+// needed. The API has no record headers; they are dropped.
+type KafkaRESTProducer struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewKafkaRESTProducer(baseURL string, timeout time.Duration) (*KafkaRESTProducer, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("invalid Kafka REST proxy URL %q", baseURL)
+	}
+	return &KafkaRESTProducer{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+type kafkaRESTRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type kafkaRESTRequest struct {
+	Records []kafkaRESTRecord `json:"records"`
+}
+
+// kafkaRESTResponse reports per-record errors with a 200 status.
+type kafkaRESTResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (p *KafkaRESTProducer) Produce(ctx context.Context, topic string, msg Message) error {
+	body, err := json.Marshal(kafkaRESTRequest{Records: []kafkaRESTRecord{{
+		Key:   base64.StdEncoding.EncodeToString([]byte(msg.Key)),
+		Value: base64.StdEncoding.EncodeToString(msg.Value),
+	}}})
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "http.NewRequest")
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.binary.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "kafka rest proxy")
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("kafka rest proxy answered %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	var out kafkaRESTResponse
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return errors.Wrap(err, "decode kafka rest proxy response")
+	}
+	for _, o := range out.Offsets {
+		if o.ErrorCode != nil {
+			return errors.Errorf("kafka rest proxy rejected record: %s", o.Error)
+		}
+	}
+	return nil
+}
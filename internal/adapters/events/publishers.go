@@ -0,0 +1,102 @@
+// Package events provides the sinks the outbox relay can publish subscription
+// events to.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// LogPublisher writes every event to the log; useful locally and as an audit
+// trail of what was published.
+type LogPublisher struct {
+	logger *slog.Logger
+}
+
+func NewLogPublisher(logger *slog.Logger) (*LogPublisher, error) {
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	return &LogPublisher{logger: logger}, nil
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event en.Event) error {
+	p.logger.InfoContext(ctx, "event published",
+		"event_id", event.ID,
+		"event_type", event.Type,
+		"tenant_id", event.TenantID,
+		"user_id", event.UserID,
+		"subscription_id", event.Subscription.ID,
+	)
+	return nil
+}
+
+// Fanout publishes every event to all of its publishers. It fails if any of
+// them fails, so the relay retries the event for all of them; publishers that
+// already succeeded see it again, which at-least-once delivery allows.
+type Fanout []cases.EventPublisher
+
+func (f Fanout) Publish(ctx context.Context, event en.Event) error {
+	var failed error
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil && failed == nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// Message is a broker record. Key keeps all events of a subscription on the
+// same partition, which preserves their order.
+type Message struct {
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+// Producer writes messages to a topic of a message broker. Implementing it on
+// top of a NATS or Kafka client plugs that broker into BrokerPublisher.
+type Producer interface {
+	Produce(ctx context.Context, topic string, msg Message) error
+}
+
+// BrokerPublisher publishes events as JSON messages to a broker topic.
+type BrokerPublisher struct {
+	producer Producer
+	topic    string
+}
+
+func NewBrokerPublisher(producer Producer, topic string) (*BrokerPublisher, error) {
+	if producer == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "producer")
+	}
+	if topic == "" {
+		return nil, errors.New("broker topic is required")
+	}
+	return &BrokerPublisher{producer: producer, topic: topic}, nil
+}
+
+func (p *BrokerPublisher) Publish(ctx context.Context, event en.Event) error {
+	value, err := cases.EncodeEvent(event)
+	if err != nil {
+		return err
+	}
+	msg := Message{
+		Key:   event.TenantID + "/" + strconv.FormatInt(event.Subscription.ID, 10),
+		Value: value,
+		Headers: map[string]string{
+			"event-id":   event.ID,
+			"event-type": string(event.Type),
+		},
+	}
+	if err := p.producer.Produce(ctx, p.topic, msg); err != nil {
+		return errors.Wrapf(err, "produce to %s", p.topic)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package events_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/adapters/events"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// fakeProxy is a Kafka REST proxy keeping the records it was sent.
+type fakeProxy struct {
+	mu      sync.Mutex
+	topics  []string
+	records []map[string]string
+	reject  bool
+}
+
+func (p *fakeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Records []map[string]string `json:"records"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.topics = append(p.topics, r.URL.Path)
+	p.records = append(p.records, req.Records...)
+	if p.reject {
+		_, _ = w.Write([]byte(`{"offsets":[{"error_code":50002,"error":"leader not available"}]}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+}
+
+func decode(t *testing.T, s string) string {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return string(b)
+}
+
+func TestBrokerPublisherKeysEventsBySubscription(t *testing.T) {
+	proxy := &fakeProxy{}
+	ts := httptest.NewServer(proxy)
+	defer ts.Close()
+	producer, err := events.NewKafkaRESTProducer(ts.URL, time.Second)
+	if err != nil {
+		t.Fatalf("NewKafkaRESTProducer: %v", err)
+	}
+	publisher, err := events.NewBrokerPublisher(producer, "subscriptions")
+	if err != nil {
+		t.Fatalf("NewBrokerPublisher: %v", err)
+	}
+
+	event := en.Event{
+		ID: "e1", Type: en.EventSubscriptionCreated, TenantID: "acme",
+		Subscription: en.Subscription{ID: 7, ServiceName: "Netflix", Price: 400},
+		OccurredAt:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(proxy.records) != 1 || proxy.topics[0] != "/topics/subscriptions" {
+		t.Fatalf("proxy got %v on %v, want one record on /topics/subscriptions", proxy.records, proxy.topics)
+	}
+	if key := decode(t, proxy.records[0]["key"]); key != "acme/7" {
+		t.Errorf("key = %q, want acme/7 so a subscription's events share a partition", key)
+	}
+	var value struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(decode(t, proxy.records[0]["value"])), &value); err != nil {
+		t.Fatalf("decode value: %v", err)
+	}
+	if value.ID != "e1" || value.Type != string(en.EventSubscriptionCreated) {
+		t.Errorf("value = %+v, want the event envelope", value)
+	}
+
+	proxy.reject = true
+	if err := publisher.Publish(context.Background(), event); err == nil {
+		t.Error("Publish of a record the proxy rejected succeeded, want an error so the relay retries")
+	}
+}
+
+type publisherFunc func(ctx context.Context, event en.Event) error
+
+func (f publisherFunc) Publish(ctx context.Context, event en.Event) error { return f(ctx, event) }
+
+func TestFanoutFailsIfAnyPublisherFails(t *testing.T) {
+	var calls int
+	ok := publisherFunc(func(context.Context, en.Event) error { calls++; return nil })
+	failing := publisherFunc(func(context.Context, en.Event) error { calls++; return errors.New("down") })
+
+	if err := (events.Fanout{ok, ok}).Publish(context.Background(), en.Event{}); err != nil {
+		t.Errorf("Fanout of healthy publishers = %v, want nil", err)
+	}
+	calls = 0
+	if err := (events.Fanout{failing, ok}).Publish(context.Background(), en.Event{}); err == nil {
+		t.Error("Fanout with a failing publisher succeeded, want its error")
+	}
+	if calls != 2 {
+		t.Errorf("Fanout called %d publishers, want all 2 despite the failure", calls)
+	}
+}
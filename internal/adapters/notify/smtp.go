@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// SMTPNotifier sends notifications as multipart text and HTML emails. It
+// upgrades the connection with STARTTLS whenever the server offers it.
+type SMTPNotifier struct {
+	addr    string
+	host    string
+	from    mail.Address
+	auth    smtp.Auth
+	timeout time.Duration
+	tls     *tls.Config
+}
+
+// SMTPOption customizes an SMTPNotifier.
+type SMTPOption func(*SMTPNotifier)
+
+// WithAuth logs in with PLAIN authentication, which net/smtp only allows
+// over TLS or to localhost.
+func WithAuth(username, password string) SMTPOption {
+	return func(n *SMTPNotifier) {
+		n.auth = smtp.PlainAuth("", username, password, n.host)
+	}
+}
+
+// WithTLSConfig replaces the configuration used for STARTTLS.
+func WithTLSConfig(cfg *tls.Config) SMTPOption {
+	return func(n *SMTPNotifier) {
+		n.tls = cfg
+	}
+}
+
+// NewSMTPNotifier sends through the server at addr (host:port) from the given
+// address; timeout bounds a whole delivery.
+func NewSMTPNotifier(addr, from string, timeout time.Duration, opts ...SMTPOption) (*SMTPNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "smtp address %q", addr)
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sender address %q", from)
+	}
+	if timeout <= 0 {
+		return nil, errors.New("timeout must be positive")
+	}
+	n := &SMTPNotifier{addr: addr, host: host, from: *sender, timeout: timeout, tls: &tls.Config{ServerName: host}}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n, nil
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, notification en.Notification) error {
+	msg, err := n.message(notification, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "SMTPNotifier.Notify")
+	}
+	if err := n.send(ctx, notification.To, msg); err != nil {
+		return errors.Wrap(err, "SMTPNotifier.Notify")
+	}
+	return nil
+}
+
+func (n *SMTPNotifier) send(ctx context.Context, to string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return errors.Wrap(err, "dial")
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return errors.Wrap(err, "greeting")
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(n.tls); err != nil {
+			return errors.Wrap(err, "STARTTLS")
+		}
+	}
+	if n.auth != nil {
+		if err := c.Auth(n.auth); err != nil {
+			return errors.Wrap(err, "AUTH")
+		}
+	}
+	if err := c.Mail(n.from.Address); err != nil {
+		return errors.Wrap(err, "MAIL FROM")
+	}
+	if err := c.Rcpt(to); err != nil {
+		return errors.Wrap(err, "RCPT TO")
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "DATA")
+	}
+	if _, err := w.Write(msg); err != nil {
+		return errors.Wrap(err, "write message")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "end of data")
+	}
+	return c.Quit()
+}
+
+// message builds a MIME multipart/alternative email with quoted-printable
+// text and HTML parts.
+func (n *SMTPNotifier) message(notification en.Notification, now time.Time) ([]byte, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.Wrap(err, "generate message id")
+	}
+
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", n.from.String())
+	header("To", notification.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", notification.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+n.host+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+body.Boundary())
+	buf.WriteString("\r\n")
+
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", notification.Text},
+		{"text/html; charset=utf-8", notification.HTML},
+	}
+	for _, p := range parts {
+		if p.content == "" {
+			continue
+		}
+		part, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
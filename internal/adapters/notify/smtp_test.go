@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// fakeSMTP accepts one session on a loopback port and records the envelope
+// and message; rcptReply answers RCPT TO.
+type fakeSMTP struct {
+	addr      string
+	rcptReply string
+	done      chan struct{}
+
+	from, to string
+	data     string
+}
+
+func newFakeSMTP(t *testing.T, rcptReply string) *fakeSMTP {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	s := &fakeSMTP{addr: lis.Addr().String(), rcptReply: rcptReply, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.serve(textproto.NewConn(conn))
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(c *textproto.Conn) {
+	_ = c.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			_ = c.PrintfLine("250 fake")
+		case "MAIL":
+			s.from = arg
+			_ = c.PrintfLine("250 ok")
+		case "RCPT":
+			s.to = arg
+			_ = c.PrintfLine("%s", s.rcptReply)
+		case "DATA":
+			_ = c.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			_ = c.PrintfLine("250 queued")
+		case "QUIT":
+			_ = c.PrintfLine("221 bye")
+			return
+		default:
+			_ = c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTP) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("smtp session did not end")
+	}
+}
+
+func TestSMTPNotifierSendsMultipartMessage(t *testing.T) {
+	server := newFakeSMTP(t, "250 ok")
+	n, err := NewSMTPNotifier(server.addr, "Subscription Aggregator <noreply@example.com>", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewSMTPNotifier: %v", err)
+	}
+	err = n.Notify(context.Background(), en.Notification{
+		To:      "user@example.com",
+		Subject: "Подписка Netflix продлится 1 марта 2025",
+		Text:    "Ваша подписка Netflix продлится 1 марта 2025.",
+		HTML:    "<p>Ваша подписка <strong>Netflix</strong></p>",
+	})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	server.wait(t)
+
+	if server.from != "FROM:<noreply@example.com>" || server.to != "TO:<user@example.com>" {
+		t.Errorf("envelope = %q, %q", server.from, server.to)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(server.data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Подписка Netflix продлится 1 марта 2025" {
+		t.Errorf("subject = %q, %v", subject, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v", mediaType, err)
+	}
+
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		// multipart decodes quoted-printable parts on read.
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+	if parts["text/plain"] != "Ваша подписка Netflix продлится 1 марта 2025." {
+		t.Errorf("text part = %q", parts["text/plain"])
+	}
+	if parts["text/html"] != "<p>Ваша подписка <strong>Netflix</strong></p>" {
+		t.Errorf("html part = %q", parts["text/html"])
+	}
+}
+
+func TestSMTPNotifierReportsRejectedRecipient(t *testing.T) {
+	server := newFakeSMTP(t, "550 no such user")
+	n, err := NewSMTPNotifier(server.addr, "noreply@example.com", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewSMTPNotifier: %v", err)
+	}
+	err = n.Notify(context.Background(), en.Notification{To: "nobody@example.com", Subject: "s", Text: "t"})
+	if err == nil || !strings.Contains(err.Error(), "RCPT TO") {
+		t.Errorf("Notify = %v, want a RCPT TO error", err)
+	}
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+//go:embed templates/*
+var templatesFS embed.FS
+
+// ruMonths are the genitive month names Russian dates use.
+var ruMonths = [...]string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+type localeTemplates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Renderer renders notifications from the embedded templates, one text and
+// one HTML template per locale in en.Locales.
+type Renderer struct {
+	locales map[string]localeTemplates
+}
+
+func NewRenderer() (*Renderer, error) {
+	r := &Renderer{locales: make(map[string]localeTemplates, len(en.Locales))}
+	for _, locale := range en.Locales {
+		text, err := texttemplate.ParseFS(templatesFS, "templates/renewal_reminder."+locale+".txt")
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s text template", locale)
+		}
+		html, err := htmltemplate.ParseFS(templatesFS, "templates/renewal_reminder."+locale+".html")
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s html template", locale)
+		}
+		r.locales[locale] = localeTemplates{text: text, html: html}
+	}
+	return r, nil
+}
+
+type reminderData struct {
+	ServiceName string
+	Price       int
+	RenewsOn    string
+	LeadDays    int
+}
+
+// RenderRenewalReminder renders the reminder in the user's locale, falling
+// back to the default one.
+func (r *Renderer) RenderRenewalReminder(reminder en.RenewalReminder) (en.Notification, error) {
+	locale := reminder.Settings.Locale
+	t, ok := r.locales[locale]
+	if !ok {
+		locale = en.Locales[0]
+		t = r.locales[locale]
+	}
+	data := reminderData{
+		ServiceName: reminder.Subscription.ServiceName,
+		Price:       reminder.Subscription.Price,
+		RenewsOn:    formatDate(locale, reminder.RenewsOn),
+		LeadDays:    reminder.Settings.LeadDays,
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return en.Notification{}, errors.Wrap(err, "render subject")
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return en.Notification{}, errors.Wrap(err, "render text")
+	}
+	if err := t.html.Execute(&html, data); err != nil {
+		return en.Notification{}, errors.Wrap(err, "render html")
+	}
+	return en.Notification{
+		UserID:  reminder.Subscription.UserID,
+		To:      reminder.Settings.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func formatDate(locale string, date time.Time) string {
+	if locale == en.LocaleRU {
+		return strconv.Itoa(date.Day()) + " " + ruMonths[date.Month()-1] + " " + strconv.Itoa(date.Year())
+	}
+	return date.Format("January 2, 2006")
+}
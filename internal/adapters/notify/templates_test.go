@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func reminder(locale, service string) en.RenewalReminder {
+	return en.RenewalReminder{
+		Subscription: en.Subscription{UserID: "60601fee-2bf1-4721-ae6f-7636e79a0cba", ServiceName: service, Price: 799},
+		Settings:     en.NotificationSettings{Email: "user@example.com", Locale: locale, LeadDays: 3},
+		RenewsOn:     time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRenderRenewalReminderPerLocale(t *testing.T) {
+	r, err := NewRenderer()
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	tests := map[string]struct {
+		locale  string
+		subject string
+		text    []string
+		html    []string
+	}{
+		"en": {
+			locale:  en.LocaleEN,
+			subject: "Netflix renews on March 1, 2025",
+			text:    []string{"renews on March 1, 2025 for 799 RUB a month", "sent 3 day(s) before"},
+			html:    []string{`lang="en"`, "<strong>Netflix</strong>"},
+		},
+		"ru": {
+			locale:  en.LocaleRU,
+			subject: "Подписка Netflix продлится 1 марта 2025",
+			text:    []string{"продлится 1 марта 2025, стоимость — 799 ₽ в месяц", "за 3 дн. до продления"},
+			html:    []string{`lang="ru"`, "<strong>Netflix</strong>"},
+		},
+		"unknown locale falls back": {
+			locale:  "de",
+			subject: "Netflix renews on March 1, 2025",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			n, err := r.RenderRenewalReminder(reminder(tt.locale, "Netflix"))
+			if err != nil {
+				t.Fatalf("RenderRenewalReminder: %v", err)
+			}
+			if n.Subject != tt.subject {
+				t.Errorf("subject = %q, want %q", n.Subject, tt.subject)
+			}
+			if n.To != "user@example.com" {
+				t.Errorf("to = %q", n.To)
+			}
+			for _, want := range tt.text {
+				if !strings.Contains(n.Text, want) {
+					t.Errorf("text %q does not contain %q", n.Text, want)
+				}
+			}
+			for _, want := range tt.html {
+				if !strings.Contains(n.HTML, want) {
+					t.Errorf("html %q does not contain %q", n.HTML, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderRenewalReminderEscapesHTML(t *testing.T) {
+	r, err := NewRenderer()
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	n, err := r.RenderRenewalReminder(reminder(en.LocaleEN, `<script>alert(1)</script>`))
+	if err != nil {
+		t.Fatalf("RenderRenewalReminder: %v", err)
+	}
+	if strings.Contains(n.HTML, "<script>") {
+		t.Errorf("html contains an unescaped service name: %s", n.HTML)
+	}
+}
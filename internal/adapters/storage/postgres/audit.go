@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+const defaultAuditLimit = 100
+
+// insertAudit appends entries to subscription_audit inside the mutation's transaction.
+func insertAudit(ctx context.Context, tx pgx.Tx, entries ...en.AuditEntry) error {
+	const q = `
+		INSERT INTO subscription_audit (tenant_id, subscription_id, user_id, action, principal, actor, request_id, before, after)
+		VALUES (current_setting('app.tenant_id'), $1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	for _, e := range entries {
+		_, err := tx.Exec(ctx, q, e.SubscriptionID, e.UserID, string(e.Action), e.Principal, e.Actor, e.RequestID, []byte(e.Before), []byte(e.After))
+		if err != nil {
+			return errors.Wrap(err, "insertAudit")
+		}
+	}
+	return nil
+}
+
+func (p *PgxStorage) ListAudit(ctx context.Context, filter en.AuditFilter) ([]en.AuditEntry, error) {
+	p.logger.DebugContext(ctx, "ListAudit", "subscription_id", filter.SubscriptionID, "user_id", filter.UserID, "principal", filter.Principal, "actor", filter.Actor, "action", filter.Action)
+
+	conds := []string{"tenant_id = current_setting('app.tenant_id')"}
+	var args []interface{}
+	addCond := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.SubscriptionID != 0 {
+		addCond("subscription_id = $%d", filter.SubscriptionID)
+	}
+	if filter.UserID != "" {
+		addCond("user_id = $%d", filter.UserID)
+	}
+	if filter.Principal != "" {
+		addCond("principal = $%d", filter.Principal)
+	}
+	if filter.Actor != "" {
+		addCond("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCond("action = $%d", string(filter.Action))
+	}
+	if !filter.From.IsZero() {
+		addCond("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCond("created_at < $%d", filter.To)
+	}
+	if filter.BeforeID != 0 {
+		addCond("id < $%d", filter.BeforeID)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	args = append(args, limit)
+
+	q := fmt.Sprintf(`
+		SELECT id, subscription_id, user_id, action, principal, actor, request_id, before, after, created_at
+		FROM subscription_audit
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT $%d
+	`, strings.Join(conds, " AND "), len(args))
+
+	var entries []en.AuditEntry
+	err := p.inTenantTx(ctx, "ListAudit", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				e             en.AuditEntry
+				action        string
+				before, after []byte
+			)
+			if err := rows.Scan(
+				&e.ID,
+				&e.SubscriptionID,
+				&e.UserID,
+				&action,
+				&e.Principal,
+				&e.Actor,
+				&e.RequestID,
+				&before,
+				&after,
+				&e.CreatedAt,
+			); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			e.Action = en.AuditAction(action)
+			e.Before, e.After = before, after
+			entries = append(entries, e)
+		}
+		setReturnedRows(ctx, len(entries))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list audit entries", "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListAudit")
+	}
+	p.logger.DebugContext(ctx, "audit entries listed", "count", len(entries))
+	return entries, nil
+}
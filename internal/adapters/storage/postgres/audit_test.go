@@ -0,0 +1,133 @@
+//go:build integration
+
+package postgres
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func TestMutationsWriteAudit(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	by := func(action en.AuditAction, actor string) en.AuditEntry {
+		return en.AuditEntry{Action: action, Principal: "acme/billing", Actor: actor, RequestID: "req-" + string(action)}
+	}
+
+	sub, err := storage.CreateSub(ctx, en.Subscription{
+		UserID: userID, ServiceName: "Netflix", Price: 400, StartDate: month(0), EndDate: month(2),
+	}, by(en.AuditActionCreate, ""))
+	if err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+	price := 500
+	if err := storage.UpdateSub(ctx, userID, "Netflix", &price, nil, nil, nil, by(en.AuditActionUpdate, "support@example.com")); err != nil {
+		t.Fatalf("UpdateSub: %v", err)
+	}
+	if err := storage.DeleteSub(ctx, userID, "Netflix", by(en.AuditActionDelete, "")); err != nil {
+		t.Fatalf("DeleteSub: %v", err)
+	}
+
+	entries, err := storage.ListAudit(ctx, en.AuditFilter{SubscriptionID: sub.ID})
+	if err != nil {
+		t.Fatalf("ListAudit: %v", err)
+	}
+	want := []struct {
+		action        en.AuditAction
+		actor         string
+		before, after bool
+	}{
+		{en.AuditActionDelete, "", true, false},
+		{en.AuditActionUpdate, "support@example.com", true, true},
+		{en.AuditActionCreate, "", false, true},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d audit entries, want %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Action != w.action || e.Principal != "acme/billing" || e.Actor != w.actor ||
+			e.UserID != userID || e.RequestID != "req-"+string(w.action) ||
+			(len(e.Before) > 0) != w.before || (len(e.After) > 0) != w.after {
+			t.Errorf("entry %d = %+v, want %s by acme/billing with actor %q", i, e, w.action, w.actor)
+		}
+	}
+
+	var after en.Subscription
+	if err := json.Unmarshal(entries[1].After, &after); err != nil {
+		t.Fatalf("unmarshal update snapshot: %v", err)
+	}
+	if after.Price != price {
+		t.Errorf("update snapshot price = %d, want %d", after.Price, price)
+	}
+}
+
+func TestListAuditFiltersAndPages(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	for _, name := range []string{"Netflix", "Spotify", "Kinopoisk", "Okko", "Ivi"} {
+		if _, err := storage.CreateSub(ctx, en.Subscription{
+			UserID: userID, ServiceName: name, Price: 100, StartDate: month(0),
+		}, en.AuditEntry{Action: en.AuditActionCreate, Principal: "acme/billing"}); err != nil {
+			t.Fatalf("CreateSub %s: %v", name, err)
+		}
+	}
+	price := 200
+	if err := storage.UpdateSub(ctx, userID, "Okko", &price, nil, nil, nil,
+		en.AuditEntry{Action: en.AuditActionUpdate, Principal: "acme/support", Actor: "support@example.com"}); err != nil {
+		t.Fatalf("UpdateSub: %v", err)
+	}
+
+	for name, tt := range map[string]struct {
+		filter en.AuditFilter
+		want   int
+	}{
+		"all":          {en.AuditFilter{}, 6},
+		"by user":      {en.AuditFilter{UserID: userID}, 6},
+		"other user":   {en.AuditFilter{UserID: uuid.NewString()}, 0},
+		"by principal": {en.AuditFilter{Principal: "acme/support"}, 1},
+		"by actor":     {en.AuditFilter{Actor: "support@example.com"}, 1},
+		"by action":    {en.AuditFilter{Action: en.AuditActionCreate}, 5},
+		"limited":      {en.AuditFilter{Limit: 4}, 4},
+	} {
+		t.Run(name, func(t *testing.T) {
+			entries, err := storage.ListAudit(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListAudit: %v", err)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("got %d entries, want %d", len(entries), tt.want)
+			}
+		})
+	}
+
+	var seen []int64
+	filter := en.AuditFilter{Limit: 4}
+	for {
+		page, err := storage.ListAudit(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListAudit: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, e := range page {
+			seen = append(seen, e.ID)
+		}
+		filter.BeforeID = page[len(page)-1].ID
+	}
+	if len(seen) != 6 {
+		t.Fatalf("paged through %d entries, want 6", len(seen))
+	}
+	for i := 1; i < len(seen); i++ {
+		if seen[i] >= seen[i-1] {
+			t.Errorf("entry %d follows %d, want newest first without repeats", seen[i], seen[i-1])
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// budgetColumns is the column list of a budgets row aliased b, in the order
+// scanBudget expects.
+const budgetColumns = `b.id, b.tenant_id, b.user_id::text, b.name, b.monthly_limit, b.currency, b.services,
+	COALESCE(to_char(b.alerted_period, 'MM-YYYY'), ''), b.created_at, b.updated_at`
+
+func budgetScanTargets(b *en.Budget) []interface{} {
+	return []interface{}{&b.ID, &b.TenantID, &b.UserID, &b.Name, &b.Limit, &b.Currency, &b.Services, &b.AlertedPeriod, &b.CreatedAt, &b.UpdatedAt}
+}
+
+func scanBudget(row rowScanner, b *en.Budget) error {
+	return row.Scan(budgetScanTargets(b)...)
+}
+
+// budgetServices keeps a missing service filter from being stored as NULL.
+func budgetServices(services []string) []string {
+	if services == nil {
+		return []string{}
+	}
+	return services
+}
+
+func (p *PgxStorage) CreateBudget(ctx context.Context, budget en.Budget) (en.Budget, error) {
+	p.logger.DebugContext(ctx, "CreateBudget", "user_id", budget.UserID, "name", budget.Name)
+	const q = `
+		INSERT INTO budgets AS b (tenant_id, user_id, name, monthly_limit, currency, services)
+		VALUES (current_setting('app.tenant_id'), $1, $2, $3, $4, $5)
+		RETURNING ` + budgetColumns
+	var created en.Budget
+	err := p.inTenantTx(ctx, "CreateBudget", func(ctx context.Context, tx pgx.Tx) error {
+		setAffectedRows(ctx, 1)
+		return scanBudget(tx.QueryRow(ctx, q, budget.UserID, budget.Name, budget.Limit, budget.Currency, budgetServices(budget.Services)), &created)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			p.logger.WarnContext(ctx, "budget already exists", "user_id", budget.UserID, "name", budget.Name)
+			return en.Budget{}, errors.Wrap(en.ErrBudgetExists, "PgxStorage.CreateBudget")
+		}
+		p.logger.ErrorContext(ctx, "failed to create budget", "user_id", budget.UserID, "error", err)
+		return en.Budget{}, errors.Wrap(err, "PgxStorage.CreateBudget")
+	}
+	return created, nil
+}
+
+func (p *PgxStorage) ListBudgets(ctx context.Context, userID string) ([]en.Budget, error) {
+	p.logger.DebugContext(ctx, "ListBudgets", "user_id", userID)
+	const q = `
+		SELECT ` + budgetColumns + ` FROM budgets b
+		WHERE b.tenant_id = current_setting('app.tenant_id') AND b.user_id = $1
+		ORDER BY b.id
+	`
+	budgets := []en.Budget{}
+	err := p.inTenantTx(ctx, "ListBudgets", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var b en.Budget
+			if err := scanBudget(rows, &b); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			budgets = append(budgets, b)
+		}
+		setReturnedRows(ctx, len(budgets))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list budgets", "user_id", userID, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListBudgets")
+	}
+	return budgets, nil
+}
+
+// UpdateBudget replaces the settings of the user's budget and clears its
+// alert, so the new limit is alerted on once crossed.
+func (p *PgxStorage) UpdateBudget(ctx context.Context, budget en.Budget) (en.Budget, error) {
+	p.logger.DebugContext(ctx, "UpdateBudget", "budget_id", budget.ID, "user_id", budget.UserID)
+	const q = `
+		UPDATE budgets b
+		SET name = $3, monthly_limit = $4, currency = $5, services = $6, alerted_period = NULL, updated_at = now()
+		WHERE b.tenant_id = current_setting('app.tenant_id') AND b.user_id = $1 AND b.id = $2
+		RETURNING ` + budgetColumns
+	var updated en.Budget
+	err := p.inTenantTx(ctx, "UpdateBudget", func(ctx context.Context, tx pgx.Tx) error {
+		if err := scanBudget(tx.QueryRow(ctx, q, budget.UserID, budget.ID, budget.Name, budget.Limit, budget.Currency, budgetServices(budget.Services)), &updated); err != nil {
+			return err
+		}
+		setAffectedRows(ctx, 1)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.logger.WarnContext(ctx, "budget not found", "budget_id", budget.ID, "user_id", budget.UserID)
+			return en.Budget{}, errors.Wrap(en.ErrBudgetNotFound, "PgxStorage.UpdateBudget")
+		}
+		if isUniqueViolation(err) {
+			p.logger.WarnContext(ctx, "budget already exists", "user_id", budget.UserID, "name", budget.Name)
+			return en.Budget{}, errors.Wrap(en.ErrBudgetExists, "PgxStorage.UpdateBudget")
+		}
+		p.logger.ErrorContext(ctx, "failed to update budget", "budget_id", budget.ID, "error", err)
+		return en.Budget{}, errors.Wrap(err, "PgxStorage.UpdateBudget")
+	}
+	return updated, nil
+}
+
+func (p *PgxStorage) DeleteBudget(ctx context.Context, userID string, id int64) error {
+	p.logger.DebugContext(ctx, "DeleteBudget", "budget_id", id, "user_id", userID)
+	const q = `DELETE FROM budgets WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1 AND id = $2`
+	var deleted int64
+	err := p.inTenantTx(ctx, "DeleteBudget", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, userID, id)
+		deleted = tag.RowsAffected()
+		setAffectedRows(ctx, deleted)
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to delete budget", "budget_id", id, "error", err)
+		return errors.Wrap(err, "PgxStorage.DeleteBudget")
+	}
+	if deleted == 0 {
+		return errors.Wrap(en.ErrBudgetNotFound, "PgxStorage.DeleteBudget")
+	}
+	return nil
+}
+
+// ListBudgetStatuses adds up the charges of the MM-YYYY period covered by each
+// of the user's budgets, leaving out those of deleted subscriptions. Paid and
+// disputed charges count with the amount paid, expected ones with the amount
+// billed; refunded and skipped ones do not count.
+func (p *PgxStorage) ListBudgetStatuses(ctx context.Context, userID, period string) ([]en.BudgetStatus, error) {
+	p.logger.DebugContext(ctx, "ListBudgetStatuses", "user_id", userID, "period", period)
+	month, err := time.Parse("01-2006", period)
+	if err != nil {
+		return nil, errors.Wrapf(en.ErrInvalidPeriod, "period %q", period)
+	}
+	const q = `
+		SELECT ` + budgetColumns + `,
+		       COALESCE(SUM(c.paid_amount) FILTER (WHERE c.status IN ('paid', 'disputed')), 0),
+		       COALESCE(SUM(CASE WHEN c.status IN ('paid', 'disputed') THEN c.paid_amount
+		                         WHEN c.status = 'expected' THEN c.amount END), 0)
+		FROM budgets b
+		LEFT JOIN charges c
+		       ON c.tenant_id = b.tenant_id AND c.user_id = b.user_id AND c.period = $2
+		      AND c.currency = b.currency
+		      AND (cardinality(b.services) = 0 OR c.service_name = ANY(b.services))
+		      AND (c.subscription_id IS NULL OR
+		           EXISTS (SELECT 1 FROM subscriptions s WHERE s.id = c.subscription_id AND s.deleted_at IS NULL))
+		WHERE b.tenant_id = current_setting('app.tenant_id') AND b.user_id = $1
+		GROUP BY b.id
+		ORDER BY b.id
+	`
+	statuses := []en.BudgetStatus{}
+	err = p.inTenantTx(ctx, "ListBudgetStatuses", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userID, month)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			status := en.BudgetStatus{Period: period}
+			if err := rows.Scan(append(budgetScanTargets(&status.Budget), &status.Spent, &status.Projected)...); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			statuses = append(statuses, status)
+		}
+		setReturnedRows(ctx, len(statuses))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to evaluate budgets", "user_id", userID, "period", period, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListBudgetStatuses")
+	}
+	return statuses, nil
+}
+
+// AlertBudget marks the budget of status alerted for its MM-YYYY period and
+// records budget.exceeded for the subscription in the outbox in the same
+// transaction. It records nothing and returns false when the period was
+// already alerted, so concurrent changes crossing the limit alert once.
+func (p *PgxStorage) AlertBudget(ctx context.Context, status en.BudgetStatus, subscriptionID int64) (bool, error) {
+	p.logger.DebugContext(ctx, "AlertBudget", "budget_id", status.ID, "period", status.Period, "subscription_id", subscriptionID)
+	month, err := time.Parse("01-2006", status.Period)
+	if err != nil {
+		return false, errors.Wrapf(en.ErrInvalidPeriod, "period %q", status.Period)
+	}
+	budget, err := json.Marshal(status)
+	if err != nil {
+		return false, errors.Wrap(err, "PgxStorage.AlertBudget: encode budget")
+	}
+	const (
+		mark = `
+			UPDATE budgets SET alerted_period = $2
+			WHERE tenant_id = current_setting('app.tenant_id') AND id = $1
+			  AND alerted_period IS DISTINCT FROM $2
+		`
+		record = `
+			INSERT INTO outbox (tenant_id, event_type, subscription_id, user_id, snapshot, budget)
+			SELECT s.tenant_id, $2, s.id, s.user_id, to_jsonb(s), $3
+			FROM subscriptions s
+			WHERE s.tenant_id = current_setting('app.tenant_id') AND s.id = $1
+		`
+	)
+	alerted := false
+	err = p.inTenantTx(ctx, "AlertBudget", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, mark, status.ID, month)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+		tag, err = tx.Exec(ctx, record, subscriptionID, string(en.EventBudgetExceeded), budget)
+		if err != nil {
+			return errors.Wrap(err, "insertOutbox")
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.Wrapf(en.ErrSubscriptionNotFound, "subscription %d", subscriptionID)
+		}
+		setAffectedRows(ctx, 1)
+		alerted = true
+		return nil
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to alert budget", "budget_id", status.ID, "error", err)
+		return false, errors.Wrap(err, "PgxStorage.AlertBudget")
+	}
+	return alerted, nil
+}
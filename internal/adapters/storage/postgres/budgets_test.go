@@ -0,0 +1,192 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func TestListBudgetStatuses(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	create := func(ctx context.Context, userID, service string, price int) en.Subscription {
+		t.Helper()
+		sub, err := storage.CreateSub(ctx, en.Subscription{
+			UserID: userID, ServiceName: service, Price: price, StartDate: "01-2025", EndDate: "03-2025",
+		}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"})
+		if err != nil {
+			t.Fatalf("CreateSub %s: %v", service, err)
+		}
+		return sub
+	}
+	mark := func(sub en.Subscription, status en.ChargeStatus, paid int) {
+		t.Helper()
+		charge, err := storage.GetCharge(ctx, sub.ID, "02-2025")
+		if err != nil {
+			t.Fatalf("GetCharge %s: %v", sub.ServiceName, err)
+		}
+		from := charge.Status
+		charge.Status = status
+		if paid > 0 {
+			charge.PaidAmount = &paid
+		}
+		if _, err := storage.UpdateChargeStatus(ctx, charge, from); err != nil {
+			t.Fatalf("UpdateChargeStatus %s: %v", sub.ServiceName, err)
+		}
+	}
+
+	netflix := create(ctx, userID, "Netflix", 400)
+	spotify := create(ctx, userID, "Spotify", 300)
+	kinopoisk := create(ctx, userID, "Kinopoisk", 200)
+	create(ctx, userID, "Ivi", 150)
+	create(ctx, userID, "Okko", 100)
+	if err := storage.DeleteSub(ctx, userID, "Okko", en.AuditEntry{Action: en.AuditActionDelete, Actor: "test"}); err != nil {
+		t.Fatalf("DeleteSub: %v", err)
+	}
+	create(ctx, uuid.NewString(), "Netflix", 999)
+	create(newTenant("globex"), userID, "Netflix", 999)
+
+	mark(netflix, en.ChargeStatusPaid, 350)
+	mark(spotify, en.ChargeStatusPaid, 300)
+	mark(spotify, en.ChargeStatusDisputed, 0)
+	mark(kinopoisk, en.ChargeStatusSkipped, 0)
+
+	for _, b := range []en.Budget{
+		{Name: "all", Limit: 700, Currency: "RUB"},
+		{Name: "video", Limit: 1000, Currency: "RUB", Services: []string{"Netflix", "Kinopoisk", "Okko", "Ivi"}},
+		{Name: "music", Limit: 300, Currency: "RUB", Services: []string{"Spotify"}},
+		{Name: "dollars", Limit: 10, Currency: "USD"},
+	} {
+		b.UserID = userID
+		if _, err := storage.CreateBudget(ctx, b); err != nil {
+			t.Fatalf("CreateBudget %s: %v", b.Name, err)
+		}
+	}
+
+	type spend struct {
+		spent, projected int
+		exceeded         bool
+	}
+	for period, want := range map[string]map[string]spend{
+		// Paid and disputed months count what was paid; skipped ones and
+		// those of the deleted subscription, another user or another tenant
+		// count nothing.
+		"02-2025": {
+			"all":     {spent: 650, projected: 800, exceeded: true},
+			"video":   {spent: 350, projected: 500},
+			"music":   {spent: 300, projected: 300},
+			"dollars": {},
+		},
+		"01-2025": {
+			"all":     {projected: 1050, exceeded: true},
+			"video":   {projected: 750},
+			"music":   {projected: 300},
+			"dollars": {},
+		},
+		"04-2025": {"all": {}, "video": {}, "music": {}, "dollars": {}},
+	} {
+		statuses, err := storage.ListBudgetStatuses(ctx, userID, period)
+		if err != nil {
+			t.Fatalf("ListBudgetStatuses %s: %v", period, err)
+		}
+		if len(statuses) != len(want) {
+			t.Fatalf("%s: %d statuses, want %d", period, len(statuses), len(want))
+		}
+		for i, status := range statuses {
+			if i > 0 && status.ID <= statuses[i-1].ID {
+				t.Errorf("%s: statuses are not ordered by ID", period)
+			}
+			got := spend{status.Spent, status.Projected, status.Exceeded()}
+			if status.Period != period || got != want[status.Name] {
+				t.Errorf("%s: %s = %+v in %s, want %+v", period, status.Name, got, status.Period, want[status.Name])
+			}
+		}
+	}
+
+	if statuses, err := storage.ListBudgetStatuses(newTenant("globex"), userID, "02-2025"); err != nil || len(statuses) != 0 {
+		t.Errorf("ListBudgetStatuses of another tenant = %+v, %v; want none", statuses, err)
+	}
+	if _, err := storage.ListBudgetStatuses(ctx, userID, "2025-02"); !errors.Is(err, en.ErrInvalidPeriod) {
+		t.Errorf("ListBudgetStatuses with a bad period = %v, want ErrInvalidPeriod", err)
+	}
+}
+
+func TestAlertBudgetRecordsOutboxEventOncePerPeriod(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	sub, err := storage.CreateSub(ctx, en.Subscription{
+		UserID: userID, ServiceName: "Netflix", Price: 400, StartDate: month(0), EndDate: month(0),
+	}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"})
+	if err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+	budget, err := storage.CreateBudget(ctx, en.Budget{UserID: userID, Name: "all", Limit: 300, Currency: "RUB"})
+	if err != nil {
+		t.Fatalf("CreateBudget: %v", err)
+	}
+	status := func() en.BudgetStatus {
+		t.Helper()
+		statuses, err := storage.ListBudgetStatuses(ctx, userID, month(0))
+		if err != nil || len(statuses) != 1 {
+			t.Fatalf("ListBudgetStatuses = %+v, %v; want one", statuses, err)
+		}
+		return statuses[0]
+	}
+	alerts := func() []en.Event {
+		t.Helper()
+		var alerts []en.Event
+		for _, event := range relayTenant(t, storage, ctx, noBackoff, published) {
+			if event.Type == en.EventBudgetExceeded && event.UserID == userID {
+				alerts = append(alerts, event)
+			}
+		}
+		return alerts
+	}
+
+	if _, err := storage.AlertBudget(ctx, status(), sub.ID+1_000_000); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("AlertBudget for a missing subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+	if got := status().AlertedPeriod; got != "" {
+		t.Errorf("failed alert left the budget alerted for %q", got)
+	}
+
+	if alerted, err := storage.AlertBudget(ctx, status(), sub.ID); err != nil || !alerted {
+		t.Fatalf("AlertBudget = %t, %v; want alerted", alerted, err)
+	}
+	if alerted, err := storage.AlertBudget(ctx, status(), sub.ID); err != nil || alerted {
+		t.Errorf("second AlertBudget = %t, %v; want already alerted", alerted, err)
+	}
+	if got := status().AlertedPeriod; got != month(0) {
+		t.Errorf("budget alerted for %q, want %s", got, month(0))
+	}
+
+	events := alerts()
+	if len(events) != 1 {
+		t.Fatalf("relayed %d budget alerts, want 1", len(events))
+	}
+	event := events[0]
+	if event.ID == "" || event.TenantID != "acme" || event.Subscription.ID != sub.ID || event.Subscription.ServiceName != "Netflix" {
+		t.Errorf("alert = %+v, want one of subscription %d", event, sub.ID)
+	}
+	if event.Budget == nil || event.Budget.ID != budget.ID || event.Budget.Period != month(0) || event.Budget.Projected != 400 || event.Budget.Limit != 300 {
+		t.Errorf("alert budget = %+v, want budget %d projected at 400 of 300 in %s", event.Budget, budget.ID, month(0))
+	}
+
+	if _, err := storage.UpdateBudget(ctx, budget); err != nil {
+		t.Fatalf("UpdateBudget: %v", err)
+	}
+	if alerted, err := storage.AlertBudget(ctx, status(), sub.ID); err != nil || !alerted {
+		t.Errorf("AlertBudget after the budget changed = %t, %v; want alerted", alerted, err)
+	}
+	if events := alerts(); len(events) != 1 || events[0].ID == event.ID {
+		t.Errorf("relayed %+v after the budget changed, want one new alert", events)
+	}
+}
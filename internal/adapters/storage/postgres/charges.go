@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// chargeColumns is the column list scanCharge expects.
+const chargeColumns = `id, tenant_id, COALESCE(subscription_id, 0), user_id::text, service_name, to_char(period, 'MM-YYYY'),
+	amount, currency, status, paid_amount, paid_on, created_at, updated_at`
+
+func scanCharge(row rowScanner, c *en.Charge) error {
+	var status string
+	if err := row.Scan(
+		&c.ID,
+		&c.TenantID,
+		&c.SubscriptionID,
+		&c.UserID,
+		&c.ServiceName,
+		&c.Period,
+		&c.Amount,
+		&c.Currency,
+		&status,
+		&c.PaidAmount,
+		&c.PaidOn,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	c.Status = en.ChargeStatus(status)
+	return nil
+}
+
+// liveCharges limits a query on charges to those of subscriptions that are
+// not deleted and to the history left by purged ones; charges of a deleted
+// subscription are skipped until it is restored or purged.
+const liveCharges = `(charges.subscription_id IS NULL OR
+		EXISTS (SELECT 1 FROM subscriptions s WHERE s.id = charges.subscription_id AND s.deleted_at IS NULL))`
+
+// ListCharges returns the charges of the user's subscriptions that are not
+// deleted for the months of the period, optionally of one service, by month
+// and service.
+func (p *PgxStorage) ListCharges(ctx context.Context, userID, serviceName, startDateStr, endDateStr string) ([]en.Charge, error) {
+	p.logger.DebugContext(ctx, "ListCharges", "user_id", userID, "service", serviceName, "start", startDateStr, "end", endDateStr)
+
+	startDate, endDate, err := parsePeriod(startDateStr, endDateStr)
+	if err != nil {
+		p.logger.WarnContext(ctx, "invalid period", "start", startDateStr, "end", endDateStr, "error", err)
+		return nil, err
+	}
+
+	const q = `
+		SELECT ` + chargeColumns + ` FROM charges
+		WHERE tenant_id = current_setting('app.tenant_id')
+		  AND user_id = $1
+		  AND period BETWEEN $2 AND $3
+		  AND ($4 = '' OR service_name = $4)
+		  AND ` + liveCharges + `
+		ORDER BY period, service_name, id
+	`
+	charges := []en.Charge{}
+	err = p.inTenantTx(ctx, "ListCharges", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userID, startDate, endDate, serviceName)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var c en.Charge
+			if err := scanCharge(rows, &c); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			charges = append(charges, c)
+		}
+		setReturnedRows(ctx, len(charges))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list charges", "user_id", userID, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListCharges")
+	}
+	return charges, nil
+}
+
+// GetCharge returns the charge of the subscription for the MM-YYYY period;
+// a deleted subscription has none.
+func (p *PgxStorage) GetCharge(ctx context.Context, subscriptionID int64, period string) (en.Charge, error) {
+	p.logger.DebugContext(ctx, "GetCharge", "subscription_id", subscriptionID, "period", period)
+	month, err := time.Parse("01-2006", period)
+	if err != nil {
+		return en.Charge{}, errors.Wrapf(en.ErrInvalidPeriod, "period %q", period)
+	}
+	const q = `
+		SELECT ` + chargeColumns + ` FROM charges
+		WHERE tenant_id = current_setting('app.tenant_id') AND subscription_id = $1 AND period = $2
+		  AND ` + liveCharges + `
+	`
+	var charge en.Charge
+	err = p.inTenantTx(ctx, "GetCharge", func(ctx context.Context, tx pgx.Tx) error {
+		return scanCharge(tx.QueryRow(ctx, q, subscriptionID, month), &charge)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.logger.WarnContext(ctx, "charge not found", "subscription_id", subscriptionID, "period", period)
+			return en.Charge{}, errors.Wrap(en.ErrChargeNotFound, "PgxStorage.GetCharge")
+		}
+		p.logger.ErrorContext(ctx, "failed to get charge", "subscription_id", subscriptionID, "period", period, "error", err)
+		return en.Charge{}, errors.Wrap(err, "PgxStorage.GetCharge")
+	}
+	return charge, nil
+}
+
+// UpdateChargeStatus stores the status and payment of charge provided it is
+// still in status from; otherwise it fails with en.ErrChargeTransition.
+func (p *PgxStorage) UpdateChargeStatus(ctx context.Context, charge en.Charge, from en.ChargeStatus) (en.Charge, error) {
+	p.logger.DebugContext(ctx, "UpdateChargeStatus", "charge_id", charge.ID, "from", from, "to", charge.Status)
+	const q = `
+		UPDATE charges
+		SET status = $3, paid_amount = $4, paid_on = $5, updated_at = now()
+		WHERE tenant_id = current_setting('app.tenant_id') AND id = $1 AND status = $2
+		RETURNING ` + chargeColumns
+	var updated en.Charge
+	err := p.inTenantTx(ctx, "UpdateChargeStatus", func(ctx context.Context, tx pgx.Tx) error {
+		if err := scanCharge(tx.QueryRow(ctx, q, charge.ID, string(from), string(charge.Status), charge.PaidAmount, charge.PaidOn), &updated); err != nil {
+			return err
+		}
+		setAffectedRows(ctx, 1)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.logger.WarnContext(ctx, "charge changed concurrently", "charge_id", charge.ID, "from", from)
+			return en.Charge{}, errors.Wrapf(en.ErrChargeTransition, "PgxStorage.UpdateChargeStatus: charge is no longer %s", from)
+		}
+		p.logger.ErrorContext(ctx, "failed to update charge", "charge_id", charge.ID, "error", err)
+		return en.Charge{}, errors.Wrap(err, "PgxStorage.UpdateChargeStatus")
+	}
+	p.logger.DebugContext(ctx, "charge updated", "charge_id", charge.ID, "status", updated.Status)
+	return updated, nil
+}
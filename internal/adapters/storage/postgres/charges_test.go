@@ -0,0 +1,175 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// month formats the month offset months from the current one as MM-YYYY.
+func month(offset int) string {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month()+time.Month(offset), 1, 0, 0, 0, 0, time.UTC).Format("01-2006")
+}
+
+func amountsByPeriod(t *testing.T, storage *PgxStorage, ctx context.Context, userID string) map[string]int {
+	t.Helper()
+	charges, err := storage.ListCharges(ctx, userID, "", month(-24), month(24))
+	if err != nil {
+		t.Fatalf("ListCharges: %v", err)
+	}
+	amounts := make(map[string]int, len(charges))
+	for _, c := range charges {
+		amounts[c.Period] = c.Amount
+	}
+	return amounts
+}
+
+func TestChargesFollowPriceEdit(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	if _, err := storage.CreateSub(ctx, en.Subscription{
+		UserID: userID, ServiceName: "Netflix", Price: 400, StartDate: month(-2), EndDate: month(2),
+	}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"}); err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+
+	price := 500
+	if err := storage.UpdateSub(ctx, userID, "Netflix", &price, nil, nil, nil,
+		en.AuditEntry{Action: en.AuditActionUpdate, Actor: "test"}); err != nil {
+		t.Fatalf("UpdateSub: %v", err)
+	}
+
+	got := amountsByPeriod(t, storage, ctx, userID)
+	want := map[string]int{month(-2): 400, month(-1): 400, month(0): 500, month(1): 500, month(2): 500}
+	if len(got) != len(want) {
+		t.Fatalf("charges = %v, want %v", got, want)
+	}
+	for period, amount := range want {
+		if got[period] != amount {
+			t.Errorf("charge for %s = %d, want %d", period, got[period], amount)
+		}
+	}
+}
+
+func TestChargesFollowEndDateEdit(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	if _, err := storage.CreateSub(ctx, en.Subscription{
+		UserID: userID, ServiceName: "Netflix", Price: 400, StartDate: month(-1), EndDate: month(2),
+	}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"}); err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+
+	for _, tt := range []struct {
+		endDate string
+		want    []string
+	}{
+		{month(0), []string{month(-1), month(0)}},
+		{month(3), []string{month(-1), month(0), month(1), month(2), month(3)}},
+	} {
+		endDate := tt.endDate
+		if err := storage.UpdateSub(ctx, userID, "Netflix", nil, nil, &endDate, nil,
+			en.AuditEntry{Action: en.AuditActionUpdate, Actor: "test"}); err != nil {
+			t.Fatalf("UpdateSub end_date %s: %v", endDate, err)
+		}
+		got := amountsByPeriod(t, storage, ctx, userID)
+		if len(got) != len(tt.want) {
+			t.Errorf("end_date %s: charges for %v, want %q", endDate, got, tt.want)
+			continue
+		}
+		for _, period := range tt.want {
+			if got[period] != 400 {
+				t.Errorf("end_date %s: charge for %s = %d, want 400", endDate, period, got[period])
+			}
+		}
+	}
+}
+
+func TestChargesOfDeletedSubscriptionsAreNotCountedUntilPurged(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	for _, service := range []string{"Netflix", "Spotify"} {
+		if _, err := storage.CreateSub(ctx, en.Subscription{
+			UserID: userID, ServiceName: service, Price: 400, StartDate: "01-2025", EndDate: "03-2025",
+		}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"}); err != nil {
+			t.Fatalf("CreateSub %s: %v", service, err)
+		}
+	}
+	if _, err := storage.CreateBudget(ctx, en.Budget{UserID: userID, Name: "all", Limit: 10000, Currency: "RUB"}); err != nil {
+		t.Fatalf("CreateBudget: %v", err)
+	}
+	deleted, err := storage.GetSub(ctx, userID, "Spotify")
+	if err != nil {
+		t.Fatalf("GetSub: %v", err)
+	}
+	if err := storage.DeleteSub(ctx, userID, "Spotify", en.AuditEntry{Action: en.AuditActionDelete, Actor: "test"}); err != nil {
+		t.Fatalf("DeleteSub: %v", err)
+	}
+
+	check := func(when string, subs int) {
+		t.Helper()
+		if total, err := storage.GetTotalByPeriod(ctx, userID, "", "01-2025", "03-2025", en.CostModeExpected); err != nil || total != subs*3*400 {
+			t.Errorf("%s: GetTotalByPeriod = %d, %v; want %d", when, total, err, subs*3*400)
+		}
+		if costs, err := storage.GetTotalByService(ctx, userID, "01-2025", "03-2025"); err != nil || len(costs) != subs {
+			t.Errorf("%s: GetTotalByService = %+v, %v; want %d services", when, costs, err, subs)
+		}
+		if charges, err := storage.ListCharges(ctx, userID, "", "01-2025", "03-2025"); err != nil || len(charges) != subs*3 {
+			t.Errorf("%s: ListCharges = %d charges, %v; want %d", when, len(charges), err, subs*3)
+		}
+		statuses, err := storage.ListBudgetStatuses(ctx, userID, "02-2025")
+		if err != nil || len(statuses) != 1 || statuses[0].Projected != subs*400 {
+			t.Errorf("%s: ListBudgetStatuses = %+v, %v; want projected %d", when, statuses, err, subs*400)
+		}
+	}
+	check("deleted", 1)
+
+	if _, err := storage.RestoreSub(ctx, deleted.ID, en.AuditEntry{Action: en.AuditActionRestore, Actor: "test"}); err != nil {
+		t.Fatalf("RestoreSub: %v", err)
+	}
+	check("restored", 2)
+
+	if err := storage.DeleteSub(ctx, userID, "Spotify", en.AuditEntry{Action: en.AuditActionDelete, Actor: "test"}); err != nil {
+		t.Fatalf("DeleteSub: %v", err)
+	}
+	if _, err := storage.PurgeDeletedSubs(context.Background(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("PurgeDeletedSubs: %v", err)
+	}
+	check("purged", 2)
+
+	charges, err := storage.ListCharges(ctx, userID, "Spotify", "01-2025", "03-2025")
+	if err != nil {
+		t.Fatalf("ListCharges: %v", err)
+	}
+	for _, c := range charges {
+		if c.SubscriptionID != 0 {
+			t.Errorf("purged charge %+v still refers to its subscription", c)
+		}
+	}
+	if _, err := storage.GetCharge(ctx, deleted.ID, "02-2025"); !errors.Is(err, en.ErrChargeNotFound) {
+		t.Errorf("GetCharge of a purged subscription: %v, want ErrChargeNotFound", err)
+	}
+
+	var detached int
+	err = storage.inTenantTx(ctx, "count detached charges", func(ctx context.Context, tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `SELECT count(*) FROM charges WHERE user_id = $1 AND subscription_id IS NULL`, userID).Scan(&detached)
+	})
+	if err != nil {
+		t.Fatalf("count detached charges: %v", err)
+	}
+	if detached != 3 {
+		t.Errorf("purge left %d detached charges, want the 3 of the purged subscription", detached)
+	}
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// lockUserFeed serializes writers of one user's feed until commit, like the
+// subscriptions trigger does, so events become visible in id order.
+const lockUserFeed = `SELECT pg_advisory_xact_lock(hashtext('user_events'), hashtext($1::text || '/' || $2::text))`
+
+// ListUserEvents returns up to limit events of the user's feed with ids
+// above afterID, oldest first.
+func (p *PgxStorage) ListUserEvents(ctx context.Context, userID string, afterID int64, limit int) ([]en.FeedEvent, error) {
+	p.logger.DebugContext(ctx, "ListUserEvents", "user_id", userID, "after_id", afterID)
+	const q = `
+		SELECT id, event_id::text, event_type, tenant_id, user_id::text, snapshot, created_at FROM user_events
+		WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+	var events []en.FeedEvent
+	err := p.inTenantTx(ctx, "ListUserEvents", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userID, afterID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				e         en.FeedEvent
+				eventType string
+				snapshot  []byte
+			)
+			if err := rows.Scan(&e.Seq, &e.ID, &eventType, &e.TenantID, &e.UserID, &snapshot, &e.OccurredAt); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			if e.Subscription, err = decodeSnapshot(snapshot); err != nil {
+				return err
+			}
+			e.Type = en.EventType(eventType)
+			events = append(events, e)
+		}
+		setReturnedRows(ctx, len(events))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list user events", "user_id", userID, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListUserEvents")
+	}
+	return events, nil
+}
+
+// LastUserEventID returns the id of the newest event of the user's feed, or 0
+// if the feed is empty.
+func (p *PgxStorage) LastUserEventID(ctx context.Context, userID string) (int64, error) {
+	p.logger.DebugContext(ctx, "LastUserEventID", "user_id", userID)
+	const q = `
+		SELECT COALESCE(max(id), 0) FROM user_events
+		WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1
+	`
+	var id int64
+	err := p.inTenantTx(ctx, "LastUserEventID", func(ctx context.Context, tx pgx.Tx) error {
+		setReturnedRows(ctx, 1)
+		return tx.QueryRow(ctx, q, userID).Scan(&id)
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to get last user event", "user_id", userID, "error", err)
+		return 0, errors.Wrap(err, "PgxStorage.LastUserEventID")
+	}
+	return id, nil
+}
+
+// AppendUserEvent adds an event that is not a row change, such as a renewal
+// reminder, to the feed of its user. An event ID already in the feed is
+// ignored, so repeated announcements show up once.
+func (p *PgxStorage) AppendUserEvent(ctx context.Context, event en.Event) error {
+	p.logger.DebugContext(ctx, "AppendUserEvent", "user_id", event.UserID, "event_type", event.Type)
+	const q = `
+		INSERT INTO user_events (tenant_id, event_id, event_type, user_id, subscription_id, snapshot)
+		SELECT current_setting('app.tenant_id'), $1, $2, user_id, id, to_jsonb(s) FROM subscriptions s
+		WHERE id = $3
+		ON CONFLICT (event_id) DO NOTHING
+	`
+	err := p.inTenantTx(ctx, "AppendUserEvent", func(ctx context.Context, tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, lockUserFeed, event.TenantID, event.UserID); err != nil {
+			return errors.Wrap(err, "lock user feed")
+		}
+		tag, err := tx.Exec(ctx, q, event.ID, string(event.Type), event.Subscription.ID)
+		setAffectedRows(ctx, tag.RowsAffected())
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to append user event", "user_id", event.UserID, "event_type", event.Type, "error", err)
+		return errors.Wrap(err, "PgxStorage.AppendUserEvent")
+	}
+	return nil
+}
+
+// PurgeUserEvents removes feed events of every tenant created before the given moment.
+func (p *PgxStorage) PurgeUserEvents(ctx context.Context, createdBefore time.Time) (int64, error) {
+	const q = `DELETE FROM user_events WHERE created_at < $1`
+	var purged int64
+	err := p.inSystemTx(ctx, "PurgeUserEvents", "app.feed_retention", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, createdBefore)
+		purged = tag.RowsAffected()
+		setAffectedRows(ctx, purged)
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to purge user events", "error", err)
+		return 0, errors.Wrap(err, "PgxStorage.PurgeUserEvents")
+	}
+	if purged > 0 {
+		p.logger.InfoContext(ctx, "expired feed events purged", "count", purged)
+	}
+	return purged, nil
+}
@@ -0,0 +1,42 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+func TestPingAndSchemaVersion(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	if err := storage.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	migrator, err := NewMigrator(os.Getenv(integrationDSNEnv), "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	defer migrator.Close()
+	latest, err := migrator.LatestVersion()
+	if err != nil {
+		t.Fatalf("LatestVersion: %v", err)
+	}
+	version, dirty, err := storage.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if version != latest || dirty {
+		t.Errorf("SchemaVersion = %d, dirty %t; want %d, clean", version, dirty, latest)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := storage.Ping(canceled); err == nil {
+		t.Error("Ping with a canceled context succeeded")
+	}
+}
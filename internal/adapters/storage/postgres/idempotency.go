@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/idempotency"
+)
+
+// IdempotencyStore keeps the responses to POST requests in Postgres, so a
+// retry finds them whichever replica it reaches.
+type IdempotencyStore struct {
+	storage *PgxStorage
+}
+
+func (p *PgxStorage) IdempotencyStore() *IdempotencyStore {
+	return &IdempotencyStore{storage: p}
+}
+
+// Claim inserts the key, or takes over a claim abandoned for longer than
+// idempotency.ClaimTimeout; otherwise it reads what the key holds.
+func (s *IdempotencyStore) Claim(ctx context.Context, key, fingerprint string, now time.Time) (*idempotency.Response, error) {
+	const (
+		claimQ = `
+			INSERT INTO idempotency_keys AS k (tenant_id, key, fingerprint, created_at)
+			VALUES (current_setting('app.tenant_id'), $1, $2, $3)
+			ON CONFLICT (tenant_id, key) DO UPDATE
+			SET fingerprint = EXCLUDED.fingerprint, created_at = EXCLUDED.created_at
+			WHERE k.status_code IS NULL AND k.created_at < $4
+			RETURNING true
+		`
+		selectQ = `
+			SELECT fingerprint, status_code, content_type, body FROM idempotency_keys
+			WHERE tenant_id = current_setting('app.tenant_id') AND key = $1
+		`
+	)
+	var stored *idempotency.Response
+	err := s.storage.inTenantTx(ctx, "IdempotencyClaim", func(ctx context.Context, tx pgx.Tx) error {
+		var claimed bool
+		err := tx.QueryRow(ctx, claimQ, key, fingerprint, now, now.Add(-idempotency.ClaimTimeout)).Scan(&claimed)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		var (
+			storedFingerprint string
+			status            *int
+			resp              idempotency.Response
+		)
+		if err := tx.QueryRow(ctx, selectQ, key).Scan(&storedFingerprint, &status, &resp.ContentType, &resp.Body); err != nil {
+			return err
+		}
+		switch {
+		case storedFingerprint != fingerprint:
+			return en.ErrIdempotencyKeyReused
+		case status == nil:
+			return en.ErrIdempotencyKeyInUse
+		}
+		resp.StatusCode = *status
+		stored = &resp
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, en.ErrIdempotencyKeyReused) || errors.Is(err, en.ErrIdempotencyKeyInUse) {
+			return nil, errors.Wrap(err, "IdempotencyStore.Claim")
+		}
+		s.storage.logger.ErrorContext(ctx, "failed to claim idempotency key", "error", err)
+		return nil, errors.Wrap(err, "IdempotencyStore.Claim")
+	}
+	return stored, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, resp idempotency.Response) error {
+	const q = `
+		UPDATE idempotency_keys SET status_code = $2, content_type = $3, body = $4
+		WHERE tenant_id = current_setting('app.tenant_id') AND key = $1 AND status_code IS NULL
+	`
+	err := s.storage.inTenantTx(ctx, "IdempotencyComplete", func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, q, key, resp.StatusCode, resp.ContentType, resp.Body)
+		return err
+	})
+	if err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to store idempotent response", "error", err)
+		return errors.Wrap(err, "IdempotencyStore.Complete")
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	const q = `
+		DELETE FROM idempotency_keys
+		WHERE tenant_id = current_setting('app.tenant_id') AND key = $1 AND status_code IS NULL
+	`
+	err := s.storage.inTenantTx(ctx, "IdempotencyRelease", func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, q, key)
+		return err
+	})
+	if err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to release idempotency key", "error", err)
+		return errors.Wrap(err, "IdempotencyStore.Release")
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) Purge(ctx context.Context, createdBefore time.Time) (int64, error) {
+	const q = `DELETE FROM idempotency_keys WHERE created_at < $1`
+	var purged int64
+	err := s.storage.inSystemTx(ctx, "IdempotencyPurge", "app.idempotency_purge", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, createdBefore)
+		purged = tag.RowsAffected()
+		setAffectedRows(ctx, purged)
+		return err
+	})
+	if err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to purge idempotency keys", "error", err)
+		return 0, errors.Wrap(err, "IdempotencyStore.Purge")
+	}
+	return purged, nil
+}
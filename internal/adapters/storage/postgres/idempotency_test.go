@@ -0,0 +1,61 @@
+//go:build integration
+
+package postgres
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/idempotency"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	store := newTestStorage(t).IdempotencyStore()
+	acme, globex := newTenant("acme"), newTenant("globex")
+	now := time.Now()
+
+	if resp, err := store.Claim(acme, "key", "fp", now); err != nil || resp != nil {
+		t.Fatalf("first Claim = %v, %v; want the key", resp, err)
+	}
+	if _, err := store.Claim(acme, "key", "fp", now); !errors.Is(err, en.ErrIdempotencyKeyInUse) {
+		t.Fatalf("Claim while running = %v, want %v", err, en.ErrIdempotencyKeyInUse)
+	}
+	if resp, err := store.Claim(globex, "key", "other", now); err != nil || resp != nil {
+		t.Fatalf("Claim by another tenant = %v, %v; want the key", resp, err)
+	}
+
+	want := idempotency.Response{StatusCode: 201, ContentType: "application/json", Body: []byte(`{"id":1}`)}
+	if err := store.Complete(acme, "key", want); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	resp, err := store.Claim(acme, "key", "fp", now)
+	if err != nil || resp == nil {
+		t.Fatalf("Claim after Complete = %v, %v; want the stored response", resp, err)
+	}
+	if resp.StatusCode != want.StatusCode || resp.ContentType != want.ContentType || string(resp.Body) != string(want.Body) {
+		t.Errorf("stored response = %+v, want %+v", *resp, want)
+	}
+	if _, err := store.Claim(acme, "key", "other", now); !errors.Is(err, en.ErrIdempotencyKeyReused) {
+		t.Errorf("Claim with another fingerprint = %v, want %v", err, en.ErrIdempotencyKeyReused)
+	}
+
+	if err := store.Release(globex, "key"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if resp, err := store.Claim(globex, "key", "fp", now); err != nil || resp != nil {
+		t.Fatalf("Claim after Release = %v, %v; want the key", resp, err)
+	}
+	// A claim abandoned for longer than ClaimTimeout is taken over.
+	if resp, err := store.Claim(globex, "key", "fp", now.Add(2*idempotency.ClaimTimeout)); err != nil || resp != nil {
+		t.Fatalf("Claim of an abandoned key = %v, %v; want the key", resp, err)
+	}
+
+	if _, err := store.Purge(acme, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if resp, err := store.Claim(acme, "key", "other", now); err != nil || resp != nil {
+		t.Errorf("Claim after Purge = %v, %v; want the key", resp, err)
+	}
+}
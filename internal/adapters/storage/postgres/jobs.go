@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/jobs"
+)
+
+// unlockTimeout bounds releasing a job lock, which also happens on shutdown.
+const unlockTimeout = 5 * time.Second
+
+// JobStore keeps the job run history and locks jobs with session advisory
+// locks, so a job runs on one replica at a time.
+type JobStore struct {
+	storage *PgxStorage
+}
+
+func (p *PgxStorage) JobStore() *JobStore {
+	return &JobStore{storage: p}
+}
+
+// TryLock holds a pooled connection for as long as the lock is held.
+func (s *JobStore) TryLock(ctx context.Context, job string) (_ func(), _ bool, err error) {
+	ctx, span := startSpan(ctx, "JobTryLock")
+	defer func() { endSpan(span, err) }()
+
+	const (
+		lock   = `SELECT pg_try_advisory_lock(hashtext('jobs'), hashtext($1::text))`
+		unlock = `SELECT pg_advisory_unlock(hashtext('jobs'), hashtext($1::text))`
+	)
+	conn, err := s.storage.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "JobStore.TryLock: pool.Acquire")
+	}
+	var locked bool
+	if err := conn.QueryRow(ctx, lock, job).Scan(&locked); err != nil {
+		conn.Release()
+		s.storage.logger.ErrorContext(ctx, "failed to lock job", "job", job, "error", err)
+		return nil, false, errors.Wrap(err, "JobStore.TryLock")
+	}
+	if !locked {
+		conn.Release()
+		return nil, false, nil
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+		if _, err := conn.Exec(ctx, unlock, job); err != nil {
+			// A session lock lives as long as its connection: close it rather
+			// than return a connection still holding the lock to the pool.
+			s.storage.logger.ErrorContext(ctx, "failed to unlock job, closing its connection", "job", job, "error", err)
+			conn.Conn().Close(ctx)
+		}
+		conn.Release()
+	}, true, nil
+}
+
+func (s *JobStore) StartRun(ctx context.Context, run jobs.Run) (_ jobs.Run, _ bool, err error) {
+	ctx, span := startSpan(ctx, "JobStartRun")
+	defer func() { endSpan(span, err) }()
+
+	const (
+		abandon = `
+			UPDATE job_runs SET status = 'failed', error = 'abandoned: the instance running it stopped', finished_at = now()
+			WHERE job = $1 AND status = 'running'
+		`
+		start = `
+			INSERT INTO job_runs (job, trigger, scheduled_at, instance)
+			SELECT $1::text, $2::text, $3::timestamptz, $4::text
+			WHERE $2::text = 'manual' OR NOT EXISTS (
+			    SELECT 1 FROM job_runs
+			    WHERE job = $1::text AND trigger = 'schedule' AND scheduled_at >= $3::timestamptz
+			)
+			RETURNING id, started_at, status
+		`
+	)
+	started := false
+	err = s.storage.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, abandon, run.Job); err != nil {
+			return errors.Wrap(err, "abandon stale runs")
+		}
+		err := tx.QueryRow(ctx, start, run.Job, run.Trigger, run.ScheduledAt, run.Instance).Scan(&run.ID, &run.StartedAt, &run.Status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		started = err == nil
+		return err
+	})
+	if err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to start job run", "job", run.Job, "error", err)
+		return jobs.Run{}, false, errors.Wrap(err, "JobStore.StartRun")
+	}
+	return run, started, nil
+}
+
+func (s *JobStore) FinishRun(ctx context.Context, run jobs.Run) (err error) {
+	ctx, span := startSpan(ctx, "JobFinishRun")
+	defer func() { endSpan(span, err) }()
+
+	const q = `UPDATE job_runs SET status = $2, error = $3, finished_at = $4 WHERE id = $1`
+	if _, err = s.storage.pool.Exec(ctx, q, run.ID, run.Status, run.Error, run.FinishedAt); err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to finish job run", "job", run.Job, "run_id", run.ID, "error", err)
+		return errors.Wrap(err, "JobStore.FinishRun")
+	}
+	return nil
+}
+
+const jobRunColumns = `id, job, trigger, scheduled_at, started_at, finished_at, status, error, instance`
+
+func scanJobRun(row pgx.Row) (jobs.Run, error) {
+	var run jobs.Run
+	err := row.Scan(&run.ID, &run.Job, &run.Trigger, &run.ScheduledAt, &run.StartedAt, &run.FinishedAt, &run.Status, &run.Error, &run.Instance)
+	return run, err
+}
+
+func (s *JobStore) LastRuns(ctx context.Context) (_ map[string]jobs.Run, err error) {
+	ctx, span := startSpan(ctx, "JobLastRuns")
+	defer func() { endSpan(span, err) }()
+
+	const q = `SELECT DISTINCT ON (job) ` + jobRunColumns + ` FROM job_runs ORDER BY job, id DESC`
+	rows, err := s.storage.pool.Query(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "JobStore.LastRuns")
+	}
+	defer rows.Close()
+
+	last := make(map[string]jobs.Run)
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "JobStore.LastRuns")
+		}
+		last[run.Job] = run
+	}
+	if err := rows.Err(); err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to list last job runs", "error", err)
+		return nil, errors.Wrap(err, "JobStore.LastRuns")
+	}
+	return last, nil
+}
+
+func (s *JobStore) ListRuns(ctx context.Context, job string, limit int) (_ []jobs.Run, err error) {
+	ctx, span := startSpan(ctx, "JobListRuns")
+	defer func() { endSpan(span, err) }()
+
+	const q = `SELECT ` + jobRunColumns + ` FROM job_runs WHERE job = $1 ORDER BY id DESC LIMIT $2`
+	rows, err := s.storage.pool.Query(ctx, q, job, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "JobStore.ListRuns")
+	}
+	defer rows.Close()
+
+	runs := []jobs.Run{}
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "JobStore.ListRuns")
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to list job runs", "job", job, "error", err)
+		return nil, errors.Wrap(err, "JobStore.ListRuns")
+	}
+	return runs, nil
+}
+
+func (s *JobStore) PurgeRuns(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "JobPurgeRuns")
+	defer func() { endSpan(span, err) }()
+
+	const q = `DELETE FROM job_runs WHERE started_at < $1 AND status <> 'running'`
+	tag, err := s.storage.pool.Exec(ctx, q, before)
+	if err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to purge job runs", "error", err)
+		return 0, errors.Wrap(err, "JobStore.PurgeRuns")
+	}
+	return tag.RowsAffected(), nil
+}
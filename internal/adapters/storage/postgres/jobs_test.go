@@ -0,0 +1,123 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/100bench/subscription_aggregator/internal/jobs"
+)
+
+func TestJobLockIsHeldByOneReplica(t *testing.T) {
+	storage := newTestStorage(t)
+	replicaA, replicaB := storage.JobStore(), newTestStorage(t).JobStore()
+	ctx := context.Background()
+	job := "test-" + uuid.NewString()
+
+	unlock, ok, err := replicaA.TryLock(ctx, job)
+	if err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v; want the lock", ok, err)
+	}
+	if _, ok, err := replicaB.TryLock(ctx, job); err != nil || ok {
+		t.Errorf("TryLock on another replica while held = %v, %v; want false", ok, err)
+	}
+	if other, ok, err := replicaB.TryLock(ctx, job+"-other"); err != nil || !ok {
+		t.Errorf("TryLock of another job = %v, %v; want the lock", ok, err)
+	} else {
+		other()
+	}
+	unlock()
+	unlock, ok, err = replicaB.TryLock(ctx, job)
+	if err != nil || !ok {
+		t.Fatalf("TryLock after unlock = %v, %v; want the lock", ok, err)
+	}
+	unlock()
+}
+
+func TestJobRunsAreStartedOncePerScheduledTime(t *testing.T) {
+	store := newTestStorage(t).JobStore()
+	ctx := context.Background()
+	job := "test-" + uuid.NewString()
+	scheduledAt := time.Now().UTC().Truncate(time.Hour)
+
+	first, ok, err := store.StartRun(ctx, jobs.Run{Job: job, Trigger: jobs.TriggerSchedule, ScheduledAt: scheduledAt, Instance: "a"})
+	if err != nil || !ok || first.Status != jobs.StatusRunning {
+		t.Fatalf("StartRun = %+v, %v, %v; want a running run", first, ok, err)
+	}
+	finished := time.Now()
+	first.Status, first.FinishedAt = jobs.StatusSucceeded, &finished
+	if err := store.FinishRun(ctx, first); err != nil {
+		t.Fatalf("FinishRun: %v", err)
+	}
+	for _, at := range []time.Time{scheduledAt, scheduledAt.Add(-time.Hour)} {
+		if _, ok, err := store.StartRun(ctx, jobs.Run{Job: job, Trigger: jobs.TriggerSchedule, ScheduledAt: at, Instance: "b"}); err != nil || ok {
+			t.Errorf("StartRun for %s after the %s run = %v, %v; want not started", at, scheduledAt, ok, err)
+		}
+	}
+	if _, ok, err := store.StartRun(ctx, jobs.Run{Job: job, Trigger: jobs.TriggerManual, ScheduledAt: scheduledAt, Instance: "b"}); err != nil || !ok {
+		t.Errorf("manual StartRun = %v, %v; want started whatever ran before", ok, err)
+	}
+	// The manual run is left running, as by a replica that crashed.
+	next, ok, err := store.StartRun(ctx, jobs.Run{Job: job, Trigger: jobs.TriggerSchedule, ScheduledAt: scheduledAt.Add(time.Hour), Instance: "b"})
+	if err != nil || !ok {
+		t.Fatalf("StartRun for the next hour = %v, %v; want started", ok, err)
+	}
+
+	runs, err := store.ListRuns(ctx, job, 10)
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if len(runs) != 3 || runs[0].ID != next.ID || runs[2].ID != first.ID {
+		t.Fatalf("runs = %+v, want three, newest first", runs)
+	}
+	if abandoned := runs[1]; abandoned.Status != jobs.StatusFailed || abandoned.FinishedAt == nil || abandoned.Error == "" {
+		t.Errorf("run left running = %+v, want marked failed as abandoned", abandoned)
+	}
+	if last, err := store.LastRuns(ctx); err != nil || last[job].ID != next.ID {
+		t.Errorf("LastRuns[%s] = %+v, %v; want the run for the next hour", job, last[job], err)
+	}
+}
+
+func TestPurgeJobRunsKeepsRunningAndRecentRuns(t *testing.T) {
+	storage := newTestStorage(t)
+	store := storage.JobStore()
+	ctx := context.Background()
+	job := "test-" + uuid.NewString()
+
+	var ids []int64
+	for i, trigger := range []string{jobs.TriggerManual, jobs.TriggerManual} {
+		run, _, err := store.StartRun(ctx, jobs.Run{Job: job, Trigger: trigger, ScheduledAt: time.Now(), Instance: "a"})
+		if err != nil {
+			t.Fatalf("StartRun %d: %v", i, err)
+		}
+		ids = append(ids, run.ID)
+	}
+	// StartRun abandoned the first run; make it old. The second one stays
+	// running and old.
+	if _, err := storage.pool.Exec(ctx, `UPDATE job_runs SET started_at = now() - interval '40 days' WHERE job = $1`, job); err != nil {
+		t.Fatalf("age runs: %v", err)
+	}
+	recent, _, err := store.StartRun(ctx, jobs.Run{Job: job, Trigger: jobs.TriggerManual, ScheduledAt: time.Now(), Instance: "a"})
+	if err != nil {
+		t.Fatalf("StartRun: %v", err)
+	}
+	// That abandoned the second run, which is now failed and old.
+	if _, err := storage.pool.Exec(ctx, `UPDATE job_runs SET status = 'running', finished_at = NULL WHERE id = $1`, ids[1]); err != nil {
+		t.Fatalf("keep run running: %v", err)
+	}
+
+	if _, err := store.PurgeRuns(ctx, time.Now().AddDate(0, 0, -30)); err != nil {
+		t.Fatalf("PurgeRuns: %v", err)
+	}
+	runs, err := store.ListRuns(ctx, job, 10)
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != recent.ID || runs[1].ID != ids[1] {
+		t.Errorf("runs after the purge = %+v, want the recent one and the old running one", runs)
+	}
+}
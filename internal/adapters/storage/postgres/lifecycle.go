@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// lapsedCondition matches active subscriptions due for a lifecycle step in
+// the MM-YYYY month $month: past their end month, or open-ended and started
+// but not billed through it.
+const lapsedCondition = `
+	deleted_at IS NULL AND expired_at IS NULL AND (
+	    (COALESCE(end_date, '') <> '' AND to_date(end_date, 'MM-YYYY') < to_date($month, 'MM-YYYY'))
+	    OR (COALESCE(end_date, '') = '' AND to_date(start_date, 'MM-YYYY') <= to_date($month, 'MM-YYYY')
+	        AND (billed_through IS NULL OR billed_through < to_date($month, 'MM-YYYY')))
+	)
+`
+
+// ListLapsedSubs returns the subscriptions of every tenant due for expiry or
+// renewal in month.
+func (p *PgxStorage) ListLapsedSubs(ctx context.Context, month string) ([]en.Subscription, error) {
+	p.logger.DebugContext(ctx, "ListLapsedSubs", "month", month)
+	q := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE ` + bindMonth(lapsedCondition, "$1") + ` ORDER BY id`
+	var subscriptions []en.Subscription
+	err := p.inSystemTx(ctx, "ListLapsedSubs", "app.renewal_scan", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, month)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var sub en.Subscription
+			if err := scanSubscription(rows, &sub); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			subscriptions = append(subscriptions, sub)
+		}
+		setReturnedRows(ctx, len(subscriptions))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list lapsed subscriptions", "month", month, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListLapsedSubs")
+	}
+	return subscriptions, nil
+}
+
+// ExpireSub marks a lapsed subscription that does not renew as expired.
+func (p *PgxStorage) ExpireSub(ctx context.Context, id int64, month string, audit en.AuditEntry) (en.Subscription, error) {
+	p.logger.DebugContext(ctx, "ExpireSub", "subscription_id", id, "month", month)
+	q := `
+		UPDATE subscriptions s
+		SET expired_at = now(),
+		    updated_at = now()
+		FROM (
+		    SELECT id, to_jsonb(subscriptions) AS snapshot FROM subscriptions
+		    WHERE tenant_id = current_setting('app.tenant_id') AND id = $1
+		      AND NOT auto_renew AND COALESCE(end_date, '') <> '' AND ` + bindMonth(lapsedCondition, "$2") + `
+		    FOR UPDATE
+		) old
+		WHERE s.id = old.id
+		RETURNING old.snapshot, to_jsonb(s)
+	`
+	sub, err := p.advanceSub(ctx, "ExpireSub", q, id, month, audit)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.logger.DebugContext(ctx, "subscription is no longer due to expire", "subscription_id", id)
+			return en.Subscription{}, errors.Wrap(en.ErrSubscriptionNotFound, "PgxStorage.ExpireSub")
+		}
+		p.logger.ErrorContext(ctx, "failed to expire subscription", "subscription_id", id, "error", err)
+		return en.Subscription{}, errors.Wrap(err, "PgxStorage.ExpireSub")
+	}
+	p.logger.DebugContext(ctx, "subscription expired", "subscription_id", id, "user_id", sub.UserID)
+	return sub, nil
+}
+
+// RenewSub bills a lapsed subscription for the month after its end month, or
+// after the month it was billed through when it is open-ended. An open-ended
+// subscription billed for the first time is billed for month itself, so
+// enabling the job does not charge the months before it.
+func (p *PgxStorage) RenewSub(ctx context.Context, id int64, month string, audit en.AuditEntry) (en.Subscription, error) {
+	p.logger.DebugContext(ctx, "RenewSub", "subscription_id", id, "month", month)
+	q := `
+		UPDATE subscriptions s
+		SET end_date = CASE WHEN COALESCE(s.end_date, '') = '' THEN s.end_date
+		                    ELSE to_char(to_date(s.end_date, 'MM-YYYY') + interval '1 month', 'MM-YYYY') END,
+		    billed_through = CASE WHEN COALESCE(s.end_date, '') = ''
+		                          THEN COALESCE(s.billed_through + interval '1 month', to_date($2, 'MM-YYYY'))::date
+		                          ELSE (to_date(s.end_date, 'MM-YYYY') + interval '1 month')::date END,
+		    updated_at = now()
+		FROM (
+		    SELECT id, to_jsonb(subscriptions) AS snapshot FROM subscriptions
+		    WHERE tenant_id = current_setting('app.tenant_id') AND id = $1
+		      AND (auto_renew OR COALESCE(end_date, '') = '') AND ` + bindMonth(lapsedCondition, "$2") + `
+		    FOR UPDATE
+		) old
+		WHERE s.id = old.id
+		RETURNING old.snapshot, to_jsonb(s)
+	`
+	sub, err := p.advanceSub(ctx, "RenewSub", q, id, month, audit)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.logger.DebugContext(ctx, "subscription is no longer due to renew", "subscription_id", id)
+			return en.Subscription{}, errors.Wrap(en.ErrSubscriptionNotFound, "PgxStorage.RenewSub")
+		}
+		p.logger.ErrorContext(ctx, "failed to renew subscription", "subscription_id", id, "error", err)
+		return en.Subscription{}, errors.Wrap(err, "PgxStorage.RenewSub")
+	}
+	p.logger.DebugContext(ctx, "subscription renewed", "subscription_id", id, "user_id", sub.UserID, "billed_through", sub.BilledThrough)
+	return sub, nil
+}
+
+// advanceSub runs a lifecycle update returning the old and new snapshots and
+// records it in the audit log, the outbox and, named after the audit action's
+// event, the user's feed.
+func (p *PgxStorage) advanceSub(ctx context.Context, operation, q string, id int64, month string, audit en.AuditEntry) (en.Subscription, error) {
+	var sub en.Subscription
+	err := p.inTenantTx(ctx, operation, func(ctx context.Context, tx pgx.Tx) error {
+		eventType := audit.Action.EventType()
+		if _, err := tx.Exec(ctx, `SELECT set_config('app.subscription_event', $1, true)`, string(eventType)); err != nil {
+			return errors.Wrap(err, "set_config app.subscription_event")
+		}
+		var before, after []byte
+		if err := tx.QueryRow(ctx, q, id, month).Scan(&before, &after); err != nil {
+			return err
+		}
+		var err error
+		if sub, err = decodeSnapshot(after); err != nil {
+			return err
+		}
+		audit.SubscriptionID = sub.ID
+		audit.UserID = sub.UserID
+		audit.Before, audit.After = before, after
+		setAffectedRows(ctx, 1)
+		if err := insertAudit(ctx, tx, audit); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, outboxEntry{eventType, sub.ID, sub.UserID, after})
+	})
+	return sub, err
+}
+
+// bindMonth puts the placeholder of the month argument into a condition.
+func bindMonth(condition, placeholder string) string {
+	return strings.ReplaceAll(condition, "$month", placeholder)
+}
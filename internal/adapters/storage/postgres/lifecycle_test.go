@@ -0,0 +1,182 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// lifecycleStep runs ExpireSub or RenewSub for month and expects the result.
+type lifecycleStep struct {
+	renew      bool
+	month      string
+	notFound   bool
+	wantEnd    string
+	wantBilled string
+}
+
+func TestLifecycleMonthArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  en.Subscription
+		// billedThrough, a YYYY-MM-DD date or "-" for none, replaces what
+		// CreateSub set.
+		billedThrough string
+		deleted       bool
+		// lapsedIn are months ListLapsedSubs lists the subscription for,
+		// notLapsedIn ones it does not.
+		lapsedIn, notLapsedIn []string
+		steps                 []lifecycleStep
+	}{
+		{
+			name:        "ended without auto-renew expires",
+			sub:         en.Subscription{StartDate: "01-2025", EndDate: "11-2025"},
+			lapsedIn:    []string{"12-2025", "01-2026"},
+			notLapsedIn: []string{"11-2025", "10-2025"},
+			steps: []lifecycleStep{
+				{renew: true, month: "12-2025", notFound: true},
+				{month: "11-2025", notFound: true},
+				{month: "12-2025", wantEnd: "11-2025"},
+				{month: "01-2026", notFound: true},
+			},
+		},
+		{
+			name:        "auto-renew crosses the year one month at a time",
+			sub:         en.Subscription{StartDate: "01-2025", EndDate: "11-2025", AutoRenew: true},
+			lapsedIn:    []string{"12-2025", "02-2026"},
+			notLapsedIn: []string{"11-2025"},
+			steps: []lifecycleStep{
+				{month: "02-2026", notFound: true},
+				{renew: true, month: "02-2026", wantEnd: "12-2025", wantBilled: "12-2025"},
+				{renew: true, month: "02-2026", wantEnd: "01-2026", wantBilled: "01-2026"},
+				{renew: true, month: "02-2026", wantEnd: "02-2026", wantBilled: "02-2026"},
+				{renew: true, month: "02-2026", notFound: true},
+			},
+		},
+		{
+			name:        "auto-renew from December",
+			sub:         en.Subscription{StartDate: "12-2024", EndDate: "12-2024", AutoRenew: true},
+			lapsedIn:    []string{"01-2025"},
+			notLapsedIn: []string{"12-2024"},
+			steps: []lifecycleStep{
+				{renew: true, month: "01-2025", wantEnd: "01-2025", wantBilled: "01-2025"},
+				{renew: true, month: "01-2025", notFound: true},
+			},
+		},
+		{
+			name:          "open-ended billed across the year",
+			sub:           en.Subscription{StartDate: "03-2025"},
+			billedThrough: "2025-11-01",
+			lapsedIn:      []string{"12-2025", "01-2026"},
+			notLapsedIn:   []string{"11-2025"},
+			steps: []lifecycleStep{
+				{month: "01-2026", notFound: true},
+				{renew: true, month: "01-2026", wantBilled: "12-2025"},
+				{renew: true, month: "01-2026", wantBilled: "01-2026"},
+				{renew: true, month: "01-2026", notFound: true},
+			},
+		},
+		{
+			name:          "open-ended billed for the first time is billed for the month only",
+			sub:           en.Subscription{StartDate: "03-2025"},
+			billedThrough: "-",
+			lapsedIn:      []string{"03-2025", "12-2025"},
+			notLapsedIn:   []string{"02-2025"},
+			steps: []lifecycleStep{
+				{renew: true, month: "12-2025", wantBilled: "12-2025"},
+				{renew: true, month: "12-2025", notFound: true},
+			},
+		},
+		{
+			name:        "deleted is left alone",
+			sub:         en.Subscription{StartDate: "01-2025", EndDate: "11-2025", AutoRenew: true},
+			deleted:     true,
+			notLapsedIn: []string{"12-2025"},
+			steps:       []lifecycleStep{{renew: true, month: "12-2025", notFound: true}},
+		},
+	}
+
+	storage := newTestStorage(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTenant("acme")
+			tenantID, _ := en.TenantFromContext(ctx)
+			sub := tt.sub
+			sub.UserID, sub.ServiceName, sub.Price = uuid.NewString(), "Netflix", 400
+			created, err := storage.CreateSub(ctx, sub, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"})
+			if err != nil {
+				t.Fatalf("CreateSub: %v", err)
+			}
+			if tt.billedThrough != "" {
+				err := storage.inTenantTx(ctx, "set billed_through", func(ctx context.Context, tx pgx.Tx) error {
+					var billed interface{}
+					if tt.billedThrough != "-" {
+						billed = tt.billedThrough
+					}
+					_, err := tx.Exec(ctx, `UPDATE subscriptions SET billed_through = $2::date WHERE id = $1`, created.ID, billed)
+					return err
+				})
+				if err != nil {
+					t.Fatalf("set billed_through: %v", err)
+				}
+			}
+			if tt.deleted {
+				if err := storage.DeleteSub(ctx, sub.UserID, sub.ServiceName, en.AuditEntry{Action: en.AuditActionDelete, Actor: "test"}); err != nil {
+					t.Fatalf("DeleteSub: %v", err)
+				}
+			}
+
+			listed := func(month string) bool {
+				t.Helper()
+				subs, err := storage.ListLapsedSubs(context.Background(), month)
+				if err != nil {
+					t.Fatalf("ListLapsedSubs %s: %v", month, err)
+				}
+				for _, s := range subs {
+					if s.TenantID == tenantID && s.ID == created.ID {
+						return true
+					}
+				}
+				return false
+			}
+			for _, month := range tt.lapsedIn {
+				if !listed(month) {
+					t.Errorf("not listed as lapsed in %s", month)
+				}
+			}
+			for _, month := range tt.notLapsedIn {
+				if listed(month) {
+					t.Errorf("listed as lapsed in %s", month)
+				}
+			}
+
+			for i, step := range tt.steps {
+				op, action, call := "ExpireSub", en.AuditActionExpire, storage.ExpireSub
+				if step.renew {
+					op, action, call = "RenewSub", en.AuditActionRenew, storage.RenewSub
+				}
+				got, err := call(ctx, created.ID, step.month, en.AuditEntry{Action: action, Actor: "test"})
+				if step.notFound {
+					if !errors.Is(err, en.ErrSubscriptionNotFound) {
+						t.Fatalf("step %d: %s for %s = %+v, %v; want ErrSubscriptionNotFound", i, op, step.month, got, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("step %d: %s for %s: %v", i, op, step.month, err)
+				}
+				if got.EndDate != step.wantEnd || got.BilledThrough != step.wantBilled || (got.ExpiredAt != nil) != !step.renew {
+					t.Errorf("step %d: %s for %s left end %q, billed through %q, expired at %v; want %q, %q",
+						i, op, step.month, got.EndDate, got.BilledThrough, got.ExpiredAt, step.wantEnd, step.wantBilled)
+				}
+			}
+		})
+	}
+}
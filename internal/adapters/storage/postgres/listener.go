@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// userEventsChannel is the NOTIFY channel of the user_events trigger.
+const userEventsChannel = "user_events"
+
+const (
+	listenerMinBackoff = time.Second
+	listenerMaxBackoff = 30 * time.Second
+)
+
+type feedKey struct {
+	tenantID string
+	userID   string
+}
+
+// FeedListener holds a dedicated connection listening for user_events
+// notifications and wakes the readers of the affected feeds in this process.
+// Every replica runs its own, so a change committed through any of them
+// reaches all open streams.
+type FeedListener struct {
+	config *pgx.ConnConfig
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	readers map[feedKey]map[chan struct{}]struct{}
+}
+
+// FeedListener returns a listener connecting with the pool's settings; it
+// does not take a connection from the pool.
+func (p *PgxStorage) FeedListener() *FeedListener {
+	return &FeedListener{
+		config:  p.pool.Config().ConnConfig.Copy(),
+		logger:  p.logger,
+		readers: make(map[feedKey]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving a value whenever the user's feed may
+// have new events, and a function to stop receiving. Wake-ups are coalesced:
+// a reader that is busy gets one pending value, not one per event.
+func (l *FeedListener) Subscribe(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "FeedListener.Subscribe")
+	}
+	// The trigger reports user IDs in the canonical lower-case uuid form.
+	key := feedKey{tenantID: tenantID, userID: strings.ToLower(userID)}
+	ch := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	if l.readers[key] == nil {
+		l.readers[key] = make(map[chan struct{}]struct{})
+	}
+	l.readers[key][ch] = struct{}{}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			delete(l.readers[key], ch)
+			if len(l.readers[key]) == 0 {
+				delete(l.readers, key)
+			}
+		})
+	}, nil
+}
+
+// Run listens until ctx is cancelled, reconnecting with backoff when the
+// connection breaks. Notifications sent while disconnected are lost, so all
+// readers are woken after every (re)connect to catch up from the table.
+func (l *FeedListener) Run(ctx context.Context) {
+	backoff := listenerMinBackoff
+	for {
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		l.logger.WarnContext(ctx, "feed listener disconnected", "error", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > listenerMaxBackoff {
+			backoff = listenerMaxBackoff
+		}
+	}
+}
+
+func (l *FeedListener) listen(ctx context.Context) error {
+	conn, err := pgx.ConnectConfig(ctx, l.config)
+	if err != nil {
+		return errors.Wrap(err, "pgx.ConnectConfig")
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+userEventsChannel); err != nil {
+		return errors.Wrap(err, "LISTEN")
+	}
+	l.logger.InfoContext(ctx, "feed listener connected", "channel", userEventsChannel)
+	l.wakeAll()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return errors.Wrap(err, "WaitForNotification")
+		}
+		var payload struct {
+			ID       int64  `json:"id"`
+			TenantID string `json:"tenant_id"`
+			UserID   string `json:"user_id"`
+		}
+		if err := json.Unmarshal([]byte(n.Payload), &payload); err != nil {
+			l.logger.WarnContext(ctx, "malformed feed notification", "payload", n.Payload, "error", err)
+			continue
+		}
+		l.wake(feedKey{tenantID: payload.TenantID, userID: payload.UserID})
+	}
+}
+
+func (l *FeedListener) wake(key feedKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.readers[key] {
+		notify(ch)
+	}
+}
+
+func (l *FeedListener) wakeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, readers := range l.readers {
+		for ch := range readers {
+			notify(ch)
+		}
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,79 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func woken(ch <-chan struct{}, within time.Duration) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(within):
+		return false
+	}
+}
+
+func TestFeedListenerWakesOnlyTheNotifiedTenantsReaders(t *testing.T) {
+	storage := newTestStorage(t)
+	acme, globex := newTenant("acme"), newTenant("globex")
+	userID := uuid.NewString()
+
+	listener := storage.FeedListener()
+	// Readers may name the user in any case; notifications carry lower case.
+	acmeWake, stopAcme, err := listener.Subscribe(acme, strings.ToUpper(userID))
+	if err != nil {
+		t.Fatalf("Subscribe acme: %v", err)
+	}
+	defer stopAcme()
+	globexWake, stopGlobex, err := listener.Subscribe(globex, userID)
+	if err != nil {
+		t.Fatalf("Subscribe globex: %v", err)
+	}
+	defer stopGlobex()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go listener.Run(ctx)
+	// Every reader is woken once the listener is connected.
+	if !woken(acmeWake, 10*time.Second) || !woken(globexWake, time.Second) {
+		t.Fatal("readers were not woken on connect")
+	}
+
+	if _, err := storage.CreateSub(acme, en.Subscription{
+		UserID: userID, ServiceName: "Netflix", Price: 400, StartDate: "01-2025", EndDate: "12-2025",
+	}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"}); err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+	if !woken(acmeWake, 5*time.Second) {
+		t.Error("acme's reader was not woken by acme's change")
+	}
+	if woken(globexWake, 500*time.Millisecond) {
+		t.Error("globex's reader was woken by acme's change of the same user ID")
+	}
+
+	if events, err := storage.ListUserEvents(acme, userID, 0, 10); err != nil || len(events) != 1 || events[0].Type != en.EventSubscriptionCreated {
+		t.Errorf("acme's feed = %+v, %v; want the creation", events, err)
+	}
+	if events, err := storage.ListUserEvents(globex, userID, 0, 10); err != nil || len(events) != 0 {
+		t.Errorf("globex's feed = %+v, %v; want empty", events, err)
+	}
+
+	stopAcme()
+	if _, err := storage.CreateSub(acme, en.Subscription{
+		UserID: userID, ServiceName: "Spotify", Price: 300, StartDate: "01-2025", EndDate: "12-2025",
+	}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"}); err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+	if woken(acmeWake, 500*time.Millisecond) {
+		t.Error("a stopped reader was woken")
+	}
+}
@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/migrations"
+)
+
+// migrationLockKey is the pg_advisory_lock key held while migrations run, so
+// replicas starting together apply them one at a time.
+const migrationLockKey int64 = 0x73756261676772 // "subaggr"
+
+// Migrator applies the schema migrations embedded in the binary.
+type Migrator struct {
+	m         *migrate.Migrate
+	dsn       string
+	sourceURL string
+	logger    *slog.Logger
+}
+
+// NewMigrator opens a migrator for dsn. An empty sourceURL uses the embedded
+// migrations; otherwise any golang-migrate source URL such as file://dir works.
+func NewMigrator(dsn, sourceURL string, logger *slog.Logger) (*Migrator, error) {
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	src, err := openSource(sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.NewWithSourceInstance("migrations", src, dsn)
+	if err != nil {
+		src.Close()
+		return nil, errors.Wrap(err, "migrate.NewWithSourceInstance")
+	}
+	m.Log = migrateLogger{logger: logger}
+	return &Migrator{m: m, dsn: dsn, sourceURL: sourceURL, logger: logger}, nil
+}
+
+func openSource(sourceURL string) (source.Driver, error) {
+	if sourceURL == "" {
+		src, err := iofs.New(migrations.FS, ".")
+		return src, errors.Wrap(err, "iofs.New")
+	}
+	src, err := source.Open(sourceURL)
+	return src, errors.Wrap(err, "source.Open")
+}
+
+// Close releases the source and database handles.
+func (mg *Migrator) Close() {
+	if srcErr, dbErr := mg.m.Close(); srcErr != nil || dbErr != nil {
+		mg.logger.Warn("failed to close migrator", "source_error", srcErr, "database_error", dbErr)
+	}
+}
+
+// Up applies all pending migrations; an up-to-date schema is not an error.
+func (mg *Migrator) Up(ctx context.Context) error {
+	return mg.locked(ctx, "Up", func() error {
+		if err := mg.m.Up(); err != nil && err != migrate.ErrNoChange {
+			return err
+		}
+		return nil
+	})
+}
+
+// Down rolls back the last n applied migrations.
+func (mg *Migrator) Down(ctx context.Context, n int) error {
+	if n <= 0 {
+		return errors.New("Migrator.Down: step count must be positive")
+	}
+	return mg.locked(ctx, "Down", func() error {
+		return mg.m.Steps(-n)
+	})
+}
+
+// Goto migrates up or down to exactly version.
+func (mg *Migrator) Goto(ctx context.Context, version uint) error {
+	return mg.locked(ctx, "Goto", func() error {
+		if err := mg.m.Migrate(version); err != nil && err != migrate.ErrNoChange {
+			return err
+		}
+		return nil
+	})
+}
+
+// Force records version as applied and clears the dirty flag without running
+// any SQL; use it after repairing a failed migration by hand.
+func (mg *Migrator) Force(ctx context.Context, version int) error {
+	return mg.locked(ctx, "Force", func() error {
+		return mg.m.Force(version)
+	})
+}
+
+// Version reports the applied version; ok is false when nothing is applied yet.
+func (mg *Migrator) Version() (version uint, dirty, ok bool, err error) {
+	version, dirty, err = mg.m.Version()
+	if err == migrate.ErrNilVersion {
+		return 0, false, false, nil
+	}
+	if err != nil {
+		return 0, false, false, errors.Wrap(err, "Migrator.Version")
+	}
+	return version, dirty, true, nil
+}
+
+// Versions lists the versions available in the migration source in
+// ascending order.
+func (mg *Migrator) Versions() ([]uint, error) {
+	src, err := openSource(mg.sourceURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Migrator.Versions")
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return nil, errors.Wrap(err, "Migrator.Versions: source.First")
+	}
+	versions := []uint{version}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Migrator.Versions: source.Next")
+		}
+		versions = append(versions, next)
+		version = next
+	}
+}
+
+// LatestVersion returns the highest version available in the migration source,
+// i.e. the schema this binary expects.
+func (mg *Migrator) LatestVersion() (uint, error) {
+	versions, err := mg.Versions()
+	if err != nil {
+		return 0, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// locked runs fn while holding the migration advisory lock on a dedicated
+// connection. pg_advisory_lock blocks, so a second replica waits for the first
+// to finish and then finds nothing left to apply.
+func (mg *Migrator) locked(ctx context.Context, operation string, fn func() error) error {
+	conn, err := pgx.Connect(ctx, mg.dsn)
+	if err != nil {
+		return errors.Wrapf(err, "Migrator.%s: connect", operation)
+	}
+	defer conn.Close(context.Background())
+
+	mg.logger.DebugContext(ctx, "waiting for migration lock")
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return errors.Wrapf(err, "Migrator.%s: pg_advisory_lock", operation)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			mg.logger.Warn("failed to release migration lock", "error", err)
+		}
+	}()
+
+	if err := fn(); err != nil {
+		return errors.Wrapf(err, "Migrator.%s", operation)
+	}
+	return nil
+}
+
+// migrateLogger forwards golang-migrate progress messages to slog.
+type migrateLogger struct {
+	logger *slog.Logger
+}
+
+func (l migrateLogger) Printf(format string, v ...interface{}) {
+	l.logger.Info("migrate: " + strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
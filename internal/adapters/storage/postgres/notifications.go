@@ -0,0 +1,177 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func (p *PgxStorage) GetNotificationSettings(ctx context.Context, userID string) (en.NotificationSettings, error) {
+	p.logger.DebugContext(ctx, "GetNotificationSettings", "user_id", userID)
+	const q = `
+		SELECT tenant_id, user_id::text, email, locale, lead_days, enabled, updated_at FROM notification_settings
+		WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1
+	`
+	var settings en.NotificationSettings
+	err := p.inTenantTx(ctx, "GetNotificationSettings", func(ctx context.Context, tx pgx.Tx) error {
+		return tx.QueryRow(ctx, q, userID).Scan(
+			&settings.TenantID,
+			&settings.UserID,
+			&settings.Email,
+			&settings.Locale,
+			&settings.LeadDays,
+			&settings.Enabled,
+			&settings.UpdatedAt,
+		)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return en.NotificationSettings{}, errors.Wrap(en.ErrNotificationSettingsNotFound, "PgxStorage.GetNotificationSettings")
+		}
+		p.logger.ErrorContext(ctx, "failed to get notification settings", "user_id", userID, "error", err)
+		return en.NotificationSettings{}, errors.Wrap(err, "PgxStorage.GetNotificationSettings")
+	}
+	return settings, nil
+}
+
+// PutNotificationSettings creates or replaces the user's settings.
+func (p *PgxStorage) PutNotificationSettings(ctx context.Context, settings en.NotificationSettings) (en.NotificationSettings, error) {
+	p.logger.DebugContext(ctx, "PutNotificationSettings", "user_id", settings.UserID)
+	const q = `
+		INSERT INTO notification_settings (tenant_id, user_id, email, locale, lead_days, enabled)
+		VALUES (current_setting('app.tenant_id'), $1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id, user_id) DO UPDATE
+		SET email = EXCLUDED.email, locale = EXCLUDED.locale, lead_days = EXCLUDED.lead_days,
+		    enabled = EXCLUDED.enabled, updated_at = now()
+		RETURNING tenant_id, updated_at
+	`
+	err := p.inTenantTx(ctx, "PutNotificationSettings", func(ctx context.Context, tx pgx.Tx) error {
+		setAffectedRows(ctx, 1)
+		return tx.QueryRow(ctx, q, settings.UserID, settings.Email, settings.Locale, settings.LeadDays, settings.Enabled).Scan(
+			&settings.TenantID,
+			&settings.UpdatedAt,
+		)
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to put notification settings", "user_id", settings.UserID, "error", err)
+		return en.NotificationSettings{}, errors.Wrap(err, "PgxStorage.PutNotificationSettings")
+	}
+	return settings, nil
+}
+
+// reminderRecipients selects, per channel, the users reachable through it
+// with their settings; users without settings get the defaults.
+var reminderRecipients = map[en.NotificationChannel]string{
+	en.ChannelEmail: `
+		SELECT tenant_id, user_id, email, locale, lead_days, enabled, updated_at FROM notification_settings`,
+	en.ChannelTelegram: `
+		SELECT c.tenant_id, c.user_id, COALESCE(n.email, ''), COALESCE(n.locale, c.locale),
+		       COALESCE(n.lead_days, ` + strconv.Itoa(en.DefaultLeadDays) + `), COALESCE(n.enabled, true), COALESCE(n.updated_at, c.linked_at)
+		FROM telegram_chats c
+		LEFT JOIN notification_settings n ON n.tenant_id = c.tenant_id AND n.user_id = c.user_id`,
+}
+
+// ListDueRenewalReminders returns, for every tenant, the subscriptions
+// renewing after today and within their owner's lead time, unless a
+// reminder for that renewal already went out through channel.
+func (p *PgxStorage) ListDueRenewalReminders(ctx context.Context, now time.Time, channel en.NotificationChannel) ([]en.RenewalReminder, error) {
+	p.logger.DebugContext(ctx, "ListDueRenewalReminders", "channel", channel)
+	recipients, ok := reminderRecipients[channel]
+	if !ok {
+		return nil, errors.Errorf("PgxStorage.ListDueRenewalReminders: unknown channel %q", channel)
+	}
+	q := `
+		SELECT s.id, s.tenant_id, s.user_id, s.service_name, s.price, s.start_date, s.end_date, s.deleted_at,
+		       n.email, n.locale, n.lead_days, n.enabled, n.updated_at, d.renews_on
+		FROM subscriptions s
+		JOIN (` + recipients + `
+		) n (tenant_id, user_id, email, locale, lead_days, enabled, updated_at)
+		  ON n.tenant_id = s.tenant_id AND n.user_id = s.user_id
+		CROSS JOIN LATERAL (SELECT (to_date(s.end_date, 'MM-YYYY') + interval '1 month')::date AS renews_on) d
+		WHERE s.deleted_at IS NULL AND s.end_date <> '' AND n.enabled
+		  AND d.renews_on > $1::date AND d.renews_on <= $1::date + n.lead_days
+		  AND NOT EXISTS (
+		      SELECT 1 FROM renewal_notifications r
+		      WHERE r.tenant_id = s.tenant_id AND r.subscription_id = s.id
+		        AND r.channel = $2 AND r.renews_on = d.renews_on
+		  )
+		ORDER BY s.tenant_id, s.id
+	`
+	var reminders []en.RenewalReminder
+	err := p.inSystemTx(ctx, "ListDueRenewalReminders", "app.renewal_scan", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, now.Format("2006-01-02"), string(channel))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var r en.RenewalReminder
+			sub := &r.Subscription
+			if err := rows.Scan(
+				&sub.ID, &sub.TenantID, &sub.UserID, &sub.ServiceName, &sub.Price, &sub.StartDate, &sub.EndDate, &sub.DeletedAt,
+				&r.Settings.Email, &r.Settings.Locale, &r.Settings.LeadDays, &r.Settings.Enabled, &r.Settings.UpdatedAt, &r.RenewsOn,
+			); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			r.Settings.TenantID = sub.TenantID
+			r.Settings.UserID = sub.UserID
+			reminders = append(reminders, r)
+		}
+		setReturnedRows(ctx, len(reminders))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list due renewal reminders", "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListDueRenewalReminders")
+	}
+	return reminders, nil
+}
+
+// ClaimRenewalReminder records the reminder as sent through channel before it
+// is sent, and reports false if another run has claimed it already.
+func (p *PgxStorage) ClaimRenewalReminder(ctx context.Context, reminder en.RenewalReminder, channel en.NotificationChannel) (bool, error) {
+	p.logger.DebugContext(ctx, "ClaimRenewalReminder", "subscription_id", reminder.Subscription.ID, "channel", channel)
+	const q = `
+		INSERT INTO renewal_notifications (tenant_id, subscription_id, user_id, channel, renews_on)
+		VALUES (current_setting('app.tenant_id'), $1, $2, $3, $4)
+		ON CONFLICT (tenant_id, subscription_id, channel, renews_on) DO NOTHING
+	`
+	var claimed bool
+	err := p.inTenantTx(ctx, "ClaimRenewalReminder", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, reminder.Subscription.ID, reminder.Subscription.UserID, string(channel), reminder.RenewsOn)
+		claimed = tag.RowsAffected() == 1
+		setAffectedRows(ctx, tag.RowsAffected())
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to claim renewal reminder", "subscription_id", reminder.Subscription.ID, "error", err)
+		return false, errors.Wrap(err, "PgxStorage.ClaimRenewalReminder")
+	}
+	return claimed, nil
+}
+
+// ReleaseRenewalReminder undoes a claim whose reminder could not be sent, so
+// the next run retries it.
+func (p *PgxStorage) ReleaseRenewalReminder(ctx context.Context, reminder en.RenewalReminder, channel en.NotificationChannel) error {
+	p.logger.DebugContext(ctx, "ReleaseRenewalReminder", "subscription_id", reminder.Subscription.ID, "channel", channel)
+	const q = `
+		DELETE FROM renewal_notifications
+		WHERE tenant_id = current_setting('app.tenant_id') AND subscription_id = $1 AND channel = $2 AND renews_on = $3
+	`
+	err := p.inTenantTx(ctx, "ReleaseRenewalReminder", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, reminder.Subscription.ID, string(channel), reminder.RenewsOn)
+		setAffectedRows(ctx, tag.RowsAffected())
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to release renewal reminder", "subscription_id", reminder.Subscription.ID, "error", err)
+		return errors.Wrap(err, "PgxStorage.ReleaseRenewalReminder")
+	}
+	return nil
+}
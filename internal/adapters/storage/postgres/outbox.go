@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// outboxEntry is one event of a mutation; snapshot is to_jsonb of the
+// subscription row after the change.
+type outboxEntry struct {
+	eventType      en.EventType
+	subscriptionID int64
+	userID         string
+	snapshot       []byte
+}
+
+// insertOutbox records events inside the mutation's transaction, so they are
+// published if and only if the mutation commits.
+func insertOutbox(ctx context.Context, tx pgx.Tx, entries ...outboxEntry) error {
+	const q = `
+		INSERT INTO outbox (tenant_id, event_type, subscription_id, user_id, snapshot)
+		VALUES (current_setting('app.tenant_id'), $1, $2, $3, $4)
+	`
+	for _, e := range entries {
+		if _, err := tx.Exec(ctx, q, string(e.eventType), e.subscriptionID, e.userID, e.snapshot); err != nil {
+			return errors.Wrap(err, "insertOutbox")
+		}
+	}
+	return nil
+}
+
+// subscriptionSnapshot mirrors the JSON to_jsonb produces for a subscriptions row.
+type subscriptionSnapshot struct {
+	ID          int64      `json:"id"`
+	TenantID    string     `json:"tenant_id"`
+	UserID      string     `json:"user_id"`
+	ServiceName string     `json:"service_name"`
+	Price       int        `json:"price"`
+	StartDate   string     `json:"start_date"`
+	EndDate     *string    `json:"end_date"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+	AutoRenew   bool       `json:"auto_renew"`
+	ExpiredAt   *time.Time `json:"expired_at"`
+	// BilledThrough is a date column, YYYY-MM-DD in JSON.
+	BilledThrough *string `json:"billed_through"`
+}
+
+func (s subscriptionSnapshot) subscription() en.Subscription {
+	sub := en.Subscription{
+		ID:          s.ID,
+		TenantID:    s.TenantID,
+		UserID:      s.UserID,
+		ServiceName: s.ServiceName,
+		Price:       s.Price,
+		StartDate:   s.StartDate,
+		DeletedAt:   s.DeletedAt,
+		AutoRenew:   s.AutoRenew,
+		ExpiredAt:   s.ExpiredAt,
+	}
+	if s.EndDate != nil {
+		sub.EndDate = *s.EndDate
+	}
+	if s.BilledThrough != nil {
+		if d, err := time.Parse("2006-01-02", *s.BilledThrough); err == nil {
+			sub.BilledThrough = d.Format("01-2006")
+		}
+	}
+	return sub
+}
+
+// outboxColumns is the event part of an outbox row, in scan order; budget
+// is only set on budget alerts.
+const outboxColumns = `id, event_id::text, event_type, tenant_id, user_id::text, snapshot, budget, created_at`
+
+func decodeSnapshot(snapshot []byte) (en.Subscription, error) {
+	var s subscriptionSnapshot
+	if err := json.Unmarshal(snapshot, &s); err != nil {
+		return en.Subscription{}, errors.Wrap(err, "decode outbox snapshot")
+	}
+	return s.subscription(), nil
+}
+
+// ProcessOutbox locks up to limit due events of all tenants and hands them to
+// publish one by one while the locks are held; published events are marked,
+// failed ones are retried after backoff(failures). Only the oldest pending
+// event of each subscription is eligible, so a subscription's events are
+// published in order even with several relays, which skip each other's
+// locked rows. If the process dies before commit, the batch is published
+// again: delivery is at least once.
+func (p *PgxStorage) ProcessOutbox(ctx context.Context, limit int, backoff func(failures int) time.Duration, publish func(ctx context.Context, event en.Event) error) (int, error) {
+	const claim = `
+		SELECT ` + outboxColumns + `, attempts FROM outbox o
+		WHERE published_at IS NULL AND next_attempt_at <= now()
+		  AND NOT EXISTS (
+		      SELECT 1 FROM outbox e
+		      WHERE e.tenant_id = o.tenant_id AND e.subscription_id = o.subscription_id
+		        AND e.published_at IS NULL AND e.id < o.id
+		  )
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	const markPublished = `UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = '' WHERE id = $1`
+	const markFailed = `
+		UPDATE outbox
+		SET attempts = attempts + 1, next_attempt_at = now() + $2 * interval '1 millisecond', last_error = $3
+		WHERE id = $1
+	`
+	type claimed struct {
+		seq      int64
+		attempts int
+		event    en.Event
+	}
+	published := 0
+	err := p.inSystemTx(ctx, "ProcessOutbox", "app.outbox_relay", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, claim, limit)
+		if err != nil {
+			return err
+		}
+		var batch []claimed
+		for rows.Next() {
+			var c claimed
+			var (
+				eventType        string
+				snapshot, budget []byte
+			)
+			if err := rows.Scan(&c.seq, &c.event.ID, &eventType, &c.event.TenantID, &c.event.UserID, &snapshot, &budget, &c.event.OccurredAt, &c.attempts); err != nil {
+				rows.Close()
+				return errors.Wrap(err, "rows.Scan")
+			}
+			if c.event.Subscription, err = decodeSnapshot(snapshot); err != nil {
+				rows.Close()
+				return err
+			}
+			if budget != nil {
+				c.event.Budget = &en.BudgetStatus{}
+				if err := json.Unmarshal(budget, c.event.Budget); err != nil {
+					rows.Close()
+					return errors.Wrap(err, "decode outbox budget")
+				}
+			}
+			c.event.Type = en.EventType(eventType)
+			batch = append(batch, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, c := range batch {
+			if err := publish(ctx, c.event); err != nil {
+				p.logger.WarnContext(ctx, "outbox event publish failed", "event_id", c.event.ID, "event_type", c.event.Type, "attempt", c.attempts+1, "error", err)
+				if _, err := tx.Exec(ctx, markFailed, c.seq, backoff(c.attempts+1).Milliseconds(), err.Error()); err != nil {
+					return errors.Wrap(err, "mark outbox event failed")
+				}
+				continue
+			}
+			if _, err := tx.Exec(ctx, markPublished, c.seq); err != nil {
+				return errors.Wrap(err, "mark outbox event published")
+			}
+			published++
+		}
+		setAffectedRows(ctx, int64(len(batch)))
+		return nil
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to process outbox", "error", err)
+		return 0, errors.Wrap(err, "PgxStorage.ProcessOutbox")
+	}
+	return published, nil
+}
+
+// PurgeOutbox removes events published before the given moment.
+func (p *PgxStorage) PurgeOutbox(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	const q = `DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`
+	var purged int64
+	err := p.inSystemTx(ctx, "PurgeOutbox", "app.outbox_relay", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, publishedBefore)
+		purged = tag.RowsAffected()
+		setAffectedRows(ctx, purged)
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to purge outbox", "error", err)
+		return 0, errors.Wrap(err, "PgxStorage.PurgeOutbox")
+	}
+	if purged > 0 {
+		p.logger.InfoContext(ctx, "published outbox events purged", "count", purged)
+	}
+	return purged, nil
+}
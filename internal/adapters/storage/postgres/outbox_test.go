@@ -0,0 +1,318 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+var errPublish = errors.New("broker is down")
+
+// relayTenant runs ProcessOutbox until no event is left to offer and returns
+// the events of tenant it offered to publish, in order. Events of other
+// tenants, left behind by other tests, are acknowledged on the way.
+func relayTenant(t *testing.T, storage *PgxStorage, ctx context.Context, backoff func(int) time.Duration, publish func(en.Event) error) []en.Event {
+	t.Helper()
+	tenant, err := en.TenantFromContext(ctx)
+	if err != nil {
+		t.Fatalf("TenantFromContext: %v", err)
+	}
+	var offered []en.Event
+	for {
+		calls := 0
+		_, err := storage.ProcessOutbox(context.Background(), 100, backoff, func(ctx context.Context, event en.Event) error {
+			calls++
+			if event.TenantID != tenant {
+				return nil
+			}
+			offered = append(offered, event)
+			return publish(event)
+		})
+		if err != nil {
+			t.Fatalf("ProcessOutbox: %v", err)
+		}
+		if calls == 0 {
+			return offered
+		}
+	}
+}
+
+func noBackoff(int) time.Duration { return 0 }
+
+func published(en.Event) error { return nil }
+
+type outboxRow struct {
+	eventType   string
+	published   bool
+	attempts    int
+	lastError   string
+	nextAttempt time.Time
+}
+
+func outboxRows(t *testing.T, storage *PgxStorage, ctx context.Context, subID int64) []outboxRow {
+	t.Helper()
+	var out []outboxRow
+	err := storage.inTenantTx(ctx, "read outbox", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			SELECT event_type, published_at IS NOT NULL, attempts, last_error, next_attempt_at
+			FROM outbox WHERE subscription_id = $1 ORDER BY id`, subID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r outboxRow
+			if err := rows.Scan(&r.eventType, &r.published, &r.attempts, &r.lastError, &r.nextAttempt); err != nil {
+				return err
+			}
+			out = append(out, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		t.Fatalf("read outbox: %v", err)
+	}
+	return out
+}
+
+func createOutboxSub(t *testing.T, storage *PgxStorage, ctx context.Context, service string) en.Subscription {
+	t.Helper()
+	sub, err := storage.CreateSub(ctx, en.Subscription{
+		UserID: uuid.NewString(), ServiceName: service, Price: 400, StartDate: "01-2025", EndDate: "12-2025",
+	}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"})
+	if err != nil {
+		t.Fatalf("CreateSub %s: %v", service, err)
+	}
+	return sub
+}
+
+func updateOutboxSub(t *testing.T, storage *PgxStorage, ctx context.Context, sub en.Subscription, price int) {
+	t.Helper()
+	if err := storage.UpdateSub(ctx, sub.UserID, sub.ServiceName, &price, nil, nil, nil,
+		en.AuditEntry{Action: en.AuditActionUpdate, Actor: "test"}); err != nil {
+		t.Fatalf("UpdateSub %s: %v", sub.ServiceName, err)
+	}
+}
+
+func TestOutboxPublishesEventsOfASubscriptionInOrder(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	relayTenant(t, storage, ctx, noBackoff, published)
+
+	sub := createOutboxSub(t, storage, ctx, "Netflix")
+	updateOutboxSub(t, storage, ctx, sub, 500)
+	if err := storage.DeleteSub(ctx, sub.UserID, sub.ServiceName, en.AuditEntry{Action: en.AuditActionDelete, Actor: "test"}); err != nil {
+		t.Fatalf("DeleteSub: %v", err)
+	}
+
+	var perCall []int
+	tenant, _ := en.TenantFromContext(ctx)
+	for {
+		ours := 0
+		_, err := storage.ProcessOutbox(context.Background(), 100, noBackoff, func(ctx context.Context, event en.Event) error {
+			if event.TenantID == tenant {
+				ours++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ProcessOutbox: %v", err)
+		}
+		if ours == 0 {
+			break
+		}
+		perCall = append(perCall, ours)
+	}
+	if len(perCall) != 3 || perCall[0] != 1 || perCall[1] != 1 || perCall[2] != 1 {
+		t.Errorf("events of the subscription per batch = %v, want one in each of three batches", perCall)
+	}
+
+	rows := outboxRows(t, storage, ctx, sub.ID)
+	want := []en.EventType{en.EventSubscriptionCreated, en.EventSubscriptionUpdated, en.EventSubscriptionDeleted}
+	if len(rows) != len(want) {
+		t.Fatalf("outbox rows = %+v, want %v", rows, want)
+	}
+	for i, r := range rows {
+		if r.eventType != string(want[i]) || !r.published || r.attempts != 1 {
+			t.Errorf("row %d = %+v, want %s published on the first attempt", i, r, want[i])
+		}
+	}
+}
+
+func TestOutboxRelaysSkipEachOthersLockedEvents(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	relayTenant(t, storage, ctx, noBackoff, published)
+	tenant, _ := en.TenantFromContext(ctx)
+
+	netflix := createOutboxSub(t, storage, ctx, "Netflix")
+	updateOutboxSub(t, storage, ctx, netflix, 500)
+	spotify := createOutboxSub(t, storage, ctx, "Spotify")
+
+	// The first relay claims both creations and holds their locks while it
+	// publishes.
+	var (
+		firstSeen []en.Event
+		once      sync.Once
+		claimed   = make(chan struct{})
+		release   = make(chan struct{})
+		done      = make(chan error, 1)
+	)
+	go func() {
+		_, err := storage.ProcessOutbox(context.Background(), 1000, noBackoff, func(ctx context.Context, event en.Event) error {
+			if event.TenantID == tenant {
+				firstSeen = append(firstSeen, event)
+				once.Do(func() { close(claimed) })
+				<-release
+			}
+			return nil
+		})
+		done <- err
+	}()
+	select {
+	case <-claimed:
+	case err := <-done:
+		t.Fatalf("first relay returned before claiming the events: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("first relay did not claim the events")
+	}
+
+	// The second relay neither waits for those locks nor publishes the update,
+	// whose creation is still pending.
+	secondCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var secondSeen []en.Event
+	if _, err := storage.ProcessOutbox(secondCtx, 1000, noBackoff, func(ctx context.Context, event en.Event) error {
+		if event.TenantID == tenant {
+			secondSeen = append(secondSeen, event)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("second ProcessOutbox while the first holds its locks: %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("first ProcessOutbox: %v", err)
+	}
+	if len(secondSeen) != 0 {
+		t.Errorf("second relay published %+v, want none of the locked events or their successors", secondSeen)
+	}
+	if len(firstSeen) != 2 {
+		t.Fatalf("first relay published %+v, want both creations", firstSeen)
+	}
+	for _, e := range firstSeen {
+		if e.Type != en.EventSubscriptionCreated {
+			t.Errorf("first relay published %s of %s, want only creations", e.Type, e.Subscription.ServiceName)
+		}
+	}
+
+	rest := relayTenant(t, storage, ctx, noBackoff, published)
+	if len(rest) != 1 || rest[0].Type != en.EventSubscriptionUpdated || rest[0].Subscription.ID != netflix.ID || rest[0].Subscription.Price != 500 {
+		t.Errorf("after the first relay committed, published %+v, want the update of Netflix", rest)
+	}
+	for _, sub := range []en.Subscription{netflix, spotify} {
+		for i, r := range outboxRows(t, storage, ctx, sub.ID) {
+			if !r.published || r.attempts != 1 {
+				t.Errorf("%s row %d = %+v, want published exactly once", sub.ServiceName, i, r)
+			}
+		}
+	}
+}
+
+func TestOutboxBacksOffFailedEvents(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	relayTenant(t, storage, ctx, noBackoff, published)
+
+	sub := createOutboxSub(t, storage, ctx, "Netflix")
+	updateOutboxSub(t, storage, ctx, sub, 500)
+
+	var failures []int
+	backoff := func(n int) time.Duration {
+		failures = append(failures, n)
+		return time.Hour
+	}
+	start := time.Now()
+	offered := relayTenant(t, storage, ctx, backoff, func(en.Event) error { return errPublish })
+	if len(offered) != 1 || offered[0].Type != en.EventSubscriptionCreated {
+		t.Fatalf("offered %+v, want only the creation while it keeps failing", offered)
+	}
+	if len(failures) != 1 || failures[0] != 1 {
+		t.Errorf("backoff called with %v, want [1]", failures)
+	}
+	rows := outboxRows(t, storage, ctx, sub.ID)
+	if len(rows) != 2 {
+		t.Fatalf("outbox rows = %+v, want the creation and the update", rows)
+	}
+	if r := rows[0]; r.published || r.attempts != 1 || r.lastError != errPublish.Error() ||
+		r.nextAttempt.Before(start.Add(59*time.Minute)) || r.nextAttempt.After(time.Now().Add(61*time.Minute)) {
+		t.Errorf("failed creation = %+v, want pending for an hour with the error recorded", r)
+	}
+	if r := rows[1]; r.published || r.attempts != 0 {
+		t.Errorf("update behind the failed creation = %+v, want untouched", r)
+	}
+
+	if offered := relayTenant(t, storage, ctx, backoff, published); len(offered) != 0 {
+		t.Errorf("offered %+v during the backoff, want nothing", offered)
+	}
+
+	err := storage.inTenantTx(ctx, "make the creation due", func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE outbox SET next_attempt_at = now() WHERE subscription_id = $1`, sub.ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("make the creation due: %v", err)
+	}
+	offered = relayTenant(t, storage, ctx, backoff, published)
+	if len(offered) != 2 || offered[0].Type != en.EventSubscriptionCreated || offered[1].Type != en.EventSubscriptionUpdated {
+		t.Fatalf("offered %+v once due, want the creation then the update", offered)
+	}
+	if offered[0].ID == "" || offered[0].ID == offered[1].ID {
+		t.Errorf("event IDs %q and %q, want distinct and stable across retries", offered[0].ID, offered[1].ID)
+	}
+	rows = outboxRows(t, storage, ctx, sub.ID)
+	if r := rows[0]; !r.published || r.attempts != 2 || r.lastError != "" {
+		t.Errorf("retried creation = %+v, want published on the second attempt with the error cleared", r)
+	}
+}
+
+func TestPurgeOutboxKeepsPendingAndRecentEvents(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	relayTenant(t, storage, ctx, noBackoff, published)
+
+	netflix := createOutboxSub(t, storage, ctx, "Netflix")
+	spotify := createOutboxSub(t, storage, ctx, "Spotify")
+	relayTenant(t, storage, ctx, func(int) time.Duration { return time.Hour }, func(e en.Event) error {
+		if e.Subscription.ID == spotify.ID {
+			return errPublish
+		}
+		return nil
+	})
+
+	if _, err := storage.PurgeOutbox(context.Background(), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("PurgeOutbox before the publish: %v", err)
+	}
+	if rows := outboxRows(t, storage, ctx, netflix.ID); len(rows) != 1 {
+		t.Errorf("event published within the retention = %+v, want kept", rows)
+	}
+
+	if _, err := storage.PurgeOutbox(context.Background(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("PurgeOutbox: %v", err)
+	}
+	if rows := outboxRows(t, storage, ctx, netflix.ID); len(rows) != 0 {
+		t.Errorf("event published before the cut-off = %+v, want purged", rows)
+	}
+	if rows := outboxRows(t, storage, ctx, spotify.ID); len(rows) != 1 || rows[0].published {
+		t.Errorf("pending event = %+v, want kept however old", rows)
+	}
+}
@@ -2,10 +2,10 @@ package postgres
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/pkg/errors"
@@ -13,201 +13,646 @@ import (
 	en "github.com/100bench/subscription_aggregator/internal/entities"
 )
 
+// subscriptionColumns is the column list scanSubscription expects.
+const subscriptionColumns = `id, tenant_id, user_id, service_name, price, start_date, end_date, deleted_at,
+	auto_renew, expired_at, COALESCE(to_char(billed_through, 'MM-YYYY'), '')`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row rowScanner, sub *en.Subscription) error {
+	return row.Scan(
+		&sub.ID,
+		&sub.TenantID,
+		&sub.UserID,
+		&sub.ServiceName,
+		&sub.Price,
+		&sub.StartDate,
+		&sub.EndDate,
+		&sub.DeletedAt,
+		&sub.AutoRenew,
+		&sub.ExpiredAt,
+		&sub.BilledThrough,
+	)
+}
+
 type PgxStorage struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+}
+
+// PoolConfig sizes the connection pool; zero fields keep the pgxpool defaults.
+type PoolConfig struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
 }
 
-func NewPgxClient(ctx context.Context, dsn string) (*PgxStorage, error) {
+func NewPgxClient(ctx context.Context, dsn string, poolCfg PoolConfig, logger *slog.Logger) (*PgxStorage, error) {
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
 	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, errors.Wrap(err, "pgxpool.ParseConfig")
 	}
-	cfg.MaxConns = 10
-	cfg.MinConns = 2
-	cfg.MaxConnLifetime = time.Hour
-	cfg.MaxConnIdleTime = time.Minute
+	if poolCfg.MaxConns > 0 {
+		cfg.MaxConns = poolCfg.MaxConns
+	}
+	if poolCfg.MinConns > 0 {
+		cfg.MinConns = poolCfg.MinConns
+	}
+	if poolCfg.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = poolCfg.MaxConnLifetime
+	}
+	if poolCfg.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = poolCfg.MaxConnIdleTime
+	}
 
 	pool, err := pgxpool.ConnectConfig(ctx, cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "pgxpool.ConnectConfig")
 	}
-	return &PgxStorage{pool: pool}, nil
+	return &PgxStorage{pool: pool, logger: logger}, nil
 }
 
 func (p *PgxStorage) Close() {
 	p.pool.Close()
 }
 
-func (p *PgxStorage) CreateSub(ctx context.Context, sub en.Subscription) error {
-	log.Printf("INFO: CreateSub for user %s, service %s", sub.UserID, sub.ServiceName)
+// Ping checks that a connection can be acquired and the server responds.
+func (p *PgxStorage) Ping(ctx context.Context) error {
+	if err := p.pool.Ping(ctx); err != nil {
+		return errors.Wrap(err, "PgxStorage.Ping")
+	}
+	return nil
+}
+
+// SchemaVersion returns the migration version recorded by golang-migrate and
+// whether the last migration left the schema dirty.
+func (p *PgxStorage) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	const q = `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	var (
+		version int64
+		dirty   bool
+	)
+	if err := p.pool.QueryRow(ctx, q).Scan(&version, &dirty); err != nil {
+		return 0, false, errors.Wrap(err, "PgxStorage.SchemaVersion")
+	}
+	return uint(version), dirty, nil
+}
+
+// inTenantTx runs fn in a transaction scoped to the tenant from ctx, so the
+// row-level security policies on subscriptions only expose that tenant's rows.
+// The transaction is traced as a single DB span named after operation.
+func (p *PgxStorage) inTenantTx(ctx context.Context, operation string, fn func(ctx context.Context, tx pgx.Tx) error) (err error) {
+	ctx, span := startSpan(ctx, operation)
+	defer func() { endSpan(span, err) }()
+
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "PgxStorage.inTenantTx")
+	}
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "pool.Begin")
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT set_config('app.tenant_id', $1, true)`, tenantID); err != nil {
+		return errors.Wrap(err, "set_config app.tenant_id")
+	}
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errors.Wrap(err, "tx.Commit")
+	}
+	return nil
+}
+
+// inSystemTx runs fn in a transaction with the given setting switched on. Such
+// settings enable narrow cross-tenant row-level security policies, e.g. for the
+// retention purge, and must never wrap tenant-facing queries.
+func (p *PgxStorage) inSystemTx(ctx context.Context, operation, setting string, fn func(ctx context.Context, tx pgx.Tx) error) (err error) {
+	ctx, span := startSpan(ctx, operation)
+	defer func() { endSpan(span, err) }()
+
+	return p.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT set_config($1, 'on', true)`, setting); err != nil {
+			return errors.Wrapf(err, "set_config %s", setting)
+		}
+		return fn(ctx, tx)
+	})
+}
+
+// PoolStat returns a snapshot of the connection pool statistics.
+func (p *PgxStorage) PoolStat() *pgxpool.Stat {
+	return p.pool.Stat()
+}
+
+// CountActiveSubs counts subscriptions of every tenant that are not deleted
+// and whose end month, if any, has not passed yet.
+func (p *PgxStorage) CountActiveSubs(ctx context.Context) (int64, error) {
 	const q = `
-		INSERT INTO subscriptions (user_id, service_name, price, start_date, end_date)
-		VALUES ($1, $2, $3, $4, $5)
+		SELECT count(*) FROM subscriptions
+		WHERE deleted_at IS NULL
+		  AND (COALESCE(end_date, '') = '' OR to_date(end_date, 'MM-YYYY') >= date_trunc('month', now()))
 	`
-	_, err := p.pool.Exec(ctx, q, sub.UserID, sub.ServiceName, sub.Price, sub.StartDate, sub.EndDate)
+	var n int64
+	err := p.inSystemTx(ctx, "CountActiveSubs", "app.stats_scope", func(ctx context.Context, tx pgx.Tx) error {
+		return tx.QueryRow(ctx, q).Scan(&n)
+	})
 	if err != nil {
-		log.Printf("ERROR: failed to create subscription for user %s, service %s: %v", sub.UserID, sub.ServiceName, err)
-		return errors.Wrap(err, "PgxStorage.CreateSub")
+		p.logger.ErrorContext(ctx, "failed to count active subscriptions", "error", err)
+		return 0, errors.Wrap(err, "PgxStorage.CountActiveSubs")
 	}
-	log.Printf("INFO: Subscription created for user %s, service %s", sub.UserID, sub.ServiceName)
-	return nil
+	return n, nil
+}
+
+// CreateSub inserts the subscription; the charges of its months are added to
+// the ledger by a trigger. An open-ended subscription that already started is
+// billed through the current month, later months are billed by RenewSub.
+func (p *PgxStorage) CreateSub(ctx context.Context, sub en.Subscription, audit en.AuditEntry) (en.Subscription, error) {
+	p.logger.DebugContext(ctx, "CreateSub", "user_id", sub.UserID, "service", sub.ServiceName)
+	const q = `
+		INSERT INTO subscriptions (tenant_id, user_id, service_name, price, start_date, end_date, auto_renew, billed_through)
+		VALUES (current_setting('app.tenant_id'), $1, $2, $3, $4, $5, $6,
+		        CASE WHEN COALESCE($5, '') = '' AND to_date($4, 'MM-YYYY') <= date_trunc('month', now())
+		             THEN date_trunc('month', now())::date END)
+		RETURNING id, tenant_id, COALESCE(to_char(billed_through, 'MM-YYYY'), ''), to_jsonb(subscriptions)
+	`
+	err := p.inTenantTx(ctx, "CreateSub", func(ctx context.Context, tx pgx.Tx) error {
+		var after []byte
+		if err := tx.QueryRow(ctx, q, sub.UserID, sub.ServiceName, sub.Price, sub.StartDate, sub.EndDate, sub.AutoRenew).Scan(
+			&sub.ID,
+			&sub.TenantID,
+			&sub.BilledThrough,
+			&after,
+		); err != nil {
+			return err
+		}
+		audit.SubscriptionID = sub.ID
+		audit.UserID = sub.UserID
+		audit.After = after
+		setAffectedRows(ctx, 1)
+		if err := insertAudit(ctx, tx, audit); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, outboxEntry{audit.Action.EventType(), sub.ID, sub.UserID, after})
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			p.logger.WarnContext(ctx, "subscription already exists", "user_id", sub.UserID, "service", sub.ServiceName)
+			return en.Subscription{}, errors.Wrap(en.ErrSubscriptionExists, "PgxStorage.CreateSub")
+		}
+		p.logger.ErrorContext(ctx, "failed to create subscription", "user_id", sub.UserID, "service", sub.ServiceName, "error", err)
+		return en.Subscription{}, errors.Wrap(err, "PgxStorage.CreateSub")
+	}
+	p.logger.DebugContext(ctx, "subscription created", "subscription_id", sub.ID, "user_id", sub.UserID, "service", sub.ServiceName)
+	return sub, nil
 }
 
 func (p *PgxStorage) GetSub(ctx context.Context, userID, serviceName string) (en.Subscription, error) {
-	log.Printf("INFO: GetSub for user %s, service %s", userID, serviceName)
+	p.logger.DebugContext(ctx, "GetSub", "user_id", userID, "service", serviceName)
 	const q = `
-		SELECT user_id, service_name, price, start_date, end_date FROM subscriptions
-		WHERE user_id = $1 AND service_name = $2
+		SELECT ` + subscriptionColumns + ` FROM subscriptions
+		WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1 AND service_name = $2
+		  AND deleted_at IS NULL
 	`
 	var sub en.Subscription
-	err := p.pool.QueryRow(ctx, q, userID, serviceName).Scan(
-		&sub.UserID,
-		&sub.ServiceName,
-		&sub.Price,
-		&sub.StartDate,
-		&sub.EndDate,
-	)
+	err := p.inTenantTx(ctx, "GetSub", func(ctx context.Context, tx pgx.Tx) error {
+		return scanSubscription(tx.QueryRow(ctx, q, userID, serviceName), &sub)
+	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			log.Printf("WARN: Subscription not found for user %s, service %s: %v", userID, serviceName, err)
+			p.logger.WarnContext(ctx, "subscription not found", "user_id", userID, "service", serviceName)
 			return en.Subscription{}, errors.Wrap(en.ErrSubscriptionNotFound, "PgxStorage.GetSub")
 		}
-		log.Printf("ERROR: failed to get subscription for user %s, service %s: %v", userID, serviceName, err)
+		p.logger.ErrorContext(ctx, "failed to get subscription", "user_id", userID, "service", serviceName, "error", err)
 		return en.Subscription{}, errors.Wrap(err, "PgxStorage.GetSub")
 	}
-	log.Printf("INFO: Subscription retrieved for user %s, service %s", userID, serviceName)
+	p.logger.DebugContext(ctx, "subscription retrieved", "subscription_id", sub.ID, "user_id", userID, "service", serviceName)
 	return sub, nil
 }
 
-func (p *PgxStorage) GetListSubs(ctx context.Context, userId string) ([]en.Subscription, error) {
-	log.Printf("INFO: GetListSubs for user %s", userId)
+func (p *PgxStorage) GetListSubs(ctx context.Context, userId string, includeDeleted bool) ([]en.Subscription, error) {
+	p.logger.DebugContext(ctx, "GetListSubs", "user_id", userId, "include_deleted", includeDeleted)
 	const q = `
-		SELECT user_id, service_name, price, start_date, end_date FROM subscriptions
-		WHERE user_id = $1
+		SELECT ` + subscriptionColumns + ` FROM subscriptions
+		WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1
+		  AND ($2 OR deleted_at IS NULL)
+		ORDER BY id
 	`
-	rows, err := p.pool.Query(ctx, q, userId)
+	var subscriptions []en.Subscription
+	err := p.inTenantTx(ctx, "GetListSubs", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userId, includeDeleted)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var sub en.Subscription
+			if err := scanSubscription(rows, &sub); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			subscriptions = append(subscriptions, sub)
+		}
+		setReturnedRows(ctx, len(subscriptions))
+		return rows.Err()
+	})
 	if err != nil {
-		log.Printf("ERROR: failed to get list of subscriptions for user %s: %v", userId, err)
+		p.logger.ErrorContext(ctx, "failed to list subscriptions", "user_id", userId, "error", err)
 		return nil, errors.Wrap(err, "PgxStorage.GetListSubs")
 	}
-	defer rows.Close()
+	p.logger.DebugContext(ctx, "subscriptions listed", "user_id", userId, "count", len(subscriptions))
+	return subscriptions, nil
+}
 
-	var subscriptions []en.Subscription
-	for rows.Next() {
-		var sub en.Subscription
-		if err := rows.Scan(
-			&sub.UserID,
-			&sub.ServiceName,
-			&sub.Price,
-			&sub.StartDate,
-			&sub.EndDate,
-		); err != nil {
-			log.Printf("ERROR: failed to scan subscription row for user %s: %v", userId, err)
-			return nil, errors.Wrap(err, "PgxStorage.GetListSubs.Scan")
+// GetListSubsForUsers lists the subscriptions of several users in one query,
+// keyed by user ID; users without subscriptions are absent from the map.
+func (p *PgxStorage) GetListSubsForUsers(ctx context.Context, userIDs []string, includeDeleted bool) (map[string][]en.Subscription, error) {
+	p.logger.DebugContext(ctx, "GetListSubsForUsers", "users", len(userIDs), "include_deleted", includeDeleted)
+	const q = `
+		SELECT ` + subscriptionColumns + ` FROM subscriptions
+		WHERE tenant_id = current_setting('app.tenant_id') AND user_id = ANY($1)
+		  AND ($2 OR deleted_at IS NULL)
+		ORDER BY id
+	`
+	subscriptions := make(map[string][]en.Subscription, len(userIDs))
+	err := p.inTenantTx(ctx, "GetListSubsForUsers", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userIDs, includeDeleted)
+		if err != nil {
+			return err
 		}
-		subscriptions = append(subscriptions, sub)
-	}
-	if rows.Err() != nil {
-		log.Printf("ERROR: rows iteration error for user %s: %v", userId, rows.Err())
-		return nil, errors.Wrap(rows.Err(), "PgxStorage.GetListSubs.RowsError")
+		defer rows.Close()
+
+		count := 0
+		for rows.Next() {
+			var sub en.Subscription
+			if err := scanSubscription(rows, &sub); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			subscriptions[sub.UserID] = append(subscriptions[sub.UserID], sub)
+			count++
+		}
+		setReturnedRows(ctx, count)
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list subscriptions", "users", len(userIDs), "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.GetListSubsForUsers")
 	}
-	log.Printf("INFO: Retrieved %d subscriptions for user %s", len(subscriptions), userId)
 	return subscriptions, nil
 }
 
-func (p *PgxStorage) UpdateSub(ctx context.Context, userID, serviceName string, price *int, startDate *string, endDate *string) error {
-	log.Printf("INFO: UpdateSub for user %s, service %s", userID, serviceName)
+// UpdateSub changes the non-nil fields. A new end date or switching
+// auto-renewal on clears the expiry, so the lifecycle job reconsiders it.
+func (p *PgxStorage) UpdateSub(ctx context.Context, userID, serviceName string, price *int, startDate *string, endDate *string, autoRenew *bool, audit en.AuditEntry) error {
+	p.logger.DebugContext(ctx, "UpdateSub", "user_id", userID, "service", serviceName)
 	const q = `
-        UPDATE subscriptions
-        SET price = COALESCE($3, price),
-            start_date = COALESCE($4, start_date),
-            end_date = COALESCE($5, end_date)
-        WHERE user_id = $1 AND service_name = $2
+        UPDATE subscriptions s
+        SET price = COALESCE($3, s.price),
+            start_date = COALESCE($4, s.start_date),
+            end_date = COALESCE($5, s.end_date),
+            auto_renew = COALESCE($6, s.auto_renew),
+            expired_at = CASE WHEN $5::text IS NOT NULL OR $6::boolean THEN NULL ELSE s.expired_at END,
+            updated_at = now()
+        FROM (
+            SELECT id, to_jsonb(subscriptions) AS snapshot FROM subscriptions
+            WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1 AND service_name = $2
+              AND deleted_at IS NULL
+            FOR UPDATE
+        ) old
+        WHERE s.id = old.id
+        RETURNING s.id, old.snapshot, to_jsonb(s)
     `
-	commandTag, err := p.pool.Exec(ctx, q, userID, serviceName, price, startDate, endDate)
+	var entries []en.AuditEntry
+	err := p.inTenantTx(ctx, "UpdateSub", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userID, serviceName, price, startDate, endDate, autoRenew)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var events []outboxEntry
+		for rows.Next() {
+			entry := audit
+			entry.UserID = userID
+			var before, after []byte
+			if err := rows.Scan(&entry.SubscriptionID, &before, &after); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			entry.Before, entry.After = before, after
+			entries = append(entries, entry)
+			events = append(events, outboxEntry{audit.Action.EventType(), entry.SubscriptionID, userID, after})
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		setAffectedRows(ctx, int64(len(entries)))
+		if err := insertAudit(ctx, tx, entries...); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, events...)
+	})
 	if err != nil {
-		log.Printf("ERROR: failed to update subscription for user %s, service %s: %v", userID, serviceName, err)
+		p.logger.ErrorContext(ctx, "failed to update subscription", "user_id", userID, "service", serviceName, "error", err)
 		return errors.Wrap(err, "PgxStorage.UpdateSub")
 	}
-	if commandTag.RowsAffected() == 0 {
-		log.Printf("WARN: Subscription not found for update for user %s, service %s", userID, serviceName)
+	if len(entries) == 0 {
+		p.logger.WarnContext(ctx, "subscription not found for update", "user_id", userID, "service", serviceName)
 		return errors.Wrap(en.ErrSubscriptionNotFound, "PgxStorage.UpdateSub")
 	}
-	log.Printf("INFO: Subscription updated for user %s, service %s", userID, serviceName)
+	p.logger.DebugContext(ctx, "subscription updated", "user_id", userID, "service", serviceName, "rows", len(entries))
 	return nil
 }
 
-func (p *PgxStorage) DeleteSub(ctx context.Context, userID, serviceName string) error {
-	log.Printf("INFO: DeleteSub for user %s, service %s", userID, serviceName)
+func (p *PgxStorage) DeleteSub(ctx context.Context, userID, serviceName string, audit en.AuditEntry) error {
+	p.logger.DebugContext(ctx, "DeleteSub", "user_id", userID, "service", serviceName)
 	const q = `
-		DELETE FROM subscriptions
-		WHERE user_id = $1 AND service_name = $2
+		UPDATE subscriptions s
+		SET deleted_at = now(),
+		    updated_at = now()
+		FROM (
+		    SELECT id, to_jsonb(subscriptions) AS snapshot FROM subscriptions
+		    WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1 AND service_name = $2
+		      AND deleted_at IS NULL
+		    FOR UPDATE
+		) old
+		WHERE s.id = old.id
+		RETURNING s.id, old.snapshot, to_jsonb(s)
 	`
-	commandTag, err := p.pool.Exec(ctx, q, userID, serviceName)
+	var entries []en.AuditEntry
+	err := p.inTenantTx(ctx, "DeleteSub", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userID, serviceName)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var events []outboxEntry
+		for rows.Next() {
+			entry := audit
+			entry.UserID = userID
+			var before, after []byte
+			if err := rows.Scan(&entry.SubscriptionID, &before, &after); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			entry.Before = before
+			entries = append(entries, entry)
+			events = append(events, outboxEntry{audit.Action.EventType(), entry.SubscriptionID, userID, after})
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		setAffectedRows(ctx, int64(len(entries)))
+		if err := insertAudit(ctx, tx, entries...); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, events...)
+	})
 	if err != nil {
-		log.Printf("ERROR: failed to delete subscription for user %s, service %s: %v", userID, serviceName, err)
+		p.logger.ErrorContext(ctx, "failed to delete subscription", "user_id", userID, "service", serviceName, "error", err)
 		return errors.Wrap(err, "PgxStorage.DeleteSub")
 	}
-	if commandTag.RowsAffected() == 0 {
-		log.Printf("WARN: Subscription not found for delete for user %s, service %s", userID, serviceName)
+	if len(entries) == 0 {
+		p.logger.WarnContext(ctx, "subscription not found for delete", "user_id", userID, "service", serviceName)
 		return errors.Wrap(en.ErrSubscriptionNotFound, "PgxStorage.DeleteSub")
 	}
-	log.Printf("INFO: Subscription deleted for user %s, service %s", userID, serviceName)
+	p.logger.DebugContext(ctx, "subscription deleted", "user_id", userID, "service", serviceName, "rows", len(entries))
 	return nil
 }
 
-func (p *PgxStorage) GetTotalByPeriod(ctx context.Context, userID string, serviceName string, startDateStr, endDateStr string) (int, error) {
-	log.Printf("INFO: GetTotalByPeriod input userID=%s service=%q start=%s end=%s", userID, serviceName, startDateStr, endDateStr)
+func (p *PgxStorage) RestoreSub(ctx context.Context, id int64, audit en.AuditEntry) (en.Subscription, error) {
+	p.logger.DebugContext(ctx, "RestoreSub", "subscription_id", id)
+	const q = `
+		UPDATE subscriptions s
+		SET deleted_at = NULL,
+		    updated_at = now()
+		FROM (
+		    SELECT id, to_jsonb(subscriptions) AS snapshot FROM subscriptions
+		    WHERE tenant_id = current_setting('app.tenant_id') AND id = $1
+		      AND deleted_at IS NOT NULL
+		    FOR UPDATE
+		) old
+		WHERE s.id = old.id
+		RETURNING s.id, s.tenant_id, s.user_id, s.service_name, s.price, s.start_date, s.end_date, s.deleted_at,
+		          s.auto_renew, s.expired_at, COALESCE(to_char(s.billed_through, 'MM-YYYY'), ''),
+		          old.snapshot, to_jsonb(s)
+	`
+	var sub en.Subscription
+	err := p.inTenantTx(ctx, "RestoreSub", func(ctx context.Context, tx pgx.Tx) error {
+		var before, after []byte
+		if err := tx.QueryRow(ctx, q, id).Scan(
+			&sub.ID,
+			&sub.TenantID,
+			&sub.UserID,
+			&sub.ServiceName,
+			&sub.Price,
+			&sub.StartDate,
+			&sub.EndDate,
+			&sub.DeletedAt,
+			&sub.AutoRenew,
+			&sub.ExpiredAt,
+			&sub.BilledThrough,
+			&before,
+			&after,
+		); err != nil {
+			return err
+		}
+		audit.SubscriptionID = sub.ID
+		audit.UserID = sub.UserID
+		audit.Before, audit.After = before, after
+		setAffectedRows(ctx, 1)
+		if err := insertAudit(ctx, tx, audit); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, outboxEntry{audit.Action.EventType(), sub.ID, sub.UserID, after})
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.logger.WarnContext(ctx, "deleted subscription not found for restore", "subscription_id", id)
+			return en.Subscription{}, errors.Wrap(en.ErrSubscriptionNotFound, "PgxStorage.RestoreSub")
+		}
+		if isUniqueViolation(err) {
+			p.logger.WarnContext(ctx, "subscription can not be restored over an active duplicate", "subscription_id", id)
+			return en.Subscription{}, errors.Wrap(en.ErrSubscriptionExists, "PgxStorage.RestoreSub")
+		}
+		p.logger.ErrorContext(ctx, "failed to restore subscription", "subscription_id", id, "error", err)
+		return en.Subscription{}, errors.Wrap(err, "PgxStorage.RestoreSub")
+	}
+	p.logger.DebugContext(ctx, "subscription restored", "subscription_id", id, "user_id", sub.UserID)
+	return sub, nil
+}
 
-	startDate, err := time.Parse("01-2006", startDateStr)
+// PurgeDeletedSubs permanently removes subscriptions of every tenant that were
+// soft-deleted before the given moment. Their charges stay in the ledger,
+// detached from the subscription.
+func (p *PgxStorage) PurgeDeletedSubs(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	p.logger.DebugContext(ctx, "PurgeDeletedSubs", "deleted_before", deletedBefore)
+	const q = `
+		DELETE FROM subscriptions
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+	`
+	var purged int64
+	err := p.inSystemTx(ctx, "PurgeDeletedSubs", "app.retention_purge", func(ctx context.Context, tx pgx.Tx) error {
+		commandTag, err := tx.Exec(ctx, q, deletedBefore)
+		purged = commandTag.RowsAffected()
+		setAffectedRows(ctx, purged)
+		return err
+	})
 	if err != nil {
-		log.Printf("ERROR: parse start_date=%s (want MM-YYYY): %v", startDateStr, err)
-		return 0, fmt.Errorf("invalid start date format (MM-YYYY): %w", err)
+		p.logger.ErrorContext(ctx, "failed to purge deleted subscriptions", "error", err)
+		return 0, errors.Wrap(err, "PgxStorage.PurgeDeletedSubs")
 	}
-	endDate, err := time.Parse("01-2006", endDateStr)
+	p.logger.InfoContext(ctx, "deleted subscriptions purged", "count", purged)
+	return purged, nil
+}
+
+// ListSubsEndingIn returns the active subscriptions of every tenant whose
+// end month is month.
+func (p *PgxStorage) ListSubsEndingIn(ctx context.Context, month string) ([]en.Subscription, error) {
+	p.logger.DebugContext(ctx, "ListSubsEndingIn", "month", month)
+	const q = `
+		SELECT ` + subscriptionColumns + ` FROM subscriptions
+		WHERE end_date = $1 AND deleted_at IS NULL
+		ORDER BY id
+	`
+	var subscriptions []en.Subscription
+	err := p.inSystemTx(ctx, "ListSubsEndingIn", "app.renewal_scan", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, month)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var sub en.Subscription
+			if err := scanSubscription(rows, &sub); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			subscriptions = append(subscriptions, sub)
+		}
+		setReturnedRows(ctx, len(subscriptions))
+		return rows.Err()
+	})
 	if err != nil {
-		log.Printf("ERROR: parse end_date=%s (want MM-YYYY): %v", endDateStr, err)
-		return 0, fmt.Errorf("invalid end date format (MM-YYYY): %w", err)
+		p.logger.ErrorContext(ctx, "failed to list subscriptions ending in month", "month", month, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListSubsEndingIn")
 	}
+	return subscriptions, nil
+}
 
-	// Нормализуем конец периода на конец месяца для наглядности логов
-	endDateLastDay := endDate.AddDate(0, 1, -1)
-	log.Printf("DEBUG: normalized period start=%s end=%s(end-of-month=%s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339), endDateLastDay.Format(time.RFC3339))
+// uniqueViolation is the SQLSTATE Postgres reports for a duplicate key.
+const uniqueViolation = "23505"
 
-	var (
-		q    string
-		args []interface{}
-	)
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// GetTotalByPeriod sums the charges of the user's subscriptions that are not
+// deleted for the months of the period, optionally of one service: their
+// amounts, except skipped months, in en.CostModeExpected, and what was paid
+// and not refunded in en.CostModeActual.
+func (p *PgxStorage) GetTotalByPeriod(ctx context.Context, userID string, serviceName string, startDateStr, endDateStr string, mode en.CostMode) (int, error) {
+	p.logger.DebugContext(ctx, "GetTotalByPeriod", "user_id", userID, "service", serviceName, "start", startDateStr, "end", endDateStr, "mode", mode)
 
-	if serviceName != "" {
-		q = `
-			SELECT COALESCE(SUM(price), 0) AS total
-			FROM subscriptions
-			WHERE user_id = $1
-			  AND service_name = $2
-			  AND to_date(start_date, 'MM-YYYY') >= $3::date
-			  AND to_date(start_date, 'MM-YYYY') <= $4::date
-		`
-		args = []interface{}{userID, serviceName, startDate, endDate}
-	} else {
-		q = `
-			SELECT COALESCE(SUM(price), 0) AS total
-			FROM subscriptions
-			WHERE user_id = $1
-			  AND to_date(start_date, 'MM-YYYY') >= $2::date
-			  AND to_date(start_date, 'MM-YYYY') <= $3::date
-		`
-		args = []interface{}{userID, startDate, endDate}
-	}
-
-	log.Printf("DEBUG: SQL=%q args=%v", q, args)
+	startDate, endDate, err := parsePeriod(startDateStr, endDateStr)
+	if err != nil {
+		p.logger.WarnContext(ctx, "invalid period", "start", startDateStr, "end", endDateStr, "error", err)
+		return 0, err
+	}
 
+	const q = `
+		SELECT COALESCE(SUM(CASE WHEN $5 = 'actual' THEN
+		                        CASE WHEN status IN ('paid', 'disputed') THEN paid_amount END
+		                    ELSE
+		                        CASE WHEN status <> 'skipped' THEN amount END
+		                    END), 0) AS total
+		FROM charges
+		WHERE tenant_id = current_setting('app.tenant_id')
+		  AND user_id = $1
+		  AND period BETWEEN $2 AND $3
+		  AND ($4 = '' OR service_name = $4)
+		  AND ` + liveCharges + `
+	`
 	var total int
-	if err := p.pool.QueryRow(ctx, q, args...).Scan(&total); err != nil {
-		log.Printf("ERROR: QueryRow failed userID=%s service=%q: %v", userID, serviceName, err)
+	err = p.inTenantTx(ctx, "GetTotalByPeriod", func(ctx context.Context, tx pgx.Tx) error {
+		return tx.QueryRow(ctx, q, userID, startDate, endDate, serviceName, string(mode)).Scan(&total)
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to get total cost", "user_id", userID, "service", serviceName, "error", err)
 		return 0, errors.Wrap(err, "PgxStorage.GetTotalByPeriod")
 	}
 
-	log.Printf("INFO: GetTotalByPeriod result userID=%s service=%q total=%d", userID, serviceName, total)
+	p.logger.DebugContext(ctx, "total cost computed", "user_id", userID, "service", serviceName, "total", total)
 	return total, nil
 }
+
+// GetTotalByService sums the charges of the user's subscriptions that are not
+// deleted for the months of the period, except skipped ones, grouped by
+// service and ordered by descending cost.
+func (p *PgxStorage) GetTotalByService(ctx context.Context, userID string, startDateStr, endDateStr string) ([]en.ServiceCost, error) {
+	p.logger.DebugContext(ctx, "GetTotalByService", "user_id", userID, "start", startDateStr, "end", endDateStr)
+
+	startDate, endDate, err := parsePeriod(startDateStr, endDateStr)
+	if err != nil {
+		p.logger.WarnContext(ctx, "invalid period", "start", startDateStr, "end", endDateStr, "error", err)
+		return nil, err
+	}
+
+	const q = `
+		SELECT service_name, SUM(amount) AS total
+		FROM charges
+		WHERE tenant_id = current_setting('app.tenant_id')
+		  AND user_id = $1
+		  AND period BETWEEN $2 AND $3
+		  AND status <> 'skipped'
+		  AND ` + liveCharges + `
+		GROUP BY service_name
+		ORDER BY total DESC, service_name
+	`
+	var costs []en.ServiceCost
+	err = p.inTenantTx(ctx, "GetTotalByService", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userID, startDate, endDate)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var c en.ServiceCost
+			if err := rows.Scan(&c.ServiceName, &c.TotalCost); err != nil {
+				return err
+			}
+			costs = append(costs, c)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		setReturnedRows(ctx, len(costs))
+		return nil
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to get cost breakdown", "user_id", userID, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.GetTotalByService")
+	}
+	return costs, nil
+}
+
+// parsePeriod parses the MM-YYYY bounds of a cost query.
+func parsePeriod(startDateStr, endDateStr string) (time.Time, time.Time, error) {
+	startDate, err := time.Parse("01-2006", startDateStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrapf(en.ErrInvalidPeriod, "start date %q", startDateStr)
+	}
+	endDate, err := time.Parse("01-2006", endDateStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrapf(en.ErrInvalidPeriod, "end date %q", endDateStr)
+	}
+	return startDate, endDate, nil
+}
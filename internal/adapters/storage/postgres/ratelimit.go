@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/ratelimit"
+)
+
+// RateLimitStore keeps token buckets in Postgres so limits hold across replicas.
+type RateLimitStore struct {
+	storage *PgxStorage
+}
+
+func (p *PgxStorage) RateLimitStore() *RateLimitStore {
+	return &RateLimitStore{storage: p}
+}
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (_ ratelimit.Result, err error) {
+	ctx, span := startSpan(ctx, "RateLimitTake")
+	defer func() { endSpan(span, err) }()
+
+	const (
+		insertQ = `
+			INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO NOTHING
+		`
+		selectQ = `
+			SELECT tokens, updated_at FROM rate_limit_buckets
+			WHERE key = $1
+			FOR UPDATE
+		`
+		updateQ = `
+			UPDATE rate_limit_buckets
+			SET tokens = $2, updated_at = $3
+			WHERE key = $1
+		`
+	)
+	var res ratelimit.Result
+	err = s.storage.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		fresh := ratelimit.NewBucket(limit, now)
+		if _, err := tx.Exec(ctx, insertQ, key, fresh.Tokens, fresh.Updated); err != nil {
+			return err
+		}
+		var b ratelimit.Bucket
+		if err := tx.QueryRow(ctx, selectQ, key).Scan(&b.Tokens, &b.Updated); err != nil {
+			return err
+		}
+		res = b.Take(limit, now)
+		_, err := tx.Exec(ctx, updateQ, key, b.Tokens, b.Updated)
+		return err
+	})
+	if err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to take rate limit token", "error", err)
+		return ratelimit.Result{}, errors.Wrap(err, "RateLimitStore.Take")
+	}
+	return res, nil
+}
+
+// PurgeIdle deletes the buckets untouched since idleBefore. Their keys are
+// chosen by callers, one per client IP and route at worst, so without the
+// purge the table only grows.
+func (s *RateLimitStore) PurgeIdle(ctx context.Context, idleBefore time.Time) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "RateLimitPurgeIdle")
+	defer func() { endSpan(span, err) }()
+
+	const q = `DELETE FROM rate_limit_buckets WHERE updated_at < $1`
+	tag, err := s.storage.pool.Exec(ctx, q, idleBefore)
+	if err != nil {
+		s.storage.logger.ErrorContext(ctx, "failed to purge idle rate limit buckets", "error", err)
+		return 0, errors.Wrap(err, "RateLimitStore.PurgeIdle")
+	}
+	setAffectedRows(ctx, tag.RowsAffected())
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,53 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/100bench/subscription_aggregator/internal/ratelimit"
+)
+
+func TestRateLimitStorePurgeIdle(t *testing.T) {
+	store := newTestStorage(t).RateLimitStore()
+	ctx := context.Background()
+	limit := ratelimit.Limit{Rate: 1, Burst: 5}
+	now := time.Now()
+	idle, active := "test-idle-"+uuid.NewString(), "test-active-"+uuid.NewString()
+
+	if _, err := store.Take(ctx, idle, limit, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Take idle: %v", err)
+	}
+	if _, err := store.Take(ctx, active, limit, now); err != nil {
+		t.Fatalf("Take active: %v", err)
+	}
+
+	purged, err := store.PurgeIdle(ctx, now.Add(-ratelimit.IdleBucketTTL))
+	if err != nil {
+		t.Fatalf("PurgeIdle: %v", err)
+	}
+	if purged < 1 {
+		t.Errorf("purged %d buckets, want at least the idle one", purged)
+	}
+
+	var keys []string
+	rows, err := store.storage.pool.Query(ctx, `SELECT key FROM rate_limit_buckets WHERE key = ANY($1)`, []string{idle, active})
+	if err != nil {
+		t.Fatalf("query buckets: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) != 1 || keys[0] != active {
+		t.Errorf("buckets left = %q, want only %q", keys, active)
+	}
+}
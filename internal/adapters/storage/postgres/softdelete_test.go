@@ -0,0 +1,83 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func TestSoftDeleteRestoreAndPurge(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	netflix := en.Subscription{UserID: userID, ServiceName: "Netflix", Price: 400, StartDate: "01-2025", EndDate: "12-2025"}
+	created, err := storage.CreateSub(ctx, netflix, en.AuditEntry{Action: en.AuditActionCreate})
+	if err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+	listed := func(includeDeleted bool) int {
+		t.Helper()
+		subs, err := storage.GetListSubs(ctx, userID, includeDeleted)
+		if err != nil {
+			t.Fatalf("GetListSubs: %v", err)
+		}
+		return len(subs)
+	}
+
+	if _, err := storage.RestoreSub(ctx, created.ID, en.AuditEntry{Action: en.AuditActionRestore}); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("RestoreSub of a live subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+	if err := storage.DeleteSub(ctx, userID, "Netflix", en.AuditEntry{Action: en.AuditActionDelete}); err != nil {
+		t.Fatalf("DeleteSub: %v", err)
+	}
+	if _, err := storage.GetSub(ctx, userID, "Netflix"); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("GetSub of a deleted subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+	if live, all := listed(false), listed(true); live != 0 || all != 1 {
+		t.Errorf("listed %d live and %d in all after delete, want 0 and 1", live, all)
+	}
+
+	if _, err := storage.CreateSub(ctx, netflix, en.AuditEntry{Action: en.AuditActionCreate}); err != nil {
+		t.Fatalf("CreateSub of a duplicate: %v", err)
+	}
+	if _, err := storage.RestoreSub(ctx, created.ID, en.AuditEntry{Action: en.AuditActionRestore}); !errors.Is(err, en.ErrSubscriptionExists) {
+		t.Errorf("RestoreSub over a live duplicate = %v, want ErrSubscriptionExists", err)
+	}
+	if err := storage.DeleteSub(ctx, userID, "Netflix", en.AuditEntry{Action: en.AuditActionDelete}); err != nil {
+		t.Fatalf("DeleteSub of the duplicate: %v", err)
+	}
+	if _, err := storage.RestoreSub(newTenant("globex"), created.ID, en.AuditEntry{Action: en.AuditActionRestore}); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("RestoreSub by another tenant = %v, want ErrSubscriptionNotFound", err)
+	}
+	restored, err := storage.RestoreSub(ctx, created.ID, en.AuditEntry{Action: en.AuditActionRestore})
+	if err != nil {
+		t.Fatalf("RestoreSub: %v", err)
+	}
+	if restored.DeletedAt != nil {
+		t.Errorf("restored subscription is still deleted at %v", restored.DeletedAt)
+	}
+	if live, all := listed(false), listed(true); live != 1 || all != 2 {
+		t.Errorf("listed %d live and %d in all after restore, want 1 and 2", live, all)
+	}
+
+	// The purge spans every tenant, so only this user's rows are checked.
+	if _, err := storage.PurgeDeletedSubs(context.Background(), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("PurgeDeletedSubs: %v", err)
+	}
+	if all := listed(true); all != 2 {
+		t.Errorf("a purge with an hour of retention left %d subscriptions, want 2", all)
+	}
+	if _, err := storage.PurgeDeletedSubs(context.Background(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("PurgeDeletedSubs: %v", err)
+	}
+	if live, all := listed(false), listed(true); live != 1 || all != 1 {
+		t.Errorf("listed %d live and %d in all after the purge, want the restored one only", live, all)
+	}
+}
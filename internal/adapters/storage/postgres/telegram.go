@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// CreateTelegramLinkCode stores the hash of a new link code for the user,
+// replacing any code issued before.
+func (p *PgxStorage) CreateTelegramLinkCode(ctx context.Context, userID, codeHash string, expiresAt time.Time) error {
+	p.logger.DebugContext(ctx, "CreateTelegramLinkCode", "user_id", userID)
+	const (
+		revoke = `DELETE FROM telegram_link_codes WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1`
+		insert = `
+			INSERT INTO telegram_link_codes (code_hash, tenant_id, user_id, expires_at)
+			VALUES ($1, current_setting('app.tenant_id'), $2, $3)
+		`
+	)
+	err := p.inTenantTx(ctx, "CreateTelegramLinkCode", func(ctx context.Context, tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, revoke, userID); err != nil {
+			return errors.Wrap(err, "revoke previous codes")
+		}
+		tag, err := tx.Exec(ctx, insert, codeHash, userID, expiresAt)
+		setAffectedRows(ctx, tag.RowsAffected())
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to create telegram link code", "user_id", userID, "error", err)
+		return errors.Wrap(err, "PgxStorage.CreateTelegramLinkCode")
+	}
+	return nil
+}
+
+// LinkTelegramChat redeems a link code: the chat is linked to the code's
+// user, replacing the user's previous chat and the chat's previous user.
+func (p *PgxStorage) LinkTelegramChat(ctx context.Context, codeHash string, chatID int64, locale string) (en.TelegramChat, error) {
+	p.logger.DebugContext(ctx, "LinkTelegramChat", "chat_id", chatID)
+	const (
+		redeem  = `DELETE FROM telegram_link_codes WHERE code_hash = $1 RETURNING tenant_id, user_id::text, expires_at`
+		release = `DELETE FROM telegram_chats WHERE chat_id = $1`
+		link    = `
+			INSERT INTO telegram_chats (tenant_id, user_id, chat_id, locale)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (tenant_id, user_id) DO UPDATE
+			SET chat_id = EXCLUDED.chat_id, locale = EXCLUDED.locale, linked_at = now()
+			RETURNING linked_at
+		`
+	)
+	chat := en.TelegramChat{ChatID: chatID, Locale: locale}
+	err := p.inSystemTx(ctx, "LinkTelegramChat", "app.chat_link", func(ctx context.Context, tx pgx.Tx) error {
+		var expiresAt time.Time
+		err := tx.QueryRow(ctx, redeem, codeHash).Scan(&chat.TenantID, &chat.UserID, &expiresAt)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && expiresAt.Before(time.Now())) {
+			return en.ErrInvalidLinkCode
+		}
+		if err != nil {
+			return errors.Wrap(err, "redeem code")
+		}
+		if _, err := tx.Exec(ctx, release, chatID); err != nil {
+			return errors.Wrap(err, "release chat")
+		}
+		setAffectedRows(ctx, 1)
+		return tx.QueryRow(ctx, link, chat.TenantID, chat.UserID, chatID, locale).Scan(&chat.LinkedAt)
+	})
+	if err != nil {
+		if errors.Is(err, en.ErrInvalidLinkCode) {
+			return en.TelegramChat{}, errors.Wrap(err, "PgxStorage.LinkTelegramChat")
+		}
+		p.logger.ErrorContext(ctx, "failed to link telegram chat", "chat_id", chatID, "error", err)
+		return en.TelegramChat{}, errors.Wrap(err, "PgxStorage.LinkTelegramChat")
+	}
+	return chat, nil
+}
+
+// UnlinkTelegramChat forgets the chat; unknown chats are ignored.
+func (p *PgxStorage) UnlinkTelegramChat(ctx context.Context, chatID int64) error {
+	p.logger.DebugContext(ctx, "UnlinkTelegramChat", "chat_id", chatID)
+	const q = `DELETE FROM telegram_chats WHERE chat_id = $1`
+	err := p.inSystemTx(ctx, "UnlinkTelegramChat", "app.chat_link", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, chatID)
+		setAffectedRows(ctx, tag.RowsAffected())
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to unlink telegram chat", "chat_id", chatID, "error", err)
+		return errors.Wrap(err, "PgxStorage.UnlinkTelegramChat")
+	}
+	return nil
+}
+
+// GetTelegramChatByID resolves the user of a chat in any tenant.
+func (p *PgxStorage) GetTelegramChatByID(ctx context.Context, chatID int64) (en.TelegramChat, error) {
+	p.logger.DebugContext(ctx, "GetTelegramChatByID", "chat_id", chatID)
+	const q = `SELECT tenant_id, user_id::text, chat_id, locale, linked_at FROM telegram_chats WHERE chat_id = $1`
+	var chat en.TelegramChat
+	err := p.inSystemTx(ctx, "GetTelegramChatByID", "app.chat_link", func(ctx context.Context, tx pgx.Tx) error {
+		return tx.QueryRow(ctx, q, chatID).Scan(&chat.TenantID, &chat.UserID, &chat.ChatID, &chat.Locale, &chat.LinkedAt)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return en.TelegramChat{}, errors.Wrap(en.ErrChatNotLinked, "PgxStorage.GetTelegramChatByID")
+		}
+		p.logger.ErrorContext(ctx, "failed to get telegram chat", "chat_id", chatID, "error", err)
+		return en.TelegramChat{}, errors.Wrap(err, "PgxStorage.GetTelegramChatByID")
+	}
+	return chat, nil
+}
+
+// GetTelegramChat returns the chat linked to the user of the tenant from ctx.
+func (p *PgxStorage) GetTelegramChat(ctx context.Context, userID string) (en.TelegramChat, error) {
+	p.logger.DebugContext(ctx, "GetTelegramChat", "user_id", userID)
+	const q = `
+		SELECT tenant_id, user_id::text, chat_id, locale, linked_at FROM telegram_chats
+		WHERE tenant_id = current_setting('app.tenant_id') AND user_id = $1
+	`
+	var chat en.TelegramChat
+	err := p.inTenantTx(ctx, "GetTelegramChat", func(ctx context.Context, tx pgx.Tx) error {
+		return tx.QueryRow(ctx, q, userID).Scan(&chat.TenantID, &chat.UserID, &chat.ChatID, &chat.Locale, &chat.LinkedAt)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return en.TelegramChat{}, errors.Wrap(en.ErrChatNotLinked, "PgxStorage.GetTelegramChat")
+		}
+		p.logger.ErrorContext(ctx, "failed to get telegram chat", "user_id", userID, "error", err)
+		return en.TelegramChat{}, errors.Wrap(err, "PgxStorage.GetTelegramChat")
+	}
+	return chat, nil
+}
@@ -0,0 +1,63 @@
+//go:build integration
+
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func TestTelegramLinkCodesAreOneTimeAndExpire(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := newTenant("acme")
+	userID := uuid.NewString()
+	chatID := time.Now().UnixNano()
+	hash := func() string { return uuid.NewString() }
+
+	expired := hash()
+	if err := storage.CreateTelegramLinkCode(ctx, userID, expired, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("CreateTelegramLinkCode: %v", err)
+	}
+	if _, err := storage.LinkTelegramChat(ctx, expired, chatID, en.LocaleEN); !errors.Is(err, en.ErrInvalidLinkCode) {
+		t.Errorf("LinkTelegramChat with an expired code = %v, want ErrInvalidLinkCode", err)
+	}
+
+	replaced, valid := hash(), hash()
+	for _, code := range []string{replaced, valid} {
+		if err := storage.CreateTelegramLinkCode(ctx, userID, code, time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("CreateTelegramLinkCode: %v", err)
+		}
+	}
+	if _, err := storage.LinkTelegramChat(ctx, replaced, chatID, en.LocaleEN); !errors.Is(err, en.ErrInvalidLinkCode) {
+		t.Errorf("LinkTelegramChat with a replaced code = %v, want ErrInvalidLinkCode", err)
+	}
+	chat, err := storage.LinkTelegramChat(ctx, valid, chatID, en.LocaleRU)
+	if err != nil {
+		t.Fatalf("LinkTelegramChat: %v", err)
+	}
+	tenantID, _ := en.TenantFromContext(ctx)
+	if chat.TenantID != tenantID || chat.UserID != userID || chat.ChatID != chatID || chat.Locale != en.LocaleRU {
+		t.Errorf("linked chat = %+v, want chat %d of %s/%s in ru", chat, chatID, tenantID, userID)
+	}
+	if _, err := storage.LinkTelegramChat(ctx, valid, chatID+1, en.LocaleEN); !errors.Is(err, en.ErrInvalidLinkCode) {
+		t.Errorf("LinkTelegramChat with a redeemed code = %v, want ErrInvalidLinkCode", err)
+	}
+
+	if got, err := storage.GetTelegramChat(ctx, userID); err != nil || got.ChatID != chatID {
+		t.Errorf("GetTelegramChat = %+v, %v; want chat %d", got, err, chatID)
+	}
+	if _, err := storage.GetTelegramChat(newTenant("globex"), userID); !errors.Is(err, en.ErrChatNotLinked) {
+		t.Errorf("GetTelegramChat in another tenant = %v, want ErrChatNotLinked", err)
+	}
+	if err := storage.UnlinkTelegramChat(ctx, chatID); err != nil {
+		t.Fatalf("UnlinkTelegramChat: %v", err)
+	}
+	if _, err := storage.GetTelegramChatByID(ctx, chatID); !errors.Is(err, en.ErrChatNotLinked) {
+		t.Errorf("GetTelegramChatByID after unlink = %v, want ErrChatNotLinked", err)
+	}
+}
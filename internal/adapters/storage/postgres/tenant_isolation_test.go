@@ -0,0 +1,101 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func TestTenantCanNotReachOtherTenantsRows(t *testing.T) {
+	storage := newTestStorage(t)
+	acme, globex := newTenant("acme"), newTenant("globex")
+	userID := uuid.NewString()
+
+	sub, err := storage.CreateSub(acme, en.Subscription{
+		UserID: userID, ServiceName: "Netflix", Price: 400, StartDate: "01-2025", EndDate: "03-2025",
+	}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"})
+	if err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+
+	if _, err := storage.GetSub(globex, userID, "Netflix"); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("GetSub = %v, want ErrSubscriptionNotFound", err)
+	}
+	if subs, err := storage.GetListSubs(globex, userID, true); err != nil || len(subs) != 0 {
+		t.Errorf("GetListSubs = %d subscriptions, %v; want none", len(subs), err)
+	}
+	price := 1
+	audit := en.AuditEntry{Action: en.AuditActionUpdate, Actor: "test"}
+	if err := storage.UpdateSub(globex, userID, "Netflix", &price, nil, nil, nil, audit); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("UpdateSub = %v, want ErrSubscriptionNotFound", err)
+	}
+	if err := storage.DeleteSub(globex, userID, "Netflix", audit); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("DeleteSub = %v, want ErrSubscriptionNotFound", err)
+	}
+	if charges, err := storage.ListCharges(globex, userID, "", "01-2025", "12-2025"); err != nil || len(charges) != 0 {
+		t.Errorf("ListCharges = %d charges, %v; want none", len(charges), err)
+	}
+	if total, err := storage.GetTotalByPeriod(globex, userID, "", "01-2025", "12-2025", en.CostModeExpected); err != nil || total != 0 {
+		t.Errorf("GetTotalByPeriod = %d, %v; want 0", total, err)
+	}
+	if entries, err := storage.ListAudit(globex, en.AuditFilter{SubscriptionID: sub.ID}); err != nil || len(entries) != 0 {
+		t.Errorf("ListAudit = %d entries, %v; want none", len(entries), err)
+	}
+
+	got, err := storage.GetSub(acme, userID, "Netflix")
+	if err != nil {
+		t.Fatalf("GetSub by owner: %v", err)
+	}
+	if got.Price != sub.Price || got.DeletedAt != nil {
+		t.Errorf("owner reads %+v after the other tenant's attempts, want %+v", got, sub)
+	}
+	if total, err := storage.GetTotalByPeriod(acme, userID, "", "01-2025", "12-2025", en.CostModeExpected); err != nil || total != 3*400 {
+		t.Errorf("owner's GetTotalByPeriod = %d, %v; want %d", total, err, 3*400)
+	}
+}
+
+// TestRowLevelSecurityHidesOtherTenants queries the tables without any
+// tenant condition, so only the policies keep the other tenant's rows out.
+func TestRowLevelSecurityHidesOtherTenants(t *testing.T) {
+	storage := newTestStorage(t)
+	acme, globex := newTenant("acme"), newTenant("globex")
+	userID := uuid.NewString()
+
+	sub, err := storage.CreateSub(acme, en.Subscription{
+		UserID: userID, ServiceName: "Netflix", Price: 400, StartDate: "01-2025", EndDate: "03-2025",
+	}, en.AuditEntry{Action: en.AuditActionCreate, Actor: "test"})
+	if err != nil {
+		t.Fatalf("CreateSub: %v", err)
+	}
+
+	for _, table := range []string{"subscriptions", "charges", "subscription_audit"} {
+		var n int
+		err := storage.inTenantTx(globex, "count "+table, func(ctx context.Context, tx pgx.Tx) error {
+			return tx.QueryRow(ctx, `SELECT count(*) FROM `+table+` WHERE user_id = $1`, userID).Scan(&n)
+		})
+		if err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n != 0 {
+			t.Errorf("%s: other tenant sees %d rows", table, n)
+		}
+	}
+
+	err = storage.inTenantTx(globex, "steal", func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE subscriptions SET tenant_id = current_setting('app.tenant_id') WHERE id = $1`, sub.ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("update across tenants: %v", err)
+	}
+	if _, err := storage.GetSub(acme, userID, "Netflix"); err != nil {
+		t.Errorf("owner lost the subscription: %v", err)
+	}
+}
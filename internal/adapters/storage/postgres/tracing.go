@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+var tracer = otel.Tracer("github.com/100bench/subscription_aggregator/internal/adapters/storage/postgres")
+
+// rowsAffectedKey is not covered by the semantic conventions, which only define returned rows.
+const rowsAffectedKey = attribute.Key("db.response.affected_rows")
+
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "postgres "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(operation),
+		),
+	)
+}
+
+// endSpan ends span, marking it failed unless err is a regular "nothing found" outcome.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) && !errors.Is(err, en.ErrSubscriptionNotFound) {
+		tracing.Fail(span, err)
+	}
+	span.End()
+}
+
+func setReturnedRows(ctx context.Context, n int) {
+	trace.SpanFromContext(ctx).SetAttributes(semconv.DBResponseReturnedRows(n))
+}
+
+func setAffectedRows(ctx context.Context, n int64) {
+	trace.SpanFromContext(ctx).SetAttributes(rowsAffectedKey.Int64(n))
+}
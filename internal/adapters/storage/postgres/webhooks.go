@@ -0,0 +1,270 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+const defaultDeliveriesLimit = 50
+
+func (p *PgxStorage) CreateWebhook(ctx context.Context, webhook en.Webhook) (en.Webhook, error) {
+	p.logger.DebugContext(ctx, "CreateWebhook", "user_id", webhook.UserID)
+	const q = `
+		INSERT INTO webhooks (tenant_id, user_id, url, secret, events)
+		VALUES (current_setting('app.tenant_id'), NULLIF($1, '')::uuid, $2, $3, $4)
+		RETURNING id, tenant_id, created_at
+	`
+	err := p.inTenantTx(ctx, "CreateWebhook", func(ctx context.Context, tx pgx.Tx) error {
+		setAffectedRows(ctx, 1)
+		return tx.QueryRow(ctx, q, webhook.UserID, webhook.URL, webhook.Secret, eventTypesToStrings(webhook.Events)).Scan(
+			&webhook.ID,
+			&webhook.TenantID,
+			&webhook.CreatedAt,
+		)
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to create webhook", "user_id", webhook.UserID, "error", err)
+		return en.Webhook{}, errors.Wrap(err, "PgxStorage.CreateWebhook")
+	}
+	return webhook, nil
+}
+
+// ListWebhooks returns the tenant's endpoints without their secrets; with a
+// userID only the endpoints of that user and the tenant-wide ones.
+func (p *PgxStorage) ListWebhooks(ctx context.Context, userID string) ([]en.Webhook, error) {
+	p.logger.DebugContext(ctx, "ListWebhooks", "user_id", userID)
+	const q = `
+		SELECT id, tenant_id, COALESCE(user_id::text, ''), url, events, created_at FROM webhooks
+		WHERE tenant_id = current_setting('app.tenant_id')
+		  AND ($1 = '' OR user_id IS NULL OR user_id = NULLIF($1, '')::uuid)
+		ORDER BY id
+	`
+	var webhooks []en.Webhook
+	err := p.inTenantTx(ctx, "ListWebhooks", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				w      en.Webhook
+				events []string
+			)
+			if err := rows.Scan(&w.ID, &w.TenantID, &w.UserID, &w.URL, &events, &w.CreatedAt); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			for _, e := range events {
+				w.Events = append(w.Events, en.EventType(e))
+			}
+			webhooks = append(webhooks, w)
+		}
+		setReturnedRows(ctx, len(webhooks))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list webhooks", "user_id", userID, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListWebhooks")
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the endpoint together with its delivery log.
+func (p *PgxStorage) DeleteWebhook(ctx context.Context, id int64) error {
+	p.logger.DebugContext(ctx, "DeleteWebhook", "webhook_id", id)
+	const q = `DELETE FROM webhooks WHERE tenant_id = current_setting('app.tenant_id') AND id = $1`
+	var deleted int64
+	err := p.inTenantTx(ctx, "DeleteWebhook", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, id)
+		deleted = tag.RowsAffected()
+		setAffectedRows(ctx, deleted)
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to delete webhook", "webhook_id", id, "error", err)
+		return errors.Wrap(err, "PgxStorage.DeleteWebhook")
+	}
+	if deleted == 0 {
+		return errors.Wrap(en.ErrWebhookNotFound, "PgxStorage.DeleteWebhook")
+	}
+	return nil
+}
+
+func (p *PgxStorage) EnqueueWebhookDeliveries(ctx context.Context, event en.Event, payload []byte) (int64, error) {
+	p.logger.DebugContext(ctx, "EnqueueWebhookDeliveries", "event_id", event.ID, "event_type", event.Type)
+	const q = `
+		INSERT INTO webhook_deliveries (tenant_id, webhook_id, event_id, event_type, payload)
+		SELECT tenant_id, id, $1, $2, $3 FROM webhooks
+		WHERE tenant_id = current_setting('app.tenant_id')
+		  AND (user_id IS NULL OR user_id = NULLIF($4, '')::uuid)
+		  AND (cardinality(events) = 0 OR $2 = ANY(events))
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`
+	var queued int64
+	err := p.inTenantTx(ctx, "EnqueueWebhookDeliveries", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, event.ID, string(event.Type), payload, event.UserID)
+		queued = tag.RowsAffected()
+		setAffectedRows(ctx, queued)
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to enqueue webhook deliveries", "event_id", event.ID, "error", err)
+		return 0, errors.Wrap(err, "PgxStorage.EnqueueWebhookDeliveries")
+	}
+	return queued, nil
+}
+
+// ClaimWebhookDeliveries picks due deliveries of all tenants. SKIP LOCKED lets
+// concurrent dispatchers claim disjoint batches, and moving next_attempt_at by
+// lease keeps a claimed delivery hidden until its attempt is recorded.
+func (p *PgxStorage) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]en.WebhookDelivery, error) {
+	const q = `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		FROM (
+		    SELECT id FROM webhook_deliveries
+		    WHERE status = 'pending' AND next_attempt_at <= now()
+		    ORDER BY next_attempt_at, id
+		    LIMIT $1
+		    FOR UPDATE SKIP LOCKED
+		) due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING d.id, d.tenant_id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, d.created_at, w.url, w.secret
+	`
+	var deliveries []en.WebhookDelivery
+	err := p.inSystemTx(ctx, "ClaimWebhookDeliveries", "app.webhook_dispatch", func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, q, limit, lease.Milliseconds())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			d := en.WebhookDelivery{Status: en.DeliveryPending}
+			var eventType string
+			if err := rows.Scan(&d.ID, &d.TenantID, &d.WebhookID, &d.EventID, &eventType, &d.Payload, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			d.EventType = en.EventType(eventType)
+			deliveries = append(deliveries, d)
+		}
+		setAffectedRows(ctx, int64(len(deliveries)))
+		return rows.Err()
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to claim webhook deliveries", "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ClaimWebhookDeliveries")
+	}
+	return deliveries, nil
+}
+
+func (p *PgxStorage) RecordWebhookAttempt(ctx context.Context, attempt en.WebhookAttempt) error {
+	const q = `
+		UPDATE webhook_deliveries
+		SET status = $2,
+		    attempts = attempts + 1,
+		    next_attempt_at = COALESCE($3, next_attempt_at),
+		    last_attempt_at = now(),
+		    response_status = NULLIF($4, 0),
+		    last_error = $5
+		WHERE id = $1
+	`
+	var nextAttemptAt *time.Time
+	if !attempt.NextAttemptAt.IsZero() {
+		nextAttemptAt = &attempt.NextAttemptAt
+	}
+	err := p.inSystemTx(ctx, "RecordWebhookAttempt", "app.webhook_dispatch", func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, attempt.DeliveryID, string(attempt.Status), nextAttemptAt, attempt.ResponseStatus, attempt.Error)
+		setAffectedRows(ctx, tag.RowsAffected())
+		return err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to record webhook attempt", "delivery_id", attempt.DeliveryID, "error", err)
+		return errors.Wrap(err, "PgxStorage.RecordWebhookAttempt")
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the delivery log of one of the tenant's
+// endpoints, newest first.
+func (p *PgxStorage) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]en.WebhookDelivery, error) {
+	p.logger.DebugContext(ctx, "ListWebhookDeliveries", "webhook_id", webhookID)
+	const exists = `SELECT EXISTS (SELECT 1 FROM webhooks WHERE tenant_id = current_setting('app.tenant_id') AND id = $1)`
+	const q = `
+		SELECT id, tenant_id, webhook_id, event_id, event_type, payload, status, attempts,
+		       next_attempt_at, last_attempt_at, COALESCE(response_status, 0), last_error, created_at
+		FROM webhook_deliveries
+		WHERE tenant_id = current_setting('app.tenant_id') AND webhook_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+	if limit <= 0 {
+		limit = defaultDeliveriesLimit
+	}
+	var deliveries []en.WebhookDelivery
+	err := p.inTenantTx(ctx, "ListWebhookDeliveries", func(ctx context.Context, tx pgx.Tx) error {
+		var found bool
+		if err := tx.QueryRow(ctx, exists, webhookID).Scan(&found); err != nil {
+			return err
+		}
+		if !found {
+			return en.ErrWebhookNotFound
+		}
+
+		rows, err := tx.Query(ctx, q, webhookID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				d                 en.WebhookDelivery
+				eventType, status string
+			)
+			if err := rows.Scan(
+				&d.ID,
+				&d.TenantID,
+				&d.WebhookID,
+				&d.EventID,
+				&eventType,
+				&d.Payload,
+				&status,
+				&d.Attempts,
+				&d.NextAttemptAt,
+				&d.LastAttemptAt,
+				&d.ResponseStatus,
+				&d.LastError,
+				&d.CreatedAt,
+			); err != nil {
+				return errors.Wrap(err, "rows.Scan")
+			}
+			d.EventType, d.Status = en.EventType(eventType), en.DeliveryStatus(status)
+			deliveries = append(deliveries, d)
+		}
+		setReturnedRows(ctx, len(deliveries))
+		return rows.Err()
+	})
+	if err != nil {
+		if errors.Is(err, en.ErrWebhookNotFound) {
+			return nil, errors.Wrap(err, "PgxStorage.ListWebhookDeliveries")
+		}
+		p.logger.ErrorContext(ctx, "failed to list webhook deliveries", "webhook_id", webhookID, "error", err)
+		return nil, errors.Wrap(err, "PgxStorage.ListWebhookDeliveries")
+	}
+	return deliveries, nil
+}
+
+func eventTypesToStrings(events []en.EventType) []string {
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		out = append(out, string(e))
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+// Package webhook delivers events to registered HTTP endpoints and signs
+// them so receivers can verify the sender.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/netguard"
+)
+
+// Headers set on every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventIDHeader   = "X-Webhook-Id"
+	EventTypeHeader = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// maxDrainBody bounds how much of a response is read so that the connection
+// can be reused; the body itself is never kept.
+const maxDrainBody = 4 << 10
+
+// Sender posts deliveries as JSON with an HMAC-SHA256 signature.
+type Sender struct {
+	client *http.Client
+	guard  *netguard.Guard
+	now    func() time.Time
+}
+
+// Option customizes optional Sender behaviour.
+type Option func(*Sender)
+
+// WithHTTPClient replaces the client used for deliveries, e.g. in tests. The
+// client is used as is, without the address checks of the default one.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sender) {
+		s.client = client
+	}
+}
+
+// WithAllowedNetworks lets deliveries reach the given private ranges, e.g. a
+// loopback receiver in tests.
+func WithAllowedNetworks(prefixes ...netip.Prefix) Option {
+	return func(s *Sender) {
+		s.guard = netguard.New(prefixes...)
+	}
+}
+
+// NewSender returns a Sender whose requests time out after timeout.
+// Redirects are not followed: a receiver must answer at the registered URL.
+// Connections are only made to public addresses, checked after name
+// resolution, and never through a proxy that could reach further.
+func NewSender(timeout time.Duration, opts ...Option) *Sender {
+	s := &Sender{guard: netguard.New(), now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.client == nil {
+		dialer := &net.Dialer{Timeout: timeout, Control: s.guard.Control}
+		s.client = &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: timeout,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	return s
+}
+
+// Send posts the delivery's payload and fails on transport errors and
+// non-2xx responses. Errors are shown to the webhook's owner, so they name
+// only the status code or the kind of failure, never the response body or
+// the addresses tried.
+func (s *Sender) Send(ctx context.Context, delivery en.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, errors.Wrap(err, "http.NewRequest")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "subscription-aggregator-webhooks/1")
+	req.Header.Set(EventIDHeader, delivery.EventID)
+	req.Header.Set(EventTypeHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, s.now(), delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, transportError(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.Errorf("receiver answered HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func transportError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, netguard.ErrForbiddenAddress):
+		return errors.Wrap(netguard.ErrForbiddenAddress, "send webhook")
+	case errors.Is(err, context.Canceled):
+		return errors.Wrap(context.Canceled, "send webhook")
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("send webhook: receiver did not answer in time")
+	default:
+		return errors.New("send webhook: receiver could not be reached")
+	}
+}
+
+// Sign returns the signature header value for payload sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<payload>">".
+func Sign(secret string, t time.Time, payload []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, payload)
+}
+
+// Verify checks a signature header produced by Sign and rejects signatures
+// older than tolerance, protecting receivers against replays.
+func Verify(secret, header string, payload []byte, tolerance time.Duration, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return errors.New("malformed webhook signature")
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return errors.New("webhook signature timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, payload))) {
+		return errors.New("webhook signature mismatch")
+	}
+	return nil
+}
+
+func signature(secret, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/netguard"
+)
+
+const secret = "whsec_test"
+
+var loopback = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+func delivery(url string) en.WebhookDelivery {
+	return en.WebhookDelivery{
+		ID:        7,
+		EventID:   "evt-1",
+		EventType: en.EventSubscriptionCreated,
+		Payload:   []byte(`{"id":"evt-1"}`),
+		URL:       url,
+		Secret:    secret,
+	}
+}
+
+func TestSendSignsDelivery(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	status, err := NewSender(time.Second, WithAllowedNetworks(loopback...)).Send(context.Background(), delivery(receiver.URL))
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v; want %d", status, err, http.StatusNoContent)
+	}
+	if got.Header.Get(EventIDHeader) != "evt-1" || got.Header.Get(EventTypeHeader) != string(en.EventSubscriptionCreated) || got.Header.Get(DeliveryHeader) != "7" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	if err := Verify(secret, got.Header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestSendKeepsOnlyStatusOfFailures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "ami-id: i-0123456789abcdef0", http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	status, err := NewSender(time.Second, WithAllowedNetworks(loopback...)).Send(context.Background(), delivery(receiver.URL))
+	if status != http.StatusInternalServerError || err == nil {
+		t.Fatalf("Send = %d, %v; want %d and an error", status, err, http.StatusInternalServerError)
+	}
+	if strings.Contains(err.Error(), "ami-id") {
+		t.Errorf("error %q exposes the response body", err)
+	}
+}
+
+func TestSendRefusesPrivateReceivers(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+	// A public-looking name that resolves to loopback, as a DNS rebinding
+	// attack would after registration.
+	rebound := strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+
+	for _, url := range []string{receiver.URL, rebound} {
+		status, err := NewSender(time.Second).Send(context.Background(), delivery(url))
+		if !errors.Is(err, netguard.ErrForbiddenAddress) || status != 0 {
+			t.Errorf("Send(%s) = %d, %v; want %v", url, status, err, netguard.ErrForbiddenAddress)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Errorf("receiver was called %d times", calls.Load())
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	status, err := NewSender(time.Second, WithAllowedNetworks(loopback...)).Send(context.Background(), delivery(receiver.URL))
+	if status != http.StatusTemporaryRedirect || err == nil {
+		t.Errorf("Send = %d, %v; want %d and an error", status, err, http.StatusTemporaryRedirect)
+	}
+	if followed.Load() {
+		t.Error("redirect was followed")
+	}
+}
@@ -0,0 +1,97 @@
+// Package auth authenticates API callers by per-tenant API keys.
+package auth
+
+import (
+	"crypto/sha256"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MinKeyLength keeps keys long enough not to be guessed.
+const MinKeyLength = 24
+
+// Principal is the owner of an API key: the tenant the key acts on and the
+// name the key was configured under.
+type Principal struct {
+	TenantID string
+	KeyName  string
+}
+
+// String identifies the principal in logs, the audit log and rate limits.
+func (p Principal) String() string {
+	return p.TenantID + "/" + p.KeyName
+}
+
+// Keyring maps API keys onto their principals. Keys are kept as SHA-256
+// digests and looked up by digest, so a lookup takes the same time however
+// much of a key matches a configured one.
+type Keyring struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+// ParseKeys parses "tenant:name=key;tenant:name=key" API key definitions.
+// A tenant may have several keys, e.g. to rotate them; names must be unique
+// within the tenant and keys at least MinKeyLength characters long.
+func ParseKeys(spec string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[[sha256.Size]byte]Principal)}
+	names := make(map[Principal]bool)
+	for _, item := range strings.Split(spec, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		owner, key, ok := strings.Cut(item, "=")
+		tenantID, name, hasName := strings.Cut(strings.TrimSpace(owner), ":")
+		p := Principal{TenantID: strings.TrimSpace(tenantID), KeyName: strings.TrimSpace(name)}
+		if !ok || !hasName || p.TenantID == "" || p.KeyName == "" {
+			return nil, errors.Errorf("%q: want tenant:name=key", redactKey(item))
+		}
+		key = strings.TrimSpace(key)
+		if len(key) < MinKeyLength {
+			return nil, errors.Errorf("key %s is shorter than %d characters", p, MinKeyLength)
+		}
+		if names[p] {
+			return nil, errors.Errorf("key %s is defined twice", p)
+		}
+		digest := sha256.Sum256([]byte(key))
+		if other, dup := k.keys[digest]; dup {
+			return nil, errors.Errorf("keys %s and %s are the same", other, p)
+		}
+		names[p] = true
+		k.keys[digest] = p
+	}
+	return k, nil
+}
+
+// Len returns the number of keys.
+func (k *Keyring) Len() int {
+	if k == nil {
+		return 0
+	}
+	return len(k.keys)
+}
+
+// Authenticate returns the principal owning key; a nil Keyring knows no keys.
+func (k *Keyring) Authenticate(key string) (Principal, bool) {
+	if k == nil || key == "" {
+		return Principal{}, false
+	}
+	p, ok := k.keys[sha256.Sum256([]byte(key))]
+	return p, ok
+}
+
+// BearerToken returns the token of an "Authorization: Bearer <token>" header
+// value, or "" for other schemes.
+func BearerToken(header string) string {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// redactKey drops the key from a definition quoted in an error.
+func redactKey(item string) string {
+	owner, _, _ := strings.Cut(item, "=")
+	return owner + "=..."
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	acmeKey   = "acme-billing-0123456789abcdef"
+	acmeKey2  = "acme-rotated-0123456789abcdef"
+	globexKey = "globex-crm-0123456789abcdefgh"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys(" acme:billing=" + acmeKey + "; acme:rotated=" + acmeKey2 + ";globex:crm=" + globexKey + ";")
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	if keys.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", keys.Len())
+	}
+
+	tests := []struct {
+		key  string
+		want Principal
+		ok   bool
+	}{
+		{acmeKey, Principal{TenantID: "acme", KeyName: "billing"}, true},
+		{acmeKey2, Principal{TenantID: "acme", KeyName: "rotated"}, true},
+		{globexKey, Principal{TenantID: "globex", KeyName: "crm"}, true},
+		{"", Principal{}, false},
+		{acmeKey[:len(acmeKey)-1], Principal{}, false},
+		{strings.ToUpper(acmeKey), Principal{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := keys.Authenticate(tt.key)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Authenticate(%q) = %v, %v; want %v, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseKeysRejects(t *testing.T) {
+	tests := map[string]string{
+		"missing name":   "acme=" + acmeKey,
+		"missing tenant": ":billing=" + acmeKey,
+		"missing key":    "acme:billing",
+		"short key":      "acme:billing=short",
+		"duplicate name": "acme:billing=" + acmeKey + ";acme:billing=" + acmeKey2,
+		"duplicate key":  "acme:billing=" + acmeKey + ";globex:crm=" + acmeKey,
+	}
+	for name, spec := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseKeys(spec)
+			if err == nil {
+				t.Fatalf("ParseKeys(%q) succeeded", spec)
+			}
+			if strings.Contains(err.Error(), acmeKey) {
+				t.Errorf("error %q leaks the key", err)
+			}
+		})
+	}
+}
+
+func TestNilKeyringAuthenticatesNothing(t *testing.T) {
+	var keys *Keyring
+	if keys.Len() != 0 {
+		t.Errorf("Len = %d, want 0", keys.Len())
+	}
+	if p, ok := keys.Authenticate(acmeKey); ok {
+		t.Errorf("Authenticate = %v, want no principal", p)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := map[string]string{
+		"Bearer " + acmeKey:         acmeKey,
+		"bearer  " + acmeKey + "  ": acmeKey,
+		"Basic " + acmeKey:          "",
+		acmeKey:                     "",
+		"":                          "",
+	}
+	for header, want := range tests {
+		if got := BearerToken(header); got != want {
+			t.Errorf("BearerToken(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
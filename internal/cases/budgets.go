@@ -0,0 +1,156 @@
+package cases
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// defaultCurrency is the currency of charges, and so the only one a budget
+// can be kept in.
+const defaultCurrency = "RUB"
+
+func (s *ServiceProvider) CreateBudget(ctx context.Context, budget en.Budget) (en.Budget, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.CreateBudget")
+	defer span.End()
+
+	budget, err := normalizeBudget(budget)
+	if err != nil {
+		return en.Budget{}, tracing.Fail(span, err)
+	}
+	created, err := s.storage.CreateBudget(ctx, budget)
+	if err != nil {
+		return en.Budget{}, tracing.Fail(span, errors.Wrap(err, "storage.CreateBudget"))
+	}
+	s.logger.InfoContext(ctx, "budget created", "budget_id", created.ID, "user_id", created.UserID, "principal", en.AuditPrincipal(ctx))
+	return created, nil
+}
+
+func (s *ServiceProvider) ListBudgets(ctx context.Context, userID string) ([]en.Budget, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.ListBudgets")
+	defer span.End()
+
+	budgets, err := s.storage.ListBudgets(ctx, userID)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.ListBudgets"))
+	}
+	return budgets, nil
+}
+
+// UpdateBudget replaces the settings of the user's budget; a changed budget
+// is alerted on again once exceeded.
+func (s *ServiceProvider) UpdateBudget(ctx context.Context, budget en.Budget) (en.Budget, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.UpdateBudget")
+	defer span.End()
+
+	budget, err := normalizeBudget(budget)
+	if err != nil {
+		return en.Budget{}, tracing.Fail(span, err)
+	}
+	updated, err := s.storage.UpdateBudget(ctx, budget)
+	if err != nil {
+		return en.Budget{}, tracing.Fail(span, errors.Wrap(err, "storage.UpdateBudget"))
+	}
+	s.logger.InfoContext(ctx, "budget updated", "budget_id", updated.ID, "user_id", updated.UserID, "principal", en.AuditPrincipal(ctx))
+	return updated, nil
+}
+
+func (s *ServiceProvider) DeleteBudget(ctx context.Context, userID string, id int64) error {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.DeleteBudget")
+	defer span.End()
+
+	if err := s.storage.DeleteBudget(ctx, userID, id); err != nil {
+		return tracing.Fail(span, errors.Wrap(err, "storage.DeleteBudget"))
+	}
+	s.logger.InfoContext(ctx, "budget deleted", "budget_id", id, "user_id", userID, "principal", en.AuditPrincipal(ctx))
+	return nil
+}
+
+// GetBudgetStatus evaluates the user's budgets against the spend of the
+// MM-YYYY period, the current month when empty.
+func (s *ServiceProvider) GetBudgetStatus(ctx context.Context, userID string, period string) ([]en.BudgetStatus, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.GetBudgetStatus")
+	defer span.End()
+
+	if period == "" {
+		period = time.Now().UTC().Format(monthLayout)
+	}
+	statuses, err := s.storage.ListBudgetStatuses(ctx, userID, period)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.ListBudgetStatuses"))
+	}
+	return statuses, nil
+}
+
+// alertBudgets records budget.exceeded for every budget covering the service
+// whose current month is now projected over its limit, once per month.
+// subscriptionID is the changed subscription, looked up when zero. Failures
+// are only logged: the change that triggered the check has been made.
+func (s *ServiceProvider) alertBudgets(ctx context.Context, userID, serviceName string, subscriptionID int64) {
+	if !s.budgetAlerts {
+		return
+	}
+	ctx, span := tracer.Start(ctx, "ServiceProvider.alertBudgets")
+	defer span.End()
+
+	period := time.Now().UTC().Format(monthLayout)
+	statuses, err := s.storage.ListBudgetStatuses(ctx, userID, period)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to evaluate budgets", "user_id", userID, "error", tracing.Fail(span, err))
+		return
+	}
+	for _, status := range statuses {
+		if !status.Exceeded() || status.AlertedPeriod == period || !status.Covers(serviceName) {
+			continue
+		}
+		if subscriptionID == 0 {
+			changed, err := s.storage.GetSub(ctx, userID, serviceName)
+			if err != nil {
+				s.logger.ErrorContext(ctx, "failed to load subscription for budget alert", "user_id", userID, "error", tracing.Fail(span, err))
+				return
+			}
+			subscriptionID = changed.ID
+		}
+		alerted, err := s.storage.AlertBudget(ctx, status, subscriptionID)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to record budget alert", "budget_id", status.ID, "error", tracing.Fail(span, err))
+			continue
+		}
+		if !alerted {
+			continue
+		}
+		s.logger.InfoContext(ctx, "budget exceeded", "budget_id", status.ID, "user_id", userID,
+			"limit", status.Limit, "projected", status.Projected, "period", period)
+	}
+}
+
+// normalizeBudget checks a budget and fills in its default currency.
+func normalizeBudget(budget en.Budget) (en.Budget, error) {
+	budget.Name = strings.TrimSpace(budget.Name)
+	if budget.Name == "" {
+		return en.Budget{}, errors.Wrap(en.ErrInvalidBudget, "name is required")
+	}
+	if budget.Limit <= 0 {
+		return en.Budget{}, errors.Wrap(en.ErrInvalidBudget, "limit must be positive")
+	}
+	if budget.Currency == "" {
+		budget.Currency = defaultCurrency
+	}
+	budget.Currency = strings.ToUpper(budget.Currency)
+	if budget.Currency != defaultCurrency {
+		// Charges are billed in RUB only, so a budget in another currency
+		// would never count anything.
+		return en.Budget{}, errors.Wrapf(en.ErrInvalidBudget, "currency %q is not supported, charges are billed in %s", budget.Currency, defaultCurrency)
+	}
+	for _, service := range budget.Services {
+		if strings.TrimSpace(service) == "" {
+			return en.Budget{}, errors.Wrap(en.ErrInvalidBudget, "service names must not be empty")
+		}
+	}
+	return budget, nil
+}
@@ -0,0 +1,187 @@
+package cases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+func newBudgetService(t *testing.T, opts ...cases.ServiceOption) (*cases.ServiceProvider, context.Context) {
+	t.Helper()
+	return testutil.NewService(t, nil, opts...), en.WithTenant(context.Background(), "acme")
+}
+
+func subscribe(t *testing.T, service *cases.ServiceProvider, ctx context.Context, name string, price int, start, end string) en.Subscription {
+	t.Helper()
+	sub, err := service.CreateSubscription(ctx, en.Subscription{
+		UserID: testutil.UserID, ServiceName: name, Price: price, StartDate: start, EndDate: end,
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription %s: %v", name, err)
+	}
+	return sub
+}
+
+func TestBudgetCurrency(t *testing.T) {
+	service, ctx := newBudgetService(t)
+
+	for name, tt := range map[string]struct {
+		currency string
+		want     string
+		wantErr  error
+	}{
+		"default":     {currency: "", want: "RUB"},
+		"lower case":  {currency: "rub", want: "RUB"},
+		"other":       {currency: "USD", wantErr: en.ErrInvalidBudget},
+		"not a code":  {currency: "RUBLE", wantErr: en.ErrInvalidBudget},
+		"other lower": {currency: "eur", wantErr: en.ErrInvalidBudget},
+	} {
+		t.Run(name, func(t *testing.T) {
+			budget, err := service.CreateBudget(ctx, en.Budget{UserID: testutil.UserID, Name: name, Limit: 1000, Currency: tt.currency})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateBudget = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && budget.Currency != tt.want {
+				t.Errorf("currency = %q, want %q", budget.Currency, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBudgetStatus(t *testing.T) {
+	service, ctx := newBudgetService(t)
+	netflix := subscribe(t, service, ctx, "Netflix", 400, "01-2025", "03-2025")
+	subscribe(t, service, ctx, "Spotify", 300, "01-2025", "03-2025")
+	subscribe(t, service, ctx, "Kinopoisk", 200, "01-2025", "03-2025")
+	deleted := subscribe(t, service, ctx, "Okko", 100, "01-2025", "03-2025")
+	if err := service.DeleteSubscription(ctx, testutil.UserID, deleted.ServiceName); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+
+	paid := 350
+	if _, err := service.MarkCharge(ctx, netflix.ID, "02-2025", en.ChargeMark{Status: en.ChargeStatusPaid, Amount: &paid}); err != nil {
+		t.Fatalf("MarkCharge paid: %v", err)
+	}
+	spotify, err := service.GetSubscription(ctx, testutil.UserID, "Spotify")
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if _, err := service.MarkCharge(ctx, spotify.ID, "02-2025", en.ChargeMark{Status: en.ChargeStatusSkipped}); err != nil {
+		t.Fatalf("MarkCharge skipped: %v", err)
+	}
+
+	for _, b := range []en.Budget{
+		{Name: "all", Limit: 500},
+		{Name: "video", Limit: 1000, Services: []string{"Netflix", "Kinopoisk", "Okko"}},
+		{Name: "music", Limit: 100, Services: []string{"Spotify"}},
+	} {
+		b.UserID = testutil.UserID
+		if _, err := service.CreateBudget(ctx, b); err != nil {
+			t.Fatalf("CreateBudget %s: %v", b.Name, err)
+		}
+	}
+
+	statuses, err := service.GetBudgetStatus(ctx, testutil.UserID, "02-2025")
+	if err != nil {
+		t.Fatalf("GetBudgetStatus: %v", err)
+	}
+	// Netflix is paid with 350, Spotify is skipped, Kinopoisk is expected
+	// and the deleted Okko does not count.
+	want := map[string]struct {
+		spent, projected int
+		exceeded         bool
+	}{
+		"all":   {350, 550, true},
+		"video": {350, 550, false},
+		"music": {0, 0, false},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("got %d statuses, want %d", len(statuses), len(want))
+	}
+	for _, s := range statuses {
+		w := want[s.Name]
+		if s.Period != "02-2025" || s.Spent != w.spent || s.Projected != w.projected || s.Exceeded() != w.exceeded {
+			t.Errorf("%s: period %s, spent %d, projected %d, exceeded %t; want 02-2025, %d, %d, %t",
+				s.Name, s.Period, s.Spent, s.Projected, s.Exceeded(), w.spent, w.projected, w.exceeded)
+		}
+	}
+
+	if _, err := service.GetBudgetStatus(ctx, testutil.UserID, "2025-02"); !errors.Is(err, en.ErrInvalidPeriod) {
+		t.Errorf("GetBudgetStatus with a bad period = %v, want ErrInvalidPeriod", err)
+	}
+}
+
+func TestBudgetAlertOncePerPeriod(t *testing.T) {
+	storage := testutil.NewStorage()
+	service := testutil.NewService(t, storage, cases.WithBudgetAlerts())
+	ctx := en.WithTenant(context.Background(), "acme")
+	month := time.Now().UTC().Format("01-2006")
+
+	budget, err := service.CreateBudget(ctx, en.Budget{UserID: testutil.UserID, Name: "all", Limit: 500})
+	if err != nil {
+		t.Fatalf("CreateBudget: %v", err)
+	}
+	alerts := func(step string, want int, sub en.Subscription) {
+		t.Helper()
+		events := storage.BudgetAlerts()
+		if len(events) != want {
+			t.Fatalf("%s: %d alerts, want %d", step, len(events), want)
+		}
+		if want == 0 {
+			return
+		}
+		last := events[len(events)-1]
+		if last.Type != en.EventBudgetExceeded || last.Budget == nil || last.Budget.ID != budget.ID || last.Budget.Period != month {
+			t.Errorf("%s: alert %+v, want budget.exceeded of budget %d for %s", step, last, budget.ID, month)
+		}
+		if last.Subscription.ID != sub.ID {
+			t.Errorf("%s: alert of subscription %d, want %d", step, last.Subscription.ID, sub.ID)
+		}
+	}
+
+	netflix := subscribe(t, service, ctx, "Netflix", 400, month, month)
+	alerts("under the limit", 0, en.Subscription{})
+
+	spotify := subscribe(t, service, ctx, "Spotify", 300, month, month)
+	alerts("over the limit", 1, spotify)
+
+	price := 350
+	if err := service.UpdateSubscription(ctx, testutil.UserID, "Spotify", &price, nil, nil, nil); err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+	alerts("still over the limit", 1, spotify)
+
+	budget.Limit = 600
+	if _, err := service.UpdateBudget(ctx, budget); err != nil {
+		t.Fatalf("UpdateBudget: %v", err)
+	}
+	if err := service.UpdateSubscription(ctx, testutil.UserID, "Spotify", &price, nil, nil, nil); err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+	alerts("over the changed budget", 2, spotify)
+	if events := storage.BudgetAlerts(); events[0].ID == events[1].ID {
+		t.Errorf("alerts for different limits share the event ID %s", events[0].ID)
+	}
+
+	// Paying more than billed raises the projection too.
+	budget.Limit = 800
+	if _, err := service.UpdateBudget(ctx, budget); err != nil {
+		t.Fatalf("UpdateBudget: %v", err)
+	}
+	paid := 400
+	if _, err := service.MarkCharge(ctx, netflix.ID, month, en.ChargeMark{Status: en.ChargeStatusPaid, Amount: &paid}); err != nil {
+		t.Fatalf("MarkCharge: %v", err)
+	}
+	alerts("paid as billed", 2, spotify)
+	paid = 500
+	if _, err := service.MarkCharge(ctx, netflix.ID, month, en.ChargeMark{Status: en.ChargeStatusPaid, Amount: &paid}); err != nil {
+		t.Fatalf("MarkCharge: %v", err)
+	}
+	alerts("paid over the bill", 3, netflix)
+}
@@ -0,0 +1,98 @@
+package cases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+func TestMarkCharge(t *testing.T) {
+	service, ctx := newBudgetService(t)
+	netflix := subscribe(t, service, ctx, "Netflix", 400, "01-2025", "03-2025")
+	amount := func(v int) *int { return &v }
+	paidOn := time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	// The steps run in order on the February charge.
+	for _, step := range []struct {
+		name       string
+		mark       en.ChargeMark
+		wantErr    error
+		wantStatus en.ChargeStatus
+		wantPaid   int
+	}{
+		{name: "unknown status", mark: en.ChargeMark{Status: "lost"}, wantErr: en.ErrInvalidCharge},
+		{name: "negative amount", mark: en.ChargeMark{Status: en.ChargeStatusPaid, Amount: amount(-1)}, wantErr: en.ErrInvalidCharge},
+		{name: "amount on a skip", mark: en.ChargeMark{Status: en.ChargeStatusSkipped, Amount: amount(400)}, wantErr: en.ErrInvalidCharge},
+		{name: "paid_on on a dispute", mark: en.ChargeMark{Status: en.ChargeStatusDisputed, PaidOn: &paidOn}, wantErr: en.ErrInvalidCharge},
+		{name: "refund before payment", mark: en.ChargeMark{Status: en.ChargeStatusRefunded}, wantErr: en.ErrChargeTransition},
+		{name: "skip", mark: en.ChargeMark{Status: en.ChargeStatusSkipped}, wantStatus: en.ChargeStatusSkipped},
+		{name: "dispute a skipped month", mark: en.ChargeMark{Status: en.ChargeStatusDisputed}, wantErr: en.ErrChargeTransition},
+		{name: "pay a skipped month", mark: en.ChargeMark{Status: en.ChargeStatusPaid, Amount: amount(350), PaidOn: &paidOn}, wantStatus: en.ChargeStatusPaid, wantPaid: 350},
+		{name: "correct the payment", mark: en.ChargeMark{Status: en.ChargeStatusPaid, Amount: amount(380), PaidOn: &paidOn}, wantStatus: en.ChargeStatusPaid, wantPaid: 380},
+		{name: "dispute", mark: en.ChargeMark{Status: en.ChargeStatusDisputed}, wantStatus: en.ChargeStatusDisputed, wantPaid: 380},
+		{name: "skip a disputed month", mark: en.ChargeMark{Status: en.ChargeStatusSkipped}, wantErr: en.ErrChargeTransition},
+		{name: "refund", mark: en.ChargeMark{Status: en.ChargeStatusRefunded}, wantStatus: en.ChargeStatusRefunded, wantPaid: 380},
+		{name: "pay a refunded month", mark: en.ChargeMark{Status: en.ChargeStatusPaid}, wantErr: en.ErrChargeTransition},
+	} {
+		charge, err := service.MarkCharge(ctx, netflix.ID, "02-2025", step.mark)
+		if step.wantErr != nil {
+			if !errors.Is(err, step.wantErr) {
+				t.Errorf("%s: MarkCharge = %v, want %v", step.name, err, step.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: MarkCharge: %v", step.name, err)
+		}
+		if charge.Status != step.wantStatus || charge.Amount != 400 {
+			t.Errorf("%s: charge = %s for %d, want %s for 400", step.name, charge.Status, charge.Amount, step.wantStatus)
+		}
+		if step.wantPaid == 0 {
+			if charge.PaidAmount != nil || charge.PaidOn != nil {
+				t.Errorf("%s: unpaid charge has payment %v on %v", step.name, charge.PaidAmount, charge.PaidOn)
+			}
+			continue
+		}
+		if charge.PaidAmount == nil || *charge.PaidAmount != step.wantPaid || charge.PaidOn == nil || !charge.PaidOn.Equal(paidOn) {
+			t.Errorf("%s: payment = %v on %v, want %d on %s", step.name, charge.PaidAmount, charge.PaidOn, step.wantPaid, paidOn.Format(time.DateOnly))
+		}
+	}
+
+	// A payment defaults to the charge's amount today.
+	charge, err := service.MarkCharge(ctx, netflix.ID, "03-2025", en.ChargeMark{Status: en.ChargeStatusPaid})
+	if err != nil {
+		t.Fatalf("MarkCharge with defaults: %v", err)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if charge.PaidAmount == nil || *charge.PaidAmount != 400 || charge.PaidOn == nil || !charge.PaidOn.Equal(today) {
+		t.Errorf("default payment = %v on %v, want 400 today", charge.PaidAmount, charge.PaidOn)
+	}
+
+	// Refunded February is neither expected nor paid any more.
+	if total, err := service.GetTotalCostByPeriod(ctx, testutil.UserID, "", "01-2025", "03-2025", en.CostModeActual); err != nil || total != 400 {
+		t.Errorf("actual total = %d, %v; want the 400 paid for March", total, err)
+	}
+	if total, err := service.GetTotalCostByPeriod(ctx, testutil.UserID, "", "01-2025", "03-2025", en.CostModeExpected); err != nil || total != 3*400 {
+		t.Errorf("expected total = %d, %v; want %d", total, err, 3*400)
+	}
+
+	for name, call := range map[string]func() error{
+		"month not billed": func() error {
+			_, err := service.MarkCharge(ctx, netflix.ID, "04-2025", en.ChargeMark{Status: en.ChargeStatusSkipped})
+			return err
+		},
+		"other tenant": func() error {
+			_, err := service.MarkCharge(en.WithTenant(context.Background(), "globex"), netflix.ID, "01-2025", en.ChargeMark{Status: en.ChargeStatusSkipped})
+			return err
+		},
+	} {
+		if err := call(); !errors.Is(err, en.ErrChargeNotFound) {
+			t.Errorf("%s: MarkCharge = %v, want ErrChargeNotFound", name, err)
+		}
+	}
+}
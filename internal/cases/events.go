@@ -0,0 +1,117 @@
+package cases
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// EventPublisher hands subscription events to downstream consumers. Events
+// may be published more than once; implementations should be idempotent on
+// the event ID where they can.
+type EventPublisher interface {
+	Publish(ctx context.Context, event en.Event) error
+}
+
+// newEvent describes a change of sub in the tenant from ctx.
+func newEvent(ctx context.Context, eventType en.EventType, sub en.Subscription) en.Event {
+	tenantID, _ := en.TenantFromContext(ctx)
+	if tenantID == "" {
+		tenantID = sub.TenantID
+	}
+	return en.Event{
+		ID:           uuid.NewString(),
+		Type:         eventType,
+		TenantID:     tenantID,
+		UserID:       sub.UserID,
+		Subscription: sub,
+		OccurredAt:   time.Now().UTC(),
+	}
+}
+
+// eventEnvelope is the JSON document consumers receive for every event.
+type eventEnvelope struct {
+	ID         string              `json:"id"`
+	Type       en.EventType        `json:"type"`
+	TenantID   string              `json:"tenant_id"`
+	OccurredAt string              `json:"occurred_at"`
+	Data       subscriptionPayload `json:"data"`
+	// Budget is the exceeded budget of a budget.exceeded event; Data is the
+	// subscription whose change exceeded it.
+	Budget *budgetPayload `json:"budget,omitempty"`
+}
+
+type subscriptionPayload struct {
+	ID          int64  `json:"id,omitempty"`
+	UserID      string `json:"user_id"`
+	ServiceName string `json:"service_name"`
+	Price       int    `json:"price"`
+	StartDate   string `json:"start_date,omitempty"`
+	EndDate     string `json:"end_date,omitempty"`
+	DeletedAt   string `json:"deleted_at,omitempty"`
+	AutoRenew   bool   `json:"auto_renew"`
+	ExpiredAt   string `json:"expired_at,omitempty"`
+	// BilledThrough is the month charged by a subscription.charged event.
+	BilledThrough string `json:"billed_through,omitempty"`
+}
+
+type budgetPayload struct {
+	ID        int64    `json:"id"`
+	Name      string   `json:"name"`
+	Limit     int      `json:"limit"`
+	Currency  string   `json:"currency"`
+	Services  []string `json:"services,omitempty"`
+	Period    string   `json:"period"`
+	Spent     int      `json:"spent"`
+	Projected int      `json:"projected"`
+}
+
+// EncodeEvent renders event as the JSON document sent to consumers.
+func EncodeEvent(event en.Event) ([]byte, error) {
+	sub := event.Subscription
+	data := subscriptionPayload{
+		ID:            sub.ID,
+		UserID:        sub.UserID,
+		ServiceName:   sub.ServiceName,
+		Price:         sub.Price,
+		StartDate:     sub.StartDate,
+		EndDate:       sub.EndDate,
+		AutoRenew:     sub.AutoRenew,
+		BilledThrough: sub.BilledThrough,
+	}
+	if sub.DeletedAt != nil {
+		data.DeletedAt = sub.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	if sub.ExpiredAt != nil {
+		data.ExpiredAt = sub.ExpiredAt.UTC().Format(time.RFC3339)
+	}
+	envelope := eventEnvelope{
+		ID:         event.ID,
+		Type:       event.Type,
+		TenantID:   event.TenantID,
+		OccurredAt: event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		Data:       data,
+	}
+	if b := event.Budget; b != nil {
+		envelope.Budget = &budgetPayload{
+			ID:        b.ID,
+			Name:      b.Name,
+			Limit:     b.Limit,
+			Currency:  b.Currency,
+			Services:  b.Services,
+			Period:    b.Period,
+			Spent:     b.Spent,
+			Projected: b.Projected,
+		}
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal event")
+	}
+	return payload, nil
+}
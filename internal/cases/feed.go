@@ -0,0 +1,116 @@
+package cases
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// FeedRepository stores the per-user change feed. Subscription changes are
+// recorded by the storage itself; other events are appended explicitly.
+type FeedRepository interface {
+	ListUserEvents(ctx context.Context, userID string, afterID int64, limit int) ([]en.FeedEvent, error)
+	LastUserEventID(ctx context.Context, userID string) (int64, error)
+	AppendUserEvent(ctx context.Context, event en.Event) error
+	PurgeUserEvents(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+// FeedNotifier wakes readers of a feed of the tenant from ctx when new events
+// may have been committed, by any replica.
+type FeedNotifier interface {
+	Subscribe(ctx context.Context, userID string) (<-chan struct{}, func(), error)
+}
+
+// FeedService serves users' change feeds: subscription changes and renewal
+// reminders, in commit order, resumable after the last event seen.
+type FeedService struct {
+	storage   FeedRepository
+	notifier  FeedNotifier
+	logger    *slog.Logger
+	retention time.Duration
+}
+
+func NewFeedService(storage FeedRepository, notifier FeedNotifier, logger *slog.Logger, retention time.Duration) (*FeedService, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if notifier == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "notifier")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	if retention <= 0 {
+		return nil, errors.New("retention must be positive")
+	}
+	return &FeedService{storage: storage, notifier: notifier, logger: logger, retention: retention}, nil
+}
+
+// Events returns up to limit events of the user's feed after afterID.
+func (s *FeedService) Events(ctx context.Context, userID string, afterID int64, limit int) ([]en.FeedEvent, error) {
+	ctx, span := tracer.Start(ctx, "FeedService.Events")
+	defer span.End()
+
+	events, err := s.storage.ListUserEvents(ctx, userID, afterID, limit)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.ListUserEvents"))
+	}
+	return events, nil
+}
+
+// LastEventID returns the position a new reader of the feed starts after.
+func (s *FeedService) LastEventID(ctx context.Context, userID string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "FeedService.LastEventID")
+	defer span.End()
+
+	id, err := s.storage.LastUserEventID(ctx, userID)
+	if err != nil {
+		return 0, tracing.Fail(span, errors.Wrap(err, "storage.LastUserEventID"))
+	}
+	return id, nil
+}
+
+// Watch signals on the returned channel when the user's feed may have grown;
+// stop must be called once the caller is done. Watch before reading, so that
+// nothing committed in between is missed.
+func (s *FeedService) Watch(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	wake, stop, err := s.notifier.Subscribe(ctx, userID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "notifier.Subscribe")
+	}
+	return wake, stop, nil
+}
+
+// Publish puts renewal reminders into the feed of the subscription's owner.
+// Subscription changes reach the feed from storage and are ignored here, so
+// the service can sit next to other EventPublishers.
+func (s *FeedService) Publish(ctx context.Context, event en.Event) error {
+	if event.Type != en.EventSubscriptionRenewing {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "FeedService.Publish")
+	defer span.End()
+
+	if err := s.storage.AppendUserEvent(en.WithTenant(ctx, event.TenantID), event); err != nil {
+		return tracing.Fail(span, errors.Wrap(err, "storage.AppendUserEvent"))
+	}
+	return nil
+}
+
+// PurgeOnce removes events older than the retention at now; it runs as a
+// scheduled job.
+func (s *FeedService) PurgeOnce(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "FeedService.PurgeOnce")
+	defer span.End()
+
+	purged, err := s.storage.PurgeUserEvents(ctx, now.Add(-s.retention))
+	if err != nil {
+		return 0, tracing.Fail(span, errors.Wrap(err, "storage.PurgeUserEvents"))
+	}
+	return purged, nil
+}
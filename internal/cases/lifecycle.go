@@ -0,0 +1,106 @@
+package cases
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+const (
+	// lifecyclePrincipal is recorded in the audit log for expiries and renewals.
+	lifecyclePrincipal = "system:lifecycle"
+	// monthLayout formats the MM-YYYY months of subscriptions.
+	monthLayout = "01-2006"
+)
+
+// SubscriptionLifecycle expires subscriptions whose end month has passed and
+// bills the others: auto-renewing subscriptions are extended one month at a
+// time and open-ended ones are billed every month, each charge emitting
+// subscription.charged. It runs as a scheduled job.
+type SubscriptionLifecycle struct {
+	storage SubRepository
+	logger  *slog.Logger
+}
+
+func NewSubscriptionLifecycle(storage SubRepository, logger *slog.Logger) (*SubscriptionLifecycle, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	return &SubscriptionLifecycle{storage: storage, logger: logger}, nil
+}
+
+// ProcessOnce brings the subscriptions of every tenant up to the month of
+// now and returns how many expired and how many charges were made. A
+// subscription that lapsed several months ago is charged for each of them.
+func (l *SubscriptionLifecycle) ProcessOnce(ctx context.Context, now time.Time) (expired, charged int, err error) {
+	ctx, span := tracer.Start(ctx, "SubscriptionLifecycle.ProcessOnce")
+	defer span.End()
+
+	month := now.UTC().Format(monthLayout)
+	subs, err := l.storage.ListLapsedSubs(ctx, month)
+	if err != nil {
+		return 0, 0, tracing.Fail(span, errors.Wrap(err, "storage.ListLapsedSubs"))
+	}
+	for _, sub := range subs {
+		if err := ctx.Err(); err != nil {
+			return expired, charged, tracing.Fail(span, err)
+		}
+		subCtx := en.WithPrincipal(en.WithTenant(ctx, sub.TenantID), lifecyclePrincipal)
+		if !sub.AutoRenew && sub.EndDate != "" {
+			if _, err := l.storage.ExpireSub(subCtx, sub.ID, month, newAuditEntry(subCtx, en.AuditActionExpire)); err != nil {
+				l.logFailure(ctx, "failed to expire subscription", sub, err)
+				continue
+			}
+			expired++
+			continue
+		}
+		for lapsed(sub, month) {
+			renewed, err := l.storage.RenewSub(subCtx, sub.ID, month, newAuditEntry(subCtx, en.AuditActionRenew))
+			if err != nil {
+				l.logFailure(ctx, "failed to renew subscription", sub, err)
+				break
+			}
+			sub = renewed
+			charged++
+		}
+	}
+	if expired > 0 || charged > 0 {
+		l.logger.InfoContext(ctx, "subscription lifecycle processed", "month", month, "expired", expired, "charged", charged)
+	}
+	return expired, charged, nil
+}
+
+// logFailure logs err unless the subscription changed since it was listed.
+func (l *SubscriptionLifecycle) logFailure(ctx context.Context, msg string, sub en.Subscription, err error) {
+	if errors.Is(err, en.ErrSubscriptionNotFound) {
+		return
+	}
+	l.logger.ErrorContext(ctx, msg, "subscription_id", sub.ID, "tenant_id", sub.TenantID, "error", err)
+}
+
+// lapsed mirrors the storage's condition for a subscription needing another
+// charge in month: its end month or, when open-ended, its last billed month
+// is before month.
+func lapsed(sub en.Subscription, month string) bool {
+	current, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return false
+	}
+	last := sub.EndDate
+	if last == "" {
+		last = sub.BilledThrough
+	}
+	if last == "" {
+		return true
+	}
+	t, err := time.Parse(monthLayout, last)
+	return err == nil && t.Before(current)
+}
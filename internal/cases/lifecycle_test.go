@@ -0,0 +1,175 @@
+package cases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+func TestLifecycleExpiresOrRenews(t *testing.T) {
+	created := time.Date(2025, 11, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		sub  en.Subscription
+		// runs are the times ProcessOnce runs at, in order.
+		runs        []time.Time
+		wantExpired int
+		wantCharged int
+		wantEnd     string
+		wantBilled  string
+	}{
+		{
+			name:        "ended without auto-renew expires",
+			sub:         en.Subscription{StartDate: "01-2025", EndDate: "11-2025"},
+			runs:        []time.Time{time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)},
+			wantExpired: 1,
+			wantEnd:     "11-2025",
+		},
+		{
+			name:    "ending this month is not lapsed",
+			sub:     en.Subscription{StartDate: "01-2025", EndDate: "12-2025"},
+			runs:    []time.Time{time.Date(2025, 12, 31, 23, 59, 0, 0, time.UTC)},
+			wantEnd: "12-2025",
+		},
+		{
+			name:        "auto-renew crosses the year",
+			sub:         en.Subscription{StartDate: "01-2025", EndDate: "12-2025", AutoRenew: true},
+			runs:        []time.Time{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			wantCharged: 1,
+			wantEnd:     "01-2026",
+			wantBilled:  "01-2026",
+		},
+		{
+			name:        "auto-renew catches up on every lapsed month",
+			sub:         en.Subscription{StartDate: "01-2025", EndDate: "10-2025", AutoRenew: true},
+			runs:        []time.Time{time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)},
+			wantCharged: 4,
+			wantEnd:     "02-2026",
+			wantBilled:  "02-2026",
+		},
+		{
+			name: "a second run in the same month charges nothing",
+			sub:  en.Subscription{StartDate: "01-2025", EndDate: "11-2025", AutoRenew: true},
+			runs: []time.Time{
+				time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2025, 12, 20, 0, 0, 0, 0, time.UTC),
+			},
+			wantCharged: 1,
+			wantEnd:     "12-2025",
+			wantBilled:  "12-2025",
+		},
+		{
+			name:       "open-ended is billed through the month of creation",
+			sub:        en.Subscription{StartDate: "03-2025"},
+			runs:       []time.Time{created.AddDate(0, 0, 10)},
+			wantBilled: "11-2025",
+		},
+		{
+			name:        "open-ended is billed for every month across the year",
+			sub:         en.Subscription{StartDate: "03-2025"},
+			runs:        []time.Time{time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+			wantCharged: 3,
+			wantBilled:  "02-2026",
+		},
+		{
+			name:        "open-ended ignores auto-renew",
+			sub:         en.Subscription{StartDate: "03-2025", AutoRenew: true},
+			runs:        []time.Time{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			wantCharged: 2,
+			wantBilled:  "01-2026",
+		},
+		{
+			name: "open-ended starting later is billed from its start",
+			sub:  en.Subscription{StartDate: "01-2026"},
+			runs: []time.Time{
+				time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			wantCharged: 1,
+			wantBilled:  "01-2026",
+		},
+		{
+			// 1 January 01:00 in Moscow is still December in UTC.
+			name:        "the month is taken in UTC",
+			sub:         en.Subscription{StartDate: "01-2025", EndDate: "11-2025", AutoRenew: true},
+			runs:        []time.Time{time.Date(2026, 1, 1, 1, 0, 0, 0, time.FixedZone("MSK", 3*60*60))},
+			wantCharged: 1,
+			wantEnd:     "12-2025",
+			wantBilled:  "12-2025",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := created
+			storage := testutil.NewStorage(testutil.WithClock(func() time.Time { return clock }))
+			service := testutil.NewService(t, storage)
+			lifecycle, err := cases.NewSubscriptionLifecycle(storage, testutil.Logger())
+			if err != nil {
+				t.Fatalf("NewSubscriptionLifecycle: %v", err)
+			}
+			ctx := en.WithTenant(context.Background(), "acme")
+			sub := tt.sub
+			sub.UserID, sub.ServiceName, sub.Price = testutil.UserID, "Netflix", 400
+			if _, err := service.CreateSubscription(ctx, sub); err != nil {
+				t.Fatalf("CreateSubscription: %v", err)
+			}
+
+			expired, charged := 0, 0
+			for _, now := range tt.runs {
+				clock = now
+				e, c, err := lifecycle.ProcessOnce(context.Background(), now)
+				if err != nil {
+					t.Fatalf("ProcessOnce at %s: %v", now, err)
+				}
+				expired, charged = expired+e, charged+c
+			}
+			if expired != tt.wantExpired || charged != tt.wantCharged {
+				t.Errorf("expired %d and charged %d, want %d and %d", expired, charged, tt.wantExpired, tt.wantCharged)
+			}
+			got, err := storage.GetSub(ctx, testutil.UserID, "Netflix")
+			if err != nil {
+				t.Fatalf("GetSub: %v", err)
+			}
+			if got.EndDate != tt.wantEnd || got.BilledThrough != tt.wantBilled || (got.ExpiredAt != nil) != (tt.wantExpired > 0) {
+				t.Errorf("subscription ends %q, billed through %q, expired at %v; want %q, %q, expired %v",
+					got.EndDate, got.BilledThrough, got.ExpiredAt, tt.wantEnd, tt.wantBilled, tt.wantExpired > 0)
+			}
+		})
+	}
+}
+
+func TestLifecycleSkipsDeletedAndAuditsAsItself(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	storage := testutil.NewStorage(testutil.WithClock(func() time.Time { return now }))
+	service := testutil.NewService(t, storage)
+	lifecycle, err := cases.NewSubscriptionLifecycle(storage, testutil.Logger())
+	if err != nil {
+		t.Fatalf("NewSubscriptionLifecycle: %v", err)
+	}
+	acme, globex := en.WithTenant(context.Background(), "acme"), en.WithTenant(context.Background(), "globex")
+	subscribe(t, service, acme, "Netflix", 400, "01-2025", "11-2025")
+	subscribe(t, service, globex, "Netflix", 400, "01-2025", "11-2025")
+	subscribe(t, service, acme, "Spotify", 300, "01-2025", "11-2025")
+	if err := service.DeleteSubscription(acme, testutil.UserID, "Spotify"); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+
+	expired, charged, err := lifecycle.ProcessOnce(context.Background(), now)
+	if err != nil {
+		t.Fatalf("ProcessOnce: %v", err)
+	}
+	if expired != 2 || charged != 0 {
+		t.Errorf("expired %d and charged %d, want both tenants' Netflix expired and nothing charged", expired, charged)
+	}
+	entries, err := storage.ListAudit(acme, en.AuditFilter{Action: en.AuditActionExpire})
+	if err != nil {
+		t.Fatalf("ListAudit: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Principal != "system:lifecycle" {
+		t.Errorf("acme's expiry audit = %+v, want one entry by system:lifecycle", entries)
+	}
+}
@@ -0,0 +1,182 @@
+package cases
+
+import (
+	"context"
+	"log/slog"
+	"net/mail"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// NotificationRepository stores notification settings and remembers which
+// reminders went out.
+type NotificationRepository interface {
+	GetNotificationSettings(ctx context.Context, userID string) (en.NotificationSettings, error)
+	PutNotificationSettings(ctx context.Context, settings en.NotificationSettings) (en.NotificationSettings, error)
+	// ListDueRenewalReminders returns the renewals of all tenants within their
+	// owners' lead time that were not reminded of through channel yet.
+	ListDueRenewalReminders(ctx context.Context, now time.Time, channel en.NotificationChannel) ([]en.RenewalReminder, error)
+	ClaimRenewalReminder(ctx context.Context, reminder en.RenewalReminder, channel en.NotificationChannel) (bool, error)
+	ReleaseRenewalReminder(ctx context.Context, reminder en.RenewalReminder, channel en.NotificationChannel) error
+}
+
+// ReminderRenderer turns a reminder into a message in the user's locale.
+type ReminderRenderer interface {
+	RenderRenewalReminder(reminder en.RenewalReminder) (en.Notification, error)
+}
+
+// Notifier delivers a rendered notification through its channel.
+type Notifier interface {
+	Notify(ctx context.Context, notification en.Notification) error
+}
+
+// NotificationService manages users' notification settings.
+type NotificationService struct {
+	storage NotificationRepository
+	logger  *slog.Logger
+}
+
+func NewNotificationService(storage NotificationRepository, logger *slog.Logger) (*NotificationService, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	return &NotificationService{storage: storage, logger: logger}, nil
+}
+
+func (s *NotificationService) GetSettings(ctx context.Context, userID string) (en.NotificationSettings, error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.GetSettings")
+	defer span.End()
+
+	settings, err := s.storage.GetNotificationSettings(ctx, userID)
+	if err != nil {
+		return en.NotificationSettings{}, tracing.Fail(span, errors.Wrap(err, "storage.GetNotificationSettings"))
+	}
+	return settings, nil
+}
+
+// UpdateSettings validates and stores the user's settings; an empty locale
+// and a zero lead time take the defaults.
+func (s *NotificationService) UpdateSettings(ctx context.Context, settings en.NotificationSettings) (en.NotificationSettings, error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.UpdateSettings")
+	defer span.End()
+
+	if settings.Locale == "" {
+		settings.Locale = en.Locales[0]
+	}
+	if settings.LeadDays == 0 {
+		settings.LeadDays = en.DefaultLeadDays
+	}
+	if err := validateNotificationSettings(&settings); err != nil {
+		return en.NotificationSettings{}, tracing.Fail(span, err)
+	}
+	updated, err := s.storage.PutNotificationSettings(ctx, settings)
+	if err != nil {
+		return en.NotificationSettings{}, tracing.Fail(span, errors.Wrap(err, "storage.PutNotificationSettings"))
+	}
+	s.logger.InfoContext(ctx, "notification settings updated", "user_id", settings.UserID, "enabled", settings.Enabled, "principal", en.AuditPrincipal(ctx))
+	return updated, nil
+}
+
+// validateNotificationSettings also normalizes the email to its bare address.
+func validateNotificationSettings(settings *en.NotificationSettings) error {
+	addr, err := mail.ParseAddress(settings.Email)
+	if err != nil {
+		return errors.Wrapf(en.ErrInvalidNotificationSettings, "email %q", settings.Email)
+	}
+	settings.Email = addr.Address
+	known := false
+	for _, locale := range en.Locales {
+		known = known || settings.Locale == locale
+	}
+	if !known {
+		return errors.Wrapf(en.ErrInvalidNotificationSettings, "unsupported locale %q", settings.Locale)
+	}
+	if settings.LeadDays < 1 || settings.LeadDays > en.MaxLeadDays {
+		return errors.Wrapf(en.ErrInvalidNotificationSettings, "lead_days must be between 1 and %d", en.MaxLeadDays)
+	}
+	return nil
+}
+
+// ReminderSender reminds users of renewals within their lead time through
+// one channel; it runs as a scheduled job. A reminder is claimed in storage before it is
+// sent and released if sending fails, so concurrent runs and replicas send it
+// once and failures are retried on the next run.
+type ReminderSender struct {
+	storage  NotificationRepository
+	renderer ReminderRenderer
+	notifier Notifier
+	channel  en.NotificationChannel
+	logger   *slog.Logger
+}
+
+func NewReminderSender(storage NotificationRepository, renderer ReminderRenderer, notifier Notifier, channel en.NotificationChannel, logger *slog.Logger) (*ReminderSender, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if renderer == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "renderer")
+	}
+	if notifier == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "notifier")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	if channel == "" {
+		return nil, errors.New("channel is required")
+	}
+	return &ReminderSender{storage: storage, renderer: renderer, notifier: notifier, channel: channel, logger: logger}, nil
+}
+
+// SendOnce sends the reminders due at now and returns how many were sent.
+func (s *ReminderSender) SendOnce(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracer.Start(ctx, "ReminderSender.SendOnce")
+	defer span.End()
+
+	reminders, err := s.storage.ListDueRenewalReminders(ctx, now, s.channel)
+	if err != nil {
+		return 0, tracing.Fail(span, errors.Wrap(err, "storage.ListDueRenewalReminders"))
+	}
+	sent := 0
+	for _, reminder := range reminders {
+		ok, err := s.send(en.WithTenant(ctx, reminder.Subscription.TenantID), reminder)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to send renewal reminder", "subscription_id", reminder.Subscription.ID, "channel", s.channel, "error", err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// send reports false for a reminder another run claimed first.
+func (s *ReminderSender) send(ctx context.Context, reminder en.RenewalReminder) (bool, error) {
+	notification, err := s.renderer.RenderRenewalReminder(reminder)
+	if err != nil {
+		return false, errors.Wrap(err, "render reminder")
+	}
+	claimed, err := s.storage.ClaimRenewalReminder(ctx, reminder, s.channel)
+	if err != nil {
+		return false, errors.Wrap(err, "storage.ClaimRenewalReminder")
+	}
+	if !claimed {
+		return false, nil
+	}
+	if err := s.notifier.Notify(ctx, notification); err != nil {
+		if releaseErr := s.storage.ReleaseRenewalReminder(ctx, reminder, s.channel); releaseErr != nil {
+			s.logger.ErrorContext(ctx, "failed to release renewal reminder, it will not be retried", "subscription_id", reminder.Subscription.ID, "error", releaseErr)
+		}
+		return false, errors.Wrap(err, "notifier.Notify")
+	}
+	s.logger.InfoContext(ctx, "renewal reminder sent", "subscription_id", reminder.Subscription.ID, "user_id", reminder.Subscription.UserID, "channel", s.channel, "renews_on", reminder.RenewsOn.Format(time.DateOnly))
+	return true, nil
+}
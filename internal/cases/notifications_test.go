@@ -0,0 +1,154 @@
+package cases
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// fakeReminders lists every reminder to every run, as if each run polled
+// before the others had claimed anything; claims are per subscription, channel
+// and renewal date, as in renewal_notifications.
+type fakeReminders struct {
+	NotificationRepository
+
+	mu       sync.Mutex
+	due      []en.RenewalReminder
+	claimed  map[string]bool
+	released int
+}
+
+func reminderKey(r en.RenewalReminder, channel en.NotificationChannel) string {
+	return r.Subscription.TenantID + "/" + r.Subscription.UserID + "/" + r.Subscription.ServiceName + "/" + string(channel) + "/" + r.RenewsOn.Format(time.DateOnly)
+}
+
+func (f *fakeReminders) ListDueRenewalReminders(context.Context, time.Time, en.NotificationChannel) ([]en.RenewalReminder, error) {
+	return f.due, nil
+}
+
+func (f *fakeReminders) ClaimRenewalReminder(ctx context.Context, r en.RenewalReminder, channel en.NotificationChannel) (bool, error) {
+	if tenant, err := en.TenantFromContext(ctx); err != nil || tenant != r.Subscription.TenantID {
+		return false, errors.New("claim outside the reminder's tenant")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := reminderKey(r, channel)
+	if f.claimed[key] {
+		return false, nil
+	}
+	f.claimed[key] = true
+	return true, nil
+}
+
+func (f *fakeReminders) ReleaseRenewalReminder(_ context.Context, r en.RenewalReminder, channel en.NotificationChannel) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.claimed, reminderKey(r, channel))
+	f.released++
+	return nil
+}
+
+type fakeRenderer struct{}
+
+func (fakeRenderer) RenderRenewalReminder(r en.RenewalReminder) (en.Notification, error) {
+	return en.Notification{UserID: r.Subscription.UserID, To: r.Settings.Email, Subject: r.Subscription.ServiceName}, nil
+}
+
+// fakeNotifier records notifications and fails those for the services in fail.
+type fakeNotifier struct {
+	mu   sync.Mutex
+	sent []string
+	fail map[string]bool
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, notification en.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.fail[notification.Subject] {
+		return errors.New("smtp: 451 try again later")
+	}
+	n.sent = append(n.sent, notification.Subject)
+	return nil
+}
+
+func newReminders(services ...string) *fakeReminders {
+	f := &fakeReminders{claimed: map[string]bool{}}
+	for _, service := range services {
+		f.due = append(f.due, en.RenewalReminder{
+			Subscription: en.Subscription{TenantID: "acme", UserID: "60601fee-2bf1-4721-ae6f-7636e79a0cba", ServiceName: service},
+			Settings:     en.NotificationSettings{Email: "user@example.com", Locale: en.LocaleEN, LeadDays: 3},
+			RenewsOn:     time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	return f
+}
+
+func newTestReminderSender(t *testing.T, storage NotificationRepository, notifier Notifier) *ReminderSender {
+	t.Helper()
+	s, err := NewReminderSender(storage, fakeRenderer{}, notifier, en.ChannelEmail, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewReminderSender: %v", err)
+	}
+	return s
+}
+
+func TestReminderSenderSendsOnceAcrossConcurrentRuns(t *testing.T) {
+	storage := newReminders("Netflix", "Spotify", "YouTube")
+	notifier := &fakeNotifier{}
+	now := time.Date(2025, time.February, 26, 9, 0, 0, 0, time.UTC)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total int
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent, err := newTestReminderSender(t, storage, notifier).SendOnce(context.Background(), now)
+			if err != nil {
+				t.Errorf("SendOnce: %v", err)
+			}
+			mu.Lock()
+			total += sent
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if total != 3 || len(notifier.sent) != 3 {
+		t.Errorf("runs reported %d sent and %d notifications went out, want 3 each", total, len(notifier.sent))
+	}
+	if sent, _ := newTestReminderSender(t, storage, notifier).SendOnce(context.Background(), now); sent != 0 {
+		t.Errorf("a later run sent %d reminders again", sent)
+	}
+}
+
+func TestReminderSenderReleasesFailedReminder(t *testing.T) {
+	storage := newReminders("Netflix", "Spotify")
+	notifier := &fakeNotifier{fail: map[string]bool{"Spotify": true}}
+	sender := newTestReminderSender(t, storage, notifier)
+	now := time.Date(2025, time.February, 26, 9, 0, 0, 0, time.UTC)
+
+	if sent, err := sender.SendOnce(context.Background(), now); err != nil || sent != 1 {
+		t.Fatalf("first run = %d, %v; want 1 sent", sent, err)
+	}
+	if storage.released != 1 {
+		t.Errorf("released %d claims, want the failed one", storage.released)
+	}
+
+	notifier.fail = nil
+	if sent, err := sender.SendOnce(context.Background(), now); err != nil || sent != 1 {
+		t.Fatalf("retry = %d, %v; want the failed reminder sent", sent, err)
+	}
+	if len(notifier.sent) != 2 || notifier.sent[1] != "Spotify" {
+		t.Errorf("notifications = %v, want Netflix then Spotify", notifier.sent)
+	}
+}
@@ -0,0 +1,116 @@
+package cases
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// OutboxRepository reads the events that mutations recorded in their transactions.
+type OutboxRepository interface {
+	// ProcessOutbox passes up to limit due events to publish, at most one per
+	// subscription, marking published ones and postponing failed ones by
+	// backoff(failures). It returns how many were published.
+	ProcessOutbox(ctx context.Context, limit int, backoff func(failures int) time.Duration, publish func(ctx context.Context, event en.Event) error) (int, error)
+	PurgeOutbox(ctx context.Context, publishedBefore time.Time) (int64, error)
+}
+
+// RelayOptions tune the outbox relay.
+type RelayOptions struct {
+	// Interval between polls of the outbox.
+	Interval time.Duration
+	// BatchSize caps the events published per poll.
+	BatchSize int
+	// InitialBackoff is the delay after the first failed publish; it doubles
+	// with every further failure up to MaxBackoff. Events are never dropped.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Retention keeps published events around for consumers catching up.
+	Retention time.Duration
+}
+
+// OutboxRelay publishes outbox events through an EventPublisher with
+// at-least-once semantics: consumers must tolerate duplicates, which carry
+// the same event ID. Several replicas may run it concurrently.
+type OutboxRelay struct {
+	storage   OutboxRepository
+	publisher EventPublisher
+	logger    *slog.Logger
+	opts      RelayOptions
+}
+
+func NewOutboxRelay(storage OutboxRepository, publisher EventPublisher, logger *slog.Logger, opts RelayOptions) (*OutboxRelay, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if publisher == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "publisher")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	if opts.Interval <= 0 || opts.BatchSize <= 0 || opts.InitialBackoff <= 0 || opts.MaxBackoff < opts.InitialBackoff || opts.Retention <= 0 {
+		return nil, errors.New("invalid outbox relay options")
+	}
+	return &OutboxRelay{storage: storage, publisher: publisher, logger: logger, opts: opts}, nil
+}
+
+// RelayOnce publishes one batch of due events and returns how many were published.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRelay.RelayOnce")
+	defer span.End()
+
+	published, err := r.storage.ProcessOutbox(ctx, r.opts.BatchSize, r.backoff, func(ctx context.Context, event en.Event) error {
+		return r.publisher.Publish(en.WithTenant(ctx, event.TenantID), event)
+	})
+	if err != nil {
+		return 0, tracing.Fail(span, errors.Wrap(err, "storage.ProcessOutbox"))
+	}
+	return published, nil
+}
+
+// backoff returns the delay after the given number of failed publishes.
+func (r *OutboxRelay) backoff(failures int) time.Duration {
+	delay := r.opts.InitialBackoff
+	for i := 1; i < failures && delay < r.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.opts.MaxBackoff {
+		delay = r.opts.MaxBackoff
+	}
+	return delay
+}
+
+// Run relays on every interval tick until ctx is cancelled, polling again at
+// once after a full batch, and purges old published events hourly.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-purge.C:
+			if _, err := r.storage.PurgeOutbox(ctx, now.Add(-r.opts.Retention)); err != nil {
+				r.logger.ErrorContext(ctx, "outbox purge failed", "error", err)
+			}
+		case <-ticker.C:
+			for {
+				n, err := r.RelayOnce(ctx)
+				if err != nil {
+					r.logger.ErrorContext(ctx, "outbox relay failed", "error", err)
+				}
+				if err != nil || n < r.opts.BatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
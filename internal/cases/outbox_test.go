@@ -0,0 +1,97 @@
+package cases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+// fakeOutbox hands its events to the relay's publish and keeps the backoff
+// the relay asked for.
+type fakeOutbox struct {
+	events  []en.Event
+	backoff func(failures int) time.Duration
+	failed  []string
+}
+
+func (o *fakeOutbox) ProcessOutbox(ctx context.Context, limit int, backoff func(failures int) time.Duration, publish func(ctx context.Context, event en.Event) error) (int, error) {
+	o.backoff = backoff
+	published := 0
+	for _, event := range o.events {
+		if err := publish(ctx, event); err != nil {
+			o.failed = append(o.failed, event.ID)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
+
+func (o *fakeOutbox) PurgeOutbox(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+type publisherFunc func(ctx context.Context, event en.Event) error
+
+func (f publisherFunc) Publish(ctx context.Context, event en.Event) error { return f(ctx, event) }
+
+func TestOutboxRelayPublishesInTheEventsTenant(t *testing.T) {
+	outbox := &fakeOutbox{events: []en.Event{
+		{ID: "1", TenantID: "acme"},
+		{ID: "2", TenantID: "globex"},
+	}}
+	publisher := publisherFunc(func(ctx context.Context, event en.Event) error {
+		tenant, err := en.TenantFromContext(ctx)
+		if err != nil || tenant != event.TenantID {
+			t.Errorf("event %s published in tenant %q, %v; want %s", event.ID, tenant, err, event.TenantID)
+		}
+		if event.ID == "2" {
+			return errors.New("broker is down")
+		}
+		return nil
+	})
+	relay, err := cases.NewOutboxRelay(outbox, publisher, testutil.Logger(), cases.RelayOptions{
+		Interval: time.Second, BatchSize: 10, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Retention: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewOutboxRelay: %v", err)
+	}
+	published, err := relay.RelayOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RelayOnce: %v", err)
+	}
+	if published != 1 || len(outbox.failed) != 1 || outbox.failed[0] != "2" {
+		t.Errorf("published %d, failed %v; want 1 published and event 2 failed", published, outbox.failed)
+	}
+}
+
+func TestOutboxRelayBackoffDoublesUpToTheCap(t *testing.T) {
+	outbox := &fakeOutbox{}
+	relay, err := cases.NewOutboxRelay(outbox, publisherFunc(func(context.Context, en.Event) error { return nil }), testutil.Logger(), cases.RelayOptions{
+		Interval: time.Second, BatchSize: 10, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Retention: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewOutboxRelay: %v", err)
+	}
+	if _, err := relay.RelayOnce(context.Background()); err != nil {
+		t.Fatalf("RelayOnce: %v", err)
+	}
+	for failures, want := range map[int]time.Duration{
+		1:   time.Second,
+		2:   2 * time.Second,
+		3:   4 * time.Second,
+		4:   8 * time.Second,
+		5:   10 * time.Second,
+		100: 10 * time.Second,
+	} {
+		if got := outbox.backoff(failures); got != want {
+			t.Errorf("backoff after %d failures = %v, want %v", failures, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package cases
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// renewalNamespace derives stable IDs for renewal events, so repeated scans
+// of the same month publish the same event.
+var renewalNamespace = uuid.MustParse("4b7c1d0e-5f0a-4c52-9a3e-2f1d8c6b7a90")
+
+// RenewalAnnouncer publishes subscription.renewing for every subscription
+// whose last paid month is the current one; it runs as a scheduled job.
+type RenewalAnnouncer struct {
+	storage SubRepository
+	events  EventPublisher
+	logger  *slog.Logger
+}
+
+func NewRenewalAnnouncer(storage SubRepository, events EventPublisher, logger *slog.Logger) (*RenewalAnnouncer, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if events == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "events")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	return &RenewalAnnouncer{storage: storage, events: events, logger: logger}, nil
+}
+
+// AnnounceOnce publishes renewal events for subscriptions of every tenant
+// ending in the month of now and returns how many were published.
+func (a *RenewalAnnouncer) AnnounceOnce(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracer.Start(ctx, "RenewalAnnouncer.AnnounceOnce")
+	defer span.End()
+
+	month := now.Format("01-2006")
+	subs, err := a.storage.ListSubsEndingIn(ctx, month)
+	if err != nil {
+		return 0, tracing.Fail(span, errors.Wrap(err, "storage.ListSubsEndingIn"))
+	}
+	published := 0
+	for _, sub := range subs {
+		event := newEvent(en.WithTenant(ctx, sub.TenantID), en.EventSubscriptionRenewing, sub)
+		event.ID = uuid.NewSHA1(renewalNamespace, []byte(strconv.FormatInt(sub.ID, 10)+"/"+month)).String()
+		if err := a.events.Publish(ctx, event); err != nil {
+			a.logger.ErrorContext(ctx, "failed to publish renewal event", "subscription_id", sub.ID, "error", err)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
@@ -0,0 +1,44 @@
+package cases
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+	"github.com/pkg/errors"
+)
+
+// RetentionPurger removes subscriptions that stayed soft-deleted for longer
+// than the retention period; it runs as a scheduled job.
+type RetentionPurger struct {
+	storage   SubRepository
+	logger    *slog.Logger
+	retention time.Duration
+}
+
+func NewRetentionPurger(storage SubRepository, logger *slog.Logger, retention time.Duration) (*RetentionPurger, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	if retention <= 0 {
+		return nil, errors.New("retention must be positive")
+	}
+	return &RetentionPurger{storage: storage, logger: logger, retention: retention}, nil
+}
+
+// PurgeOnce removes everything deleted more than the retention period before now.
+func (p *RetentionPurger) PurgeOnce(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RetentionPurger.PurgeOnce")
+	defer span.End()
+
+	purged, err := p.storage.PurgeDeletedSubs(ctx, now.Add(-p.retention))
+	if err != nil {
+		return 0, tracing.Fail(span, errors.Wrap(err, "storage.PurgeDeletedSubs"))
+	}
+	return purged, nil
+}
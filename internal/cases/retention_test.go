@@ -0,0 +1,120 @@
+package cases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+func TestRetentionPurgerKeepsRecentDeletes(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := now
+	storage := testutil.NewStorage(testutil.WithClock(func() time.Time { return clock }))
+	service := testutil.NewService(t, storage)
+	ctx := en.WithTenant(context.Background(), "acme")
+	purger, err := cases.NewRetentionPurger(storage, testutil.Logger(), 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewRetentionPurger: %v", err)
+	}
+
+	for _, name := range []string{"Netflix", "Spotify", "Kinopoisk"} {
+		subscribe(t, service, ctx, name, 400, "01-2025", "12-2025")
+	}
+	for _, deletion := range []struct {
+		service string
+		at      time.Time
+	}{
+		{"Netflix", now.AddDate(0, 0, -40)},
+		{"Spotify", now.AddDate(0, 0, -10)},
+	} {
+		clock = deletion.at
+		if err := service.DeleteSubscription(ctx, testutil.UserID, deletion.service); err != nil {
+			t.Fatalf("DeleteSubscription %s: %v", deletion.service, err)
+		}
+	}
+
+	purged, err := purger.PurgeOnce(ctx, now)
+	if err != nil {
+		t.Fatalf("PurgeOnce: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d subscriptions, want only the one deleted 40 days ago", purged)
+	}
+	subs, err := service.GetListSubscriptions(ctx, testutil.UserID, true)
+	if err != nil {
+		t.Fatalf("GetListSubscriptions: %v", err)
+	}
+	var names []string
+	for _, sub := range subs {
+		names = append(names, sub.ServiceName)
+	}
+	if len(names) != 2 || names[0] != "Spotify" || names[1] != "Kinopoisk" {
+		t.Errorf("subscriptions after the purge = %v, want [Spotify Kinopoisk]", names)
+	}
+	// Netflix was billed through April, the month it was deleted in, and
+	// those charges count once it is purged; Spotify's stay hidden.
+	if total, err := service.GetTotalCostByPeriod(ctx, testutil.UserID, "", "01-2025", "12-2025", en.CostModeExpected); err != nil || total != (12+4)*400 {
+		t.Errorf("total after the purge = %d, %v; want %d", total, err, (12+4)*400)
+	}
+
+	if purged, err := purger.PurgeOnce(ctx, now); err != nil || purged != 0 {
+		t.Errorf("second PurgeOnce = %d, %v; want nothing left to purge", purged, err)
+	}
+}
+
+func TestNewRetentionPurgerRejectsNonPositiveRetention(t *testing.T) {
+	for _, retention := range []time.Duration{0, -time.Hour} {
+		if _, err := cases.NewRetentionPurger(testutil.NewStorage(), testutil.Logger(), retention); err == nil {
+			t.Errorf("NewRetentionPurger(%s) succeeded", retention)
+		}
+	}
+	if _, err := cases.NewRetentionPurger(nil, testutil.Logger(), time.Hour); !errors.Is(err, en.ErrNilDependency) {
+		t.Errorf("NewRetentionPurger without storage = %v, want ErrNilDependency", err)
+	}
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	service, ctx := newBudgetService(t)
+	sub := subscribe(t, service, ctx, "Netflix", 400, "01-2025", "12-2025")
+
+	if err := service.DeleteSubscription(ctx, testutil.UserID, "Netflix"); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+	if _, err := service.GetSubscription(ctx, testutil.UserID, "Netflix"); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("GetSubscription of a deleted subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+	if total, err := service.GetTotalCostByPeriod(ctx, testutil.UserID, "", "01-2025", "12-2025", en.CostModeExpected); err != nil || total != 0 {
+		t.Errorf("total of a deleted subscription = %d, %v; want 0", total, err)
+	}
+	if err := service.DeleteSubscription(ctx, testutil.UserID, "Netflix"); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("second DeleteSubscription = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	subscribe(t, service, ctx, "Netflix", 500, "01-2025", "12-2025")
+	if _, err := service.RestoreSubscription(ctx, sub.ID); !errors.Is(err, en.ErrSubscriptionExists) {
+		t.Errorf("RestoreSubscription over a live duplicate = %v, want ErrSubscriptionExists", err)
+	}
+	if err := service.DeleteSubscription(ctx, testutil.UserID, "Netflix"); err != nil {
+		t.Fatalf("DeleteSubscription of the duplicate: %v", err)
+	}
+
+	restored, err := service.RestoreSubscription(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("RestoreSubscription: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Price != 400 {
+		t.Errorf("restored = %+v, want the live subscription for 400", restored)
+	}
+	if _, err := service.RestoreSubscription(ctx, sub.ID); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("RestoreSubscription of a live subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+	if _, err := service.RestoreSubscription(en.WithTenant(context.Background(), "globex"), sub.ID); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("RestoreSubscription by another tenant = %v, want ErrSubscriptionNotFound", err)
+	}
+}
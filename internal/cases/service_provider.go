@@ -2,66 +2,318 @@ package cases
 
 import (
 	"context"
+	"log/slog"
+	"time"
 
 	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("github.com/100bench/subscription_aggregator/internal/cases")
+
+// ServiceProvider implements the subscription use cases. Every mutation also
+// records its event in the storage's outbox; the OutboxRelay publishes them.
 type ServiceProvider struct {
 	storage SubRepository
+	logger  *slog.Logger
+	// budgetAlerts checks budgets after changes.
+	budgetAlerts bool
+}
+
+// ServiceOption customizes optional ServiceProvider behaviour.
+type ServiceOption func(*ServiceProvider)
+
+// WithBudgetAlerts checks the user's budgets after a subscription is created
+// or updated or a charge is marked, and records budget.exceeded in the outbox.
+func WithBudgetAlerts() ServiceOption {
+	return func(s *ServiceProvider) {
+		s.budgetAlerts = true
+	}
 }
 
-func NewServiceProvider(storage SubRepository) (*ServiceProvider, error) {
+func NewServiceProvider(storage SubRepository, logger *slog.Logger, opts ...ServiceOption) (*ServiceProvider, error) {
 	if storage == nil {
 		return nil, errors.Wrap(en.ErrNilDependency, "storage")
 	}
-	return &ServiceProvider{storage}, nil
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	s := &ServiceProvider{storage: storage, logger: logger}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
 }
 
-func (s *ServiceProvider) CreateSubscription(ctx context.Context, subscription en.Subscription) error {
-	err := s.storage.CreateSub(ctx, subscription)
+func (s *ServiceProvider) CreateSubscription(ctx context.Context, subscription en.Subscription) (en.Subscription, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.CreateSubscription")
+	defer span.End()
+
+	if err := validateMonths(subscription.StartDate, subscription.EndDate); err != nil {
+		return en.Subscription{}, tracing.Fail(span, err)
+	}
+	created, err := s.storage.CreateSub(ctx, subscription, newAuditEntry(ctx, en.AuditActionCreate))
 	if err != nil {
-		return errors.Wrap(err, "storage.CreateSub")
+		return en.Subscription{}, tracing.Fail(span, errors.Wrap(err, "storage.CreateSub"))
 	}
-	return nil
+	s.logger.InfoContext(ctx, "subscription created", "subscription_id", created.ID, "user_id", created.UserID, "principal", en.AuditPrincipal(ctx))
+	s.alertBudgets(ctx, created.UserID, created.ServiceName, created.ID)
+	return created, nil
 }
 
 func (s *ServiceProvider) GetSubscription(ctx context.Context, userID string, serviceName string) (en.Subscription, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.GetSubscription")
+	defer span.End()
+
 	sub, err := s.storage.GetSub(ctx, userID, serviceName)
 	if err != nil {
-		return en.Subscription{}, errors.Wrap(err, "storage.GetSub")
+		return en.Subscription{}, tracing.Fail(span, errors.Wrap(err, "storage.GetSub"))
 	}
 	return sub, nil
 }
 
-func (s *ServiceProvider) UpdateSubscription(ctx context.Context, userID string, serviceName string, price *int, startDate *string, endDate *string) error {
-	err := s.storage.UpdateSub(ctx, userID, serviceName, price, startDate, endDate)
+// UpdateSubscription changes the fields that are not nil. A changed end date
+// or switching auto-renewal on revives an expired subscription; the lifecycle
+// job expires or renews it again if it is still past its end.
+func (s *ServiceProvider) UpdateSubscription(ctx context.Context, userID string, serviceName string, price *int, startDate *string, endDate *string, autoRenew *bool) error {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.UpdateSubscription")
+	defer span.End()
+
+	if startDate != nil || endDate != nil {
+		current, err := s.storage.GetSub(ctx, userID, serviceName)
+		if err != nil {
+			return tracing.Fail(span, errors.Wrap(err, "storage.GetSub"))
+		}
+		start, end := current.StartDate, current.EndDate
+		if startDate != nil && *startDate != "" {
+			start = *startDate
+		}
+		if endDate != nil && *endDate != "" {
+			end = *endDate
+		}
+		if err := validateMonths(start, end); err != nil {
+			return tracing.Fail(span, err)
+		}
+	}
+	err := s.storage.UpdateSub(ctx, userID, serviceName, price, startDate, endDate, autoRenew, newAuditEntry(ctx, en.AuditActionUpdate))
 	if err != nil {
-		return errors.Wrap(err, "storage.UpdateSub")
+		return tracing.Fail(span, errors.Wrap(err, "storage.UpdateSub"))
 	}
+	s.logger.InfoContext(ctx, "subscription updated", "user_id", userID, "service", serviceName, "principal", en.AuditPrincipal(ctx))
+	s.alertBudgets(ctx, userID, serviceName, 0)
 	return nil
 }
 
 func (s *ServiceProvider) DeleteSubscription(ctx context.Context, userID string, serviceName string) error {
-	err := s.storage.DeleteSub(ctx, userID, serviceName)
+	ctx, span := tracer.Start(ctx, "ServiceProvider.DeleteSubscription")
+	defer span.End()
+
+	err := s.storage.DeleteSub(ctx, userID, serviceName, newAuditEntry(ctx, en.AuditActionDelete))
 	if err != nil {
-		return errors.Wrap(err, "storage.DeleteSub")
+		return tracing.Fail(span, errors.Wrap(err, "storage.DeleteSub"))
 	}
+	s.logger.InfoContext(ctx, "subscription deleted", "user_id", userID, "service", serviceName, "principal", en.AuditPrincipal(ctx))
 	return nil
 }
 
-func (s *ServiceProvider) GetListSubscriptions(ctx context.Context, userID string) ([]en.Subscription, error) {
-	subs, err := s.storage.GetListSubs(ctx, userID)
+func (s *ServiceProvider) RestoreSubscription(ctx context.Context, id int64) (en.Subscription, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.RestoreSubscription")
+	defer span.End()
+
+	sub, err := s.storage.RestoreSub(ctx, id, newAuditEntry(ctx, en.AuditActionRestore))
 	if err != nil {
-		return nil, errors.Wrap(err, "storage.GetListSubs")
+		return en.Subscription{}, tracing.Fail(span, errors.Wrap(err, "storage.RestoreSub"))
+	}
+	s.logger.InfoContext(ctx, "subscription restored", "subscription_id", sub.ID, "user_id", sub.UserID, "principal", en.AuditPrincipal(ctx))
+	return sub, nil
+}
+
+// GetListSubscriptions returns the user's subscriptions; soft-deleted ones are
+// only included when includeDeleted is set.
+func (s *ServiceProvider) GetListSubscriptions(ctx context.Context, userID string, includeDeleted bool) ([]en.Subscription, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.GetListSubscriptions")
+	defer span.End()
+
+	subs, err := s.storage.GetListSubs(ctx, userID, includeDeleted)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.GetListSubs"))
+	}
+	return subs, nil
+}
+
+// GetListSubscriptionsForUsers lists the subscriptions of several users at
+// once, keyed by user ID.
+func (s *ServiceProvider) GetListSubscriptionsForUsers(ctx context.Context, userIDs []string, includeDeleted bool) (map[string][]en.Subscription, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.GetListSubscriptionsForUsers")
+	defer span.End()
+
+	subs, err := s.storage.GetListSubsForUsers(ctx, userIDs, includeDeleted)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.GetListSubsForUsers"))
 	}
 	return subs, nil
 }
 
-func (s *ServiceProvider) GetTotalCostByPeriod(ctx context.Context, userID string, serviceName string, startDate string, endDate string) (int, error) {
-	cost, err := s.storage.GetTotalByPeriod(ctx, userID, serviceName, startDate, endDate)
+// GetTotalCostByPeriod sums the user's charges for the months of the period:
+// the amounts billed in en.CostModeExpected, the default, or what was paid in
+// en.CostModeActual.
+func (s *ServiceProvider) GetTotalCostByPeriod(ctx context.Context, userID string, serviceName string, startDate string, endDate string, mode en.CostMode) (int, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.GetTotalCostByPeriod")
+	defer span.End()
+
+	switch mode {
+	case "":
+		mode = en.CostModeExpected
+	case en.CostModeExpected, en.CostModeActual:
+	default:
+		return 0, tracing.Fail(span, errors.Wrapf(en.ErrInvalidCostMode, "%q", mode))
+	}
+	cost, err := s.storage.GetTotalByPeriod(ctx, userID, serviceName, startDate, endDate, mode)
 	if err != nil {
-		return 0, errors.Wrap(err, "storage.GetTotalCostByPeriod")
+		return 0, tracing.Fail(span, errors.Wrap(err, "storage.GetTotalCostByPeriod"))
 	}
 	return cost, nil
 }
+
+// GetCostBreakdown splits the total cost of the period by service, most expensive first.
+func (s *ServiceProvider) GetCostBreakdown(ctx context.Context, userID string, startDate string, endDate string) ([]en.ServiceCost, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.GetCostBreakdown")
+	defer span.End()
+
+	costs, err := s.storage.GetTotalByService(ctx, userID, startDate, endDate)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.GetTotalByService"))
+	}
+	return costs, nil
+}
+
+// ListCharges returns the ledger entries of the user's billed months within
+// the period, optionally of one service.
+func (s *ServiceProvider) ListCharges(ctx context.Context, userID string, serviceName string, startDate string, endDate string) ([]en.Charge, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.ListCharges")
+	defer span.End()
+
+	charges, err := s.storage.ListCharges(ctx, userID, serviceName, startDate, endDate)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.ListCharges"))
+	}
+	return charges, nil
+}
+
+// MarkCharge reconciles the subscription's charge for the MM-YYYY period with
+// what happened to it. A payment defaults to the charged amount, paid today.
+func (s *ServiceProvider) MarkCharge(ctx context.Context, subscriptionID int64, period string, mark en.ChargeMark) (en.Charge, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.MarkCharge")
+	defer span.End()
+
+	switch mark.Status {
+	case en.ChargeStatusPaid:
+		if mark.Amount != nil && *mark.Amount < 0 {
+			return en.Charge{}, tracing.Fail(span, errors.Wrap(en.ErrInvalidCharge, "amount must not be negative"))
+		}
+	case en.ChargeStatusRefunded, en.ChargeStatusSkipped, en.ChargeStatusDisputed:
+		if mark.Amount != nil || mark.PaidOn != nil {
+			return en.Charge{}, tracing.Fail(span, errors.Wrap(en.ErrInvalidCharge, "amount and paid_on only apply to payments"))
+		}
+	default:
+		return en.Charge{}, tracing.Fail(span, errors.Wrapf(en.ErrInvalidCharge, "unknown status %q", mark.Status))
+	}
+
+	charge, err := s.storage.GetCharge(ctx, subscriptionID, period)
+	if err != nil {
+		return en.Charge{}, tracing.Fail(span, errors.Wrap(err, "storage.GetCharge"))
+	}
+	from := charge.Status
+	if !from.CanBecome(mark.Status) {
+		return en.Charge{}, tracing.Fail(span, errors.Wrapf(en.ErrChargeTransition, "%s charge can not become %s", from, mark.Status))
+	}
+	charge.Status = mark.Status
+	if mark.Status == en.ChargeStatusPaid {
+		amount := charge.Amount
+		if mark.Amount != nil {
+			amount = *mark.Amount
+		}
+		paidOn := time.Now().UTC().Truncate(24 * time.Hour)
+		if mark.PaidOn != nil {
+			paidOn = *mark.PaidOn
+		}
+		charge.PaidAmount, charge.PaidOn = &amount, &paidOn
+	}
+
+	updated, err := s.storage.UpdateChargeStatus(ctx, charge, from)
+	if err != nil {
+		return en.Charge{}, tracing.Fail(span, errors.Wrap(err, "storage.UpdateChargeStatus"))
+	}
+	s.logger.InfoContext(ctx, "charge marked", "charge_id", updated.ID, "subscription_id", subscriptionID, "period", period,
+		"from", from, "status", updated.Status, "principal", en.AuditPrincipal(ctx))
+	s.alertBudgets(ctx, updated.UserID, updated.ServiceName, subscriptionID)
+	return updated, nil
+}
+
+func (s *ServiceProvider) GetSubscriptionAudit(ctx context.Context, subscriptionID int64) ([]en.AuditEntry, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.GetSubscriptionAudit")
+	defer span.End()
+
+	entries, err := s.storage.ListAudit(ctx, en.AuditFilter{SubscriptionID: subscriptionID})
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.ListAudit"))
+	}
+	return entries, nil
+}
+
+func (s *ServiceProvider) ListAudit(ctx context.Context, filter en.AuditFilter) ([]en.AuditEntry, error) {
+	ctx, span := tracer.Start(ctx, "ServiceProvider.ListAudit")
+	defer span.End()
+
+	entries, err := s.storage.ListAudit(ctx, filter)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.ListAudit"))
+	}
+	return entries, nil
+}
+
+// Subscriptions are billed for months of these years only, so that the
+// charges trigger never generates more than a century of rows for one.
+const (
+	minYear = 2000
+	maxYear = 2099
+)
+
+// validateMonths checks the MM-YYYY months a subscription is billed for; an
+// empty end is open-ended.
+func validateMonths(startDate, endDate string) error {
+	start, err := time.Parse(monthLayout, startDate)
+	if err != nil {
+		return errors.Wrapf(en.ErrInvalidPeriod, "start date %q", startDate)
+	}
+	if start.Year() < minYear || start.Year() > maxYear {
+		return errors.Wrapf(en.ErrInvalidPeriod, "start date %q is outside %d-%d", startDate, minYear, maxYear)
+	}
+	if endDate == "" {
+		return nil
+	}
+	end, err := time.Parse(monthLayout, endDate)
+	if err != nil {
+		return errors.Wrapf(en.ErrInvalidPeriod, "end date %q", endDate)
+	}
+	if end.Year() > maxYear {
+		return errors.Wrapf(en.ErrInvalidPeriod, "end date %q is outside %d-%d", endDate, minYear, maxYear)
+	}
+	if end.Before(start) {
+		return errors.Wrapf(en.ErrInvalidPeriod, "end date %q is before start date %q", endDate, startDate)
+	}
+	return nil
+}
+
+// newAuditEntry describes who is performing action; the storage fills in the
+// affected subscription and its snapshots within the mutation's transaction.
+func newAuditEntry(ctx context.Context, action en.AuditAction) en.AuditEntry {
+	return en.AuditEntry{
+		Action:    action,
+		Principal: en.AuditPrincipal(ctx),
+		Actor:     en.ActorFromContext(ctx),
+		RequestID: en.RequestIDFromContext(ctx),
+	}
+}
@@ -2,15 +2,60 @@ package cases
 
 import (
 	"context"
+	"time"
 
 	en "github.com/100bench/subscription_aggregator/internal/entities"
 )
 
 type SubRepository interface {
-	CreateSub(ctx context.Context, subscription en.Subscription) error
+	CreateSub(ctx context.Context, subscription en.Subscription, audit en.AuditEntry) (en.Subscription, error)
 	GetSub(ctx context.Context, userID, serviceName string) (en.Subscription, error)
-	UpdateSub(ctx context.Context, userID, serviceName string, price *int, startDate *string, endDate *string) error
-	DeleteSub(ctx context.Context, userID, serviceName string) error
-	GetListSubs(ctx context.Context, userId string) ([]en.Subscription, error)
-	GetTotalByPeriod(ctx context.Context, userID string, serviceName string, startDate, endDate string) (int, error)
+	UpdateSub(ctx context.Context, userID, serviceName string, price *int, startDate *string, endDate *string, autoRenew *bool, audit en.AuditEntry) error
+	DeleteSub(ctx context.Context, userID, serviceName string, audit en.AuditEntry) error
+	GetListSubs(ctx context.Context, userId string, includeDeleted bool) ([]en.Subscription, error)
+	GetListSubsForUsers(ctx context.Context, userIDs []string, includeDeleted bool) (map[string][]en.Subscription, error)
+	RestoreSub(ctx context.Context, id int64, audit en.AuditEntry) (en.Subscription, error)
+	PurgeDeletedSubs(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// ListSubsEndingIn returns active subscriptions of every tenant whose end
+	// month is month (MM-YYYY).
+	ListSubsEndingIn(ctx context.Context, month string) ([]en.Subscription, error)
+	// ListLapsedSubs returns active, unexpired subscriptions of every tenant
+	// that are due for a lifecycle step in month (MM-YYYY): those whose end
+	// month is before it, and open-ended ones not billed through it.
+	ListLapsedSubs(ctx context.Context, month string) ([]en.Subscription, error)
+	// ExpireSub marks the subscription expired unless it renews, has been
+	// changed to end in month or later, or is already expired or deleted, in
+	// which case it fails with en.ErrSubscriptionNotFound.
+	ExpireSub(ctx context.Context, id int64, month string, audit en.AuditEntry) (en.Subscription, error)
+	// RenewSub bills the subscription for one more month: it extends the end
+	// date of a renewing subscription, or advances BilledThrough of an
+	// open-ended one. It fails with en.ErrSubscriptionNotFound when the
+	// subscription is no longer lapsed in month.
+	RenewSub(ctx context.Context, id int64, month string, audit en.AuditEntry) (en.Subscription, error)
+	GetTotalByPeriod(ctx context.Context, userID string, serviceName string, startDate, endDate string, mode en.CostMode) (int, error)
+	GetTotalByService(ctx context.Context, userID string, startDate, endDate string) ([]en.ServiceCost, error)
+	ListCharges(ctx context.Context, userID, serviceName, startDate, endDate string) ([]en.Charge, error)
+	// GetCharge returns the charge of the subscription for the MM-YYYY
+	// period or en.ErrChargeNotFound.
+	GetCharge(ctx context.Context, subscriptionID int64, period string) (en.Charge, error)
+	// UpdateChargeStatus stores the status and payment of charge if it is
+	// still in status from, and fails with en.ErrChargeTransition otherwise.
+	UpdateChargeStatus(ctx context.Context, charge en.Charge, from en.ChargeStatus) (en.Charge, error)
+	// CreateBudget fails with en.ErrBudgetExists when the user has a budget
+	// of the same name.
+	CreateBudget(ctx context.Context, budget en.Budget) (en.Budget, error)
+	ListBudgets(ctx context.Context, userID string) ([]en.Budget, error)
+	// UpdateBudget replaces the settings of the user's budget budget.ID and
+	// clears its alert; it fails with en.ErrBudgetNotFound or en.ErrBudgetExists.
+	UpdateBudget(ctx context.Context, budget en.Budget) (en.Budget, error)
+	DeleteBudget(ctx context.Context, userID string, id int64) error
+	// ListBudgetStatuses returns the spend of every budget of the user in the
+	// MM-YYYY period.
+	ListBudgetStatuses(ctx context.Context, userID, period string) ([]en.BudgetStatus, error)
+	// AlertBudget marks the budget of status alerted for status.Period and
+	// records its budget.exceeded event for the subscription in the outbox in
+	// the same transaction; it returns false when the period was already
+	// alerted.
+	AlertBudget(ctx context.Context, status en.BudgetStatus, subscriptionID int64) (bool, error)
+	ListAudit(ctx context.Context, filter en.AuditFilter) ([]en.AuditEntry, error)
 }
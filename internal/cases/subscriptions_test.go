@@ -0,0 +1,82 @@
+package cases_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+func TestCreateSubscriptionValidatesMonths(t *testing.T) {
+	service, ctx := newBudgetService(t)
+
+	for name, tt := range map[string]struct {
+		start, end string
+		wantErr    bool
+	}{
+		"open-ended":        {start: "01-2025"},
+		"single month":      {start: "01-2025", end: "01-2025"},
+		"first year":        {start: "01-2000", end: "12-2000"},
+		"last year":         {start: "01-2099", end: "12-2099"},
+		"end before start":  {start: "03-2025", end: "02-2025", wantErr: true},
+		"bad start":         {start: "2025-01", wantErr: true},
+		"bad end":           {start: "01-2025", end: "13-2025", wantErr: true},
+		"start before 2000": {start: "12-1999", end: "01-2000", wantErr: true},
+		"ancient start":     {start: "01-0001", wantErr: true},
+		"start after 2099":  {start: "01-2100", wantErr: true},
+		"end after 2099":    {start: "01-2025", end: "01-2100", wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.CreateSubscription(ctx, en.Subscription{
+				UserID: testutil.UserID, ServiceName: name, Price: 100, StartDate: tt.start, EndDate: tt.end,
+			})
+			if tt.wantErr && !errors.Is(err, en.ErrInvalidPeriod) {
+				t.Errorf("CreateSubscription(%q, %q) = %v, want ErrInvalidPeriod", tt.start, tt.end, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CreateSubscription(%q, %q): %v", tt.start, tt.end, err)
+			}
+		})
+	}
+}
+
+func TestUpdateSubscriptionValidatesMonthsLikeCreate(t *testing.T) {
+	service, ctx := newBudgetService(t)
+	subscribe(t, service, ctx, "Netflix", 400, "03-2025", "06-2025")
+
+	month := func(s string) *string { return &s }
+	for name, tt := range map[string]struct {
+		start, end *string
+	}{
+		"end before the current start": {end: month("02-2025")},
+		"start after the current end":  {start: month("07-2025")},
+		"end before the new start":     {start: month("05-2025"), end: month("04-2025")},
+		"start before 2000":            {start: month("12-1999")},
+		"end after 2099":               {end: month("01-2100")},
+		"bad month":                    {end: month("2025-06")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := service.UpdateSubscription(ctx, testutil.UserID, "Netflix", nil, tt.start, tt.end, nil)
+			if !errors.Is(err, en.ErrInvalidPeriod) {
+				t.Errorf("UpdateSubscription = %v, want ErrInvalidPeriod", err)
+			}
+		})
+	}
+
+	if err := service.UpdateSubscription(ctx, testutil.UserID, "Netflix", nil, month("07-2025"), month("09-2025"), nil); err != nil {
+		t.Fatalf("UpdateSubscription moving both months: %v", err)
+	}
+
+	sub, err := service.GetSubscription(ctx, testutil.UserID, "Netflix")
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if sub.StartDate != "07-2025" || sub.EndDate != "09-2025" {
+		t.Errorf("subscription = %s..%s, want 07-2025..09-2025", sub.StartDate, sub.EndDate)
+	}
+	if err := service.UpdateSubscription(ctx, testutil.UserID, "Hulu", nil, month("01-2025"), nil, nil); !errors.Is(err, en.ErrSubscriptionNotFound) {
+		t.Errorf("UpdateSubscription of a missing subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+}
@@ -0,0 +1,126 @@
+package cases
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// TelegramRepository stores linked chats and pending link codes.
+type TelegramRepository interface {
+	CreateTelegramLinkCode(ctx context.Context, userID, codeHash string, expiresAt time.Time) error
+	LinkTelegramChat(ctx context.Context, codeHash string, chatID int64, locale string) (en.TelegramChat, error)
+	UnlinkTelegramChat(ctx context.Context, chatID int64) error
+	GetTelegramChatByID(ctx context.Context, chatID int64) (en.TelegramChat, error)
+	GetTelegramChat(ctx context.Context, userID string) (en.TelegramChat, error)
+}
+
+// linkCodeBytes is the entropy of link codes: 8 base32 characters.
+const linkCodeBytes = 5
+
+// ChatLinkService links Telegram chats to users through one-time codes the
+// user obtains from an authenticated API and sends to the bot.
+type ChatLinkService struct {
+	storage TelegramRepository
+	logger  *slog.Logger
+	codeTTL time.Duration
+}
+
+func NewChatLinkService(storage TelegramRepository, logger *slog.Logger, codeTTL time.Duration) (*ChatLinkService, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	if codeTTL <= 0 {
+		return nil, errors.New("code TTL must be positive")
+	}
+	return &ChatLinkService{storage: storage, logger: logger, codeTTL: codeTTL}, nil
+}
+
+// CreateLinkCode issues a code linking a chat to the user of the tenant from
+// ctx; it invalidates earlier codes of the user.
+func (s *ChatLinkService) CreateLinkCode(ctx context.Context, userID string) (en.ChatLinkCode, error) {
+	ctx, span := tracer.Start(ctx, "ChatLinkService.CreateLinkCode")
+	defer span.End()
+
+	raw := make([]byte, linkCodeBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return en.ChatLinkCode{}, tracing.Fail(span, errors.Wrap(err, "generate link code"))
+	}
+	code := en.ChatLinkCode{
+		Code:      base32.StdEncoding.EncodeToString(raw),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.codeTTL),
+	}
+	if err := s.storage.CreateTelegramLinkCode(ctx, userID, hashLinkCode(code.Code), code.ExpiresAt); err != nil {
+		return en.ChatLinkCode{}, tracing.Fail(span, errors.Wrap(err, "storage.CreateTelegramLinkCode"))
+	}
+	s.logger.InfoContext(ctx, "telegram link code issued", "user_id", userID, "principal", en.AuditPrincipal(ctx))
+	return code, nil
+}
+
+// LinkChat redeems code for the chat. Codes are case-insensitive.
+func (s *ChatLinkService) LinkChat(ctx context.Context, code string, chatID int64, locale string) (en.TelegramChat, error) {
+	ctx, span := tracer.Start(ctx, "ChatLinkService.LinkChat")
+	defer span.End()
+
+	chat, err := s.storage.LinkTelegramChat(ctx, hashLinkCode(code), chatID, locale)
+	if err != nil {
+		return en.TelegramChat{}, tracing.Fail(span, errors.Wrap(err, "storage.LinkTelegramChat"))
+	}
+	s.logger.InfoContext(ctx, "telegram chat linked", "chat_id", chatID, "tenant_id", chat.TenantID, "user_id", chat.UserID)
+	return chat, nil
+}
+
+func (s *ChatLinkService) UnlinkChat(ctx context.Context, chatID int64) error {
+	ctx, span := tracer.Start(ctx, "ChatLinkService.UnlinkChat")
+	defer span.End()
+
+	if err := s.storage.UnlinkTelegramChat(ctx, chatID); err != nil {
+		return tracing.Fail(span, errors.Wrap(err, "storage.UnlinkTelegramChat"))
+	}
+	s.logger.InfoContext(ctx, "telegram chat unlinked", "chat_id", chatID)
+	return nil
+}
+
+// ChatByID returns the user the chat acts for, or en.ErrChatNotLinked.
+func (s *ChatLinkService) ChatByID(ctx context.Context, chatID int64) (en.TelegramChat, error) {
+	ctx, span := tracer.Start(ctx, "ChatLinkService.ChatByID")
+	defer span.End()
+
+	chat, err := s.storage.GetTelegramChatByID(ctx, chatID)
+	if err != nil {
+		return en.TelegramChat{}, tracing.Fail(span, errors.Wrap(err, "storage.GetTelegramChatByID"))
+	}
+	return chat, nil
+}
+
+// ChatForUser returns the chat of the user of the tenant from ctx, or
+// en.ErrChatNotLinked.
+func (s *ChatLinkService) ChatForUser(ctx context.Context, userID string) (en.TelegramChat, error) {
+	ctx, span := tracer.Start(ctx, "ChatLinkService.ChatForUser")
+	defer span.End()
+
+	chat, err := s.storage.GetTelegramChat(ctx, userID)
+	if err != nil {
+		return en.TelegramChat{}, tracing.Fail(span, errors.Wrap(err, "storage.GetTelegramChat"))
+	}
+	return chat, nil
+}
+
+func hashLinkCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
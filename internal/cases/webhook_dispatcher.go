@@ -0,0 +1,144 @@
+package cases
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// WebhookSender performs one signed HTTP delivery and returns the status code
+// the receiver answered with; any non-2xx status counts as a failure.
+type WebhookSender interface {
+	Send(ctx context.Context, delivery en.WebhookDelivery) (int, error)
+}
+
+// DispatchOptions tune delivery retries.
+type DispatchOptions struct {
+	// Interval between polls for due deliveries.
+	Interval time.Duration
+	// BatchSize caps the deliveries sent concurrently per poll.
+	BatchSize int
+	// MaxAttempts before a delivery is dead-lettered.
+	MaxAttempts int
+	// InitialBackoff is the delay after the first failure; it doubles with
+	// every further failure up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Lease hides claimed deliveries from other dispatchers while they are
+	// being sent; it must exceed the sender's timeout.
+	Lease time.Duration
+}
+
+// WebhookDispatcher sends queued deliveries, retrying failures with
+// exponential backoff. Several replicas may run it concurrently.
+type WebhookDispatcher struct {
+	storage WebhookRepository
+	sender  WebhookSender
+	logger  *slog.Logger
+	opts    DispatchOptions
+}
+
+func NewWebhookDispatcher(storage WebhookRepository, sender WebhookSender, logger *slog.Logger, opts DispatchOptions) (*WebhookDispatcher, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if sender == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "sender")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	if opts.Interval <= 0 || opts.BatchSize <= 0 || opts.MaxAttempts <= 0 || opts.InitialBackoff <= 0 || opts.MaxBackoff < opts.InitialBackoff || opts.Lease <= 0 {
+		return nil, errors.New("invalid webhook dispatch options")
+	}
+	return &WebhookDispatcher{storage: storage, sender: sender, logger: logger, opts: opts}, nil
+}
+
+// DispatchOnce sends one batch of due deliveries and returns how many were claimed.
+func (d *WebhookDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "WebhookDispatcher.DispatchOnce")
+	defer span.End()
+
+	deliveries, err := d.storage.ClaimWebhookDeliveries(ctx, d.opts.BatchSize, d.opts.Lease)
+	if err != nil {
+		return 0, tracing.Fail(span, errors.Wrap(err, "storage.ClaimWebhookDeliveries"))
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery en.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery en.WebhookDelivery) {
+	logger := d.logger.With("delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "event_id", delivery.EventID, "attempt", delivery.Attempts+1)
+
+	status, err := d.sender.Send(ctx, delivery)
+	attempt := en.WebhookAttempt{DeliveryID: delivery.ID, ResponseStatus: status}
+	switch {
+	case err == nil:
+		attempt.Status = en.DeliveryDelivered
+		logger.DebugContext(ctx, "webhook delivered", "status", status)
+	case delivery.Attempts+1 >= d.opts.MaxAttempts:
+		attempt.Status = en.DeliveryDead
+		attempt.Error = err.Error()
+		logger.WarnContext(ctx, "webhook delivery dead-lettered", "status", status, "error", err)
+	default:
+		attempt.Status = en.DeliveryPending
+		attempt.Error = err.Error()
+		attempt.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts + 1))
+		logger.InfoContext(ctx, "webhook delivery failed, will retry", "status", status, "next_attempt_at", attempt.NextAttemptAt, "error", err)
+	}
+
+	// The outcome is recorded even if shutdown cancelled ctx mid-send.
+	if err := d.storage.RecordWebhookAttempt(context.WithoutCancel(ctx), attempt); err != nil {
+		logger.ErrorContext(ctx, "failed to record webhook attempt", "error", err)
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (d *WebhookDispatcher) backoff(failures int) time.Duration {
+	delay := d.opts.InitialBackoff
+	for i := 1; i < failures && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		delay = d.opts.MaxBackoff
+	}
+	return delay
+}
+
+// Run dispatches on every interval tick until ctx is cancelled. A full batch
+// is followed by another poll right away to drain backlogs quickly.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := d.DispatchOnce(ctx)
+				if err != nil {
+					d.logger.ErrorContext(ctx, "webhook dispatch failed", "error", err)
+				}
+				if err != nil || n < d.opts.BatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
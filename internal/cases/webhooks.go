@@ -0,0 +1,154 @@
+package cases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/netguard"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// WebhookRepository stores webhook endpoints and their delivery queue.
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook en.Webhook) (en.Webhook, error)
+	ListWebhooks(ctx context.Context, userID string) ([]en.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	// EnqueueWebhookDeliveries queues payload for every endpoint of the
+	// event's tenant subscribed to it and returns how many were queued.
+	EnqueueWebhookDeliveries(ctx context.Context, event en.Event, payload []byte) (int64, error)
+	// ClaimWebhookDeliveries locks up to limit due deliveries of all tenants
+	// and postpones them by lease, so concurrent dispatchers skip them.
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]en.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, attempt en.WebhookAttempt) error
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]en.WebhookDelivery, error)
+}
+
+// webhookSecretBytes is the entropy of generated signing secrets.
+const webhookSecretBytes = 32
+
+// WebhookService manages webhook registrations and queues events for them;
+// it is the EventPublisher for webhooks.
+type WebhookService struct {
+	storage WebhookRepository
+	logger  *slog.Logger
+	guard   *netguard.Guard
+}
+
+func NewWebhookService(storage WebhookRepository, logger *slog.Logger) (*WebhookService, error) {
+	if storage == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "storage")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	return &WebhookService{storage: storage, logger: logger, guard: netguard.New()}, nil
+}
+
+// RegisterWebhook validates the endpoint, generates its signing secret and
+// stores it. Endpoints inside the service's network are refused; the sender
+// checks the address again when it connects. The returned webhook is the only place the secret is exposed.
+func (s *WebhookService) RegisterWebhook(ctx context.Context, webhook en.Webhook) (en.Webhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.RegisterWebhook")
+	defer span.End()
+
+	if err := s.validateWebhook(ctx, webhook); err != nil {
+		return en.Webhook{}, tracing.Fail(span, err)
+	}
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return en.Webhook{}, tracing.Fail(span, errors.Wrap(err, "generate webhook secret"))
+	}
+	webhook.Secret = "whsec_" + hex.EncodeToString(secret)
+
+	created, err := s.storage.CreateWebhook(ctx, webhook)
+	if err != nil {
+		return en.Webhook{}, tracing.Fail(span, errors.Wrap(err, "storage.CreateWebhook"))
+	}
+	s.logger.InfoContext(ctx, "webhook registered", "webhook_id", created.ID, "user_id", created.UserID, "principal", en.AuditPrincipal(ctx))
+	return created, nil
+}
+
+// ListWebhooks returns the endpoints of the tenant, or of one user if userID is set.
+func (s *WebhookService) ListWebhooks(ctx context.Context, userID string) ([]en.Webhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListWebhooks")
+	defer span.End()
+
+	webhooks, err := s.storage.ListWebhooks(ctx, userID)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.ListWebhooks"))
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.DeleteWebhook")
+	defer span.End()
+
+	if err := s.storage.DeleteWebhook(ctx, id); err != nil {
+		return tracing.Fail(span, errors.Wrap(err, "storage.DeleteWebhook"))
+	}
+	s.logger.InfoContext(ctx, "webhook deleted", "webhook_id", id, "principal", en.AuditPrincipal(ctx))
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries of the endpoint, newest first.
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]en.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListDeliveries")
+	defer span.End()
+
+	deliveries, err := s.storage.ListWebhookDeliveries(ctx, webhookID, limit)
+	if err != nil {
+		return nil, tracing.Fail(span, errors.Wrap(err, "storage.ListWebhookDeliveries"))
+	}
+	return deliveries, nil
+}
+
+// Publish queues event for every matching endpoint; the WebhookDispatcher
+// sends it asynchronously.
+func (s *WebhookService) Publish(ctx context.Context, event en.Event) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.Publish")
+	defer span.End()
+
+	payload, err := EncodeEvent(event)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	queued, err := s.storage.EnqueueWebhookDeliveries(en.WithTenant(ctx, event.TenantID), event, payload)
+	if err != nil {
+		return tracing.Fail(span, errors.Wrap(err, "storage.EnqueueWebhookDeliveries"))
+	}
+	if queued > 0 {
+		s.logger.DebugContext(ctx, "webhook deliveries queued", "event_id", event.ID, "event_type", event.Type, "count", queued)
+	}
+	return nil
+}
+
+func (s *WebhookService) validateWebhook(ctx context.Context, webhook en.Webhook) error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.Wrapf(en.ErrInvalidWebhook, "url %q must be an absolute http(s) URL", webhook.URL)
+	}
+	if err := s.guard.CheckHost(ctx, u.Hostname()); err != nil {
+		if errors.Is(err, netguard.ErrForbiddenAddress) {
+			return errors.Wrapf(en.ErrInvalidWebhook, "url %q points into a private network", webhook.URL)
+		}
+		return errors.Wrapf(en.ErrInvalidWebhook, "url %q: host does not resolve", webhook.URL)
+	}
+	for _, event := range webhook.Events {
+		known := false
+		for _, t := range en.EventTypes {
+			known = known || event == t
+		}
+		if !known {
+			return errors.Wrapf(en.ErrInvalidWebhook, "unknown event type %q", event)
+		}
+	}
+	return nil
+}
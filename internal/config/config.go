@@ -0,0 +1,461 @@
+// Package config loads the service configuration from defaults, an optional
+// YAML file, environment variables and command-line flags, in increasing
+// order of precedence.
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+
+	"github.com/100bench/subscription_aggregator/internal/auth"
+	"github.com/100bench/subscription_aggregator/internal/jobs"
+	"github.com/100bench/subscription_aggregator/internal/logging"
+	"github.com/100bench/subscription_aggregator/internal/ratelimit"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+// Config is the complete service configuration. Every leaf field carries an
+// env tag naming its environment variable; the matching flag is the
+// lower-cased name with dashes, e.g. --database-max-conns.
+type Config struct {
+	HTTP        HTTP        `yaml:"http"`
+	GRPC        GRPC        `yaml:"grpc"`
+	Metrics     Metrics     `yaml:"metrics"`
+	Database    Database    `yaml:"database"`
+	Log         Log         `yaml:"log"`
+	Migrations  Migrations  `yaml:"migrations"`
+	Auth        Auth        `yaml:"auth"`
+	CORS        CORS        `yaml:"cors"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Retention   Retention   `yaml:"retention"`
+	Webhooks    Webhooks    `yaml:"webhooks"`
+	Outbox      Outbox      `yaml:"outbox"`
+	Feed        Feed        `yaml:"feed"`
+	Reminders   Reminders   `yaml:"reminders"`
+	Telegram    Telegram    `yaml:"telegram"`
+	Jobs        Jobs        `yaml:"jobs"`
+}
+
+type HTTP struct {
+	Port              int           `yaml:"port" env:"PORT" usage:"HTTP listen port"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"time allowed to read request headers"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"time allowed to read the whole request"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"time allowed to write the response"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"keep-alive idle timeout"`
+	DrainPeriod       time.Duration `yaml:"drain_period" env:"SHUTDOWN_DRAIN_PERIOD" usage:"how long /readyz fails before the listener closes on shutdown"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"how long in-flight requests may take to finish on shutdown"`
+}
+
+type GRPC struct {
+	Port int `yaml:"port" env:"GRPC_PORT" usage:"gRPC listen port; 0 disables the gRPC API"`
+}
+
+// Metrics are served on a listener of their own, which is meant to be
+// reachable only from inside the deployment.
+type Metrics struct {
+	Port     int           `yaml:"port" env:"METRICS_PORT" usage:"internal listen port of /metrics; 0 disables it"`
+	CacheTTL time.Duration `yaml:"cache_ttl" env:"METRICS_CACHE_TTL" usage:"how long gauges computed by the database are reused between scrapes"`
+}
+
+type Database struct {
+	URL             string        `yaml:"url" env:"DATABASE_URL" usage:"PostgreSQL connection string"`
+	MaxConns        int32         `yaml:"max_conns" env:"DATABASE_MAX_CONNS" usage:"maximum pool size"`
+	MinConns        int32         `yaml:"min_conns" env:"DATABASE_MIN_CONNS" usage:"connections kept open when idle"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" env:"DATABASE_MAX_CONN_LIFETIME" usage:"connections older than this are recycled"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" env:"DATABASE_MAX_CONN_IDLE_TIME" usage:"idle connections older than this are closed"`
+}
+
+type Log struct {
+	Level         string `yaml:"level" env:"LOG_LEVEL" usage:"debug, info, warn or error"`
+	RedactUserIDs bool   `yaml:"redact_user_ids" env:"LOG_REDACT_USER_IDS" usage:"hash user IDs, chat IDs and actors in logs"`
+}
+
+type Migrations struct {
+	AutoMigrate bool   `yaml:"auto_migrate" env:"MIGRATIONS_AUTO" usage:"apply pending migrations on startup instead of via the migrate subcommand"`
+	Source      string `yaml:"source" env:"MIGRATIONS_SOURCE" usage:"golang-migrate source URL; empty uses the migrations embedded in the binary"`
+}
+
+type Auth struct {
+	AdminToken string `yaml:"admin_token" env:"ADMIN_TOKEN" usage:"token for admin-only routes; empty disables them"`
+	APIKeys    string `yaml:"api_keys" env:"API_KEYS" usage:"semicolon-separated tenant:name=key API keys binding callers to tenants"`
+}
+
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"comma-separated origins; empty disables CORS"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS" usage:"comma-separated methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" usage:"comma-separated request headers"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" usage:"allow cookies and auth headers"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" usage:"how long preflight results may be cached"`
+}
+
+type RateLimit struct {
+	Default string `yaml:"default" env:"RATE_LIMIT_DEFAULT" usage:"RATE/BURST applied to every route"`
+	Routes  string `yaml:"routes" env:"RATE_LIMIT_ROUTES" usage:"METHOD /pattern=RATE/BURST;... overrides"`
+	Store   string `yaml:"store" env:"RATE_LIMIT_STORE" usage:"memory or postgres"`
+}
+
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" usage:"how long responses to requests with an Idempotency-Key are replayed"`
+}
+
+type Tracing struct {
+	Exporter     string `yaml:"exporter" env:"TRACING_EXPORTER" usage:"none, stdout or otlp"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" usage:"OTLP/HTTP collector host:port"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE" usage:"talk to the collector without TLS"`
+}
+
+type Retention struct {
+	SoftDeleted   time.Duration `yaml:"soft_deleted" env:"SOFT_DELETE_RETENTION" usage:"purge soft-deleted subscriptions after this long; 0 disables"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"SOFT_DELETE_PURGE_INTERVAL" usage:"how often the purge runs"`
+}
+
+type Webhooks struct {
+	DispatchInterval    time.Duration `yaml:"dispatch_interval" env:"WEBHOOK_DISPATCH_INTERVAL" usage:"how often due deliveries are picked up"`
+	BatchSize           int           `yaml:"batch_size" env:"WEBHOOK_BATCH_SIZE" usage:"deliveries sent concurrently per poll"`
+	Timeout             time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT" usage:"time a receiver has to answer a delivery"`
+	MaxAttempts         int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" usage:"attempts before a delivery is dead-lettered"`
+	InitialBackoff      time.Duration `yaml:"initial_backoff" env:"WEBHOOK_BACKOFF_INITIAL" usage:"retry delay after the first failure, doubled after each further one"`
+	MaxBackoff          time.Duration `yaml:"max_backoff" env:"WEBHOOK_BACKOFF_MAX" usage:"upper bound of the retry delay"`
+	RenewalScanInterval time.Duration `yaml:"renewal_scan_interval" env:"WEBHOOK_RENEWAL_SCAN_INTERVAL" usage:"how often subscriptions ending this month are announced as renewing; 0 disables"`
+}
+
+// Outbox publishers.
+const (
+	PublisherLog       = "log"
+	PublisherWebhook   = "webhook"
+	PublisherKafkaREST = "kafka_rest"
+)
+
+type Outbox struct {
+	Publishers     []string      `yaml:"publishers" env:"OUTBOX_PUBLISHERS" usage:"comma-separated event sinks: log, webhook, kafka_rest"`
+	Topic          string        `yaml:"topic" env:"OUTBOX_TOPIC" usage:"broker topic events are produced to"`
+	KafkaRESTURL   string        `yaml:"kafka_rest_url" env:"OUTBOX_KAFKA_REST_URL" usage:"Kafka REST Proxy or Redpanda HTTP Proxy base URL for the kafka_rest publisher"`
+	RelayInterval  time.Duration `yaml:"relay_interval" env:"OUTBOX_RELAY_INTERVAL" usage:"how often the outbox is polled for new events"`
+	BatchSize      int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" usage:"events published per poll"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"OUTBOX_BACKOFF_INITIAL" usage:"retry delay after the first failed publish, doubled after each further one"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env:"OUTBOX_BACKOFF_MAX" usage:"upper bound of the retry delay"`
+	Retention      time.Duration `yaml:"retention" env:"OUTBOX_RETENTION" usage:"how long published events are kept"`
+}
+
+type Feed struct {
+	Retention time.Duration `yaml:"retention" env:"FEED_RETENTION" usage:"how long change feed events stay available for Last-Event-ID resume"`
+	Heartbeat time.Duration `yaml:"heartbeat" env:"FEED_HEARTBEAT" usage:"interval of keep-alive comments on idle event streams"`
+}
+
+// Reminders are sent by email only when an SMTP server is configured.
+type Reminders struct {
+	Interval     time.Duration `yaml:"interval" env:"REMINDER_INTERVAL" usage:"how often due renewal reminders are sent; 0 disables"`
+	SMTPAddr     string        `yaml:"smtp_addr" env:"SMTP_ADDR" usage:"SMTP server host:port; empty disables email reminders"`
+	SMTPUsername string        `yaml:"smtp_username" env:"SMTP_USERNAME" usage:"SMTP login; empty sends without authentication"`
+	SMTPPassword string        `yaml:"smtp_password" env:"SMTP_PASSWORD" usage:"SMTP password"`
+	SMTPFrom     string        `yaml:"smtp_from" env:"SMTP_FROM" usage:"sender address of reminder emails"`
+	SMTPTimeout  time.Duration `yaml:"smtp_timeout" env:"SMTP_TIMEOUT" usage:"time allowed to deliver one email"`
+}
+
+// Jobs run on the schedules derived from the intervals of their sections
+// unless overridden by name.
+type Jobs struct {
+	Schedules        string        `yaml:"schedules" env:"JOB_SCHEDULES" usage:"name=SCHEDULE;... overrides, SCHEDULE being a cron expression in UTC or @every DURATION"`
+	HistoryRetention time.Duration `yaml:"history_retention" env:"JOB_HISTORY_RETENTION" usage:"how long job run history is kept"`
+}
+
+// Telegram runs the bot only when a token is configured; it then also sends
+// renewal reminders to linked chats on the reminders interval.
+type Telegram struct {
+	BotToken    string        `yaml:"bot_token" env:"TELEGRAM_BOT_TOKEN" usage:"Telegram bot token; empty disables the bot"`
+	APIURL      string        `yaml:"api_url" env:"TELEGRAM_API_URL" usage:"base URL of the Bot API server"`
+	PollTimeout time.Duration `yaml:"poll_timeout" env:"TELEGRAM_POLL_TIMEOUT" usage:"long-poll timeout of getUpdates"`
+	LinkCodeTTL time.Duration `yaml:"link_code_ttl" env:"TELEGRAM_LINK_CODE_TTL" usage:"how long a chat link code stays valid"`
+}
+
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			DrainPeriod:       5 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		GRPC:    GRPC{Port: 9090},
+		Metrics: Metrics{Port: 9091, CacheTTL: time.Minute},
+		Database: Database{
+			MaxConns:        10,
+			MinConns:        2,
+			MaxConnLifetime: time.Hour,
+			MaxConnIdleTime: time.Minute,
+		},
+		Log: Log{Level: "info"},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-Tenant-ID", "X-Actor-ID", "X-Admin-Token", "X-Request-Id", "Idempotency-Key", "traceparent"},
+			MaxAge:         5 * time.Minute,
+		},
+		RateLimit: RateLimit{
+			Default: "10/20",
+			Routes:  "GET /subscriptions/total-cost=1/5",
+			Store:   "memory",
+		},
+		Idempotency: Idempotency{TTL: 24 * time.Hour},
+		Tracing:     Tracing{Exporter: tracing.ExporterNone},
+		Retention: Retention{
+			SoftDeleted:   30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Webhooks: Webhooks{
+			DispatchInterval:    2 * time.Second,
+			BatchSize:           20,
+			Timeout:             10 * time.Second,
+			MaxAttempts:         10,
+			InitialBackoff:      30 * time.Second,
+			MaxBackoff:          6 * time.Hour,
+			RenewalScanInterval: 6 * time.Hour,
+		},
+		Outbox: Outbox{
+			Publishers:     []string{PublisherWebhook},
+			Topic:          "subscription-events",
+			RelayInterval:  time.Second,
+			BatchSize:      100,
+			InitialBackoff: time.Second,
+			MaxBackoff:     5 * time.Minute,
+			Retention:      7 * 24 * time.Hour,
+		},
+		Feed: Feed{
+			Retention: 72 * time.Hour,
+			Heartbeat: 15 * time.Second,
+		},
+		Reminders: Reminders{
+			Interval:    time.Hour,
+			SMTPFrom:    "Subscription Aggregator <noreply@localhost>",
+			SMTPTimeout: 10 * time.Second,
+		},
+		Telegram: Telegram{
+			APIURL:      "https://api.telegram.org",
+			PollTimeout: 30 * time.Second,
+			LinkCodeTTL: 15 * time.Minute,
+		},
+		Jobs: Jobs{
+			HistoryRetention: 30 * 24 * time.Hour,
+		},
+	}
+}
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Mode is what the process was started to do, which decides the settings
+// it needs.
+type Mode int
+
+const (
+	// ModeServe runs the service and needs the whole configuration.
+	ModeServe Mode = iota
+	// ModeMigrate runs the migrate subcommand, which only connects to the
+	// database; API keys and the other serving settings may be missing.
+	ModeMigrate
+)
+
+// Validate checks the configuration needed in mode and reports all problems
+// at once.
+func (c Config) Validate(mode Mode) error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Database.URL != "", "database.url (DATABASE_URL): is required")
+	if c.Database.URL != "" {
+		// The parse error is left out, it may quote the password.
+		_, err := pgconn.ParseConfig(c.Database.URL)
+		check(err == nil, "database.url (DATABASE_URL): not a valid connection string")
+	}
+	_, err := logging.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level (LOG_LEVEL): %q is not one of debug, info, warn, error", c.Log.Level)
+	if mode == ModeMigrate {
+		return problemsError(problems)
+	}
+
+	check(c.HTTP.Port > 0 && c.HTTP.Port <= 65535, "http.port (PORT): %d is not a valid port", c.HTTP.Port)
+	check(c.GRPC.Port >= 0 && c.GRPC.Port <= 65535, "grpc.port (GRPC_PORT): %d is not a valid port", c.GRPC.Port)
+	check(c.GRPC.Port == 0 || c.GRPC.Port != c.HTTP.Port, "grpc.port (GRPC_PORT): must differ from http.port")
+	check(c.Metrics.Port >= 0 && c.Metrics.Port <= 65535, "metrics.port (METRICS_PORT): %d is not a valid port", c.Metrics.Port)
+	check(c.Metrics.Port == 0 || (c.Metrics.Port != c.HTTP.Port && c.Metrics.Port != c.GRPC.Port),
+		"metrics.port (METRICS_PORT): must differ from http.port and grpc.port")
+	check(c.Metrics.CacheTTL > 0, "metrics.cache_ttl (METRICS_CACHE_TTL): must be positive")
+	for name, d := range map[string]time.Duration{
+		"http.read_header_timeout (HTTP_READ_HEADER_TIMEOUT)": c.HTTP.ReadHeaderTimeout,
+		"http.read_timeout (HTTP_READ_TIMEOUT)":               c.HTTP.ReadTimeout,
+		"http.write_timeout (HTTP_WRITE_TIMEOUT)":             c.HTTP.WriteTimeout,
+		"http.idle_timeout (HTTP_IDLE_TIMEOUT)":               c.HTTP.IdleTimeout,
+		"http.shutdown_timeout (SHUTDOWN_TIMEOUT)":            c.HTTP.ShutdownTimeout,
+	} {
+		check(d > 0, "%s: must be positive", name)
+	}
+	check(c.HTTP.DrainPeriod >= 0, "http.drain_period (SHUTDOWN_DRAIN_PERIOD): must not be negative")
+
+	check(c.Database.MaxConns > 0, "database.max_conns (DATABASE_MAX_CONNS): must be positive")
+	check(c.Database.MinConns >= 0 && c.Database.MinConns <= c.Database.MaxConns,
+		"database.min_conns (DATABASE_MIN_CONNS): must be between 0 and max_conns (%d)", c.Database.MaxConns)
+	check(c.Database.MaxConnLifetime > 0, "database.max_conn_lifetime (DATABASE_MAX_CONN_LIFETIME): must be positive")
+	check(c.Database.MaxConnIdleTime > 0, "database.max_conn_idle_time (DATABASE_MAX_CONN_IDLE_TIME): must be positive")
+
+	keys, err := auth.ParseKeys(c.Auth.APIKeys)
+	check(err == nil, "auth.api_keys (API_KEYS): %v", err)
+	check(err != nil || keys.Len() > 0, "auth.api_keys (API_KEYS): at least one key is required")
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin != "*" || !c.CORS.AllowCredentials, `cors.allowed_origins (CORS_ALLOWED_ORIGINS): "*" can not be combined with allow_credentials`)
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age (CORS_MAX_AGE): must not be negative")
+
+	_, err = ratelimit.ParseLimit(c.RateLimit.Default)
+	check(err == nil, "rate_limit.default (RATE_LIMIT_DEFAULT): %v", err)
+	_, err = ratelimit.ParseRouteLimits(c.RateLimit.Routes)
+	check(err == nil, "rate_limit.routes (RATE_LIMIT_ROUTES): %v", err)
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "postgres",
+		"rate_limit.store (RATE_LIMIT_STORE): %q is not one of memory, postgres", c.RateLimit.Store)
+
+	check(c.Idempotency.TTL > 0, "idempotency.ttl (IDEMPOTENCY_TTL): must be positive")
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		check(false, "tracing.exporter (TRACING_EXPORTER): %q is not one of none, stdout, otlp", c.Tracing.Exporter)
+	}
+
+	check(c.Retention.SoftDeleted >= 0, "retention.soft_deleted (SOFT_DELETE_RETENTION): must not be negative")
+	check(c.Retention.PurgeInterval > 0, "retention.purge_interval (SOFT_DELETE_PURGE_INTERVAL): must be positive")
+
+	check(c.Webhooks.DispatchInterval > 0, "webhooks.dispatch_interval (WEBHOOK_DISPATCH_INTERVAL): must be positive")
+	check(c.Webhooks.BatchSize > 0, "webhooks.batch_size (WEBHOOK_BATCH_SIZE): must be positive")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout (WEBHOOK_TIMEOUT): must be positive")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts (WEBHOOK_MAX_ATTEMPTS): must be positive")
+	check(c.Webhooks.InitialBackoff > 0, "webhooks.initial_backoff (WEBHOOK_BACKOFF_INITIAL): must be positive")
+	check(c.Webhooks.MaxBackoff >= c.Webhooks.InitialBackoff, "webhooks.max_backoff (WEBHOOK_BACKOFF_MAX): must not be below initial_backoff")
+	check(c.Webhooks.RenewalScanInterval >= 0, "webhooks.renewal_scan_interval (WEBHOOK_RENEWAL_SCAN_INTERVAL): must not be negative")
+
+	for _, p := range c.Outbox.Publishers {
+		switch p {
+		case PublisherLog, PublisherWebhook:
+		case PublisherKafkaREST:
+			check(c.Outbox.KafkaRESTURL != "", "outbox.kafka_rest_url (OUTBOX_KAFKA_REST_URL): is required by the kafka_rest publisher")
+			check(c.Outbox.Topic != "", "outbox.topic (OUTBOX_TOPIC): is required by the kafka_rest publisher")
+		default:
+			check(false, "outbox.publishers (OUTBOX_PUBLISHERS): %q is not one of log, webhook, kafka_rest", p)
+		}
+	}
+	check(c.Outbox.RelayInterval > 0, "outbox.relay_interval (OUTBOX_RELAY_INTERVAL): must be positive")
+	check(c.Outbox.BatchSize > 0, "outbox.batch_size (OUTBOX_BATCH_SIZE): must be positive")
+	check(c.Outbox.InitialBackoff > 0, "outbox.initial_backoff (OUTBOX_BACKOFF_INITIAL): must be positive")
+	check(c.Outbox.MaxBackoff >= c.Outbox.InitialBackoff, "outbox.max_backoff (OUTBOX_BACKOFF_MAX): must not be below initial_backoff")
+	check(c.Outbox.Retention > 0, "outbox.retention (OUTBOX_RETENTION): must be positive")
+	check(c.Feed.Retention > 0, "feed.retention (FEED_RETENTION): must be positive")
+	check(c.Feed.Heartbeat > 0, "feed.heartbeat (FEED_HEARTBEAT): must be positive")
+	check(c.Reminders.Interval >= 0, "reminders.interval (REMINDER_INTERVAL): must not be negative")
+	if c.Reminders.SMTPAddr != "" {
+		_, _, err := net.SplitHostPort(c.Reminders.SMTPAddr)
+		check(err == nil, "reminders.smtp_addr (SMTP_ADDR): want host:port")
+		_, err = mail.ParseAddress(c.Reminders.SMTPFrom)
+		check(err == nil, "reminders.smtp_from (SMTP_FROM): not a valid address")
+		check(c.Reminders.SMTPTimeout > 0, "reminders.smtp_timeout (SMTP_TIMEOUT): must be positive")
+	}
+	if c.Telegram.BotToken != "" {
+		u, err := url.Parse(c.Telegram.APIURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "telegram.api_url (TELEGRAM_API_URL): want an http(s) URL")
+		check(c.Telegram.PollTimeout >= time.Second, "telegram.poll_timeout (TELEGRAM_POLL_TIMEOUT): must be at least 1s")
+		check(c.Telegram.LinkCodeTTL > 0, "telegram.link_code_ttl (TELEGRAM_LINK_CODE_TTL): must be positive")
+	}
+	_, err = jobs.ParseSchedules(c.Jobs.Schedules)
+	check(err == nil, "jobs.schedules (JOB_SCHEDULES): %v", err)
+	check(c.Jobs.HistoryRetention > 0, "jobs.history_retention (JOB_HISTORY_RETENTION): must be positive")
+
+	return problemsError(problems)
+}
+
+// problemsError sorts the problems so that the report does not depend on the
+// order checks ran in.
+func problemsError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return &ValidationError{Problems: problems}
+}
+
+const redacted = "REDACTED"
+
+// Redacted returns a copy safe to print: secrets are masked, including the
+// passwords in the database connection string.
+func (c Config) Redacted() Config {
+	if c.Auth.AdminToken != "" {
+		c.Auth.AdminToken = redacted
+	}
+	if c.Auth.APIKeys != "" {
+		c.Auth.APIKeys = redacted
+	}
+	if c.Reminders.SMTPPassword != "" {
+		c.Reminders.SMTPPassword = redacted
+	}
+	if c.Telegram.BotToken != "" {
+		c.Telegram.BotToken = redacted
+	}
+	if c.Database.URL != "" {
+		c.Database.URL = redactDSN(c.Database.URL)
+	}
+	return c
+}
+
+// dsnSecrets are the connection parameters that hold passwords.
+var dsnSecrets = []string{"password", "sslpassword"}
+
+// dsnKeywordSecret matches a password in a keyword/value connection string,
+// where the value may be single-quoted with backslash escapes.
+var dsnKeywordSecret = regexp.MustCompile(`\b((?:ssl)?password)\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactDSN masks the passwords of a connection string in either of the forms
+// pgconn accepts: a URL, with the password in the user info or the query, or
+// keyword/value pairs.
+func redactDSN(dsn string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsnKeywordSecret.ReplaceAllString(dsn, "${1}="+redacted)
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	query := u.Query()
+	masked := false
+	for _, key := range dsnSecrets {
+		if query.Has(key) {
+			query.Set(key, redacted)
+			masked = true
+		}
+	}
+	if masked {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
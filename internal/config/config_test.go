@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testDSN  = "postgres://app:s3cret@db:5432/subs?sslmode=disable"
+	testKeys = "acme:billing=acme-billing-key-0123456789"
+)
+
+func validConfig() Config {
+	cfg := Default()
+	cfg.Database.URL = testDSN
+	cfg.Auth.APIKeys = testKeys
+	return cfg
+}
+
+func problems(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want a *ValidationError", err)
+	}
+	return verr.Problems
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		edit func(c *Config)
+		mode Mode
+		want []string
+	}{
+		"valid": {edit: func(*Config) {}},
+		"keyword DSN": {
+			edit: func(c *Config) { c.Database.URL = "host=db user=app password=s3cret dbname=subs" },
+		},
+		"missing DSN": {
+			edit: func(c *Config) { c.Database.URL = "" },
+			want: []string{"database.url (DATABASE_URL): is required"},
+		},
+		"broken DSN": {
+			edit: func(c *Config) { c.Database.URL = "postgres://app:s3cret@db:port/subs" },
+			want: []string{"database.url (DATABASE_URL): not a valid connection string"},
+		},
+		"metrics on the API port": {
+			edit: func(c *Config) { c.Metrics.Port = c.HTTP.Port },
+			want: []string{"metrics.port (METRICS_PORT): must differ from http.port and grpc.port"},
+		},
+		"metrics disabled": {
+			edit: func(c *Config) { c.Metrics.Port = 0 },
+		},
+		"several timeouts": {
+			edit: func(c *Config) {
+				c.HTTP.WriteTimeout, c.HTTP.IdleTimeout, c.HTTP.ReadTimeout = 0, 0, -time.Second
+			},
+			want: []string{
+				"http.idle_timeout (HTTP_IDLE_TIMEOUT): must be positive",
+				"http.read_timeout (HTTP_READ_TIMEOUT): must be positive",
+				"http.write_timeout (HTTP_WRITE_TIMEOUT): must be positive",
+			},
+		},
+		"serving settings are ignored by migrate": {
+			edit: func(c *Config) { c.Auth.APIKeys, c.HTTP.Port = "", 0 },
+			mode: ModeMigrate,
+		},
+		"migrate still needs the database": {
+			edit: func(c *Config) { c.Database.URL, c.Log.Level = "", "loud" },
+			mode: ModeMigrate,
+			want: []string{
+				"database.url (DATABASE_URL): is required",
+				`log.level (LOG_LEVEL): "loud" is not one of debug, info, warn, error`,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.edit(&cfg)
+			if got := problems(t, cfg.Validate(tt.mode)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problems =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidateReportsProblemsInAStableOrder(t *testing.T) {
+	cfg := validConfig()
+	cfg.HTTP = HTTP{}
+	cfg.Auth.APIKeys = ""
+	cfg.Outbox.BatchSize = 0
+
+	first := problems(t, cfg.Validate(ModeServe))
+	if len(first) < 8 {
+		t.Fatalf("got %d problems, want every broken setting reported", len(first))
+	}
+	if !sort.StringsAreSorted(first) {
+		t.Errorf("problems are not sorted:\n%s", strings.Join(first, "\n"))
+	}
+	for i := 0; i < 20; i++ {
+		if again := problems(t, cfg.Validate(ModeServe)); !reflect.DeepEqual(again, first) {
+			t.Fatalf("problems changed between runs:\n%s\nthen\n%s", strings.Join(first, "\n"), strings.Join(again, "\n"))
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	tests := map[string]struct {
+		dsn  string
+		want string
+	}{
+		"URL with user info": {
+			dsn:  "postgres://app:s3cret@db:5432/subs?sslmode=disable",
+			want: "postgres://app:REDACTED@db:5432/subs?sslmode=disable",
+		},
+		"URL with password parameters": {
+			dsn:  "postgresql://app@db/subs?password=s3cret&sslmode=require&sslpassword=k3y",
+			want: "postgresql://app@db/subs?password=REDACTED&sslmode=require&sslpassword=REDACTED",
+		},
+		"URL without password": {
+			dsn:  "postgres://app@db/subs?sslmode=disable",
+			want: "postgres://app@db/subs?sslmode=disable",
+		},
+		"keyword/value": {
+			dsn:  "host=db user=app password=s3cret dbname=subs",
+			want: "host=db user=app password=REDACTED dbname=subs",
+		},
+		"keyword/value with quoting and spaces": {
+			dsn:  `host=db password = 'it\'s s3cret' sslpassword=k3y dbname=subs`,
+			want: "host=db password=REDACTED sslpassword=REDACTED dbname=subs",
+		},
+		"keyword/value without password": {
+			dsn:  "host=db user=app dbname=subs passfile=/run/pgpass",
+			want: "host=db user=app dbname=subs passfile=/run/pgpass",
+		},
+		"unparsable URL": {
+			dsn:  "postgres://app:s3cret@db:port/subs",
+			want: "REDACTED",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Database.URL = tt.dsn
+			got := cfg.Redacted().Database.URL
+			if got != tt.want {
+				t.Errorf("Redacted URL = %q, want %q", got, tt.want)
+			}
+			for _, secret := range []string{"s3cret", "k3y"} {
+				if strings.Contains(got, secret) {
+					t.Errorf("Redacted URL %q still contains %s", got, secret)
+				}
+			}
+		})
+	}
+
+	cfg := validConfig()
+	cfg.Auth.AdminToken = "admin-token"
+	cfg.Reminders.SMTPPassword = "smtp-password"
+	cfg.Telegram.BotToken = "bot-token"
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		t.Fatalf("YAML: %v", err)
+	}
+	for _, secret := range []string{"s3cret", "admin-token", "smtp-password", "bot-token", "acme-billing-key"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("redacted YAML contains %s", secret)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable that may point at a YAML config
+// file; the --config flag takes precedence over it.
+const FileEnv = "CONFIG_FILE"
+
+// Options describe how the process was invoked.
+type Options struct {
+	// PrintConfig asks the caller to print the redacted configuration and exit.
+	PrintConfig bool
+	// Args are the positional arguments left after the flags.
+	Args []string
+}
+
+// Load builds the configuration from defaults, the YAML file, environment
+// variables and flags parsed from args, in that order of precedence, and
+// validates what mode needs of the result.
+func Load(name string, mode Mode, args []string, lookupEnv func(string) (string, bool)) (Config, Options, error) {
+	cfg := Default()
+	var opts Options
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", "", "path to a YAML config file (env "+FileEnv+")")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+
+	leaves := fields(&cfg)
+	flagValues := make(map[string]*string, len(leaves))
+	for _, f := range leaves {
+		flagValues[f.env] = fs.String(f.flag, "", f.usage+" (env "+f.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return Config{}, opts, err
+	}
+	opts.Args = fs.Args()
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(FileEnv)
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, opts, err
+		}
+	}
+
+	set := make(map[string]bool, len(leaves))
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var problems []string
+	for _, f := range leaves {
+		raw, source := "", ""
+		if set[f.flag] {
+			raw, source = *flagValues[f.env], "--"+f.flag
+		} else if v, ok := lookupEnv(f.env); ok && v != "" {
+			raw, source = v, f.env
+		} else {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", source, err))
+		}
+	}
+	if len(problems) > 0 {
+		return Config{}, opts, &ValidationError{Problems: problems}
+	}
+
+	if err := cfg.Validate(mode); err != nil {
+		return Config{}, opts, err
+	}
+	return cfg, opts, nil
+}
+
+// YAML renders the configuration in the same shape the config file accepts.
+func (c Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, errors.Wrap(err, "yaml.Encode")
+	}
+	return buf.Bytes(), nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "read config file")
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return errors.Wrapf(err, "parse config file %s", path)
+	}
+	return nil
+}
+
+type field struct {
+	env   string
+	flag  string
+	usage string
+	value reflect.Value
+}
+
+// fields walks the config struct and returns every leaf carrying an env tag.
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf, fv := t.Field(i), v.Field(i)
+			if sf.Type.Kind() == reflect.Struct {
+				walk(fv)
+				continue
+			}
+			env := sf.Tag.Get("env")
+			if env == "" {
+				continue
+			}
+			out = append(out, field{
+				env:   env,
+				flag:  strings.ReplaceAll(strings.ToLower(env), "_", "-"),
+				usage: sf.Tag.Get("usage"),
+				value: fv,
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return errors.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(`
+http:
+  port: 8081
+  write_timeout: 1m
+log:
+  level: warn
+database:
+  url: postgres://file@db/subs
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	base := map[string]string{"API_KEYS": testKeys, "DATABASE_URL": testDSN}
+
+	tests := map[string]struct {
+		env  map[string]string
+		args []string
+		port int
+		lvl  string
+		dsn  string
+		wt   time.Duration
+	}{
+		"defaults": {
+			env:  base,
+			port: 8080, lvl: "info", dsn: testDSN, wt: Default().HTTP.WriteTimeout,
+		},
+		"file over defaults": {
+			env:  map[string]string{"API_KEYS": testKeys, FileEnv: file},
+			port: 8081, lvl: "warn", dsn: "postgres://file@db/subs", wt: time.Minute,
+		},
+		"env over file": {
+			env:  map[string]string{"API_KEYS": testKeys, FileEnv: file, "PORT": "8082", "DATABASE_URL": testDSN},
+			port: 8082, lvl: "warn", dsn: testDSN, wt: time.Minute,
+		},
+		"flags over env": {
+			env:  map[string]string{"API_KEYS": testKeys, FileEnv: file, "PORT": "8082", "LOG_LEVEL": "error"},
+			args: []string{"--port", "8083", "--log-level", "debug"},
+			port: 8083, lvl: "debug", dsn: "postgres://file@db/subs", wt: time.Minute,
+		},
+		"--config over the env file": {
+			env:  map[string]string{"API_KEYS": testKeys, "DATABASE_URL": testDSN, FileEnv: filepath.Join(t.TempDir(), "missing.yaml")},
+			args: []string{"--config", file},
+			port: 8081, lvl: "warn", dsn: testDSN, wt: time.Minute,
+		},
+		"empty env is unset": {
+			env:  map[string]string{"API_KEYS": testKeys, "DATABASE_URL": testDSN, "PORT": ""},
+			port: 8080, lvl: "info", dsn: testDSN, wt: Default().HTTP.WriteTimeout,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, _, err := Load("test", ModeServe, tt.args, env(tt.env))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.HTTP.Port != tt.port || cfg.Log.Level != tt.lvl || cfg.Database.URL != tt.dsn || cfg.HTTP.WriteTimeout != tt.wt {
+				t.Errorf("port %d, level %s, url %s, write timeout %s; want %d, %s, %s, %s",
+					cfg.HTTP.Port, cfg.Log.Level, cfg.Database.URL, cfg.HTTP.WriteTimeout, tt.port, tt.lvl, tt.dsn, tt.wt)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	unknownKey := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(unknownKey, []byte("http:\n  prot: 8080\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		env  map[string]string
+		args []string
+	}{
+		"bad env value":          {env: map[string]string{"API_KEYS": testKeys, "DATABASE_URL": testDSN, "PORT": "http"}},
+		"bad flag value":         {env: map[string]string{"API_KEYS": testKeys, "DATABASE_URL": testDSN}, args: []string{"--http-write-timeout", "soon"}},
+		"unknown flag":           {env: map[string]string{"API_KEYS": testKeys, "DATABASE_URL": testDSN}, args: []string{"--no-such-flag"}},
+		"unknown key in file":    {env: map[string]string{"API_KEYS": testKeys, "DATABASE_URL": testDSN}, args: []string{"--config", unknownKey}},
+		"missing config file":    {env: map[string]string{"API_KEYS": testKeys, "DATABASE_URL": testDSN}, args: []string{"--config", unknownKey + ".missing"}},
+		"invalid after overlays": {env: map[string]string{"DATABASE_URL": testDSN}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := Load("test", ModeServe, tt.args, env(tt.env)); err == nil {
+				t.Error("Load succeeded")
+			}
+		})
+	}
+
+	if _, opts, err := Load("test", ModeMigrate, []string{"--print-config", "up"}, env(map[string]string{"DATABASE_URL": testDSN})); err != nil ||
+		!opts.PrintConfig || len(opts.Args) != 1 || opts.Args[0] != "up" {
+		t.Errorf("Load for migrate = %+v, %v; want print-config and the positional up", opts, err)
+	}
+}
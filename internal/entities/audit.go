@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreate  AuditAction = "create"
+	AuditActionUpdate  AuditAction = "update"
+	AuditActionDelete  AuditAction = "delete"
+	AuditActionRestore AuditAction = "restore"
+	AuditActionRenew   AuditAction = "renew"
+	AuditActionExpire  AuditAction = "expire"
+)
+
+// AuditEntry is an immutable record of a single subscription mutation.
+// Before is empty for creates and After is empty for deletes. Principal is
+// the authenticated caller; Actor is whoever the caller claimed to act for
+// and is not verified.
+type AuditEntry struct {
+	ID             int64
+	SubscriptionID int64
+	UserID         string
+	Action         AuditAction
+	Principal      string
+	Actor          string
+	RequestID      string
+	Before         json.RawMessage
+	After          json.RawMessage
+	CreatedAt      time.Time
+}
+
+// AuditFilter narrows an audit query; zero-valued fields are ignored.
+// Entries come newest first; BeforeID pages on by returning only entries
+// older than the one with that ID.
+type AuditFilter struct {
+	SubscriptionID int64
+	UserID         string
+	Principal      string
+	Actor          string
+	Action         AuditAction
+	From           time.Time
+	To             time.Time
+	BeforeID       int64
+	Limit          int
+}
@@ -0,0 +1,55 @@
+package entities
+
+import "time"
+
+// Budget is a monthly spending limit of a user. With Services it only covers
+// the subscriptions of those services: one service or a category of several.
+type Budget struct {
+	ID       int64
+	TenantID string
+	UserID   string
+	Name     string
+	Limit    int
+	Currency string
+	Services []string
+	// AlertedPeriod is the last month, MM-YYYY, an overspend alert was
+	// published for; changing the budget clears it.
+	AlertedPeriod string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Covers reports whether the budget counts the charges of the service.
+func (b Budget) Covers(serviceName string) bool {
+	if len(b.Services) == 0 {
+		return true
+	}
+	for _, s := range b.Services {
+		if s == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+// BudgetStatus is the spend of a budget in one month, read from the charges
+// in its currency. Spent is what was paid so far; Projected adds the charges
+// still expected, so it is what the month costs if nothing else changes.
+type BudgetStatus struct {
+	Budget
+	// Period is the evaluated month, MM-YYYY.
+	Period    string
+	Spent     int
+	Projected int
+}
+
+// Exceeded reports whether the month is projected to go over the limit.
+func (s BudgetStatus) Exceeded() bool {
+	return s.Projected > s.Limit
+}
+
+// Remaining is what is left of the limit after the projected spend; negative
+// when exceeded.
+func (s BudgetStatus) Remaining() int {
+	return s.Limit - s.Projected
+}
@@ -0,0 +1,76 @@
+package entities
+
+import "time"
+
+type ChargeStatus string
+
+const (
+	// ChargeStatusExpected is a month the subscription is billed for.
+	ChargeStatusExpected ChargeStatus = "expected"
+	ChargeStatusPaid     ChargeStatus = "paid"
+	ChargeStatusRefunded ChargeStatus = "refunded"
+	// ChargeStatusSkipped is a month that is not paid for, e.g. a free one.
+	ChargeStatusSkipped  ChargeStatus = "skipped"
+	ChargeStatusDisputed ChargeStatus = "disputed"
+)
+
+// chargeTransitions lists the statuses a charge may come from for each
+// status it can be marked with. A paid charge may be paid again to correct
+// the amount or the day.
+var chargeTransitions = map[ChargeStatus][]ChargeStatus{
+	ChargeStatusPaid:     {ChargeStatusExpected, ChargeStatusPaid, ChargeStatusSkipped, ChargeStatusDisputed},
+	ChargeStatusRefunded: {ChargeStatusPaid, ChargeStatusDisputed},
+	ChargeStatusSkipped:  {ChargeStatusExpected},
+	ChargeStatusDisputed: {ChargeStatusExpected, ChargeStatusPaid},
+}
+
+// CanBecome reports whether a charge in status s may be marked with to.
+func (s ChargeStatus) CanBecome(to ChargeStatus) bool {
+	for _, from := range chargeTransitions[to] {
+		if from == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Charge is the ledger entry of one billed month of a subscription. Its
+// Amount is the price when the month was billed and stays fixed for past
+// months when the price changes.
+type Charge struct {
+	ID       int64
+	TenantID string
+	// SubscriptionID is zero once the subscription is purged.
+	SubscriptionID int64
+	UserID         string
+	ServiceName    string
+	// Period is the billed month, MM-YYYY.
+	Period   string
+	Amount   int
+	Currency string
+	Status   ChargeStatus
+	// PaidAmount and PaidOn record the payment of a paid charge; they are
+	// kept when it is refunded or disputed afterwards.
+	PaidAmount *int
+	PaidOn     *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// ChargeMark changes the status of a charge. Amount and PaidOn only apply
+// to ChargeStatusPaid and default to the charge's amount and today.
+type ChargeMark struct {
+	Status ChargeStatus
+	Amount *int
+	PaidOn *time.Time
+}
+
+// CostMode selects what cost totals add up.
+type CostMode string
+
+const (
+	// CostModeExpected adds up the amounts billed, except skipped months.
+	CostModeExpected CostMode = "expected"
+	// CostModeActual adds up what was paid and not refunded.
+	CostModeActual CostMode = "actual"
+)
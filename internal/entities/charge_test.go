@@ -0,0 +1,44 @@
+package entities_test
+
+import (
+	"testing"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func TestChargeStatusCanBecome(t *testing.T) {
+	statuses := []en.ChargeStatus{
+		en.ChargeStatusExpected,
+		en.ChargeStatusPaid,
+		en.ChargeStatusRefunded,
+		en.ChargeStatusSkipped,
+		en.ChargeStatusDisputed,
+	}
+	allowed := map[en.ChargeStatus][]en.ChargeStatus{
+		en.ChargeStatusExpected: {en.ChargeStatusPaid, en.ChargeStatusSkipped, en.ChargeStatusDisputed},
+		en.ChargeStatusPaid:     {en.ChargeStatusPaid, en.ChargeStatusRefunded, en.ChargeStatusDisputed},
+		en.ChargeStatusRefunded: {},
+		en.ChargeStatusSkipped:  {en.ChargeStatusPaid},
+		en.ChargeStatusDisputed: {en.ChargeStatusPaid, en.ChargeStatusRefunded},
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, status := range allowed[from] {
+				want = want || status == to
+			}
+			if got := from.CanBecome(to); got != want {
+				t.Errorf("%s.CanBecome(%s) = %t, want %t", from, to, got, want)
+			}
+		}
+	}
+	for _, status := range statuses {
+		if en.ChargeStatus("unknown").CanBecome(status) {
+			t.Errorf("unknown status can become %s", status)
+		}
+		if status.CanBecome("unknown") || status.CanBecome("") {
+			t.Errorf("%s can become an unknown status", status)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package entities
+
+import "context"
+
+// AnonymousPrincipal is recorded for changes made outside of any
+// authenticated caller.
+const AnonymousPrincipal = "anonymous"
+
+type (
+	actorKey     struct{}
+	principalKey struct{}
+	requestIDKey struct{}
+)
+
+// WithActor returns a copy of ctx carrying the person the caller claims to
+// act for. Nothing verifies the claim; it is kept as metadata only.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the claimed actor stored in ctx, if any.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller,
+// which unlike the actor is not claimed by the caller itself.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller stored in ctx, if any.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// AuditPrincipal returns the principal stored in ctx or AnonymousPrincipal.
+func AuditPrincipal(ctx context.Context) string {
+	if principal := PrincipalFromContext(ctx); principal != "" {
+		return principal
+	}
+	return AnonymousPrincipal
+}
+
+// WithRequestID returns a copy of ctx carrying the ID of the request being served.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -0,0 +1,7 @@
+package entities
+
+// ServiceCost is the total of one service's charges over a period.
+type ServiceCost struct {
+	ServiceName string
+	TotalCost   int
+}
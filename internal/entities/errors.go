@@ -5,4 +5,27 @@ import "errors"
 var (
 	ErrNilDependency        = errors.New("nil dependency: ")
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrSubscriptionExists   = errors.New("subscription already exists")
+	ErrTenantRequired       = errors.New("tenant is required")
+	ErrUnauthenticated      = errors.New("a valid API key is required")
+	ErrTenantMismatch       = errors.New("API key does not belong to the requested tenant")
+	ErrInvalidPeriod        = errors.New("invalid period, want MM-YYYY")
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrInvalidWebhook       = errors.New("invalid webhook")
+
+	ErrNotificationSettingsNotFound = errors.New("notification settings not found")
+	ErrInvalidNotificationSettings  = errors.New("invalid notification settings")
+	ErrInvalidLinkCode              = errors.New("invalid or expired link code")
+	ErrChatNotLinked                = errors.New("chat is not linked to a user")
+	ErrJobNotFound                  = errors.New("job not found")
+	ErrJobRunning                   = errors.New("job is already running")
+	ErrChargeNotFound               = errors.New("charge not found")
+	ErrInvalidCharge                = errors.New("invalid charge")
+	ErrChargeTransition             = errors.New("charge can not change to this status")
+	ErrInvalidCostMode              = errors.New("invalid cost mode, want expected or actual")
+	ErrBudgetNotFound               = errors.New("budget not found")
+	ErrBudgetExists                 = errors.New("budget with this name already exists")
+	ErrInvalidBudget                = errors.New("invalid budget")
+	ErrIdempotencyKeyInUse          = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused         = errors.New("idempotency key was already used for a different request")
 )
@@ -0,0 +1,68 @@
+package entities
+
+import "time"
+
+type EventType string
+
+const (
+	EventSubscriptionCreated  EventType = "subscription.created"
+	EventSubscriptionUpdated  EventType = "subscription.updated"
+	EventSubscriptionDeleted  EventType = "subscription.deleted"
+	EventSubscriptionRenewing EventType = "subscription.renewing"
+	// EventSubscriptionCharged records a renewal charging the subscription's
+	// price for the month in its BilledThrough.
+	EventSubscriptionCharged EventType = "subscription.charged"
+	EventSubscriptionExpired EventType = "subscription.expired"
+	// EventBudgetExceeded records a change of the subscription taking the
+	// projected spend of a budget over its limit; Event.Budget holds it.
+	EventBudgetExceeded EventType = "budget.exceeded"
+)
+
+// EventTypes lists every event type in a stable order.
+var EventTypes = []EventType{
+	EventSubscriptionCreated,
+	EventSubscriptionUpdated,
+	EventSubscriptionDeleted,
+	EventSubscriptionRenewing,
+	EventSubscriptionCharged,
+	EventSubscriptionExpired,
+	EventBudgetExceeded,
+}
+
+// EventType returns the event a mutation of this kind emits; a restore is an
+// update that clears the deletion.
+func (a AuditAction) EventType() EventType {
+	switch a {
+	case AuditActionCreate:
+		return EventSubscriptionCreated
+	case AuditActionDelete:
+		return EventSubscriptionDeleted
+	case AuditActionRenew:
+		return EventSubscriptionCharged
+	case AuditActionExpire:
+		return EventSubscriptionExpired
+	default:
+		return EventSubscriptionUpdated
+	}
+}
+
+// Event is a change of a subscription that downstream systems may react to.
+// Subscription holds the state after the change, including DeletedAt for
+// deletes.
+type Event struct {
+	ID           string
+	Type         EventType
+	TenantID     string
+	UserID       string
+	Subscription Subscription
+	// Budget is the exceeded budget of an EventBudgetExceeded.
+	Budget     *BudgetStatus
+	OccurredAt time.Time
+}
+
+// FeedEvent is an event of a user's change feed; Seq orders the feed and lets
+// readers resume after the last event they saw.
+type FeedEvent struct {
+	Seq int64
+	Event
+}
@@ -0,0 +1,52 @@
+package entities
+
+import "time"
+
+// Locales the notifications are translated to; the first one is the default.
+const (
+	LocaleEN = "en"
+	LocaleRU = "ru"
+)
+
+var Locales = []string{LocaleEN, LocaleRU}
+
+// DefaultLeadDays is how many days before a renewal the reminder is sent
+// unless the user chose otherwise.
+const DefaultLeadDays = 3
+
+// MaxLeadDays bounds the reminder lead time.
+const MaxLeadDays = 28
+
+// NotificationChannel is the medium a notification is delivered through.
+type NotificationChannel string
+
+const ChannelEmail NotificationChannel = "email"
+
+// NotificationSettings are a user's preferences for renewal reminders.
+type NotificationSettings struct {
+	TenantID  string
+	UserID    string
+	Email     string
+	Locale    string
+	LeadDays  int
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+// RenewalReminder is a subscription renewing on RenewsOn, the first day after
+// its end month, addressed to its owner according to their settings.
+type RenewalReminder struct {
+	Subscription Subscription
+	Settings     NotificationSettings
+	RenewsOn     time.Time
+}
+
+// Notification is a rendered message for one user; To is their address on
+// the channel it is sent through.
+type Notification struct {
+	UserID  string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
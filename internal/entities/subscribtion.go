@@ -1,11 +1,24 @@
 package entities
 
+import "time"
+
 type Subscription struct {
+	ID          int64
+	TenantID    string
 	ServiceName string
 	Price       int
 	UserID      string
 	StartDate   string
 	EndDate     string
+	DeletedAt   *time.Time
+	// AutoRenew extends EndDate by a month whenever it passes instead of
+	// letting the subscription expire.
+	AutoRenew bool
+	// ExpiredAt is set once the end month of a subscription that does not
+	// renew has passed.
+	ExpiredAt *time.Time
+	// BilledThrough is the last month (MM-YYYY) charged by a renewal.
+	BilledThrough string
 }
 
 func NewSubscription(serviceName, userID, startDate, endDate string, price int) (*Subscription, error) {
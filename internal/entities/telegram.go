@@ -0,0 +1,22 @@
+package entities
+
+import "time"
+
+const ChannelTelegram NotificationChannel = "telegram"
+
+// TelegramChat is a Telegram chat linked to a user; the bot acts on behalf
+// of that user in the user's tenant.
+type TelegramChat struct {
+	TenantID string
+	UserID   string
+	ChatID   int64
+	Locale   string
+	LinkedAt time.Time
+}
+
+// ChatLinkCode is a one-time code the user sends to the bot to link a chat.
+type ChatLinkCode struct {
+	Code      string
+	UserID    string
+	ExpiresAt time.Time
+}
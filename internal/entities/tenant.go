@@ -0,0 +1,19 @@
+package entities
+
+import "context"
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant the request acts on behalf of.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant stored in ctx or ErrTenantRequired if there is none.
+func TenantFromContext(ctx context.Context) (string, error) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	if !ok || tenantID == "" {
+		return "", ErrTenantRequired
+	}
+	return tenantID, nil
+}
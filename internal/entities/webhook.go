@@ -0,0 +1,58 @@
+package entities
+
+import "time"
+
+// Webhook is an endpoint registered to receive events of one tenant, either
+// for a single user or, with an empty UserID, for all of them.
+type Webhook struct {
+	ID       int64
+	TenantID string
+	UserID   string
+	URL      string
+	// Secret signs every delivery; it is only returned on registration.
+	Secret string
+	// Events the endpoint receives; empty means all of them.
+	Events    []EventType
+	CreatedAt time.Time
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead marks a delivery that ran out of attempts.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event queued for one endpoint together with the
+// outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             int64
+	TenantID       string
+	WebhookID      int64
+	EventID        string
+	EventType      EventType
+	Payload        []byte
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus int
+	LastError      string
+	CreatedAt      time.Time
+
+	// URL and Secret of the endpoint, filled in when a delivery is claimed
+	// for sending.
+	URL    string
+	Secret string
+}
+
+// WebhookAttempt is the outcome of sending a delivery once.
+type WebhookAttempt struct {
+	DeliveryID     int64
+	Status         DeliveryStatus
+	NextAttemptAt  time.Time
+	ResponseStatus int
+	Error          string
+}
@@ -0,0 +1,50 @@
+// Package idempotency makes retried POST requests safe: the response to the
+// first request carrying an Idempotency-Key is stored and replayed to repeats
+// of the same request, so a create retried after a lost response returns what
+// the first attempt created instead of a conflict.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// ClaimTimeout is how long a claimed key waits for its response. A claim
+// older than that belongs to a request that died with its replica, and the
+// next request with the key takes it over.
+const ClaimTimeout = time.Minute
+
+// Response is the stored outcome of a request.
+type Response struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Store keeps responses by tenant, taken from the context, and key.
+type Store interface {
+	// Claim reserves key for the request with fingerprint. It returns the
+	// stored response when the key was used for the same request before and
+	// nil when the caller now holds the key and has to Complete or Release
+	// it. It fails with en.ErrIdempotencyKeyInUse while the first request is
+	// still running and with en.ErrIdempotencyKeyReused when the key was
+	// used for a different request.
+	Claim(ctx context.Context, key, fingerprint string, now time.Time) (*Response, error)
+	// Complete stores the response to the request holding key.
+	Complete(ctx context.Context, key string, resp Response) error
+	// Release forgets a claimed key, so that a retry runs the request again.
+	Release(ctx context.Context, key string) error
+	// Purge deletes the keys of every tenant created before createdBefore.
+	Purge(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+// Fingerprint identifies a request by method, target and body, so that a key
+// reused for another request is told apart from a retry.
+func Fingerprint(method, target string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + target + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// MemoryStore keeps responses in process memory; a retry only finds them on
+// the replica that served the first attempt.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[memoryKey]*record
+}
+
+type memoryKey struct {
+	tenantID string
+	key      string
+}
+
+type record struct {
+	fingerprint string
+	createdAt   time.Time
+	// response is nil while the request holding the key runs.
+	response *Response
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[memoryKey]*record)}
+}
+
+func (m *MemoryStore) Claim(ctx context.Context, key, fingerprint string, now time.Time) (*Response, error) {
+	k, err := scoped(ctx, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "MemoryStore.Claim")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.records[k]
+	switch {
+	case !ok || (r.response == nil && now.Sub(r.createdAt) > ClaimTimeout):
+		m.records[k] = &record{fingerprint: fingerprint, createdAt: now}
+		return nil, nil
+	case r.fingerprint != fingerprint:
+		return nil, en.ErrIdempotencyKeyReused
+	case r.response == nil:
+		return nil, en.ErrIdempotencyKeyInUse
+	}
+	resp := *r.response
+	return &resp, nil
+}
+
+func (m *MemoryStore) Complete(ctx context.Context, key string, resp Response) error {
+	k, err := scoped(ctx, key)
+	if err != nil {
+		return errors.Wrap(err, "MemoryStore.Complete")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.records[k]; ok {
+		r.response = &resp
+	}
+	return nil
+}
+
+func (m *MemoryStore) Release(ctx context.Context, key string) error {
+	k, err := scoped(ctx, key)
+	if err != nil {
+		return errors.Wrap(err, "MemoryStore.Release")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.records[k]; ok && r.response == nil {
+		delete(m.records, k)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Purge(_ context.Context, createdBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for k, r := range m.records {
+		if r.createdAt.Before(createdBefore) {
+			delete(m.records, k)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func scoped(ctx context.Context, key string) (memoryKey, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return memoryKey{}, err
+	}
+	return memoryKey{tenantID: tenantID, key: key}, nil
+}
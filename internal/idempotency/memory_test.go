@@ -0,0 +1,39 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+func TestMemoryStoreTakesOverAbandonedClaim(t *testing.T) {
+	ctx := en.WithTenant(context.Background(), "acme")
+	store := NewMemoryStore()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if _, err := store.Claim(ctx, "k", "fp", now); err != nil {
+		t.Fatalf("first Claim: %v", err)
+	}
+	if _, err := store.Claim(ctx, "k", "fp", now.Add(ClaimTimeout/2)); !errors.Is(err, en.ErrIdempotencyKeyInUse) {
+		t.Fatalf("Claim while running = %v, want %v", err, en.ErrIdempotencyKeyInUse)
+	}
+	resp, err := store.Claim(ctx, "k", "fp", now.Add(2*ClaimTimeout))
+	if err != nil || resp != nil {
+		t.Fatalf("Claim after ClaimTimeout = %v, %v; want the key", resp, err)
+	}
+
+	if err := store.Complete(ctx, "k", Response{StatusCode: 201, Body: []byte("{}")}); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	// A completed key is replayed however old it is, until purged.
+	resp, err = store.Claim(ctx, "k", "fp", now.Add(time.Hour))
+	if err != nil || resp == nil || resp.StatusCode != 201 {
+		t.Fatalf("Claim after Complete = %v, %v; want the stored response", resp, err)
+	}
+	if n, _ := store.Purge(ctx, now.Add(3*ClaimTimeout)); n != 1 {
+		t.Errorf("Purge = %d, want 1", n)
+	}
+}
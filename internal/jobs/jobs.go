@@ -0,0 +1,67 @@
+// Package jobs runs periodic background work on cron-style schedules. Every
+// replica runs the same scheduler; a per-job lock in the store and the
+// persisted run history make each scheduled run happen on one replica only.
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// Func is the work of a job; now is the scheduled time of the run.
+type Func func(ctx context.Context, now time.Time) error
+
+// Run triggers.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Run statuses.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Run is one execution of a job.
+type Run struct {
+	ID          int64
+	Job         string
+	Trigger     string
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	FinishedAt  *time.Time
+	Status      string
+	Error       string
+	Instance    string
+}
+
+// Status describes a registered job.
+type Status struct {
+	Name     string
+	Schedule string
+	NextRun  time.Time
+	LastRun  *Run
+}
+
+// Store persists run history and provides the locks that keep a job from
+// running on two replicas at once.
+type Store interface {
+	// TryLock takes the job's lock without waiting; ok is false when another
+	// replica holds it. unlock must be called once the run is over.
+	TryLock(ctx context.Context, job string) (unlock func(), ok bool, err error)
+	// StartRun records run as running. A scheduled run is not started, and
+	// ok is false, when a run of the job for the same or a later scheduled
+	// time exists. Runs of the job left running by a crashed replica are
+	// marked failed. It must be called with the job's lock held.
+	StartRun(ctx context.Context, run Run) (started Run, ok bool, err error)
+	// FinishRun stores the outcome of a started run.
+	FinishRun(ctx context.Context, run Run) error
+	// LastRuns returns the latest run of every job that has run.
+	LastRuns(ctx context.Context) (map[string]Run, error)
+	// ListRuns returns the latest runs of the job, newest first.
+	ListRuns(ctx context.Context, job string, limit int) ([]Run, error)
+	// PurgeRuns removes runs started before the given time.
+	PurgeRuns(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule yields the run times of a job.
+type Schedule interface {
+	// Next returns the first run time strictly after t.
+	Next(t time.Time) time.Time
+}
+
+// ParseSchedule parses a five-field cron expression (minute, hour, day of
+// month, month, day of week; evaluated in UTC), one of @hourly, @daily,
+// @weekly and @monthly, or "@every <duration>". Interval schedules are
+// aligned to the Unix epoch, so every replica computes the same run times.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, errors.Wrapf(err, "schedule %q", spec)
+		}
+		if d < time.Second {
+			return nil, errors.Errorf("schedule %q: interval must be at least 1s", spec)
+		}
+		return every(d), nil
+	}
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("schedule %q: want 5 cron fields", spec)
+	}
+	var c cron
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, errors.Wrapf(err, "schedule %q: minute", spec)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, errors.Wrapf(err, "schedule %q: hour", spec)
+	}
+	if c.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, errors.Wrapf(err, "schedule %q: day of month", spec)
+	}
+	if c.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, errors.Wrapf(err, "schedule %q: month", spec)
+	}
+	if c.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, errors.Wrapf(err, "schedule %q: day of week", spec)
+	}
+	// Both 0 and 7 are Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.anyDOM = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	c.anyDOW = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+	return c, nil
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	d := time.Duration(e)
+	return t.Truncate(d).Add(d)
+}
+
+// cron holds the allowed values of each field as bit sets.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// Like in cron(8), a day matches either day field when both are
+	// restricted, and the other one when only one is.
+	anyDOM, anyDOW bool
+}
+
+// maxSearch bounds the search for expressions that never match, e.g. 30 February.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+func (c cron) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDOM && c.anyDOW:
+		return true
+	case c.anyDOM:
+		return dow
+	case c.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// parseField parses a comma-separated list of *, N, N-M, each optionally
+// followed by /STEP, into a bit set of values between lo and hi.
+func parseField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, errors.Errorf("invalid step %q", stepStr)
+			}
+		}
+		from, to := lo, hi
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err1, err2 error
+			from, err1 = strconv.Atoi(a)
+			to, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil {
+				return 0, errors.Errorf("invalid range %q", rng)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, errors.Errorf("invalid value %q", rng)
+			}
+			from = n
+			if !hasStep {
+				to = n
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, errors.Errorf("%q is outside %d-%d", item, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	if set == 0 {
+		return 0, errors.Errorf("empty field %q", field)
+	}
+	return set, nil
+}
+
+// ParseSchedules parses "name=spec;name=spec" schedule overrides, checking
+// every spec.
+func ParseSchedules(s string) (map[string]string, error) {
+	schedules := make(map[string]string)
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+		if !ok || name == "" {
+			return nil, errors.Errorf("%q: want name=schedule", item)
+		}
+		if _, err := ParseSchedule(spec); err != nil {
+			return nil, errors.Wrapf(err, "job %s", name)
+		}
+		schedules[name] = spec
+	}
+	return schedules, nil
+}
@@ -0,0 +1,115 @@
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/100bench/subscription_aggregator/internal/jobs"
+)
+
+func at(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04:05", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		spec string
+		from string
+		want string
+	}{
+		{"*/15 * * * *", "2025-01-01 10:07:00", "2025-01-01 10:15:00"},
+		{"*/15 * * * *", "2025-01-01 10:15:00", "2025-01-01 10:30:00"},
+		{"*/15 * * * *", "2025-01-01 23:59:59", "2025-01-02 00:00:00"},
+		{"5,35 8-9 * * *", "2025-01-01 09:35:00", "2025-01-02 08:05:00"},
+		{"0 9 * * 1-5", "2025-01-03 10:00:00", "2025-01-06 09:00:00"},
+		{"0 0 * * 7", "2025-01-01 00:00:00", "2025-01-05 00:00:00"},
+		{"0 0 * * 0", "2025-01-01 00:00:00", "2025-01-05 00:00:00"},
+		// With both day fields restricted either one matches, like cron(8).
+		{"0 0 13 * 5", "2025-01-01 00:00:00", "2025-01-03 00:00:00"},
+		{"0 0 31 * *", "2025-04-15 00:00:00", "2025-05-31 00:00:00"},
+		{"0 0 29 2 *", "2025-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"@hourly", "2025-01-01 10:59:30", "2025-01-01 11:00:00"},
+		{"@daily", "2025-12-31 12:00:00", "2026-01-01 00:00:00"},
+		{"@weekly", "2025-01-01 00:00:00", "2025-01-05 00:00:00"},
+		{"@monthly", "2025-12-15 00:00:00", "2026-01-01 00:00:00"},
+		{"@every 90m", "2025-01-01 01:00:00", "2025-01-01 01:30:00"},
+		{"@every 90m", "2025-01-01 01:30:00", "2025-01-01 03:00:00"},
+		{"@every 1h", "2025-01-01 10:20:00", "2025-01-01 11:00:00"},
+	}
+	for _, tt := range tests {
+		schedule, err := jobs.ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q after %s = %s, want %s", tt.spec, tt.from, got.Format(time.DateTime), tt.want)
+		}
+	}
+}
+
+func TestScheduleIsEvaluatedInUTC(t *testing.T) {
+	schedule, err := jobs.ParseSchedule("0 3 * * *")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	moscow := time.FixedZone("MSK", 3*60*60)
+	got := schedule.Next(time.Date(2025, 1, 1, 5, 0, 0, 0, moscow))
+	if want := at("2025-01-01 03:00:00"); !got.Equal(want) {
+		t.Errorf("next run = %s, want %s", got, want)
+	}
+}
+
+func TestScheduleThatNeverFires(t *testing.T) {
+	schedule, err := jobs.ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	if got := schedule.Next(at("2025-01-01 00:00:00")); !got.IsZero() {
+		t.Errorf("30 February fires at %s, want never", got)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@yearly",
+		"@every",
+		"@every 500ms",
+		"@every soon",
+	} {
+		if _, err := jobs.ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestParseSchedules(t *testing.T) {
+	got, err := jobs.ParseSchedules(" outbox.purge = @daily ; reminders.email=*/5 * * * *;")
+	if err != nil {
+		t.Fatalf("ParseSchedules: %v", err)
+	}
+	if len(got) != 2 || got["outbox.purge"] != "@daily" || got["reminders.email"] != "*/5 * * * *" {
+		t.Errorf("ParseSchedules = %v", got)
+	}
+	for _, s := range []string{"@daily", "=@daily", "outbox.purge=61 * * * *"} {
+		if _, err := jobs.ParseSchedules(s); err == nil {
+			t.Errorf("ParseSchedules(%q) succeeded, want an error", s)
+		}
+	}
+}
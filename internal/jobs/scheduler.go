@@ -0,0 +1,275 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/tracing"
+)
+
+var tracer = otel.Tracer("github.com/100bench/subscription_aggregator/internal/jobs")
+
+// finishTimeout bounds recording the outcome of a run cut short by shutdown.
+const finishTimeout = 5 * time.Second
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	fn       Func
+}
+
+// Scheduler runs registered jobs on their schedules and on demand.
+type Scheduler struct {
+	store    Store
+	logger   *slog.Logger
+	instance string
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// base is the context of Run; manual runs started by Trigger use it so
+	// that they stop on shutdown rather than with the triggering request.
+	base context.Context
+	wg   sync.WaitGroup
+}
+
+// Option customizes optional Scheduler behaviour.
+type Option func(*Scheduler)
+
+// WithInstance names this replica in the run history; the host name by default.
+func WithInstance(instance string) Option {
+	return func(s *Scheduler) {
+		s.instance = instance
+	}
+}
+
+func NewScheduler(store Store, logger *slog.Logger, opts ...Option) (*Scheduler, error) {
+	if store == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "store")
+	}
+	if logger == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "logger")
+	}
+	s := &Scheduler{store: store, logger: logger, jobs: make(map[string]*job)}
+	s.instance, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Register adds a job running fn on the schedule spec, see ParseSchedule.
+// Jobs must be registered before Run.
+func (s *Scheduler) Register(name, spec string, fn Func) error {
+	if name == "" || fn == nil {
+		return errors.New("job name and function are required")
+	}
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return errors.Wrapf(err, "job %s", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return errors.Errorf("job %s is already registered", name)
+	}
+	s.jobs[name] = &job{name: name, spec: strings.TrimSpace(spec), schedule: schedule, fn: fn}
+	return nil
+}
+
+// Run runs every job on its schedule until ctx is cancelled, then waits for
+// the runs in progress to return.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.base = ctx
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func(j *job) {
+			defer s.wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+	s.mu.Unlock()
+	<-ctx.Done()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			s.logger.WarnContext(ctx, "job schedule never fires", "job", j.name, "schedule", j.spec)
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.runScheduled(ctx, j, next)
+	}
+}
+
+// runScheduled runs the job for the scheduled time unless another replica
+// is running it or already ran it for that time.
+func (s *Scheduler) runScheduled(ctx context.Context, j *job, scheduledAt time.Time) {
+	unlock, ok, err := s.store.TryLock(ctx, j.name)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to lock job", "job", j.name, "error", err)
+		return
+	}
+	if !ok {
+		s.logger.DebugContext(ctx, "job is running on another instance", "job", j.name)
+		return
+	}
+	defer unlock()
+
+	run, ok, err := s.store.StartRun(ctx, Run{Job: j.name, Trigger: TriggerSchedule, ScheduledAt: scheduledAt, Instance: s.instance})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to start job run", "job", j.name, "error", err)
+		return
+	}
+	if !ok {
+		s.logger.DebugContext(ctx, "job already ran for this time", "job", j.name, "scheduled_at", scheduledAt)
+		return
+	}
+	s.execute(ctx, j, run)
+}
+
+// Trigger starts a manual run of the job in the background and returns it.
+// It fails with en.ErrJobRunning while the job runs on any replica.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (Run, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	base := s.base
+	s.mu.Unlock()
+	if !ok {
+		return Run{}, errors.Wrapf(en.ErrJobNotFound, "job %s", name)
+	}
+	if base == nil || base.Err() != nil {
+		return Run{}, errors.New("scheduler is not running")
+	}
+
+	unlock, ok, err := s.store.TryLock(base, name)
+	if err != nil {
+		return Run{}, errors.Wrap(err, "store.TryLock")
+	}
+	if !ok {
+		return Run{}, errors.Wrapf(en.ErrJobRunning, "job %s", name)
+	}
+	run, _, err := s.store.StartRun(base, Run{Job: name, Trigger: TriggerManual, ScheduledAt: time.Now(), Instance: s.instance})
+	if err != nil {
+		unlock()
+		return Run{}, errors.Wrap(err, "store.StartRun")
+	}
+	s.logger.InfoContext(ctx, "job triggered", "job", name, "run_id", run.ID, "principal", en.AuditPrincipal(ctx))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer unlock()
+		s.execute(base, j, run)
+	}()
+	return run, nil
+}
+
+// execute calls the job and records its outcome.
+func (s *Scheduler) execute(ctx context.Context, j *job, run Run) {
+	ctx, span := tracer.Start(ctx, "Job "+j.name)
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("job.name", j.name),
+		attribute.String("job.trigger", run.Trigger),
+		attribute.Int64("job.run_id", run.ID),
+	)
+
+	err := s.call(ctx, j, run.ScheduledAt)
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = StatusSucceeded
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+		tracing.Fail(span, err)
+		s.logger.ErrorContext(ctx, "job failed", "job", j.name, "run_id", run.ID, "error", err)
+	} else {
+		s.logger.InfoContext(ctx, "job finished", "job", j.name, "run_id", run.ID, "duration", finished.Sub(run.StartedAt).String())
+	}
+
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+	if err := s.store.FinishRun(finishCtx, run); err != nil {
+		s.logger.ErrorContext(ctx, "failed to record job run", "job", j.name, "run_id", run.ID, "error", err)
+	}
+}
+
+func (s *Scheduler) call(ctx context.Context, j *job, now time.Time) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return j.fn(ctx, now)
+}
+
+// Jobs lists the registered jobs by name with their next run time on this
+// replica's clock and their latest run on any replica.
+func (s *Scheduler) Jobs(ctx context.Context) ([]Status, error) {
+	last, err := s.store.LastRuns(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "store.LastRuns")
+	}
+	now := time.Now()
+	s.mu.Lock()
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		status := Status{Name: j.name, Schedule: j.spec, NextRun: j.schedule.Next(now)}
+		if run, ok := last[j.name]; ok {
+			status.LastRun = &run
+		}
+		statuses = append(statuses, status)
+	}
+	s.mu.Unlock()
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Name < statuses[k].Name })
+	return statuses, nil
+}
+
+// Runs returns the latest runs of the job, newest first.
+func (s *Scheduler) Runs(ctx context.Context, name string, limit int) ([]Run, error) {
+	s.mu.Lock()
+	_, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errors.Wrapf(en.ErrJobNotFound, "job %s", name)
+	}
+	runs, err := s.store.ListRuns(ctx, name, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "store.ListRuns")
+	}
+	return runs, nil
+}
+
+// PurgeHistory returns a job removing runs older than retention.
+func (s *Scheduler) PurgeHistory(retention time.Duration) Func {
+	return func(ctx context.Context, now time.Time) error {
+		purged, err := s.store.PurgeRuns(ctx, now.Add(-retention))
+		if err != nil {
+			return errors.Wrap(err, "store.PurgeRuns")
+		}
+		s.logger.DebugContext(ctx, "job history purged", "runs", purged)
+		return nil
+	}
+}
@@ -0,0 +1,297 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// memStore is a Store shared by the schedulers of a test, standing in for
+// the database all replicas use.
+type memStore struct {
+	mu     sync.Mutex
+	locked map[string]bool
+	runs   []Run
+}
+
+func newMemStore() *memStore {
+	return &memStore{locked: make(map[string]bool)}
+}
+
+func (m *memStore) TryLock(ctx context.Context, job string) (func(), bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locked[job] {
+		return nil, false, nil
+	}
+	m.locked[job] = true
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			delete(m.locked, job)
+		})
+	}, true, nil
+}
+
+func (m *memStore) StartRun(ctx context.Context, run Run) (Run, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.runs {
+		if r.Job == run.Job && r.Status == StatusRunning {
+			m.runs[i].Status, m.runs[i].Error = StatusFailed, "abandoned"
+		}
+	}
+	if run.Trigger == TriggerSchedule {
+		for _, r := range m.runs {
+			if r.Job == run.Job && r.Trigger == TriggerSchedule && !r.ScheduledAt.Before(run.ScheduledAt) {
+				return Run{}, false, nil
+			}
+		}
+	}
+	run.ID = int64(len(m.runs) + 1)
+	run.StartedAt = time.Now()
+	run.Status = StatusRunning
+	m.runs = append(m.runs, run)
+	return run, true, nil
+}
+
+func (m *memStore) FinishRun(ctx context.Context, run Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[run.ID-1] = run
+	return nil
+}
+
+func (m *memStore) LastRuns(ctx context.Context) (map[string]Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	last := make(map[string]Run)
+	for _, r := range m.runs {
+		last[r.Job] = r
+	}
+	return last, nil
+}
+
+func (m *memStore) ListRuns(ctx context.Context, job string, limit int) ([]Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []Run
+	for i := len(m.runs) - 1; i >= 0 && len(runs) < limit; i-- {
+		if m.runs[i].Job == job {
+			runs = append(runs, m.runs[i])
+		}
+	}
+	return runs, nil
+}
+
+func (m *memStore) PurgeRuns(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.runs[:0]
+	for _, r := range m.runs {
+		if r.StartedAt.Before(before) && r.Status != StatusRunning {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	purged := int64(len(m.runs) - len(kept))
+	m.runs = kept
+	return purged, nil
+}
+
+func newTestScheduler(t *testing.T, store Store, instance string) *Scheduler {
+	t.Helper()
+	s, err := NewScheduler(store, slog.New(slog.NewTextHandler(io.Discard, nil)), WithInstance(instance))
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	return s
+}
+
+// blockingJob counts its calls; every call waits for release once it has
+// signalled entered.
+type blockingJob struct {
+	mu      sync.Mutex
+	calls   []time.Time
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockingJob() *blockingJob {
+	return &blockingJob{entered: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (b *blockingJob) run(ctx context.Context, now time.Time) error {
+	b.mu.Lock()
+	b.calls = append(b.calls, now)
+	b.mu.Unlock()
+	b.entered <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func (b *blockingJob) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.calls)
+}
+
+func TestScheduledRunHappensOnOneReplicaOnly(t *testing.T) {
+	store := newMemStore()
+	job := newBlockingJob()
+	replicas := []*Scheduler{newTestScheduler(t, store, "a"), newTestScheduler(t, store, "b")}
+	for _, s := range replicas {
+		if err := s.Register("purge", "@hourly", job.run); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	ctx := context.Background()
+	scheduledAt := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		replicas[0].runScheduled(ctx, replicas[0].jobs["purge"], scheduledAt)
+	}()
+	<-job.entered
+	// The other replica's timer fires while the lock is held.
+	replicas[1].runScheduled(ctx, replicas[1].jobs["purge"], scheduledAt)
+	close(job.release)
+	<-done
+	// A replica whose timer fires late finds the run in the history.
+	replicas[1].runScheduled(ctx, replicas[1].jobs["purge"], scheduledAt)
+	replicas[1].runScheduled(ctx, replicas[1].jobs["purge"], scheduledAt.Add(-time.Hour))
+	if n := job.count(); n != 1 {
+		t.Fatalf("job ran %d times for one scheduled time, want once", n)
+	}
+
+	replicas[1].runScheduled(ctx, replicas[1].jobs["purge"], scheduledAt.Add(time.Hour))
+	if n := job.count(); n != 2 {
+		t.Fatalf("job ran %d times after the next scheduled time, want twice", n)
+	}
+	runs, err := replicas[0].Runs(ctx, "purge", 10)
+	if err != nil {
+		t.Fatalf("Runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Instance != "b" || runs[1].Instance != "a" {
+		t.Fatalf("runs = %+v, want the 11:00 run on b and the 10:00 run on a", runs)
+	}
+	for _, r := range runs {
+		if r.Status != StatusSucceeded || r.FinishedAt == nil || r.Trigger != TriggerSchedule {
+			t.Errorf("run %+v, want a finished scheduled run", r)
+		}
+	}
+}
+
+func TestTriggerFailsWhileTheJobRunsOnAnyReplica(t *testing.T) {
+	store := newMemStore()
+	job := newBlockingJob()
+	a, b := newTestScheduler(t, store, "a"), newTestScheduler(t, store, "b")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, s := range []*Scheduler{a, b} {
+		if err := s.Register("purge", "@hourly", job.run); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+
+	if _, err := a.Trigger(ctx, "purge"); err == nil {
+		t.Error("Trigger before Run succeeded, want an error")
+	}
+	for _, s := range []*Scheduler{a, b} {
+		s.base = ctx
+	}
+	if _, err := a.Trigger(ctx, "unknown"); !errors.Is(err, en.ErrJobNotFound) {
+		t.Errorf("Trigger of an unknown job = %v, want ErrJobNotFound", err)
+	}
+
+	run, err := a.Trigger(ctx, "purge")
+	if err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	<-job.entered
+	if run.Trigger != TriggerManual || run.Status != StatusRunning || run.Instance != "a" {
+		t.Errorf("triggered run = %+v, want a manual run on a", run)
+	}
+	if _, err := b.Trigger(ctx, "purge"); !errors.Is(err, en.ErrJobRunning) {
+		t.Errorf("Trigger on b while a runs the job = %v, want ErrJobRunning", err)
+	}
+	b.runScheduled(ctx, b.jobs["purge"], time.Now())
+	close(job.release)
+	a.wg.Wait()
+	if n := job.count(); n != 1 {
+		t.Errorf("job ran %d times, want once", n)
+	}
+	if last, _ := store.LastRuns(ctx); last["purge"].Status != StatusSucceeded {
+		t.Errorf("last run = %+v, want succeeded", last["purge"])
+	}
+}
+
+func TestFailedAndPanickingRunsAreRecorded(t *testing.T) {
+	store := newMemStore()
+	s := newTestScheduler(t, store, "a")
+	if err := s.Register("fails", "@hourly", func(context.Context, time.Time) error { return errors.New("smtp down") }); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := s.Register("panics", "@hourly", func(context.Context, time.Time) error { panic("nil map") }); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	s.runScheduled(ctx, s.jobs["fails"], now)
+	s.runScheduled(ctx, s.jobs["panics"], now)
+
+	statuses, err := s.Jobs(ctx)
+	if err != nil {
+		t.Fatalf("Jobs: %v", err)
+	}
+	want := map[string]string{"fails": "smtp down", "panics": "panic: nil map"}
+	if len(statuses) != 2 || statuses[0].Name != "fails" || statuses[1].Name != "panics" {
+		t.Fatalf("jobs = %+v, want fails and panics by name", statuses)
+	}
+	for _, st := range statuses {
+		if st.LastRun == nil || st.LastRun.Status != StatusFailed || st.LastRun.Error != want[st.Name] {
+			t.Errorf("%s last run = %+v, want failed with %q", st.Name, st.LastRun, want[st.Name])
+		}
+		if !st.NextRun.After(now) {
+			t.Errorf("%s next run = %s, want after now", st.Name, st.NextRun)
+		}
+	}
+}
+
+func TestPurgeHistoryKeepsRecentAndRunningRuns(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := newMemStore()
+	for i, r := range []Run{
+		{Job: "purge", StartedAt: now.AddDate(0, 0, -40), Status: StatusSucceeded},
+		{Job: "purge", StartedAt: now.AddDate(0, 0, -31), Status: StatusFailed},
+		{Job: "purge", StartedAt: now.AddDate(0, 0, -35), Status: StatusRunning},
+		{Job: "purge", StartedAt: now.AddDate(0, 0, -29), Status: StatusSucceeded},
+	} {
+		r.ID = int64(i + 1)
+		store.runs = append(store.runs, r)
+	}
+	s := newTestScheduler(t, store, "a")
+
+	if err := s.PurgeHistory(30*24*time.Hour)(context.Background(), now); err != nil {
+		t.Fatalf("PurgeHistory: %v", err)
+	}
+	var kept []int64
+	for _, r := range store.runs {
+		kept = append(kept, r.ID)
+	}
+	sort.Slice(kept, func(i, k int) bool { return kept[i] < kept[k] })
+	if len(kept) != 2 || kept[0] != 3 || kept[1] != 4 {
+		t.Errorf("kept runs %v, want the running one and the one within 30 days: [3 4]", kept)
+	}
+}
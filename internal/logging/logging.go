@@ -0,0 +1,111 @@
+// Package logging builds the service's structured logger.
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// UserIDKey is the attribute key that carries user IDs; its values are hashed when redaction is on.
+const UserIDKey = "user_id"
+
+// Attribute keys whose values identify a person, besides UserIDKey: the
+// Telegram chat of a user and the actor a caller claims to act for, which is
+// usually an e-mail address.
+const (
+	ChatIDKey = "chat_id"
+	ActorKey  = "actor"
+	// PrincipalKey carries the authenticated caller; only Telegram
+	// principals, which embed the chat ID, are redacted.
+	PrincipalKey = "principal"
+)
+
+// telegramPrincipal prefixes the principal of changes made through the bot.
+const telegramPrincipal = "telegram:"
+
+// redactedKeys lists the attributes hashed when redaction is on.
+var redactedKeys = map[string]bool{UserIDKey: true, ChatIDKey: true, ActorKey: true}
+
+type Options struct {
+	Level slog.Level
+	// RedactUserIDs replaces user IDs, chat IDs and claimed actors with a
+	// stable hash so that log lines remain correlatable without exposing who
+	// they are about.
+	RedactUserIDs bool
+}
+
+// New returns a JSON logger that adds the request ID and the active trace from
+// the context to every record.
+func New(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	if opts.RedactUserIDs {
+		handlerOpts.ReplaceAttr = redactUserID
+	}
+	return slog.New(contextHandler{slog.NewJSONHandler(w, handlerOpts)})
+}
+
+// ParseLevel accepts debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, errors.Wrap(err, "log level")
+	}
+	return level, nil
+}
+
+// HashUserID returns the redacted form of a user ID.
+func HashUserID(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// redactUserID hashes the values of redactedKeys whatever their kind, so
+// that numeric chat IDs are covered too, and the chat ID of Telegram
+// principals. Groups are passed through; slog calls it for their members.
+func redactUserID(_ []string, a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	value := a.Value.String()
+	switch {
+	case value == "":
+		return a
+	case redactedKeys[a.Key]:
+		return slog.String(a.Key, HashUserID(value))
+	case a.Key == PrincipalKey && strings.HasPrefix(value, telegramPrincipal):
+		return slog.String(a.Key, telegramPrincipal+HashUserID(strings.TrimPrefix(value, telegramPrincipal)))
+	}
+	return a
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := en.RequestIDFromContext(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
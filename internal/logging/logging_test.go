@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+const userID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+// record logs a single line with args and returns it decoded.
+func record(t *testing.T, ctx context.Context, opts Options, args ...interface{}) map[string]interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	New(&buf, opts).InfoContext(ctx, "test", args...)
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("unmarshal %q: %v", buf.String(), err)
+	}
+	return line
+}
+
+func TestRedaction(t *testing.T) {
+	redact := Options{RedactUserIDs: true}
+	for name, tt := range map[string]struct {
+		args []interface{}
+		key  string
+		want interface{}
+	}{
+		"user ID":            {[]interface{}{"user_id", userID}, "user_id", HashUserID(userID)},
+		"numeric chat ID":    {[]interface{}{"chat_id", int64(42)}, "chat_id", HashUserID("42")},
+		"claimed actor":      {[]interface{}{"actor", "support@example.com"}, "actor", HashUserID("support@example.com")},
+		"telegram principal": {[]interface{}{"principal", "telegram:42"}, "principal", "telegram:" + HashUserID("42")},
+		"api key principal":  {[]interface{}{"principal", "acme/billing"}, "principal", "acme/billing"},
+		"empty user ID":      {[]interface{}{"user_id", ""}, "user_id", ""},
+		"other IDs":          {[]interface{}{"subscription_id", 7}, "subscription_id", float64(7)},
+		"any value":          {[]interface{}{"user_id", slog.AnyValue(userID)}, "user_id", HashUserID(userID)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := record(t, context.Background(), redact, tt.args...)[tt.key]; got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("grouped", func(t *testing.T) {
+		line := record(t, context.Background(), redact, slog.Group("chat", "user_id", userID))
+		group, _ := line["chat"].(map[string]interface{})
+		if group["user_id"] != HashUserID(userID) {
+			t.Errorf("chat.user_id = %v, want %s", group["user_id"], HashUserID(userID))
+		}
+	})
+	t.Run("off", func(t *testing.T) {
+		line := record(t, context.Background(), Options{}, "user_id", userID, "chat_id", 42)
+		if line["user_id"] != userID || line["chat_id"] != float64(42) {
+			t.Errorf("line = %v, want the values unchanged", line)
+		}
+	})
+}
+
+func TestHashUserIDIsStable(t *testing.T) {
+	if HashUserID(userID) != HashUserID(userID) {
+		t.Error("the same user ID hashes differently")
+	}
+	if HashUserID(userID) == HashUserID("8b9c2f43-3d57-4f3a-9b0e-1b2f0a6f0c11") {
+		t.Error("different user IDs share a hash")
+	}
+	if strings.Contains(HashUserID(userID), userID) {
+		t.Error("the hash contains the user ID")
+	}
+}
+
+func TestRecordsCarryTheRequestID(t *testing.T) {
+	line := record(t, en.WithRequestID(context.Background(), "host/abc-000001"), Options{})
+	if line["request_id"] != "host/abc-000001" {
+		t.Errorf("request_id = %v, want host/abc-000001", line["request_id"])
+	}
+	if _, ok := record(t, context.Background(), Options{})["request_id"]; ok {
+		t.Error("request_id is logged without one in the context")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, " warn ": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(in); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) succeeded")
+	}
+}
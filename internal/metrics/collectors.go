@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reports pgxpool.Stat() at scrape time.
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	total           *prometheus.Desc
+	max             *prometheus.Desc
+	acquireCount    *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	emptyAcquire    *prometheus.Desc
+	canceledAcquire *prometheus.Desc
+}
+
+// NewPoolCollector exposes connection pool saturation; stat is typically PgxStorage.PoolStat.
+func NewPoolCollector(stat func() *pgxpool.Stat) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		stat:            stat,
+		acquired:        desc("acquired_conns", "Connections currently checked out of the pool."),
+		idle:            desc("idle_conns", "Idle connections in the pool."),
+		total:           desc("total_conns", "Open connections in the pool."),
+		max:             desc("max_conns", "Maximum size of the pool."),
+		acquireCount:    desc("acquires_total", "Successful connection acquires."),
+		acquireDuration: desc("acquire_wait_seconds_total", "Total time spent waiting for successful acquires."),
+		emptyAcquire:    desc("empty_acquires_total", "Acquires that had to wait because the pool was empty."),
+		canceledAcquire: desc("canceled_acquires_total", "Acquires cancelled by their context."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.total
+	ch <- c.max
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquire
+	ch <- c.canceledAcquire
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquire, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+}
+
+// scrapeTimeout bounds the database queries run on behalf of a scrape.
+const scrapeTimeout = 5 * time.Second
+
+// activeSubscriptionsCollector reports the number of active subscriptions of
+// all tenants together; a per-tenant label would disclose every tenant's ID
+// and size to whoever can scrape. The count is reused for ttl, so frequent
+// or concurrent scrapes cost one query at most per ttl.
+type activeSubscriptionsCollector struct {
+	count  func(ctx context.Context) (int64, error)
+	logger *slog.Logger
+	ttl    time.Duration
+	now    func() time.Time
+	active *prometheus.Desc
+
+	mu       sync.Mutex
+	cached   int64
+	cachedAt time.Time
+}
+
+// NewActiveSubscriptionsCollector queries count at most once per ttl; count is
+// typically PgxStorage.CountActiveSubs.
+func NewActiveSubscriptionsCollector(count func(ctx context.Context) (int64, error), logger *slog.Logger, ttl time.Duration) prometheus.Collector {
+	return &activeSubscriptionsCollector{
+		count:  count,
+		logger: logger,
+		ttl:    ttl,
+		now:    time.Now,
+		active: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "subscriptions_active"),
+			"Subscriptions that are neither deleted nor past their end month.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *activeSubscriptionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+}
+
+func (c *activeSubscriptionsCollector) Collect(ch chan<- prometheus.Metric) {
+	n, err := c.load()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.active, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(n))
+}
+
+// load returns the cached count, refreshing it once it is older than ttl.
+// Failures are not cached, so the next scrape tries again.
+func (c *activeSubscriptionsCollector) load() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.cachedAt.IsZero() && c.now().Sub(c.cachedAt) < c.ttl {
+		return c.cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+	n, err := c.count(ctx)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "failed to count active subscriptions", "error", err)
+		return 0, err
+	}
+	c.cached, c.cachedAt = n, c.now()
+	return n, nil
+}
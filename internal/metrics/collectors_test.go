@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestActiveSubscriptionsAreCached(t *testing.T) {
+	var (
+		calls int
+		n     int64 = 3
+		err   error
+		clock = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	)
+	count := func(context.Context) (int64, error) {
+		calls++
+		return n, err
+	}
+	c := NewActiveSubscriptionsCollector(count, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Minute).(*activeSubscriptionsCollector)
+	c.now = func() time.Time { return clock }
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	scrape := func(step string, want float64, wantCalls int) {
+		t.Helper()
+		families, gatherErr := registry.Gather()
+		if gatherErr != nil {
+			t.Fatalf("%s: Gather: %v", step, gatherErr)
+		}
+		if len(families) != 1 || len(families[0].GetMetric()) != 1 {
+			t.Fatalf("%s: gathered %v, want a single series", step, families)
+		}
+		metric := families[0].GetMetric()[0]
+		if len(metric.GetLabel()) != 0 {
+			t.Errorf("%s: labels %v, want none", step, metric.GetLabel())
+		}
+		if got := metric.GetGauge().GetValue(); got != want {
+			t.Errorf("%s: value %v, want %v", step, got, want)
+		}
+		if calls != wantCalls {
+			t.Errorf("%s: counted %d times, want %d", step, calls, wantCalls)
+		}
+	}
+
+	scrape("first scrape", 3, 1)
+	n = 5
+	clock = clock.Add(30 * time.Second)
+	scrape("within the ttl", 3, 1)
+	clock = clock.Add(31 * time.Second)
+	scrape("after the ttl", 5, 2)
+
+	err = errors.New("connection refused")
+	clock = clock.Add(2 * time.Minute)
+	if _, gatherErr := registry.Gather(); gatherErr == nil {
+		t.Error("Gather succeeded although counting failed")
+	}
+	err = nil
+	scrape("after a failure", 5, 4)
+}
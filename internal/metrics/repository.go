@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// instrumentedRepository records latency and errors of every SubRepository call.
+type instrumentedRepository struct {
+	next    cases.SubRepository
+	metrics *Metrics
+}
+
+func InstrumentRepository(next cases.SubRepository, m *Metrics) cases.SubRepository {
+	return &instrumentedRepository{next: next, metrics: m}
+}
+
+// observe is deferred with a pointer to the named error result so that it sees the final value.
+// Domain outcomes such as a missing subscription are not counted as errors.
+func (r *instrumentedRepository) observe(method string, start time.Time, errp *error) {
+	err := *errp
+	if errors.Is(err, en.ErrSubscriptionNotFound) || errors.Is(err, en.ErrSubscriptionExists) ||
+		errors.Is(err, en.ErrChargeNotFound) || errors.Is(err, en.ErrChargeTransition) ||
+		errors.Is(err, en.ErrBudgetNotFound) || errors.Is(err, en.ErrBudgetExists) {
+		err = nil
+	}
+	r.metrics.observeRepo(method, start, err)
+}
+
+func (r *instrumentedRepository) CreateSub(ctx context.Context, subscription en.Subscription, audit en.AuditEntry) (_ en.Subscription, err error) {
+	defer r.observe("CreateSub", time.Now(), &err)
+	return r.next.CreateSub(ctx, subscription, audit)
+}
+
+func (r *instrumentedRepository) GetSub(ctx context.Context, userID, serviceName string) (_ en.Subscription, err error) {
+	defer r.observe("GetSub", time.Now(), &err)
+	return r.next.GetSub(ctx, userID, serviceName)
+}
+
+func (r *instrumentedRepository) UpdateSub(ctx context.Context, userID, serviceName string, price *int, startDate *string, endDate *string, autoRenew *bool, audit en.AuditEntry) (err error) {
+	defer r.observe("UpdateSub", time.Now(), &err)
+	return r.next.UpdateSub(ctx, userID, serviceName, price, startDate, endDate, autoRenew, audit)
+}
+
+func (r *instrumentedRepository) DeleteSub(ctx context.Context, userID, serviceName string, audit en.AuditEntry) (err error) {
+	defer r.observe("DeleteSub", time.Now(), &err)
+	return r.next.DeleteSub(ctx, userID, serviceName, audit)
+}
+
+func (r *instrumentedRepository) GetListSubs(ctx context.Context, userId string, includeDeleted bool) (_ []en.Subscription, err error) {
+	defer r.observe("GetListSubs", time.Now(), &err)
+	return r.next.GetListSubs(ctx, userId, includeDeleted)
+}
+
+func (r *instrumentedRepository) GetListSubsForUsers(ctx context.Context, userIDs []string, includeDeleted bool) (_ map[string][]en.Subscription, err error) {
+	defer r.observe("GetListSubsForUsers", time.Now(), &err)
+	return r.next.GetListSubsForUsers(ctx, userIDs, includeDeleted)
+}
+
+func (r *instrumentedRepository) RestoreSub(ctx context.Context, id int64, audit en.AuditEntry) (_ en.Subscription, err error) {
+	defer r.observe("RestoreSub", time.Now(), &err)
+	return r.next.RestoreSub(ctx, id, audit)
+}
+
+func (r *instrumentedRepository) PurgeDeletedSubs(ctx context.Context, deletedBefore time.Time) (_ int64, err error) {
+	defer r.observe("PurgeDeletedSubs", time.Now(), &err)
+	return r.next.PurgeDeletedSubs(ctx, deletedBefore)
+}
+
+func (r *instrumentedRepository) ListSubsEndingIn(ctx context.Context, month string) (_ []en.Subscription, err error) {
+	defer r.observe("ListSubsEndingIn", time.Now(), &err)
+	return r.next.ListSubsEndingIn(ctx, month)
+}
+
+func (r *instrumentedRepository) ListLapsedSubs(ctx context.Context, month string) (_ []en.Subscription, err error) {
+	defer r.observe("ListLapsedSubs", time.Now(), &err)
+	return r.next.ListLapsedSubs(ctx, month)
+}
+
+func (r *instrumentedRepository) ExpireSub(ctx context.Context, id int64, month string, audit en.AuditEntry) (_ en.Subscription, err error) {
+	defer r.observe("ExpireSub", time.Now(), &err)
+	return r.next.ExpireSub(ctx, id, month, audit)
+}
+
+func (r *instrumentedRepository) RenewSub(ctx context.Context, id int64, month string, audit en.AuditEntry) (_ en.Subscription, err error) {
+	defer r.observe("RenewSub", time.Now(), &err)
+	return r.next.RenewSub(ctx, id, month, audit)
+}
+
+func (r *instrumentedRepository) GetTotalByPeriod(ctx context.Context, userID string, serviceName string, startDate, endDate string, mode en.CostMode) (_ int, err error) {
+	defer r.observe("GetTotalByPeriod", time.Now(), &err)
+	return r.next.GetTotalByPeriod(ctx, userID, serviceName, startDate, endDate, mode)
+}
+
+func (r *instrumentedRepository) GetTotalByService(ctx context.Context, userID string, startDate, endDate string) (_ []en.ServiceCost, err error) {
+	defer r.observe("GetTotalByService", time.Now(), &err)
+	return r.next.GetTotalByService(ctx, userID, startDate, endDate)
+}
+
+func (r *instrumentedRepository) ListCharges(ctx context.Context, userID, serviceName, startDate, endDate string) (_ []en.Charge, err error) {
+	defer r.observe("ListCharges", time.Now(), &err)
+	return r.next.ListCharges(ctx, userID, serviceName, startDate, endDate)
+}
+
+func (r *instrumentedRepository) GetCharge(ctx context.Context, subscriptionID int64, period string) (_ en.Charge, err error) {
+	defer r.observe("GetCharge", time.Now(), &err)
+	return r.next.GetCharge(ctx, subscriptionID, period)
+}
+
+func (r *instrumentedRepository) UpdateChargeStatus(ctx context.Context, charge en.Charge, from en.ChargeStatus) (_ en.Charge, err error) {
+	defer r.observe("UpdateChargeStatus", time.Now(), &err)
+	return r.next.UpdateChargeStatus(ctx, charge, from)
+}
+
+func (r *instrumentedRepository) CreateBudget(ctx context.Context, budget en.Budget) (_ en.Budget, err error) {
+	defer r.observe("CreateBudget", time.Now(), &err)
+	return r.next.CreateBudget(ctx, budget)
+}
+
+func (r *instrumentedRepository) ListBudgets(ctx context.Context, userID string) (_ []en.Budget, err error) {
+	defer r.observe("ListBudgets", time.Now(), &err)
+	return r.next.ListBudgets(ctx, userID)
+}
+
+func (r *instrumentedRepository) UpdateBudget(ctx context.Context, budget en.Budget) (_ en.Budget, err error) {
+	defer r.observe("UpdateBudget", time.Now(), &err)
+	return r.next.UpdateBudget(ctx, budget)
+}
+
+func (r *instrumentedRepository) DeleteBudget(ctx context.Context, userID string, id int64) (err error) {
+	defer r.observe("DeleteBudget", time.Now(), &err)
+	return r.next.DeleteBudget(ctx, userID, id)
+}
+
+func (r *instrumentedRepository) ListBudgetStatuses(ctx context.Context, userID, period string) (_ []en.BudgetStatus, err error) {
+	defer r.observe("ListBudgetStatuses", time.Now(), &err)
+	return r.next.ListBudgetStatuses(ctx, userID, period)
+}
+
+func (r *instrumentedRepository) AlertBudget(ctx context.Context, status en.BudgetStatus, subscriptionID int64) (_ bool, err error) {
+	defer r.observe("AlertBudget", time.Now(), &err)
+	return r.next.AlertBudget(ctx, status, subscriptionID)
+}
+
+func (r *instrumentedRepository) ListAudit(ctx context.Context, filter en.AuditFilter) (_ []en.AuditEntry, err error) {
+	defer r.observe("ListAudit", time.Now(), &err)
+	return r.next.ListAudit(ctx, filter)
+}
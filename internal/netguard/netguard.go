@@ -0,0 +1,104 @@
+// Package netguard keeps requests to URLs supplied by tenants, such as
+// webhook endpoints, away from the service's own network: loopback, private,
+// link-local and cloud metadata addresses are refused.
+package netguard
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// ErrForbiddenAddress is returned for destinations inside the service's
+// network.
+var ErrForbiddenAddress = errors.New("destination address is not allowed")
+
+// forbidden lists the ranges that are unicast by netip's rules but still not
+// on the public internet.
+var forbidden = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// Carrier-grade NAT; some clouds serve metadata here.
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64 reaches IPv4 addresses through an IPv6 one.
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// Guard decides which addresses may be dialled. The zero Guard allows public
+// unicast addresses only.
+type Guard struct {
+	exempt []netip.Prefix
+}
+
+// New returns a Guard that also allows the exempt ranges, e.g. a loopback
+// receiver in tests.
+func New(exempt ...netip.Prefix) *Guard {
+	return &Guard{exempt: exempt}
+}
+
+// Allowed reports whether addr is a public unicast address or exempt.
+func (g *Guard) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if g != nil {
+		for _, p := range g.exempt {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+	}
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range forbidden {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Control is a net.Dialer Control function refusing connections to
+// addresses that are not allowed. It sees the address after name resolution,
+// so a host that resolves differently at dial time than when it was checked
+// is still refused.
+func (g *Guard) Control(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrap(err, "netguard: split address")
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return errors.Wrap(err, "netguard: parse address")
+	}
+	if !g.Allowed(addr) {
+		return errors.Wrapf(ErrForbiddenAddress, "%s", addr)
+	}
+	return nil
+}
+
+// CheckHost resolves host and fails with ErrForbiddenAddress if any of its
+// addresses is not allowed.
+func (g *Guard) CheckHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !g.Allowed(addr) {
+			return errors.Wrapf(ErrForbiddenAddress, "%s", host)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return errors.Wrapf(err, "resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !g.Allowed(addr) {
+			return errors.Wrapf(ErrForbiddenAddress, "%s resolves to %s", host, addr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package netguard
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00:ec2::254":        false,
+		"100.100.100.200":      false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:10.0.0.1":      false,
+		"64:ff9b::a00:1":       false,
+	}
+	for addr, want := range tests {
+		if got := New().Allowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestExemptNetworks(t *testing.T) {
+	g := New(netip.MustParsePrefix("127.0.0.0/8"))
+	if !g.Allowed(netip.MustParseAddr("127.0.0.1")) {
+		t.Error("exempt loopback refused")
+	}
+	if g.Allowed(netip.MustParseAddr("169.254.169.254")) {
+		t.Error("metadata address allowed")
+	}
+}
+
+func TestControl(t *testing.T) {
+	if err := New().Control("tcp4", "10.0.0.1:443", nil); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Control(10.0.0.1) = %v, want %v", err, ErrForbiddenAddress)
+	}
+	if err := New().Control("tcp6", "[::1]:80", nil); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Control([::1]) = %v, want %v", err, ErrForbiddenAddress)
+	}
+	if err := New().Control("tcp4", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("Control(public) = %v", err)
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	ctx := context.Background()
+	for _, host := range []string{"localhost", "127.0.0.1", "169.254.169.254", "::1"} {
+		if err := New().CheckHost(ctx, host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%s) = %v, want %v", host, err, ErrForbiddenAddress)
+		}
+	}
+	if err := New().CheckHost(ctx, "93.184.216.34"); err != nil {
+		t.Errorf("CheckHost(public) = %v", err)
+	}
+}
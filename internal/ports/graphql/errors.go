@@ -0,0 +1,62 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// Error codes reported in the "extensions.code" field of GraphQL errors.
+const (
+	codeNotFound      = "NOT_FOUND"
+	codeAlreadyExists = "ALREADY_EXISTS"
+	codeBadUserInput  = "BAD_USER_INPUT"
+	codeForbidden     = "FORBIDDEN"
+	codeInternal      = "INTERNAL"
+)
+
+// resolverError carries a client-facing message and a machine readable code;
+// graphql-go copies Extensions into the response.
+type resolverError struct {
+	code    string
+	message string
+	cause   error
+}
+
+func (e *resolverError) Error() string { return e.message }
+
+func (e *resolverError) Unwrap() error { return e.cause }
+
+func (e *resolverError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+func badInput(format string, args ...interface{}) error {
+	return &resolverError{code: codeBadUserInput, message: errors.Errorf(format, args...).Error()}
+}
+
+func forbidden(message string) error {
+	return &resolverError{code: codeForbidden, message: message}
+}
+
+// toResolverError maps use case errors to GraphQL errors the same way the
+// REST and gRPC ports map them to status codes. Internal details are hidden
+// from the client and logged by the handler instead.
+func toResolverError(err error) error {
+	switch {
+	case errors.Is(err, entities.ErrSubscriptionNotFound):
+		return &resolverError{code: codeNotFound, message: "subscription not found", cause: err}
+	case errors.Is(err, entities.ErrSubscriptionExists):
+		return &resolverError{code: codeAlreadyExists, message: "subscription already exists", cause: err}
+	case errors.Is(err, entities.ErrTenantRequired):
+		return &resolverError{code: codeBadUserInput, message: "tenant is required", cause: err}
+	case errors.Is(err, entities.ErrInvalidPeriod), errors.Is(err, entities.ErrInvalidCostMode):
+		return &resolverError{code: codeBadUserInput, message: err.Error(), cause: err}
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return &resolverError{code: codeInternal, message: "request cancelled", cause: err}
+	default:
+		return &resolverError{code: codeInternal, message: "internal server error", cause: err}
+	}
+}
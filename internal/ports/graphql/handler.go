@@ -0,0 +1,126 @@
+// Package graphql serves a GraphQL API over the subscription use cases for
+// dashboards that need lists, totals and breakdowns in one round trip.
+package graphql
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	gql "github.com/graph-gophers/graphql-go"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// AdminTokenHeader must carry the configured admin token to list deleted subscriptions.
+const AdminTokenHeader = "X-Admin-Token"
+
+// maxBodyBytes bounds the size of a GraphQL request document.
+const maxBodyBytes = 1 << 20
+
+// Handler executes GraphQL requests. It expects the tenant and actor in the
+// request context, so it must be mounted behind the REST tenant middleware.
+type Handler struct {
+	schema     *gql.Schema
+	service    PublicService
+	adminToken string
+	logger     *slog.Logger
+}
+
+// Option customizes optional Handler behaviour.
+type Option func(*Handler)
+
+// WithLogger replaces slog.Default as the destination of error logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithAdminToken enables admin-only arguments for callers presenting token.
+func WithAdminToken(token string) Option {
+	return func(h *Handler) {
+		h.adminToken = token
+	}
+}
+
+func NewHandler(service PublicService, opts ...Option) (*Handler, error) {
+	if service == nil {
+		return nil, errors.Wrap(entities.ErrNilDependency, "graphql handler service")
+	}
+	h := &Handler{service: service, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	schema, err := gql.ParseSchema(schemaSDL, &resolver{service: service},
+		gql.MaxDepth(8),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "graphql.ParseSchema")
+	}
+	h.schema = schema
+	return h, nil
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+			http.Error(w, "invalid GraphQL request body", http.StatusBadRequest)
+			return
+		}
+	case http.MethodGet:
+		// GET requests may only run queries; mutation resolvers refuse to
+		// run for them, see requireWritable.
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := withRequestState(r.Context(), h.service, h.isAdmin(r))
+	if r.Method == http.MethodGet {
+		ctx = context.WithValue(ctx, readOnlyKey{}, true)
+	}
+	resp := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	for _, e := range resp.Errors {
+		if code, _ := e.Extensions["code"].(string); code == codeInternal {
+			h.logger.ErrorContext(ctx, "graphql resolver failed", "path", e.Path, "error", e.ResolverError)
+		}
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func (h *Handler) isAdmin(r *http.Request) bool {
+	token := r.Header.Get(AdminTokenHeader)
+	return h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+}
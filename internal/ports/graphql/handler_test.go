@@ -0,0 +1,259 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/ports/graphql"
+	"github.com/100bench/subscription_aggregator/internal/ports/http/public"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+// countingStorage records the subscription list queries reaching storage.
+type countingStorage struct {
+	*testutil.Storage
+
+	mu      sync.Mutex
+	batches [][]string
+	singles int
+}
+
+func (s *countingStorage) GetListSubsForUsers(ctx context.Context, userIDs []string, includeDeleted bool) (map[string][]en.Subscription, error) {
+	s.mu.Lock()
+	s.batches = append(s.batches, append([]string(nil), userIDs...))
+	s.mu.Unlock()
+	return s.Storage.GetListSubsForUsers(ctx, userIDs, includeDeleted)
+}
+
+func (s *countingStorage) GetListSubs(ctx context.Context, userID string, includeDeleted bool) ([]en.Subscription, error) {
+	s.mu.Lock()
+	s.singles++
+	s.mu.Unlock()
+	return s.Storage.GetListSubs(ctx, userID, includeDeleted)
+}
+
+func (s *countingStorage) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches, s.singles = nil, 0
+}
+
+// serve mounts the GraphQL handler on the public router, behind the API keys
+// of the tenants acme and globex, as the service does.
+func serve(t *testing.T) (*httptest.Server, *countingStorage) {
+	t.Helper()
+	storage := &countingStorage{Storage: testutil.NewStorage()}
+	service := testutil.NewService(t, storage)
+	handler, err := graphql.NewHandler(service, graphql.WithLogger(testutil.Logger()), graphql.WithAdminToken(testutil.AdminToken))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	server, err := public.NewServer(service,
+		public.WithLogger(testutil.Logger()),
+		public.WithAPIKeys(testutil.APIKeys(t)),
+		public.WithMount("/graphql", handler),
+	)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+	return ts, storage
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func (r response) code() string {
+	if len(r.Errors) == 0 {
+		return ""
+	}
+	code, _ := r.Errors[0].Extensions["code"].(string)
+	return code
+}
+
+func query(t *testing.T, ts *httptest.Server, key, q string, data interface{}) response {
+	t.Helper()
+	body, err := json.Marshal(map[string]string{"query": q})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/graphql", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("POST /graphql: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /graphql: status %d", resp.StatusCode)
+	}
+	var out response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if data != nil && len(out.Errors) == 0 {
+		if err := json.Unmarshal(out.Data, data); err != nil {
+			t.Fatalf("decode data: %v", err)
+		}
+	}
+	return out
+}
+
+func mustQuery(t *testing.T, ts *httptest.Server, key, q string, data interface{}) {
+	t.Helper()
+	if resp := query(t, ts, key, q, data); len(resp.Errors) > 0 {
+		t.Fatalf("%s: %+v", q, resp.Errors)
+	}
+}
+
+func createSub(t *testing.T, ts *httptest.Server, key, userID, service string, price int) string {
+	t.Helper()
+	var data struct {
+		CreateSubscription struct{ ID string } `json:"createSubscription"`
+	}
+	mustQuery(t, ts, key, fmt.Sprintf(`mutation { createSubscription(input: {userId: %q, serviceName: %q, price: %d, startDate: "01-2025", endDate: "12-2025"}) { id } }`,
+		userID, service, price), &data)
+	return data.CreateSubscription.ID
+}
+
+type userSubs struct {
+	ID            string `json:"id"`
+	Subscriptions struct {
+		Nodes []struct {
+			ServiceName string `json:"serviceName"`
+			Price       int    `json:"price"`
+		} `json:"nodes"`
+		TotalCount int `json:"totalCount"`
+	} `json:"subscriptions"`
+}
+
+func TestSubscriptionsOfManyUsersAreLoadedInOneQuery(t *testing.T) {
+	ts, storage := serve(t)
+	userIDs := make([]string, 5)
+	for i := range userIDs {
+		userIDs[i] = uuid.NewString()
+		createSub(t, ts, testutil.AcmeKey, userIDs[i], "Netflix", 400+i)
+	}
+
+	for name, q := range map[string]string{
+		"users(ids:)": fmt.Sprintf(`{ users(ids: ["%s"]) { id subscriptions { nodes { serviceName price } totalCount } } }`,
+			strings.Join(userIDs, `", "`)),
+		"aliased user(id:)": func() string {
+			var b strings.Builder
+			b.WriteString("{")
+			for i, id := range userIDs {
+				fmt.Fprintf(&b, ` u%d: user(id: %q) { id subscriptions { nodes { serviceName price } totalCount } }`, i, id)
+			}
+			b.WriteString(" }")
+			return b.String()
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			storage.reset()
+			var data map[string]json.RawMessage
+			mustQuery(t, ts, testutil.AcmeKey, q, &data)
+
+			var users []userSubs
+			if raw, ok := data["users"]; ok {
+				if err := json.Unmarshal(raw, &users); err != nil {
+					t.Fatalf("decode users: %v", err)
+				}
+			} else {
+				for i := range userIDs {
+					var u userSubs
+					if err := json.Unmarshal(data[fmt.Sprintf("u%d", i)], &u); err != nil {
+						t.Fatalf("decode u%d: %v", i, err)
+					}
+					users = append(users, u)
+				}
+			}
+			if len(users) != len(userIDs) {
+				t.Fatalf("got %d users, want %d", len(users), len(userIDs))
+			}
+			for i, u := range users {
+				if u.ID != userIDs[i] || u.Subscriptions.TotalCount != 1 || u.Subscriptions.Nodes[0].Price != 400+i {
+					t.Errorf("user %d = %+v, want %s with Netflix for %d", i, u, userIDs[i], 400+i)
+				}
+			}
+
+			storage.mu.Lock()
+			defer storage.mu.Unlock()
+			if storage.singles != 0 || len(storage.batches) != 1 {
+				t.Fatalf("%d batched and %d single lookups, want one batch", len(storage.batches), storage.singles)
+			}
+			got := append([]string(nil), storage.batches[0]...)
+			want := append([]string(nil), userIDs...)
+			sort.Strings(got)
+			sort.Strings(want)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("batch = %v, want every user once: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestResolversAreScopedToTheCallersTenant(t *testing.T) {
+	ts, _ := serve(t)
+	userID := uuid.NewString()
+	acmeSub := createSub(t, ts, testutil.AcmeKey, userID, "Netflix", 400)
+	createSub(t, ts, testutil.GlobexKey, userID, "Spotify", 300)
+
+	for key, want := range map[string]string{testutil.AcmeKey: "Netflix", testutil.GlobexKey: "Spotify"} {
+		var data struct {
+			Users []userSubs `json:"users"`
+			User  struct {
+				TotalCost struct{ Amount int } `json:"totalCost"`
+			} `json:"user"`
+		}
+		mustQuery(t, ts, key, fmt.Sprintf(`{
+			users(ids: [%q]) { id subscriptions { nodes { serviceName price } totalCount } }
+			user(id: %q) { totalCost(period: {from: "01-2025", to: "01-2025"}) { amount } }
+		}`, userID, userID), &data)
+		if len(data.Users) != 1 || data.Users[0].Subscriptions.TotalCount != 1 || data.Users[0].Subscriptions.Nodes[0].ServiceName != want {
+			t.Errorf("%s sees %+v, want only %s", want, data.Users, want)
+			continue
+		}
+		if price := data.Users[0].Subscriptions.Nodes[0].Price; data.User.TotalCost.Amount != price {
+			t.Errorf("%s total cost = %d, want only its own %d", want, data.User.TotalCost.Amount, price)
+		}
+	}
+
+	for name, q := range map[string]string{
+		"update":  fmt.Sprintf(`mutation { updateSubscription(userId: %q, serviceName: "Netflix", input: {price: 1}) { id } }`, userID),
+		"delete":  fmt.Sprintf(`mutation { deleteSubscription(userId: %q, serviceName: "Netflix") }`, userID),
+		"restore": fmt.Sprintf(`mutation { restoreSubscription(id: %q) { id } }`, acmeSub),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if resp := query(t, ts, testutil.GlobexKey, q, nil); resp.code() != "NOT_FOUND" {
+				t.Errorf("globex %s of acme's subscription = %+v, want NOT_FOUND", name, resp.Errors)
+			}
+		})
+	}
+
+	var data struct{ User userSubs }
+	mustQuery(t, ts, testutil.AcmeKey, fmt.Sprintf(`{ user(id: %q) { id subscriptions { nodes { serviceName price } totalCount } } }`, userID), &data)
+	if data.User.Subscriptions.TotalCount != 1 || data.User.Subscriptions.Nodes[0].Price != 400 {
+		t.Errorf("acme's subscriptions after globex's attempts = %+v, want Netflix for 400", data.User.Subscriptions)
+	}
+}
@@ -0,0 +1,62 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/graph-gophers/dataloader"
+)
+
+type stateKey struct{}
+
+type readOnlyKey struct{}
+
+// requestState holds everything resolvers share within one GraphQL request.
+// Loaders are per request so cached results never leak across tenants.
+type requestState struct {
+	admin bool
+	// subscriptions batches user(id).subscriptions lookups into a single
+	// storage query per includeDeleted flavour.
+	subscriptions map[bool]*dataloader.Loader
+}
+
+func withRequestState(ctx context.Context, service PublicService, admin bool) context.Context {
+	state := &requestState{
+		admin: admin,
+		subscriptions: map[bool]*dataloader.Loader{
+			false: newSubscriptionsLoader(service, false),
+			true:  newSubscriptionsLoader(service, true),
+		},
+	}
+	return context.WithValue(ctx, stateKey{}, state)
+}
+
+func stateFromContext(ctx context.Context) *requestState {
+	state, _ := ctx.Value(stateKey{}).(*requestState)
+	return state
+}
+
+func newSubscriptionsLoader(service PublicService, includeDeleted bool) *dataloader.Loader {
+	batch := func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		results := make([]*dataloader.Result, len(keys))
+		byUser, err := service.GetListSubscriptionsForUsers(ctx, keys.Keys(), includeDeleted)
+		for i, key := range keys {
+			if err != nil {
+				results[i] = &dataloader.Result{Error: err}
+				continue
+			}
+			results[i] = &dataloader.Result{Data: byUser[key.String()]}
+		}
+		return results
+	}
+	return dataloader.NewBatchedLoader(batch)
+}
+
+// clearSubscriptions drops cached lists after a mutation so later fields of
+// the same request observe it.
+func clearSubscriptions(ctx context.Context, userID string) {
+	if state := stateFromContext(ctx); state != nil {
+		for _, l := range state.subscriptions {
+			l.Clear(ctx, dataloader.StringKey(userID))
+		}
+	}
+}
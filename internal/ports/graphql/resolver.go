@@ -0,0 +1,423 @@
+package graphql
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/dataloader"
+	gql "github.com/graph-gophers/graphql-go"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+	// maxUsersPerQuery bounds the users(ids:) fan-out of a single request.
+	maxUsersPerQuery = 100
+	// currencyRUB is the only currency prices are stored in.
+	currencyRUB = "RUB"
+	monthLayout = "01-2006"
+)
+
+type resolver struct {
+	service PublicService
+}
+
+func (r *resolver) User(args struct{ ID gql.ID }) (*userResolver, error) {
+	if args.ID == "" {
+		return nil, badInput("id is required")
+	}
+	return &userResolver{service: r.service, id: string(args.ID)}, nil
+}
+
+func (r *resolver) Users(args struct{ IDs []gql.ID }) ([]*userResolver, error) {
+	if len(args.IDs) > maxUsersPerQuery {
+		return nil, badInput("at most %d ids per query", maxUsersPerQuery)
+	}
+	out := make([]*userResolver, 0, len(args.IDs))
+	for _, id := range args.IDs {
+		out = append(out, &userResolver{service: r.service, id: string(id)})
+	}
+	return out, nil
+}
+
+type createSubscriptionInput struct {
+	UserID      gql.ID
+	ServiceName string
+	Price       int32
+	StartDate   string
+	EndDate     *string
+	AutoRenew   *bool
+}
+
+func (r *resolver) CreateSubscription(ctx context.Context, args struct{ Input createSubscriptionInput }) (*subscriptionResolver, error) {
+	if err := requireWritable(ctx); err != nil {
+		return nil, err
+	}
+	in := args.Input
+	if in.UserID == "" || in.ServiceName == "" || in.StartDate == "" {
+		return nil, badInput("userId, serviceName and startDate are required")
+	}
+	sub := entities.Subscription{
+		UserID:      string(in.UserID),
+		ServiceName: in.ServiceName,
+		Price:       int(in.Price),
+		StartDate:   in.StartDate,
+	}
+	if in.EndDate != nil {
+		sub.EndDate = *in.EndDate
+	}
+	if in.AutoRenew != nil {
+		sub.AutoRenew = *in.AutoRenew
+	}
+	created, err := r.service.CreateSubscription(ctx, sub)
+	if err != nil {
+		return nil, toResolverError(err)
+	}
+	clearSubscriptions(ctx, created.UserID)
+	return &subscriptionResolver{sub: created}, nil
+}
+
+type updateSubscriptionInput struct {
+	Price     *int32
+	StartDate *string
+	EndDate   *string
+	AutoRenew *bool
+}
+
+func (r *resolver) UpdateSubscription(ctx context.Context, args struct {
+	UserID      gql.ID
+	ServiceName string
+	Input       updateSubscriptionInput
+}) (*subscriptionResolver, error) {
+	if err := requireWritable(ctx); err != nil {
+		return nil, err
+	}
+	var price *int
+	if args.Input.Price != nil {
+		p := int(*args.Input.Price)
+		price = &p
+	}
+	userID := string(args.UserID)
+	if err := r.service.UpdateSubscription(ctx, userID, args.ServiceName, price, args.Input.StartDate, args.Input.EndDate, args.Input.AutoRenew); err != nil {
+		return nil, toResolverError(err)
+	}
+	clearSubscriptions(ctx, userID)
+	updated, err := r.service.GetSubscription(ctx, userID, args.ServiceName)
+	if err != nil {
+		return nil, toResolverError(err)
+	}
+	return &subscriptionResolver{sub: updated}, nil
+}
+
+func (r *resolver) DeleteSubscription(ctx context.Context, args struct {
+	UserID      gql.ID
+	ServiceName string
+}) (bool, error) {
+	if err := requireWritable(ctx); err != nil {
+		return false, err
+	}
+	if err := r.service.DeleteSubscription(ctx, string(args.UserID), args.ServiceName); err != nil {
+		return false, toResolverError(err)
+	}
+	clearSubscriptions(ctx, string(args.UserID))
+	return true, nil
+}
+
+func (r *resolver) RestoreSubscription(ctx context.Context, args struct{ ID gql.ID }) (*subscriptionResolver, error) {
+	if err := requireWritable(ctx); err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(string(args.ID), 10, 64)
+	if err != nil || id <= 0 {
+		return nil, badInput("invalid subscription id %q", args.ID)
+	}
+	restored, err := r.service.RestoreSubscription(ctx, id)
+	if err != nil {
+		return nil, toResolverError(err)
+	}
+	clearSubscriptions(ctx, restored.UserID)
+	return &subscriptionResolver{sub: restored}, nil
+}
+
+func requireWritable(ctx context.Context) error {
+	if readOnly, _ := ctx.Value(readOnlyKey{}).(bool); readOnly {
+		return badInput("mutations must be sent with POST")
+	}
+	return nil
+}
+
+type userResolver struct {
+	service PublicService
+	id      string
+}
+
+func (u *userResolver) ID() gql.ID {
+	return gql.ID(u.id)
+}
+
+type subscriptionFilter struct {
+	ServiceName    *string
+	ActiveIn       *string
+	IncludeDeleted *bool
+}
+
+type pageInput struct {
+	First *int32
+	After *string
+}
+
+func (u *userResolver) Subscriptions(ctx context.Context, args struct {
+	Filter *subscriptionFilter
+	Page   *pageInput
+}) (*subscriptionPageResolver, error) {
+	filter := args.Filter
+	if filter == nil {
+		filter = &subscriptionFilter{}
+	}
+	includeDeleted := filter.IncludeDeleted != nil && *filter.IncludeDeleted
+	state := stateFromContext(ctx)
+	if includeDeleted && !state.admin {
+		return nil, forbidden("includeDeleted requires a valid admin token")
+	}
+	activeIn := -1
+	if filter.ActiveIn != nil {
+		month, err := monthIndex(*filter.ActiveIn)
+		if err != nil {
+			return nil, badInput("activeIn: invalid month %q, want MM-YYYY", *filter.ActiveIn)
+		}
+		activeIn = month
+	}
+	first, offset := defaultPageSize, 0
+	if args.Page != nil {
+		if args.Page.First != nil {
+			first = int(*args.Page.First)
+		}
+		if args.Page.After != nil {
+			var err error
+			if offset, err = decodeCursor(*args.Page.After); err != nil {
+				return nil, badInput("invalid cursor")
+			}
+		}
+	}
+	if first < 1 || first > maxPageSize {
+		return nil, badInput("page.first must be between 1 and %d", maxPageSize)
+	}
+
+	data, err := state.subscriptions[includeDeleted].Load(ctx, dataloader.StringKey(u.id))()
+	if err != nil {
+		return nil, toResolverError(err)
+	}
+	subs, _ := data.([]entities.Subscription)
+
+	matched := make([]entities.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		if filter.ServiceName != nil && sub.ServiceName != *filter.ServiceName {
+			continue
+		}
+		if activeIn >= 0 && !activeInMonth(sub, activeIn) {
+			continue
+		}
+		matched = append(matched, sub)
+	}
+
+	page := &subscriptionPageResolver{totalCount: int32(len(matched))}
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	end := offset + first
+	if end > len(matched) {
+		end = len(matched)
+	}
+	for _, sub := range matched[offset:end] {
+		page.nodes = append(page.nodes, &subscriptionResolver{sub: sub})
+	}
+	if end > offset {
+		cursor := encodeCursor(end)
+		page.endCursor = &cursor
+	}
+	page.hasNextPage = end < len(matched)
+	return page, nil
+}
+
+type periodInput struct {
+	From string
+	To   string
+}
+
+type moneyResolver struct {
+	amount   int32
+	currency string
+}
+
+func (m *moneyResolver) Amount() int32 { return m.amount }
+
+func (m *moneyResolver) Currency() string { return m.currency }
+
+func (u *userResolver) TotalCost(ctx context.Context, args struct {
+	Period      periodInput
+	ServiceName *string
+	Currency    *string
+	Mode        string
+}) (*moneyResolver, error) {
+	currency, err := checkCurrency(args.Currency)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := ""
+	if args.ServiceName != nil {
+		serviceName = *args.ServiceName
+	}
+	total, err := u.service.GetTotalCostByPeriod(ctx, u.id, serviceName, args.Period.From, args.Period.To, entities.CostMode(strings.ToLower(args.Mode)))
+	if err != nil {
+		return nil, toResolverError(err)
+	}
+	return &moneyResolver{amount: int32(total), currency: currency}, nil
+}
+
+type costGroupResolver struct {
+	key  string
+	cost *moneyResolver
+}
+
+func (g *costGroupResolver) Key() string { return g.key }
+
+func (g *costGroupResolver) Cost() *moneyResolver { return g.cost }
+
+func (u *userResolver) Breakdown(ctx context.Context, args struct {
+	Period   periodInput
+	GroupBy  string
+	Currency *string
+}) ([]*costGroupResolver, error) {
+	currency, err := checkCurrency(args.Currency)
+	if err != nil {
+		return nil, err
+	}
+	// SERVICE is the only grouping so far; the schema enum rejects others.
+	costs, err := u.service.GetCostBreakdown(ctx, u.id, args.Period.From, args.Period.To)
+	if err != nil {
+		return nil, toResolverError(err)
+	}
+	out := make([]*costGroupResolver, 0, len(costs))
+	for _, c := range costs {
+		out = append(out, &costGroupResolver{
+			key:  c.ServiceName,
+			cost: &moneyResolver{amount: int32(c.TotalCost), currency: currency},
+		})
+	}
+	return out, nil
+}
+
+type subscriptionPageResolver struct {
+	nodes       []*subscriptionResolver
+	totalCount  int32
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (p *subscriptionPageResolver) Nodes() []*subscriptionResolver { return p.nodes }
+
+func (p *subscriptionPageResolver) TotalCount() int32 { return p.totalCount }
+
+func (p *subscriptionPageResolver) EndCursor() *string { return p.endCursor }
+
+func (p *subscriptionPageResolver) HasNextPage() bool { return p.hasNextPage }
+
+type subscriptionResolver struct {
+	sub entities.Subscription
+}
+
+func (s *subscriptionResolver) ID() gql.ID {
+	return gql.ID(strconv.FormatInt(s.sub.ID, 10))
+}
+
+func (s *subscriptionResolver) UserID() gql.ID { return gql.ID(s.sub.UserID) }
+
+func (s *subscriptionResolver) ServiceName() string { return s.sub.ServiceName }
+
+func (s *subscriptionResolver) Price() int32 { return int32(s.sub.Price) }
+
+func (s *subscriptionResolver) StartDate() string { return s.sub.StartDate }
+
+func (s *subscriptionResolver) EndDate() *string {
+	if s.sub.EndDate == "" {
+		return nil
+	}
+	return &s.sub.EndDate
+}
+
+func (s *subscriptionResolver) DeletedAt() *string {
+	if s.sub.DeletedAt == nil {
+		return nil
+	}
+	v := s.sub.DeletedAt.UTC().Format(time.RFC3339)
+	return &v
+}
+
+func (s *subscriptionResolver) AutoRenew() bool { return s.sub.AutoRenew }
+
+func (s *subscriptionResolver) ExpiredAt() *string {
+	if s.sub.ExpiredAt == nil {
+		return nil
+	}
+	v := s.sub.ExpiredAt.UTC().Format(time.RFC3339)
+	return &v
+}
+
+func (s *subscriptionResolver) BilledThrough() *string {
+	if s.sub.BilledThrough == "" {
+		return nil
+	}
+	return &s.sub.BilledThrough
+}
+
+func checkCurrency(currency *string) (string, error) {
+	if currency == nil || *currency == currencyRUB {
+		return currencyRUB, nil
+	}
+	return "", badInput("unsupported currency %q, prices are stored in %s", *currency, currencyRUB)
+}
+
+// monthIndex turns MM-YYYY into a number of months for range comparisons.
+func monthIndex(month string) (int, error) {
+	t, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return 0, err
+	}
+	return t.Year()*12 + int(t.Month()) - 1, nil
+}
+
+// activeInMonth reports whether sub runs in month; open-ended subscriptions
+// run indefinitely. Malformed stored dates never match.
+func activeInMonth(sub entities.Subscription, month int) bool {
+	start, err := monthIndex(sub.StartDate)
+	if err != nil || start > month {
+		return false
+	}
+	if sub.EndDate == "" {
+		return true
+	}
+	end, err := monthIndex(sub.EndDate)
+	return err == nil && end >= month
+}
+
+// Cursors are opaque to clients; they encode the offset of the next page.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return offset, nil
+}
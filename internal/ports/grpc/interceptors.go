@@ -0,0 +1,81 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"runtime/debug"
+	"time"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/100bench/subscription_aggregator/internal/auth"
+	"github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// Metadata keys mirroring the REST headers.
+const (
+	AuthKey       = "authorization"
+	TenantKey     = "x-tenant-id"
+	ActorKey      = "x-actor-id"
+	AdminTokenKey = "x-admin-token"
+	RequestIDKey  = "x-request-id"
+)
+
+// metadataInterceptor authenticates calls to the subscription service by the
+// "authorization: Bearer" API key, binds them to the key's tenant and moves
+// actor and request ID from the incoming metadata into the context; health and
+// reflection calls pass through untouched. A TenantKey naming another tenant
+// than the key's is rejected.
+func (s *Server) metadataInterceptor(ctx context.Context, req interface{}, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (interface{}, error) {
+	if _, ok := info.Server.(*Server); !ok {
+		return handler(ctx, req)
+	}
+	principal, ok := s.apiKeys.Authenticate(auth.BearerToken(firstValue(ctx, AuthKey)))
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, entities.ErrUnauthenticated.Error())
+	}
+	if requested := firstValue(ctx, TenantKey); requested != "" && requested != principal.TenantID {
+		return nil, status.Error(codes.PermissionDenied, entities.ErrTenantMismatch.Error())
+	}
+	ctx = entities.WithTenant(ctx, principal.TenantID)
+	ctx = entities.WithPrincipal(ctx, principal.String())
+	ctx = entities.WithActor(ctx, firstValue(ctx, ActorKey))
+	ctx = entities.WithRequestID(ctx, firstValue(ctx, RequestIDKey))
+	return handler(ctx, req)
+}
+
+func (s *Server) logInterceptor(ctx context.Context, req interface{}, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.logger.InfoContext(ctx, "grpc call",
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start),
+	)
+	return resp, err
+}
+
+func (s *Server) recoverInterceptor(ctx context.Context, req interface{}, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.ErrorContext(ctx, "panic in grpc handler", "method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func (s *Server) isAdmin(ctx context.Context) bool {
+	token := firstValue(ctx, AdminTokenKey)
+	return s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+func firstValue(ctx context.Context, key string) string {
+	if v := metadata.ValueFromIncomingContext(ctx, key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
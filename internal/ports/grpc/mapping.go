@@ -0,0 +1,96 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	pb "github.com/100bench/subscription_aggregator/pkg/grpc/subscriptionpb"
+)
+
+func toPBSubscription(sub entities.Subscription) *pb.Subscription {
+	out := &pb.Subscription{
+		Id:          sub.ID,
+		UserId:      sub.UserID,
+		ServiceName: sub.ServiceName,
+		Price:       int64(sub.Price),
+		StartDate:   sub.StartDate,
+		EndDate:     sub.EndDate,
+	}
+	if sub.DeletedAt != nil {
+		out.DeletedAt = sub.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+func toPBAudit(entries []entities.AuditEntry) *pb.AuditEntries {
+	out := &pb.AuditEntries{Entries: make([]*pb.AuditEntry, 0, len(entries))}
+	for _, e := range entries {
+		out.Entries = append(out.Entries, &pb.AuditEntry{
+			Id:             e.ID,
+			SubscriptionId: e.SubscriptionID,
+			UserId:         e.UserID,
+			Action:         string(e.Action),
+			Principal:      e.Principal,
+			Actor:          e.Actor,
+			RequestId:      e.RequestID,
+			Before:         string(e.Before),
+			After:          string(e.After),
+			CreatedAt:      e.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return out
+}
+
+func toAuditFilter(req *pb.ListAuditRequest) (entities.AuditFilter, error) {
+	filter := entities.AuditFilter{
+		SubscriptionID: req.GetSubscriptionId(),
+		UserID:         req.GetUserId(),
+		Principal:      req.GetPrincipal(),
+		Actor:          req.GetActor(),
+		Action:         entities.AuditAction(req.GetAction()),
+		BeforeID:       req.GetBeforeId(),
+		Limit:          int(req.GetLimit()),
+	}
+	switch filter.Action {
+	case "", entities.AuditActionCreate, entities.AuditActionUpdate, entities.AuditActionDelete, entities.AuditActionRestore,
+		entities.AuditActionRenew, entities.AuditActionExpire:
+	default:
+		return entities.AuditFilter{}, errors.Errorf("unknown action %q", filter.Action)
+	}
+	var err error
+	if v := req.GetFrom(); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return entities.AuditFilter{}, errors.Wrap(err, "from")
+		}
+	}
+	if v := req.GetTo(); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return entities.AuditFilter{}, errors.Wrap(err, "to")
+		}
+	}
+	return filter, nil
+}
+
+// Page tokens are opaque to clients; they encode the offset of the next page.
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid page token")
+	}
+	return offset, nil
+}
@@ -0,0 +1,21 @@
+package grpc
+
+import (
+	"context"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// PublicService is the use case surface exposed over gRPC.
+type PublicService interface {
+	CreateSubscription(ctx context.Context, subscription en.Subscription) (en.Subscription, error)
+	GetSubscription(ctx context.Context, userID string, serviceName string) (en.Subscription, error)
+	UpdateSubscription(ctx context.Context, userID string, serviceName string, price *int, startDate *string, endDate *string, autoRenew *bool) error
+	DeleteSubscription(ctx context.Context, userID string, serviceName string) error
+	RestoreSubscription(ctx context.Context, id int64) (en.Subscription, error)
+	GetListSubscriptions(ctx context.Context, userID string, includeDeleted bool) ([]en.Subscription, error)
+	GetTotalCostByPeriod(ctx context.Context, userID string, serviceName string, startDate string, endDate string, mode en.CostMode) (int, error)
+	GetCostBreakdown(ctx context.Context, userID string, startDate string, endDate string) ([]en.ServiceCost, error)
+	GetSubscriptionAudit(ctx context.Context, subscriptionID int64) ([]en.AuditEntry, error)
+	ListAudit(ctx context.Context, filter en.AuditFilter) ([]en.AuditEntry, error)
+}
@@ -0,0 +1,266 @@
+// Package grpc exposes the subscription use cases as a gRPC service, next to
+// the REST API, together with the standard health and reflection services.
+package grpc
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/100bench/subscription_aggregator/internal/auth"
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	pb "github.com/100bench/subscription_aggregator/pkg/grpc/subscriptionpb"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+type Server struct {
+	pb.UnimplementedSubscriptionServiceServer
+
+	service    PublicService
+	adminToken string
+	apiKeys    *auth.Keyring
+	logger     *slog.Logger
+	health     *health.Server
+	grpc       *gogrpc.Server
+}
+
+// Option customizes optional Server behaviour.
+type Option func(*Server)
+
+// WithLogger replaces slog.Default as the destination of call logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithAdminToken enables admin-only calls for callers presenting token.
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// WithAPIKeys authenticates subscription calls by the API keys of keys;
+// without it every such call fails with Unauthenticated.
+func WithAPIKeys(keys *auth.Keyring) Option {
+	return func(s *Server) {
+		s.apiKeys = keys
+	}
+}
+
+func NewServer(service PublicService, opts ...Option) (*Server, error) {
+	if service == nil {
+		return nil, errors.Wrap(entities.ErrNilDependency, "grpc server service")
+	}
+	s := &Server{
+		service: service,
+		logger:  slog.Default(),
+		health:  health.NewServer(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.grpc = gogrpc.NewServer(
+		gogrpc.StatsHandler(otelgrpc.NewServerHandler()),
+		gogrpc.ChainUnaryInterceptor(s.recoverInterceptor, s.logInterceptor, s.metadataInterceptor),
+	)
+	pb.RegisterSubscriptionServiceServer(s.grpc, s)
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+	s.health.SetServingStatus(pb.SubscriptionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s, nil
+}
+
+// GRPCServer returns the server to Serve on a listener.
+func (s *Server) GRPCServer() *gogrpc.Server {
+	return s.grpc
+}
+
+// StartDraining reports NOT_SERVING from the health service so load
+// balancers stop routing new calls before GracefulStop.
+func (s *Server) StartDraining() {
+	s.health.Shutdown()
+}
+
+func (s *Server) CreateSubscription(ctx context.Context, req *pb.CreateSubscriptionRequest) (*pb.Subscription, error) {
+	if req.GetUserId() == "" || req.GetServiceName() == "" || req.GetStartDate() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id, service_name and start_date are required")
+	}
+	created, err := s.service.CreateSubscription(ctx, entities.Subscription{
+		UserID:      req.GetUserId(),
+		ServiceName: req.GetServiceName(),
+		Price:       int(req.GetPrice()),
+		StartDate:   req.GetStartDate(),
+		EndDate:     req.GetEndDate(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toPBSubscription(created), nil
+}
+
+func (s *Server) GetSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	sub, err := s.service.GetSubscription(ctx, req.GetUserId(), req.GetServiceName())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toPBSubscription(sub), nil
+}
+
+func (s *Server) UpdateSubscription(ctx context.Context, req *pb.UpdateSubscriptionRequest) (*pb.Subscription, error) {
+	var price *int
+	if req.Price != nil {
+		p := int(req.GetPrice())
+		price = &p
+	}
+	err := s.service.UpdateSubscription(ctx, req.GetUserId(), req.GetServiceName(), price, req.StartDate, req.EndDate, nil)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	sub, err := s.service.GetSubscription(ctx, req.GetUserId(), req.GetServiceName())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toPBSubscription(sub), nil
+}
+
+func (s *Server) DeleteSubscription(ctx context.Context, req *pb.DeleteSubscriptionRequest) (*pb.DeleteSubscriptionResponse, error) {
+	if err := s.service.DeleteSubscription(ctx, req.GetUserId(), req.GetServiceName()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DeleteSubscriptionResponse{}, nil
+}
+
+func (s *Server) RestoreSubscription(ctx context.Context, req *pb.RestoreSubscriptionRequest) (*pb.Subscription, error) {
+	sub, err := s.service.RestoreSubscription(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toPBSubscription(sub), nil
+}
+
+// ListSubscriptions pages through the user's subscriptions in the order the
+// use case returns them.
+func (s *Server) ListSubscriptions(ctx context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+	if req.GetIncludeDeleted() && !s.isAdmin(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "admin access required")
+	}
+	size := int(req.GetPageSize())
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case size == 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize
+	}
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	subs, err := s.service.GetListSubscriptions(ctx, req.GetUserId(), req.GetIncludeDeleted())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if offset > len(subs) {
+		offset = len(subs)
+	}
+	end := offset + size
+	if end > len(subs) {
+		end = len(subs)
+	}
+
+	resp := &pb.ListSubscriptionsResponse{Subscriptions: make([]*pb.Subscription, 0, end-offset)}
+	for _, sub := range subs[offset:end] {
+		resp.Subscriptions = append(resp.Subscriptions, toPBSubscription(sub))
+	}
+	if end < len(subs) {
+		resp.NextPageToken = encodePageToken(end)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetTotalCost(ctx context.Context, req *pb.GetTotalCostRequest) (*pb.GetTotalCostResponse, error) {
+	if req.GetUserId() == "" || req.GetStartDate() == "" || req.GetEndDate() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id, start_date and end_date are required")
+	}
+	total, err := s.service.GetTotalCostByPeriod(ctx, req.GetUserId(), req.GetServiceName(), req.GetStartDate(), req.GetEndDate(), entities.CostModeExpected)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.GetTotalCostResponse{TotalCost: int64(total)}, nil
+}
+
+func (s *Server) GetCostBreakdown(ctx context.Context, req *pb.GetCostBreakdownRequest) (*pb.GetCostBreakdownResponse, error) {
+	if req.GetUserId() == "" || req.GetStartDate() == "" || req.GetEndDate() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id, start_date and end_date are required")
+	}
+	costs, err := s.service.GetCostBreakdown(ctx, req.GetUserId(), req.GetStartDate(), req.GetEndDate())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &pb.GetCostBreakdownResponse{Services: make([]*pb.ServiceCost, 0, len(costs))}
+	for _, c := range costs {
+		resp.Services = append(resp.Services, &pb.ServiceCost{ServiceName: c.ServiceName, TotalCost: int64(c.TotalCost)})
+		resp.TotalCost += int64(c.TotalCost)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetSubscriptionAudit(ctx context.Context, req *pb.GetSubscriptionAuditRequest) (*pb.AuditEntries, error) {
+	entries, err := s.service.GetSubscriptionAudit(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toPBAudit(entries), nil
+}
+
+func (s *Server) ListAudit(ctx context.Context, req *pb.ListAuditRequest) (*pb.AuditEntries, error) {
+	if !s.isAdmin(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "admin access required")
+	}
+	filter, err := toAuditFilter(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	entries, err := s.service.ListAudit(ctx, filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toPBAudit(entries), nil
+}
+
+// toStatus maps use case errors onto gRPC status codes.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, entities.ErrSubscriptionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, entities.ErrSubscriptionExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, entities.ErrTenantRequired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, entities.ErrInvalidPeriod):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,285 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	pb "github.com/100bench/subscription_aggregator/pkg/grpc/subscriptionpb"
+)
+
+// newTestConn serves a Server over a testutil.Storage on a bufconn listener
+// and dials it.
+func newTestConn(t *testing.T) *gogrpc.ClientConn {
+	t.Helper()
+	server, err := NewServer(testutil.NewService(t, nil),
+		WithLogger(testutil.Logger()), WithAPIKeys(testutil.APIKeys(t)), WithAdminToken(testutil.AdminToken))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = server.GRPCServer().Serve(lis) }()
+	t.Cleanup(server.GRPCServer().Stop)
+
+	conn, err := gogrpc.NewClient("passthrough:///bufconn",
+		gogrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		gogrpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// withKey authenticates outgoing calls with key, followed by extra key-value
+// metadata pairs.
+func withKey(key string, kv ...string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), append([]string{AuthKey, "Bearer " + key}, kv...)...)
+}
+
+func TestSubscriptionCRUD(t *testing.T) {
+	client := pb.NewSubscriptionServiceClient(newTestConn(t))
+	ctx := withKey(testutil.AcmeKey)
+
+	created, err := client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{
+		UserId: testutil.UserID, ServiceName: "Netflix", Price: 799, StartDate: "01-2025", EndDate: "12-2025",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	price := int64(899)
+	updated, err := client.UpdateSubscription(ctx, &pb.UpdateSubscriptionRequest{UserId: testutil.UserID, ServiceName: "Netflix", Price: &price})
+	if err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+	if updated.GetId() != created.GetId() || updated.GetPrice() != price {
+		t.Errorf("updated = %v, want id %d price %d", updated, created.GetId(), price)
+	}
+
+	got, err := client.GetSubscription(ctx, &pb.GetSubscriptionRequest{UserId: testutil.UserID, ServiceName: "Netflix"})
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if got.GetPrice() != price || got.GetStartDate() != "01-2025" || got.GetEndDate() != "12-2025" {
+		t.Errorf("got = %v", got)
+	}
+
+	if _, err := client.DeleteSubscription(ctx, &pb.DeleteSubscriptionRequest{UserId: testutil.UserID, ServiceName: "Netflix"}); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+	list, err := client.ListSubscriptions(ctx, &pb.ListSubscriptionsRequest{UserId: testutil.UserID})
+	if err != nil {
+		t.Fatalf("ListSubscriptions: %v", err)
+	}
+	if len(list.GetSubscriptions()) != 0 {
+		t.Errorf("listed %d subscriptions after delete, want 0", len(list.GetSubscriptions()))
+	}
+	admin := withKey(testutil.AcmeKey, AdminTokenKey, testutil.AdminToken)
+	list, err = client.ListSubscriptions(admin, &pb.ListSubscriptionsRequest{UserId: testutil.UserID, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("ListSubscriptions with deleted: %v", err)
+	}
+	if len(list.GetSubscriptions()) != 1 || list.GetSubscriptions()[0].GetDeletedAt() == "" {
+		t.Errorf("deleted subscriptions = %v, want the deleted one", list.GetSubscriptions())
+	}
+
+	restored, err := client.RestoreSubscription(ctx, &pb.RestoreSubscriptionRequest{Id: created.GetId()})
+	if err != nil {
+		t.Fatalf("RestoreSubscription: %v", err)
+	}
+	if restored.GetDeletedAt() != "" {
+		t.Errorf("restored deleted_at = %q, want empty", restored.GetDeletedAt())
+	}
+}
+
+func TestListSubscriptionsPages(t *testing.T) {
+	client := pb.NewSubscriptionServiceClient(newTestConn(t))
+	ctx := withKey(testutil.AcmeKey)
+	for _, name := range []string{"Netflix", "Spotify", "YouTube"} {
+		if _, err := client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{UserId: testutil.UserID, ServiceName: name, Price: 100, StartDate: "01-2025"}); err != nil {
+			t.Fatalf("CreateSubscription %s: %v", name, err)
+		}
+	}
+
+	var names []string
+	req := &pb.ListSubscriptionsRequest{UserId: testutil.UserID, PageSize: 2}
+	for pages := 1; ; pages++ {
+		resp, err := client.ListSubscriptions(ctx, req)
+		if err != nil {
+			t.Fatalf("ListSubscriptions page %d: %v", pages, err)
+		}
+		for _, sub := range resp.GetSubscriptions() {
+			names = append(names, sub.GetServiceName())
+		}
+		if resp.GetNextPageToken() == "" {
+			if pages != 2 {
+				t.Errorf("got %d pages, want 2", pages)
+			}
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if len(names) != 3 {
+		t.Errorf("listed %v, want 3 subscriptions", names)
+	}
+}
+
+func TestErrorsMapToStatusCodes(t *testing.T) {
+	client := pb.NewSubscriptionServiceClient(newTestConn(t))
+	if _, err := client.CreateSubscription(withKey(testutil.AcmeKey), &pb.CreateSubscriptionRequest{
+		UserId: testutil.UserID, ServiceName: "Netflix", Price: 799, StartDate: "01-2025",
+	}); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	tests := map[string]struct {
+		call func() error
+		want codes.Code
+	}{
+		"not found": {
+			call: func() error {
+				_, err := client.GetSubscription(withKey(testutil.AcmeKey), &pb.GetSubscriptionRequest{UserId: testutil.UserID, ServiceName: "Spotify"})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		"other tenant's subscription": {
+			call: func() error {
+				_, err := client.GetSubscription(withKey(testutil.GlobexKey), &pb.GetSubscriptionRequest{UserId: testutil.UserID, ServiceName: "Netflix"})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		"already exists": {
+			call: func() error {
+				_, err := client.CreateSubscription(withKey(testutil.AcmeKey), &pb.CreateSubscriptionRequest{UserId: testutil.UserID, ServiceName: "Netflix", Price: 1, StartDate: "01-2025"})
+				return err
+			},
+			want: codes.AlreadyExists,
+		},
+		"invalid period": {
+			call: func() error {
+				_, err := client.GetTotalCost(withKey(testutil.AcmeKey), &pb.GetTotalCostRequest{UserId: testutil.UserID, StartDate: "13-2025", EndDate: "12-2025"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		"missing fields": {
+			call: func() error {
+				_, err := client.CreateSubscription(withKey(testutil.AcmeKey), &pb.CreateSubscriptionRequest{UserId: testutil.UserID})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		"no key": {
+			call: func() error {
+				_, err := client.ListSubscriptions(context.Background(), &pb.ListSubscriptionsRequest{UserId: testutil.UserID})
+				return err
+			},
+			want: codes.Unauthenticated,
+		},
+		"unknown key": {
+			call: func() error {
+				_, err := client.ListSubscriptions(withKey("not-a-configured-key-0123456789"), &pb.ListSubscriptionsRequest{UserId: testutil.UserID})
+				return err
+			},
+			want: codes.Unauthenticated,
+		},
+		"tenant mismatch": {
+			call: func() error {
+				_, err := client.ListSubscriptions(withKey(testutil.GlobexKey, TenantKey, "acme"), &pb.ListSubscriptionsRequest{UserId: testutil.UserID})
+				return err
+			},
+			want: codes.PermissionDenied,
+		},
+		"admin only": {
+			call: func() error {
+				_, err := client.ListAudit(withKey(testutil.AcmeKey), &pb.ListAuditRequest{})
+				return err
+			},
+			want: codes.PermissionDenied,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealth(t *testing.T) {
+	client := healthpb.NewHealthClient(newTestConn(t))
+
+	// Health calls need no API key.
+	for _, service := range []string{"", pb.SubscriptionService_ServiceDesc.ServiceName} {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q): %v", service, err)
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Check(%q) = %v, want SERVING", service, resp.GetStatus())
+		}
+	}
+}
+
+func TestReflectionListsSubscriptionService(t *testing.T) {
+	client := reflectionpb.NewServerReflectionClient(newTestConn(t))
+	stream, err := client.ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	_ = stream.CloseSend()
+
+	services := map[string]bool{}
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services[s.GetName()] = true
+	}
+	for _, want := range []string{pb.SubscriptionService_ServiceDesc.ServiceName, "grpc.health.v1.Health"} {
+		if !services[want] {
+			t.Errorf("reflection lists %v, missing %s", services, want)
+		}
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	tests := map[error]codes.Code{
+		errors.Wrap(entities.ErrSubscriptionNotFound, "get"):  codes.NotFound,
+		errors.Wrap(entities.ErrSubscriptionExists, "create"): codes.AlreadyExists,
+		entities.ErrTenantRequired:                            codes.Unauthenticated,
+		errors.Wrap(entities.ErrInvalidPeriod, "start date"):  codes.InvalidArgument,
+		context.Canceled: codes.Canceled,
+		errors.Wrap(context.DeadlineExceeded, "query"): codes.DeadlineExceeded,
+		errors.New("connection refused"):               codes.Internal,
+	}
+	for err, want := range tests {
+		if got := status.Code(toStatus(err)); got != want {
+			t.Errorf("toStatus(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
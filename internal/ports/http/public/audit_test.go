@@ -0,0 +1,165 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+func listAudit(t *testing.T, ts *httptest.Server, query url.Values) []pkg.AuditEntryDTO {
+	t.Helper()
+	resp := call(t, ts, http.MethodGet, "/admin/audit?"+query.Encode(), testutil.AcmeKey, nil,
+		http.Header{AdminTokenHeader: {testutil.AdminToken}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /admin/audit?%s: status %d", query.Encode(), resp.StatusCode)
+	}
+	var audit pkg.GetAuditResponse
+	if err := json.NewDecoder(resp.Body).Decode(&audit); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return audit.Entries
+}
+
+func TestMutationsWriteAudit(t *testing.T) {
+	ts, _ := newTestServer(t, WithAdminToken(testutil.AdminToken))
+	path := "/subscriptions/" + testutil.UserID + "/Netflix"
+	claimed := http.Header{ActorHeader: {"support@example.com"}}
+
+	sub := createSub(t, ts, testutil.AcmeKey, "Netflix")
+	price := 500
+	if resp := call(t, ts, http.MethodPut, path, testutil.AcmeKey, pkg.UpdateSubRequest{Price: &price}, claimed); resp.StatusCode != http.StatusOK {
+		t.Fatalf("update: status %d", resp.StatusCode)
+	}
+	if resp := call(t, ts, http.MethodDelete, path, testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+
+	entries := listAudit(t, ts, url.Values{"subscription_id": {strconv.FormatInt(sub.ID, 10)}})
+	want := []struct {
+		action, actor string
+		before, after bool
+	}{
+		{"delete", "", true, false},
+		{"update", "support@example.com", true, true},
+		{"create", "", false, true},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d audit entries, want %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Action != w.action || e.Principal != "acme/billing" || e.Actor != w.actor ||
+			e.UserId != testutil.UserID || e.RequestID == "" ||
+			(len(e.Before) > 0) != w.before || (len(e.After) > 0) != w.after {
+			t.Errorf("entry %d = %+v, want %s by acme/billing with actor %q", i, e, w.action, w.actor)
+		}
+	}
+
+	var after pkg.SubscriptionDTO
+	if err := json.Unmarshal(entries[1].After, &after); err != nil {
+		t.Fatalf("unmarshal update snapshot: %v", err)
+	}
+	if after.Price != price {
+		t.Errorf("update snapshot price = %d, want %d", after.Price, price)
+	}
+}
+
+func TestActorHeaderDoesNotOverridePrincipal(t *testing.T) {
+	ts, _ := newTestServer(t, WithAdminToken(testutil.AdminToken))
+	resp := call(t, ts, http.MethodPost, "/subscriptions", testutil.AcmeKey, testutil.CreateSubRequest("Netflix"),
+		http.Header{ActorHeader: {"globex/crm"}})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d", resp.StatusCode)
+	}
+
+	if entries := listAudit(t, ts, url.Values{"principal": {"globex/crm"}}); len(entries) != 0 {
+		t.Errorf("the claimed actor is recorded as principal in %+v", entries)
+	}
+	entries := listAudit(t, ts, url.Values{"actor": {"globex/crm"}})
+	if len(entries) != 1 || entries[0].Principal != "acme/billing" {
+		t.Errorf("entries = %+v, want one by acme/billing claiming globex/crm", entries)
+	}
+}
+
+func TestAdminAuditFilters(t *testing.T) {
+	ts, _ := newTestServer(t, WithAdminToken(testutil.AdminToken))
+	netflix := createSub(t, ts, testutil.AcmeKey, "Netflix")
+	createSub(t, ts, testutil.AcmeKey, "Spotify")
+	price := 500
+	call(t, ts, http.MethodPut, "/subscriptions/"+testutil.UserID+"/Spotify", testutil.AcmeKey,
+		pkg.UpdateSubRequest{Price: &price}, http.Header{ActorHeader: {"support@example.com"}})
+	createSub(t, ts, testutil.GlobexKey, "Netflix")
+
+	tests := map[string]struct {
+		query url.Values
+		want  int
+	}{
+		"all of the tenant":   {url.Values{}, 3},
+		"by subscription":     {url.Values{"subscription_id": {strconv.FormatInt(netflix.ID, 10)}}, 1},
+		"by user":             {url.Values{"user_id": {testutil.UserID}}, 3},
+		"by other user":       {url.Values{"user_id": {"8b9c2f43-3d57-4f3a-9b0e-1b2f0a6f0c11"}}, 0},
+		"by principal":        {url.Values{"principal": {"acme/billing"}}, 3},
+		"by actor":            {url.Values{"actor": {"support@example.com"}}, 1},
+		"by action":           {url.Values{"action": {"create"}}, 2},
+		"from the future":     {url.Values{"from": {"2999-01-01T00:00:00Z"}}, 0},
+		"up to the past":      {url.Values{"to": {"2000-01-01T00:00:00Z"}}, 0},
+		"limited":             {url.Values{"limit": {"2"}}, 2},
+		"action and actor":    {url.Values{"action": {"create"}, "actor": {"support@example.com"}}, 0},
+		"principal of globex": {url.Values{"principal": {"globex/crm"}}, 0},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := listAudit(t, ts, tt.query); len(got) != tt.want {
+				t.Errorf("got %d entries, want %d: %+v", len(got), tt.want, got)
+			}
+		})
+	}
+
+	for _, query := range []string{"action=rename", "subscription_id=x", "before_id=x", "limit=x", "from=yesterday"} {
+		resp := call(t, ts, http.MethodGet, "/admin/audit?"+query, testutil.AcmeKey, nil,
+			http.Header{AdminTokenHeader: {testutil.AdminToken}})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAdminAuditPaging(t *testing.T) {
+	ts, _ := newTestServer(t, WithAdminToken(testutil.AdminToken))
+	for _, name := range []string{"Netflix", "Spotify", "Kinopoisk", "Okko", "Ivi"} {
+		createSub(t, ts, testutil.AcmeKey, name)
+	}
+
+	var pages [][]pkg.AuditEntryDTO
+	query := url.Values{"limit": {"2"}}
+	for {
+		page := listAudit(t, ts, query)
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, page)
+		query.Set("before_id", strconv.FormatInt(page[len(page)-1].ID, 10))
+		if len(pages) > 5 {
+			t.Fatal("paging does not end")
+		}
+	}
+
+	if len(pages) != 3 || len(pages[0]) != 2 || len(pages[1]) != 2 || len(pages[2]) != 1 {
+		t.Fatalf("pages = %+v, want 2, 2 and 1 entries", pages)
+	}
+	var last int64
+	for _, page := range pages {
+		for _, e := range page {
+			if last != 0 && e.ID >= last {
+				t.Errorf("entry %d follows %d, want newest first without repeats", e.ID, last)
+			}
+			last = e.ID
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// @Summary Create a budget
+// @Description Sets a monthly spending limit over all subscriptions of the user or, with services, over those services only. Charges are billed in RUB, so RUB is the only currency accepted and the default.
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param Idempotency-Key header string false "Replays the first response to repeats of the request with this key"
+// @Param userID path string true "User ID"
+// @Param budget body pkg.BudgetRequest true "Budget"
+// @Success 201 {object} pkg.BudgetDTO
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Failure 422 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/budgets [post]
+func (s *Server) handleCreateBudget(w http.ResponseWriter, r *http.Request) {
+	var req pkg.BudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	created, err := s.service.CreateBudget(r.Context(), toBudget(chi.URLParam(r, "userID"), 0, req))
+	if err != nil {
+		s.respondWithBudgetError(w, err)
+		return
+	}
+	s.respondWithJSON(w, http.StatusCreated, toBudgetDTO(created))
+}
+
+// @Summary List budgets
+// @Tags budgets
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param userID path string true "User ID"
+// @Success 200 {object} pkg.GetBudgetsResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/budgets [get]
+func (s *Server) handleListBudgets(w http.ResponseWriter, r *http.Request) {
+	budgets, err := s.service.ListBudgets(r.Context(), chi.URLParam(r, "userID"))
+	if err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	list := make([]pkg.BudgetDTO, 0, len(budgets))
+	for _, b := range budgets {
+		list = append(list, toBudgetDTO(b))
+	}
+	s.respondWithJSON(w, http.StatusOK, pkg.GetBudgetsResponse{Budgets: list})
+}
+
+// @Summary Replace a budget
+// @Description Replaces the name, limit, currency and services of the budget; a changed budget is alerted on again once exceeded
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param userID path string true "User ID"
+// @Param id path int true "Budget ID"
+// @Param budget body pkg.BudgetRequest true "Budget"
+// @Success 200 {object} pkg.BudgetDTO
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/budgets/{id} [put]
+func (s *Server) handleUpdateBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid budget id"})
+		return
+	}
+	var req pkg.BudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	updated, err := s.service.UpdateBudget(r.Context(), toBudget(chi.URLParam(r, "userID"), id, req))
+	if err != nil {
+		s.respondWithBudgetError(w, err)
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toBudgetDTO(updated))
+}
+
+// @Summary Delete a budget
+// @Tags budgets
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param userID path string true "User ID"
+// @Param id path int true "Budget ID"
+// @Success 204
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/budgets/{id} [delete]
+func (s *Server) handleDeleteBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid budget id"})
+		return
+	}
+	if err := s.service.DeleteBudget(r.Context(), chi.URLParam(r, "userID"), id); err != nil {
+		s.respondWithBudgetError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Budget status
+// @Description Evaluates every budget of the user against the charges of the month: spent is what was paid so far, projected adds the charges still expected. Exceeded budgets are projected over their limit.
+// @Tags budgets
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param userID path string true "User ID"
+// @Param period query string false "Month MM-YYYY, the current one by default"
+// @Success 200 {object} pkg.GetBudgetStatusResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/budgets/status [get]
+func (s *Server) handleGetBudgetStatus(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = time.Now().UTC().Format("01-2006")
+	}
+	statuses, err := s.service.GetBudgetStatus(r.Context(), chi.URLParam(r, "userID"), period)
+	if err != nil {
+		s.respondWithBudgetError(w, err)
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toBudgetStatusResponse(period, statuses))
+}
+
+func (s *Server) respondWithBudgetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, entities.ErrInvalidBudget), errors.Is(err, entities.ErrInvalidPeriod):
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, entities.ErrBudgetNotFound):
+		s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, entities.ErrBudgetExists):
+		s.respondWithError(w, http.StatusConflict, pkg.ErrorResponse{Error: err.Error()})
+	default:
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+	}
+}
+
+func toBudget(userID string, id int64, req pkg.BudgetRequest) entities.Budget {
+	return entities.Budget{
+		ID:       id,
+		UserID:   userID,
+		Name:     req.Name,
+		Limit:    req.Limit,
+		Currency: req.Currency,
+		Services: req.Services,
+	}
+}
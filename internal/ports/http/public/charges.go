@@ -0,0 +1,139 @@
+package public
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// @Summary List charges
+// @Description Returns the charge ledger of the user: one entry per subscription per billed month within the period
+// @Tags subscriptions
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param userID path string true "User ID"
+// @Param start_date query string true "Start month MM-YYYY"
+// @Param end_date query string true "End month MM-YYYY"
+// @Param service_name query string false "Service name"
+// @Success 200 {object} pkg.GetChargesResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/charges [get]
+func (s *Server) handleListCharges(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	startDate := q.Get("start_date")
+	endDate := q.Get("end_date")
+	if startDate == "" || endDate == "" {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "missing required query params: start_date, end_date"})
+		return
+	}
+
+	charges, err := s.service.ListCharges(r.Context(), chi.URLParam(r, "userID"), q.Get("service_name"), startDate, endDate)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidPeriod) {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toChargesResponse(charges))
+}
+
+// @Summary Mark a charge as paid
+// @Description Records the payment of the subscription's charge for the month; the body is optional and defaults to the charge amount paid today
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param Idempotency-Key header string false "Replays the first response to repeats of the request with this key"
+// @Param id path int true "Subscription ID"
+// @Param period path string true "Month MM-YYYY"
+// @Param request body pkg.PayChargeRequest false "Actual amount and payment date YYYY-MM-DD"
+// @Success 200 {object} pkg.ChargeDTO
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Failure 422 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /subscriptions/by-id/{id}/charges/{period}/pay [post]
+func (s *Server) handlePayCharge(w http.ResponseWriter, r *http.Request) {
+	var req pkg.PayChargeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	mark := entities.ChargeMark{Status: entities.ChargeStatusPaid, Amount: req.Amount}
+	if req.PaidOn != "" {
+		paidOn, err := time.Parse(time.DateOnly, req.PaidOn)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "paid_on must be YYYY-MM-DD"})
+			return
+		}
+		mark.PaidOn = &paidOn
+	}
+	s.markCharge(w, r, mark)
+}
+
+// @Summary Mark a charge as refunded, skipped or disputed
+// @Description Refunds a paid or disputed charge, skips an expected one or disputes an expected or paid one
+// @Tags subscriptions
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param Idempotency-Key header string false "Replays the first response to repeats of the request with this key"
+// @Param id path int true "Subscription ID"
+// @Param period path string true "Month MM-YYYY"
+// @Param action path string true "Status change" Enums(refund, skip, dispute)
+// @Success 200 {object} pkg.ChargeDTO
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Failure 422 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /subscriptions/by-id/{id}/charges/{period}/{action} [post]
+func (s *Server) handleMarkCharge(status entities.ChargeStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.markCharge(w, r, entities.ChargeMark{Status: status})
+	}
+}
+
+func (s *Server) markCharge(w http.ResponseWriter, r *http.Request, mark entities.ChargeMark) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid subscription id"})
+		return
+	}
+
+	charge, err := s.service.MarkCharge(r.Context(), id, chi.URLParam(r, "period"), mark)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidCharge), errors.Is(err, entities.ErrInvalidPeriod):
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, entities.ErrChargeNotFound):
+			s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, entities.ErrChargeTransition):
+			s.respondWithError(w, http.StatusConflict, pkg.ErrorResponse{Error: err.Error()})
+		default:
+			s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toChargeDTO(charge))
+}
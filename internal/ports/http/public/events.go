@@ -0,0 +1,135 @@
+package public
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+const (
+	// feedBatchSize caps the events read from storage at a time.
+	feedBatchSize = 100
+	// feedWriteTimeout bounds every write to an event stream, replacing the
+	// server write timeout which would end the stream.
+	feedWriteTimeout = 10 * time.Second
+	// feedRetry tells EventSource clients how long to wait before reconnecting.
+	feedRetry = 3 * time.Second
+)
+
+// CloseStreams ends all open event streams, whose clients reconnect elsewhere
+// with Last-Event-ID. It is meant for http.Server.RegisterOnShutdown, as
+// Shutdown would otherwise wait for the streams until its deadline.
+func (s *Server) CloseStreams() {
+	s.closeOnce.Do(func() { close(s.closing) })
+}
+
+// @Summary Stream a user's change feed
+// @Description Server-Sent Events for changes of the user's subscriptions and renewal reminders, in commit order. Every event has the feed position as its id and the event type as its name. Without Last-Event-ID only new events are sent; with it the stream resumes after that event as long as it is within the feed retention.
+// @Tags events
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param userID path string true "User ID"
+// @Param Last-Event-ID header int false "Resume after this event"
+// @Param last_event_id query int false "Resume after this event, for clients that cannot set headers"
+// @Success 200 {object} pkg.FeedEventDTO "data of each event"
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/events [get]
+func (s *Server) handleUserEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := chi.URLParam(r, "userID")
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var after int64
+	if lastID != "" {
+		var err error
+		if after, err = strconv.ParseInt(lastID, 10, 64); err != nil || after < 0 {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	wake, stop, err := s.feed.Watch(ctx, userID)
+	if err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer stop()
+	if lastID == "" {
+		if after, err = s.feed.LastEventID(ctx, userID); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server read timeout, whose expiry would cancel
+	// the request context; writes get their own deadline instead.
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		s.logger.WarnContext(ctx, "event stream keeps the server read deadline", "error", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", feedRetry.Milliseconds())
+
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		events, err := s.feed.Events(ctx, userID, after, feedBatchSize)
+		if err != nil {
+			// Headers are sent already; the client reconnects and resumes.
+			s.logger.ErrorContext(ctx, "failed to read user events", "error", err)
+			return
+		}
+		rc.SetWriteDeadline(time.Now().Add(feedWriteTimeout))
+		for _, event := range events {
+			if err := writeFeedEvent(w, event.Seq, string(event.Type), toFeedEventDTO(event)); err != nil {
+				return
+			}
+			after = event.Seq
+		}
+		if len(events) == feedBatchSize {
+			continue
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.closing:
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(feedWriteTimeout))
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeFeedEvent(w io.Writer, seq int64, name string, data pkg.FeedEventDTO) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", seq, name, payload)
+	return err
+}
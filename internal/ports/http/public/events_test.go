@@ -0,0 +1,321 @@
+package public
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+type feedKey struct{ tenantID, userID string }
+
+// memFeed keeps the feeds of all tenants in one sequence, like the
+// user_events table, and wakes readers of the tenant and user an event was
+// appended for, like the LISTEN/NOTIFY listener.
+type memFeed struct {
+	mu      sync.Mutex
+	seq     int64
+	events  map[feedKey][]en.FeedEvent
+	readers map[feedKey]map[chan struct{}]struct{}
+}
+
+func newMemFeed() *memFeed {
+	return &memFeed{events: map[feedKey][]en.FeedEvent{}, readers: map[feedKey]map[chan struct{}]struct{}{}}
+}
+
+func feedKeyOf(ctx context.Context, userID string) feedKey {
+	tenantID, _ := en.TenantFromContext(ctx)
+	return feedKey{tenantID, userID}
+}
+
+func (f *memFeed) ListUserEvents(ctx context.Context, userID string, afterID int64, limit int) ([]en.FeedEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []en.FeedEvent
+	for _, e := range f.events[feedKeyOf(ctx, userID)] {
+		if e.Seq > afterID && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (f *memFeed) LastUserEventID(ctx context.Context, userID string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	events := f.events[feedKeyOf(ctx, userID)]
+	if len(events) == 0 {
+		return 0, nil
+	}
+	return events[len(events)-1].Seq, nil
+}
+
+func (f *memFeed) AppendUserEvent(ctx context.Context, event en.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := feedKeyOf(ctx, event.UserID)
+	f.seq++
+	event.TenantID = key.tenantID
+	f.events[key] = append(f.events[key], en.FeedEvent{Seq: f.seq, Event: event})
+	for ch := range f.readers[key] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (f *memFeed) PurgeUserEvents(ctx context.Context, createdBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *memFeed) Subscribe(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	key := feedKeyOf(ctx, userID)
+	ch := make(chan struct{}, 1)
+	f.mu.Lock()
+	if f.readers[key] == nil {
+		f.readers[key] = map[chan struct{}]struct{}{}
+	}
+	f.readers[key][ch] = struct{}{}
+	f.mu.Unlock()
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.readers[key], ch)
+	}, nil
+}
+
+func (f *memFeed) watchers(tenantID, userID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.readers[feedKey{tenantID, userID}])
+}
+
+func (f *memFeed) append(t *testing.T, tenantID, userID, service string) {
+	t.Helper()
+	ctx := en.WithTenant(context.Background(), tenantID)
+	event := en.Event{
+		ID: uuid.NewString(), Type: en.EventSubscriptionRenewing, UserID: userID,
+		Subscription: en.Subscription{UserID: userID, ServiceName: service, Price: 400, StartDate: "01-2025"},
+		OccurredAt:   time.Now(),
+	}
+	if err := f.AppendUserEvent(ctx, event); err != nil {
+		t.Fatalf("AppendUserEvent: %v", err)
+	}
+}
+
+func newFeedServer(t *testing.T, heartbeat time.Duration) (*httptest.Server, *memFeed) {
+	t.Helper()
+	feed := newMemFeed()
+	service, err := cases.NewFeedService(feed, feed, testutil.Logger(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewFeedService: %v", err)
+	}
+	ts, _ := newTestServer(t, WithFeed(service, heartbeat))
+	return ts, feed
+}
+
+// sseFrame is one event or comment of an event stream.
+type sseFrame struct {
+	id, event, data, comment string
+}
+
+// stream opens the user's feed and returns its frames as they arrive.
+func stream(t *testing.T, ts *httptest.Server, key, userID, query string, header http.Header) <-chan sseFrame {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/users/"+userID+"/events"+query, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET events: %d %s, want an event stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	frames := make(chan sseFrame, 100)
+	go func() {
+		defer close(frames)
+		scanner := bufio.NewScanner(resp.Body)
+		var f sseFrame
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if f != (sseFrame{}) {
+					frames <- f
+				}
+				f = sseFrame{}
+			case strings.HasPrefix(line, ":"):
+				f.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				f.id = line[len("id: "):]
+			case strings.HasPrefix(line, "event: "):
+				f.event = line[len("event: "):]
+			case strings.HasPrefix(line, "data: "):
+				f.data = line[len("data: "):]
+			}
+		}
+	}()
+	return frames
+}
+
+// next returns the next event of frames, skipping retry hints and comments.
+func next(t *testing.T, frames <-chan sseFrame) sseFrame {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case f, ok := <-frames:
+			if !ok {
+				t.Fatal("event stream ended")
+			}
+			if f.id != "" {
+				return f
+			}
+		case <-timeout:
+			t.Fatal("no event within 5s")
+		}
+	}
+}
+
+// quiet fails if frames carries an event within d.
+func quiet(t *testing.T, frames <-chan sseFrame, d time.Duration) {
+	t.Helper()
+	timeout := time.After(d)
+	for {
+		select {
+		case f := <-frames:
+			if f.id != "" {
+				t.Fatalf("unexpected event %+v", f)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func waitWatching(t *testing.T, feed *memFeed, tenantID, userID string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for feed.watchers(tenantID, userID) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d streams of %s/%s, want %d", feed.watchers(tenantID, userID), tenantID, userID, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFeedResumesAfterLastEventID(t *testing.T) {
+	ts, feed := newFeedServer(t, time.Hour)
+	userID := uuid.NewString()
+	for _, service := range []string{"Netflix", "Spotify", "Kinopoisk"} {
+		feed.append(t, "acme", userID, service)
+	}
+
+	t.Run("without Last-Event-ID only new events", func(t *testing.T) {
+		frames := stream(t, ts, testutil.AcmeKey, userID, "", nil)
+		waitWatching(t, feed, "acme", userID, 1)
+		quiet(t, frames, 100*time.Millisecond)
+		feed.append(t, "acme", userID, "Okko")
+		if f := next(t, frames); f.id != "4" || !strings.Contains(f.data, `"Okko"`) {
+			t.Errorf("first event = %+v, want the new Okko event 4", f)
+		}
+	})
+
+	for name, resume := range map[string]struct {
+		query  string
+		header http.Header
+	}{
+		"header": {"", http.Header{"Last-Event-Id": {"1"}}},
+		"query":  {"?last_event_id=1", nil},
+	} {
+		t.Run("resume by "+name, func(t *testing.T) {
+			frames := stream(t, ts, testutil.AcmeKey, userID, resume.query, resume.header)
+			for _, want := range []string{"2", "3", "4"} {
+				f := next(t, frames)
+				if f.id != want || f.event != string(en.EventSubscriptionRenewing) {
+					t.Fatalf("event = %+v, want %s named %s", f, want, en.EventSubscriptionRenewing)
+				}
+			}
+		})
+	}
+
+	for _, bad := range []string{"abc", "-1"} {
+		resp := call(t, ts, http.MethodGet, "/users/"+userID+"/events", testutil.AcmeKey, nil, http.Header{"Last-Event-Id": {bad}})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Last-Event-ID %q: status %d, want 400", bad, resp.StatusCode)
+		}
+	}
+}
+
+func TestFeedSendsHeartbeatsOnIdleStreams(t *testing.T) {
+	ts, _ := newFeedServer(t, 20*time.Millisecond)
+	frames := stream(t, ts, testutil.AcmeKey, uuid.NewString(), "", nil)
+
+	heartbeats := 0
+	timeout := time.After(5 * time.Second)
+	for heartbeats < 2 {
+		select {
+		case f, ok := <-frames:
+			if !ok {
+				t.Fatal("event stream ended")
+			}
+			if f.id != "" {
+				t.Fatalf("unexpected event %+v on an idle stream", f)
+			}
+			if f.comment == "keep-alive" {
+				heartbeats++
+			}
+		case <-timeout:
+			t.Fatalf("%d heartbeats within 5s, want 2", heartbeats)
+		}
+	}
+}
+
+func TestFeedIsScopedToTheCallersTenant(t *testing.T) {
+	ts, feed := newFeedServer(t, time.Hour)
+	userID := uuid.NewString()
+	feed.append(t, "acme", userID, "Netflix")
+
+	globex := stream(t, ts, testutil.GlobexKey, userID, "", http.Header{"Last-Event-Id": {"0"}})
+	acme := stream(t, ts, testutil.AcmeKey, userID, "", http.Header{"Last-Event-Id": {"0"}})
+	if f := next(t, acme); f.id != "1" {
+		t.Fatalf("acme's first event = %+v, want 1", f)
+	}
+	waitWatching(t, feed, "globex", userID, 1)
+	waitWatching(t, feed, "acme", userID, 1)
+
+	// An event of acme wakes only acme's stream; one of globex, for the same
+	// user ID, reaches only globex's.
+	feed.append(t, "acme", userID, "Spotify")
+	if f := next(t, acme); f.id != "2" || !strings.Contains(f.data, `"Spotify"`) {
+		t.Errorf("acme's event = %+v, want Spotify 2", f)
+	}
+	feed.append(t, "globex", userID, "Okko")
+	if f := next(t, globex); f.id != "3" || !strings.Contains(f.data, `"Okko"`) {
+		t.Errorf("globex's first event = %+v, want its own Okko 3, none of acme's", f)
+	}
+	quiet(t, acme, 100*time.Millisecond)
+	quiet(t, globex, 100*time.Millisecond)
+}
@@ -0,0 +1,72 @@
+package public
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// readinessTimeout bounds all readiness checks of a single probe.
+const readinessTimeout = 2 * time.Second
+
+// WithReadinessCheck adds a named dependency check to /readyz.
+func WithReadinessCheck(name string, check ReadinessCheck) Option {
+	return func(s *Server) {
+		s.readinessChecks[name] = check
+	}
+}
+
+// StartDraining makes /readyz fail so that load balancers stop routing new
+// requests here while in-flight ones complete.
+func (s *Server) StartDraining() {
+	s.draining.Store(true)
+}
+
+// @Summary Liveness probe
+// @Tags health
+// @Produce json
+// @Success 200 {object} pkg.HealthResponse
+// @Router /healthz [get]
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	s.respondWithJSON(w, http.StatusOK, pkg.HealthResponse{Status: "ok"})
+}
+
+// @Summary Readiness probe
+// @Description Fails while the server is draining or any dependency check fails
+// @Tags health
+// @Produce json
+// @Success 200 {object} pkg.HealthResponse
+// @Failure 503 {object} pkg.HealthResponse
+// @Router /readyz [get]
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		s.respondWithJSON(w, http.StatusServiceUnavailable, pkg.HealthResponse{Status: "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(s.readinessChecks))
+	for name := range s.readinessChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp := pkg.HealthResponse{Status: "ok", Checks: make(map[string]string, len(names))}
+	code := http.StatusOK
+	for _, name := range names {
+		if err := s.readinessChecks[name](ctx); err != nil {
+			s.logger.WarnContext(ctx, "readiness check failed", "check", name, "error", err)
+			resp.Checks[name] = err.Error()
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[name] = "ok"
+	}
+	s.respondWithJSON(w, code, resp)
+}
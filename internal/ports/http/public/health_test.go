@@ -0,0 +1,101 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+func probe(t *testing.T, ts *httptest.Server, url string) (int, pkg.HealthResponse) {
+	t.Helper()
+	resp, err := ts.Client().Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	var health pkg.HealthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp.StatusCode, health
+}
+
+func TestReadiness(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+	deadline := func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("no deadline")
+		}
+		return nil
+	}
+
+	tests := map[string]struct {
+		checks map[string]ReadinessCheck
+		status int
+		want   pkg.HealthResponse
+	}{
+		"no checks": {nil, http.StatusOK, pkg.HealthResponse{Status: "ok"}},
+		"all pass": {
+			map[string]ReadinessCheck{"postgres": ok, "schema": deadline},
+			http.StatusOK,
+			pkg.HealthResponse{Status: "ok", Checks: map[string]string{"postgres": "ok", "schema": "ok"}},
+		},
+		"one fails": {
+			map[string]ReadinessCheck{"postgres": down, "schema": ok},
+			http.StatusServiceUnavailable,
+			pkg.HealthResponse{Status: "unavailable", Checks: map[string]string{"postgres": "connection refused", "schema": "ok"}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var opts []Option
+			for name, check := range tt.checks {
+				opts = append(opts, WithReadinessCheck(name, check))
+			}
+			ts, _ := newTestServer(t, opts...)
+
+			status, health := probe(t, ts, ts.URL+"/readyz")
+			if status != tt.status || health.Status != tt.want.Status || len(health.Checks) != len(tt.want.Checks) {
+				t.Fatalf("readyz = %d %+v, want %d %+v", status, health, tt.status, tt.want)
+			}
+			for name, want := range tt.want.Checks {
+				if health.Checks[name] != want {
+					t.Errorf("check %s = %q, want %q", name, health.Checks[name], want)
+				}
+			}
+			if status, health := probe(t, ts, ts.URL+"/healthz"); status != http.StatusOK || health.Status != "ok" {
+				t.Errorf("healthz = %d %+v, want 200 ok whatever the dependencies", status, health)
+			}
+		})
+	}
+}
+
+func TestDrainingFailsReadinessOnly(t *testing.T) {
+	server, err := NewServer(testutil.NewService(t, nil), WithLogger(testutil.Logger()), WithAPIKeys(testutil.APIKeys(t)))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+	if status, _ := probe(t, ts, ts.URL+"/readyz"); status != http.StatusOK {
+		t.Fatalf("readyz before draining = %d, want %d", status, http.StatusOK)
+	}
+
+	server.StartDraining()
+	if status, health := probe(t, ts, ts.URL+"/readyz"); status != http.StatusServiceUnavailable || health.Status != "draining" {
+		t.Errorf("readyz while draining = %d %+v, want 503 draining", status, health)
+	}
+	if status, _ := probe(t, ts, ts.URL+"/healthz"); status != http.StatusOK {
+		t.Errorf("healthz while draining = %d, want %d", status, http.StatusOK)
+	}
+	// Requests still reaching the server while it drains are served.
+	createSub(t, ts, testutil.AcmeKey, "Netflix")
+}
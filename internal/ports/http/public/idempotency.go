@@ -0,0 +1,107 @@
+package public
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/idempotency"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+const (
+	// IdempotencyKeyHeader makes a POST safe to retry: repeats of the request
+	// with the same key get the response to the first one.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// ReplayedHeader marks a response replayed for a repeated key.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize bounds the request bodies read for fingerprinting.
+	maxIdempotentBodySize = 1 << 20
+)
+
+// idempotencyMiddleware replays the stored response to POST requests that
+// repeat an Idempotency-Key of the tenant. Server errors are not stored, so a
+// retry after one runs the request again.
+func (s *Server) idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if s.idemStore == nil || r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "idempotency key is longer than 255 characters"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		if err != nil {
+			s.respondWithError(w, http.StatusRequestEntityTooLarge, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		stored, err := s.idemStore.Claim(ctx, key, idempotency.Fingerprint(r.Method, r.URL.RequestURI(), body), time.Now())
+		switch {
+		case errors.Is(err, entities.ErrIdempotencyKeyInUse):
+			w.Header().Set("Retry-After", "1")
+			s.respondWithError(w, http.StatusConflict, pkg.ErrorResponse{Error: entities.ErrIdempotencyKeyInUse.Error()})
+			return
+		case errors.Is(err, entities.ErrIdempotencyKeyReused):
+			s.respondWithError(w, http.StatusUnprocessableEntity, pkg.ErrorResponse{Error: entities.ErrIdempotencyKeyReused.Error()})
+			return
+		case err != nil:
+			// Failing open like the rate limiter: without the store a retry
+			// may conflict, but the request itself still works.
+			s.logger.ErrorContext(ctx, "idempotency store unavailable", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		case stored != nil:
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(stored.StatusCode)
+			_, _ = w.Write(stored.Body)
+			return
+		}
+
+		// The key is released unless a response is stored, also when the
+		// handler panics, so that it does not stay claimed.
+		completed := false
+		defer func() {
+			if !completed {
+				if err := s.idemStore.Release(context.WithoutCancel(ctx), key); err != nil {
+					s.logger.ErrorContext(ctx, "failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		var buf bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buf)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		resp := idempotency.Response{StatusCode: status, ContentType: ww.Header().Get("Content-Type"), Body: buf.Bytes()}
+		if err := s.idemStore.Complete(context.WithoutCancel(ctx), key, resp); err != nil {
+			s.logger.ErrorContext(ctx, "failed to store idempotent response", "error", err)
+			return
+		}
+		completed = true
+	})
+}
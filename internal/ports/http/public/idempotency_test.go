@@ -0,0 +1,102 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/idempotency"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+func TestIdempotentCreateReplaysFirstResponse(t *testing.T) {
+	ts, _ := newTestServer(t, WithIdempotency(idempotency.NewMemoryStore()))
+	key := http.Header{IdempotencyKeyHeader: {"create-netflix"}}
+
+	var ids []int64
+	for i := 0; i < 2; i++ {
+		resp := call(t, ts, http.MethodPost, "/subscriptions", testutil.AcmeKey, testutil.CreateSubRequest("Netflix"), key)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, resp.StatusCode, http.StatusCreated)
+		}
+		if replayed := resp.Header.Get(ReplayedHeader) == "true"; replayed != (i == 1) {
+			t.Errorf("attempt %d: %s = %q", i+1, ReplayedHeader, resp.Header.Get(ReplayedHeader))
+		}
+		var sub pkg.SubscriptionDTO
+		if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		ids = append(ids, sub.ID)
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("replayed id %d, want %d", ids[1], ids[0])
+	}
+
+	// Without the key the same create conflicts as before.
+	if resp := call(t, ts, http.MethodPost, "/subscriptions", testutil.AcmeKey, testutil.CreateSubRequest("Netflix"), nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("create without key: status %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+}
+
+func TestIdempotencyKeyReusedForDifferentRequest(t *testing.T) {
+	ts, _ := newTestServer(t, WithIdempotency(idempotency.NewMemoryStore()))
+	key := http.Header{IdempotencyKeyHeader: {"shared"}}
+
+	if resp := call(t, ts, http.MethodPost, "/subscriptions", testutil.AcmeKey, testutil.CreateSubRequest("Netflix"), key); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("first: status %d", resp.StatusCode)
+	}
+	if resp := call(t, ts, http.MethodPost, "/subscriptions", testutil.AcmeKey, testutil.CreateSubRequest("Spotify"), key); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("other body: status %d, want %d", resp.StatusCode, http.StatusUnprocessableEntity)
+	}
+	// Keys are per tenant, so globex may use the same one.
+	if resp := call(t, ts, http.MethodPost, "/subscriptions", testutil.GlobexKey, testutil.CreateSubRequest("Spotify"), key); resp.StatusCode != http.StatusCreated {
+		t.Errorf("other tenant: status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+}
+
+func TestIdempotencyKeyInUse(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	ts, _ := newTestServer(t, WithIdempotency(store))
+
+	// A claim without a response is a first request that is still running.
+	body, err := json.Marshal(testutil.CreateSubRequest("Netflix"))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	ctx := entities.WithTenant(context.Background(), "acme")
+	if _, err := store.Claim(ctx, "running", idempotency.Fingerprint(http.MethodPost, "/subscriptions", body), time.Now()); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+
+	resp := call(t, ts, http.MethodPost, "/subscriptions", testutil.AcmeKey, testutil.CreateSubRequest("Netflix"), http.Header{IdempotencyKeyHeader: {"running"}})
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("status %d, Retry-After %q; want %d with Retry-After", resp.StatusCode, resp.Header.Get("Retry-After"), http.StatusConflict)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	ts, _ := newTestServer(t, WithIdempotency(idempotency.NewMemoryStore()), WithMount("/flaky", flaky))
+	key := http.Header{IdempotencyKeyHeader: {"flaky"}}
+
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusCreated, http.StatusCreated} {
+		if resp := call(t, ts, http.MethodPost, "/flaky", testutil.AcmeKey, nil, key); resp.StatusCode != want {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("handler ran %d times, want 2: the 503 is retried, the 201 replayed", got)
+	}
+}
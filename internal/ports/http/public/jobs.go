@@ -0,0 +1,102 @@
+package public
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// maxJobRunsLimit caps the page size of the job run history.
+const maxJobRunsLimit = 500
+
+// defaultJobRunsLimit is the page size of the job run history without a limit.
+const defaultJobRunsLimit = 50
+
+// @Summary List background jobs
+// @Description Registered jobs with their schedule, next run time and latest run on any replica
+// @Tags jobs
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Success 200 {object} pkg.GetJobsResponse
+// @Failure 403 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /admin/jobs [get]
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	statuses, err := s.jobs.Jobs(r.Context())
+	if err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toJobsResponse(statuses))
+}
+
+// @Summary Job run history
+// @Description Latest runs of the job, newest first
+// @Tags jobs
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param name path string true "Job name"
+// @Param limit query int false "Max runs, 50 by default"
+// @Success 200 {object} pkg.GetJobRunsResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 403 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /admin/jobs/{name}/runs [get]
+func (s *Server) handleListJobRuns(w http.ResponseWriter, r *http.Request) {
+	limit := defaultJobRunsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxJobRunsLimit {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "limit must be between 1 and " + strconv.Itoa(maxJobRunsLimit)})
+			return
+		}
+	}
+
+	runs, err := s.jobs.Runs(r.Context(), chi.URLParam(r, "name"), limit)
+	if err != nil {
+		if errors.Is(err, entities.ErrJobNotFound) {
+			s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toJobRunsResponse(runs))
+}
+
+// @Summary Run a job now
+// @Description Starts a run of the job in the background outside its schedule. The run is recorded in the job history with the manual trigger.
+// @Tags jobs
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param name path string true "Job name"
+// @Success 202 {object} pkg.JobRunDTO
+// @Failure 403 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /admin/jobs/{name}/run [post]
+func (s *Server) handleTriggerJob(w http.ResponseWriter, r *http.Request) {
+	run, err := s.jobs.Trigger(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrJobNotFound):
+			s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, entities.ErrJobRunning):
+			s.respondWithError(w, http.StatusConflict, pkg.ErrorResponse{Error: err.Error()})
+		default:
+			s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	s.respondWithJSON(w, http.StatusAccepted, toJobRunDTO(run))
+}
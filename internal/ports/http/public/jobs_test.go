@@ -0,0 +1,85 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/jobs"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+// stubJobs knows one job, "purge", which is running.
+type stubJobs struct{}
+
+func (stubJobs) Jobs(ctx context.Context) ([]jobs.Status, error) {
+	return []jobs.Status{{Name: "purge", Schedule: "@daily", NextRun: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)}}, nil
+}
+
+func (stubJobs) Runs(ctx context.Context, name string, limit int) ([]jobs.Run, error) {
+	if name != "purge" {
+		return nil, errors.Wrapf(en.ErrJobNotFound, "job %s", name)
+	}
+	return []jobs.Run{{ID: 1, Job: name, Trigger: jobs.TriggerSchedule, Status: jobs.StatusRunning}}, nil
+}
+
+func (stubJobs) Trigger(ctx context.Context, name string) (jobs.Run, error) {
+	if name != "purge" {
+		return jobs.Run{}, errors.Wrapf(en.ErrJobNotFound, "job %s", name)
+	}
+	return jobs.Run{}, errors.Wrapf(en.ErrJobRunning, "job %s", name)
+}
+
+func TestJobRoutesAreRateLimitedBeforeTheAdminCheck(t *testing.T) {
+	ts, _ := newTestServer(t, WithAdminToken(testutil.AdminToken), WithJobs(stubJobs{}), withTwoRequests(t))
+
+	for i, want := range []int{http.StatusForbidden, http.StatusForbidden, http.StatusTooManyRequests} {
+		resp := call(t, ts, http.MethodPost, "/admin/jobs/purge/run", "", nil, http.Header{AdminTokenHeader: {"guess"}})
+		if resp.StatusCode != want {
+			t.Fatalf("request %d with a wrong token: status %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestJobRoutes(t *testing.T) {
+	ts, _ := newTestServer(t, WithAdminToken(testutil.AdminToken), WithJobs(stubJobs{}))
+	admin := http.Header{AdminTokenHeader: {testutil.AdminToken}}
+
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/admin/jobs", http.StatusOK},
+		{http.MethodGet, "/admin/jobs/purge/runs", http.StatusOK},
+		{http.MethodGet, "/admin/jobs/purge/runs?limit=0", http.StatusBadRequest},
+		{http.MethodGet, "/admin/jobs/unknown/runs", http.StatusNotFound},
+		{http.MethodPost, "/admin/jobs/purge/run", http.StatusConflict},
+		{http.MethodPost, "/admin/jobs/unknown/run", http.StatusNotFound},
+	} {
+		if resp := call(t, ts, tt.method, tt.path, "", nil, admin); resp.StatusCode != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+	if resp := call(t, ts, http.MethodGet, "/admin/jobs", "", nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET /admin/jobs without the token: status %d, want 403", resp.StatusCode)
+	}
+
+	resp := call(t, ts, http.MethodGet, "/admin/jobs", "", nil, admin)
+	var body struct {
+		Jobs []struct {
+			Name     string `json:"name"`
+			Schedule string `json:"schedule"`
+		} `json:"jobs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Jobs) != 1 || body.Jobs[0].Name != "purge" || body.Jobs[0].Schedule != "@daily" {
+		t.Errorf("jobs = %+v, want purge on @daily", body.Jobs)
+	}
+}
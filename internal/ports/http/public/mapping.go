@@ -0,0 +1,259 @@
+package public
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/jobs"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+func toSubscriptionDTO(sub entities.Subscription) pkg.SubscriptionDTO {
+	dto := pkg.SubscriptionDTO{
+		ID:            sub.ID,
+		UserId:        sub.UserID,
+		ServiceName:   sub.ServiceName,
+		Price:         sub.Price,
+		StartDate:     sub.StartDate,
+		EndDate:       sub.EndDate,
+		AutoRenew:     sub.AutoRenew,
+		BilledThrough: sub.BilledThrough,
+	}
+	if sub.DeletedAt != nil {
+		dto.DeletedAt = sub.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	if sub.ExpiredAt != nil {
+		dto.ExpiredAt = sub.ExpiredAt.UTC().Format(time.RFC3339)
+	}
+	return dto
+}
+
+func toChargesResponse(charges []entities.Charge) pkg.GetChargesResponse {
+	list := make([]pkg.ChargeDTO, 0, len(charges))
+	for _, c := range charges {
+		list = append(list, toChargeDTO(c))
+	}
+	return pkg.GetChargesResponse{Charges: list}
+}
+
+func toChargeDTO(c entities.Charge) pkg.ChargeDTO {
+	dto := pkg.ChargeDTO{
+		ID:             c.ID,
+		SubscriptionID: c.SubscriptionID,
+		ServiceName:    c.ServiceName,
+		Period:         c.Period,
+		Amount:         c.Amount,
+		Currency:       c.Currency,
+		Status:         string(c.Status),
+		PaidAmount:     c.PaidAmount,
+	}
+	if c.PaidOn != nil {
+		dto.PaidOn = c.PaidOn.Format(time.DateOnly)
+	}
+	return dto
+}
+
+func toBudgetDTO(b entities.Budget) pkg.BudgetDTO {
+	services := b.Services
+	if services == nil {
+		services = []string{}
+	}
+	return pkg.BudgetDTO{
+		ID:        b.ID,
+		UserId:    b.UserID,
+		Name:      b.Name,
+		Limit:     b.Limit,
+		Currency:  b.Currency,
+		Services:  services,
+		CreatedAt: b.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: b.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func toBudgetStatusResponse(period string, statuses []entities.BudgetStatus) pkg.GetBudgetStatusResponse {
+	list := make([]pkg.BudgetStatusDTO, 0, len(statuses))
+	for _, s := range statuses {
+		list = append(list, pkg.BudgetStatusDTO{
+			Budget:    toBudgetDTO(s.Budget),
+			Spent:     s.Spent,
+			Projected: s.Projected,
+			Remaining: s.Remaining(),
+			Exceeded:  s.Exceeded(),
+		})
+	}
+	return pkg.GetBudgetStatusResponse{Period: period, Budgets: list}
+}
+
+func toAuditResponse(entries []entities.AuditEntry) pkg.GetAuditResponse {
+	list := make([]pkg.AuditEntryDTO, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, pkg.AuditEntryDTO{
+			ID:             e.ID,
+			SubscriptionID: e.SubscriptionID,
+			UserId:         e.UserID,
+			Action:         string(e.Action),
+			Principal:      e.Principal,
+			Actor:          e.Actor,
+			RequestID:      e.RequestID,
+			Before:         e.Before,
+			After:          e.After,
+			CreatedAt:      e.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return pkg.GetAuditResponse{Entries: list}
+}
+
+func parseAuditFilter(q url.Values) (entities.AuditFilter, error) {
+	filter := entities.AuditFilter{
+		UserID:    q.Get("user_id"),
+		Principal: q.Get("principal"),
+		Actor:     q.Get("actor"),
+		Action:    entities.AuditAction(q.Get("action")),
+	}
+	switch filter.Action {
+	case "", entities.AuditActionCreate, entities.AuditActionUpdate, entities.AuditActionDelete, entities.AuditActionRestore,
+		entities.AuditActionRenew, entities.AuditActionExpire:
+	default:
+		return entities.AuditFilter{}, errors.Errorf("unknown action %q", filter.Action)
+	}
+
+	var err error
+	if v := q.Get("subscription_id"); v != "" {
+		if filter.SubscriptionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return entities.AuditFilter{}, errors.Wrap(err, "subscription_id")
+		}
+	}
+	if v := q.Get("before_id"); v != "" {
+		if filter.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return entities.AuditFilter{}, errors.Wrap(err, "before_id")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return entities.AuditFilter{}, errors.Wrap(err, "limit")
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return entities.AuditFilter{}, errors.Wrap(err, "from")
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return entities.AuditFilter{}, errors.Wrap(err, "to")
+		}
+	}
+	return filter, nil
+}
+
+func toWebhookDTO(webhook entities.Webhook) pkg.WebhookDTO {
+	events := make([]string, 0, len(webhook.Events))
+	for _, e := range webhook.Events {
+		events = append(events, string(e))
+	}
+	return pkg.WebhookDTO{
+		ID:        webhook.ID,
+		UserId:    webhook.UserID,
+		URL:       webhook.URL,
+		Events:    events,
+		Secret:    webhook.Secret,
+		CreatedAt: webhook.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func toDeliveriesResponse(deliveries []entities.WebhookDelivery) pkg.GetWebhookDeliveriesResponse {
+	list := make([]pkg.WebhookDeliveryDTO, 0, len(deliveries))
+	for _, d := range deliveries {
+		dto := pkg.WebhookDeliveryDTO{
+			ID:             d.ID,
+			EventID:        d.EventID,
+			EventType:      string(d.EventType),
+			Status:         string(d.Status),
+			Attempts:       d.Attempts,
+			ResponseStatus: d.ResponseStatus,
+			LastError:      d.LastError,
+			CreatedAt:      d.CreatedAt.UTC().Format(time.RFC3339),
+			Payload:        d.Payload,
+		}
+		if d.Status == entities.DeliveryPending {
+			dto.NextAttemptAt = d.NextAttemptAt.UTC().Format(time.RFC3339)
+		}
+		if d.LastAttemptAt != nil {
+			dto.LastAttemptAt = d.LastAttemptAt.UTC().Format(time.RFC3339)
+		}
+		list = append(list, dto)
+	}
+	return pkg.GetWebhookDeliveriesResponse{Deliveries: list}
+}
+
+func toFeedEventDTO(event entities.FeedEvent) pkg.FeedEventDTO {
+	return pkg.FeedEventDTO{
+		ID:           event.ID,
+		Type:         string(event.Type),
+		OccurredAt:   event.OccurredAt.UTC().Format(time.RFC3339),
+		Subscription: toSubscriptionDTO(event.Subscription),
+	}
+}
+
+func toNotificationSettingsDTO(settings entities.NotificationSettings) pkg.NotificationSettingsDTO {
+	return pkg.NotificationSettingsDTO{
+		UserId:    settings.UserID,
+		Email:     settings.Email,
+		Locale:    settings.Locale,
+		LeadDays:  settings.LeadDays,
+		Enabled:   settings.Enabled,
+		UpdatedAt: settings.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func toTelegramLinkCodeDTO(code entities.ChatLinkCode) pkg.TelegramLinkCodeDTO {
+	return pkg.TelegramLinkCodeDTO{
+		Code:      code.Code,
+		ExpiresAt: code.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func toJobRunDTO(run jobs.Run) pkg.JobRunDTO {
+	dto := pkg.JobRunDTO{
+		ID:          run.ID,
+		Job:         run.Job,
+		Trigger:     run.Trigger,
+		Status:      run.Status,
+		Error:       run.Error,
+		Instance:    run.Instance,
+		ScheduledAt: run.ScheduledAt.UTC().Format(time.RFC3339),
+		StartedAt:   run.StartedAt.UTC().Format(time.RFC3339),
+	}
+	if run.FinishedAt != nil {
+		dto.FinishedAt = run.FinishedAt.UTC().Format(time.RFC3339)
+	}
+	return dto
+}
+
+func toJobsResponse(statuses []jobs.Status) pkg.GetJobsResponse {
+	list := make([]pkg.JobDTO, 0, len(statuses))
+	for _, st := range statuses {
+		dto := pkg.JobDTO{Name: st.Name, Schedule: st.Schedule}
+		if !st.NextRun.IsZero() {
+			dto.NextRunAt = st.NextRun.UTC().Format(time.RFC3339)
+		}
+		if st.LastRun != nil {
+			run := toJobRunDTO(*st.LastRun)
+			dto.LastRun = &run
+		}
+		list = append(list, dto)
+	}
+	return pkg.GetJobsResponse{Jobs: list}
+}
+
+func toJobRunsResponse(runs []jobs.Run) pkg.GetJobRunsResponse {
+	list := make([]pkg.JobRunDTO, 0, len(runs))
+	for _, run := range runs {
+		list = append(list, toJobRunDTO(run))
+	}
+	return pkg.GetJobRunsResponse{Runs: list}
+}
@@ -0,0 +1,227 @@
+package public
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/100bench/subscription_aggregator/internal/auth"
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+var tracer = otel.Tracer("github.com/100bench/subscription_aggregator/internal/ports/http/public")
+
+// TenantHeader optionally names the tenant the caller expects to act on; a
+// request whose API key belongs to another tenant is rejected.
+const TenantHeader = "X-Tenant-ID"
+
+// authMiddleware binds requests carrying a valid "Authorization: Bearer"
+// API key to the key's tenant and principal. It does not reject anything, so
+// that the rate limit applies to unauthenticated requests by client IP
+// before tenantMiddleware turns them away.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := s.apiKeys.Authenticate(auth.BearerToken(r.Header.Get("Authorization")))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := entities.WithTenant(r.Context(), principal.TenantID)
+		ctx = entities.WithPrincipal(ctx, principal.String())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// tenantMiddleware rejects requests that authMiddleware did not bind to a
+// tenant, and those naming a different tenant in TenantHeader.
+func (s *Server) tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, err := entities.TenantFromContext(r.Context())
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="subscription-aggregator"`)
+			s.respondWithError(w, http.StatusUnauthorized, pkg.ErrorResponse{Error: entities.ErrUnauthenticated.Error()})
+			return
+		}
+		if requested := r.Header.Get(TenantHeader); requested != "" && requested != tenantID {
+			s.respondWithError(w, http.StatusForbidden, pkg.ErrorResponse{Error: entities.ErrTenantMismatch.Error()})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ActorHeader optionally names the person acting through the API key, e.g. a
+// support agent. It is recorded in the audit log as unverified metadata next
+// to the authenticated principal.
+const ActorHeader = "X-Actor-ID"
+
+// AdminTokenHeader must carry the configured admin token to reach admin routes.
+const AdminTokenHeader = "X-Admin-Token"
+
+// requestMetaMiddleware copies the claimed actor into the context in the
+// form the use cases understand. It never stands in for the principal.
+func (s *Server) requestMetaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(entities.WithActor(r.Context(), r.Header.Get(ActorHeader))))
+	})
+}
+
+// accessLogMiddleware propagates chi's request ID to the logger and the use
+// cases, and logs every request once it is served.
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := entities.WithRequestID(r.Context(), middleware.GetReqID(r.Context()))
+		r = r.WithContext(ctx)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		s.logger.InfoContext(ctx, "http request",
+			"method", r.Method,
+			"route", chi.RouteContext(ctx).RoutePattern(),
+			"status", ww.Status(),
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// adminMiddleware only lets through requests presenting the configured admin token.
+func (s *Server) adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAdmin(r) {
+			s.respondWithError(w, http.StatusForbidden, pkg.ErrorResponse{Error: "admin access required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) isAdmin(r *http.Request) bool {
+	token := r.Header.Get(AdminTokenHeader)
+	return s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// tracingMiddleware starts a server span continuing the caller's W3C trace
+// context. The span is renamed after the chi route once it is resolved.
+func (s *Server) tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if route := chi.RouteContext(r.Context()).RoutePattern(); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// metricsMiddleware reads the route pattern after the request is served, when chi has resolved it.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.metrics.ObserveHTTP(r.Method, chi.RouteContext(r.Context()).RoutePattern(), status, time.Since(start))
+	})
+}
+
+// rateLimitMiddleware must run inside a route group so that the chi route
+// pattern is already resolved when it picks the limit.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		pattern := chi.RouteContext(r.Context()).RoutePattern()
+		limit := s.limiter.LimitFor(r.Method, pattern)
+		key := r.Method + " " + pattern + "|" + rateLimitPrincipal(r)
+
+		res, err := s.limiter.Allow(r.Context(), key, limit)
+		if err != nil {
+			// Failing open: an unavailable bucket store must not take the API down.
+			s.logger.ErrorContext(r.Context(), "rate limiter unavailable", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if limit.Unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			retryAfter := ceilSeconds(res.RetryAfter)
+			h.Set("Retry-After", strconv.Itoa(retryAfter))
+			s.respondWithProblem(w, pkg.ProblemResponse{
+				Type:   "about:blank",
+				Title:  http.StatusText(http.StatusTooManyRequests),
+				Status: http.StatusTooManyRequests,
+				Detail: fmt.Sprintf("rate limit exceeded, retry in %d seconds", retryAfter),
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitPrincipal identifies the caller: the principal of its API key when
+// authenticated, the client IP otherwise. Headers the caller sets freely,
+// such as the actor, are never part of the key, or a caller could get a fresh
+// bucket per request.
+func rateLimitPrincipal(r *http.Request) string {
+	if principal := entities.PrincipalFromContext(r.Context()); principal != "" {
+		return "key:" + principal
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
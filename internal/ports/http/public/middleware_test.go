@@ -0,0 +1,113 @@
+package public
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// newTestServer serves the real router over a testutil.Storage with the API
+// keys of the tenants acme and globex.
+func newTestServer(t *testing.T, opts ...Option) (*httptest.Server, *testutil.Storage) {
+	t.Helper()
+	storage := testutil.NewStorage()
+	return serve(t, storage, opts...), storage
+}
+
+// serve serves the real router over storage.
+func serve(t *testing.T, storage cases.SubRepository, opts ...Option) *httptest.Server {
+	t.Helper()
+	server, err := NewServer(testutil.NewService(t, storage),
+		append([]Option{WithLogger(testutil.Logger()), WithAPIKeys(testutil.APIKeys(t))}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func call(t *testing.T, ts *httptest.Server, method, path, key string, body interface{}, header http.Header) *http.Response {
+	t.Helper()
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		payload = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, payload)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func createSub(t *testing.T, ts *httptest.Server, key, service string) pkg.SubscriptionDTO {
+	t.Helper()
+	resp := call(t, ts, http.MethodPost, "/subscriptions", key, testutil.CreateSubRequest(service), nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create %s: status %d", service, resp.StatusCode)
+	}
+	var sub pkg.SubscriptionDTO
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return sub
+}
+
+func TestTenantRoutesRequireAPIKey(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	tests := map[string]struct {
+		key    string
+		header http.Header
+		want   int
+	}{
+		"no key":             {want: http.StatusUnauthorized},
+		"unknown key":        {key: "not-a-configured-key-0123456789", want: http.StatusUnauthorized},
+		"tenant header only": {header: http.Header{TenantHeader: {"acme"}}, want: http.StatusUnauthorized},
+		"other tenant":       {key: testutil.GlobexKey, header: http.Header{TenantHeader: {"acme"}}, want: http.StatusForbidden},
+		"own tenant":         {key: testutil.AcmeKey, header: http.Header{TenantHeader: {"acme"}}, want: http.StatusOK},
+		"key only":           {key: testutil.AcmeKey, want: http.StatusOK},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := call(t, ts, http.MethodGet, "/subscriptions/"+testutil.UserID, tt.key, nil, tt.header)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestWithoutAPIKeysTenantRoutesFailClosed(t *testing.T) {
+	ts, _ := newTestServer(t, WithAPIKeys(nil))
+
+	resp := call(t, ts, http.MethodGet, "/subscriptions/"+testutil.UserID, testutil.AcmeKey, nil, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,79 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// @Summary Get notification settings
+// @Description Returns where and when the user is reminded of renewals
+// @Tags notifications
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param userID path string true "User ID"
+// @Success 200 {object} pkg.NotificationSettingsDTO
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/notification-settings [get]
+func (s *Server) handleGetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.notify.GetSettings(r.Context(), chi.URLParam(r, "userID"))
+	if err != nil {
+		if errors.Is(err, entities.ErrNotificationSettingsNotFound) {
+			s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toNotificationSettingsDTO(settings))
+}
+
+// @Summary Update notification settings
+// @Description Sets the email, locale (en, ru) and lead time in days of the user's renewal reminders. A reminder is emailed lead_days before a subscription renews, that is before the first day after its end month.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param userID path string true "User ID"
+// @Param settings body pkg.UpdateNotificationSettingsRequest true "Settings"
+// @Success 200 {object} pkg.NotificationSettingsDTO
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/notification-settings [put]
+func (s *Server) handleUpdateNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	var req pkg.UpdateNotificationSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	settings := entities.NotificationSettings{
+		UserID:   chi.URLParam(r, "userID"),
+		Email:    req.Email,
+		Locale:   req.Locale,
+		LeadDays: req.LeadDays,
+		Enabled:  req.Enabled == nil || *req.Enabled,
+	}
+
+	updated, err := s.notify.UpdateSettings(r.Context(), settings)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidNotificationSettings) {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toNotificationSettingsDTO(updated))
+}
@@ -4,13 +4,59 @@ import (
 	"context"
 
 	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/jobs"
 )
 
 type PublicService interface {
-	CreateSubscription(ctx context.Context, subscription en.Subscription) error
+	CreateSubscription(ctx context.Context, subscription en.Subscription) (en.Subscription, error)
 	GetSubscription(ctx context.Context, userID string, serviceName string) (en.Subscription, error)
-	UpdateSubscription(ctx context.Context, userID string, serviceName string, price *int, startDate *string, endDate *string) error
+	UpdateSubscription(ctx context.Context, userID string, serviceName string, price *int, startDate *string, endDate *string, autoRenew *bool) error
 	DeleteSubscription(ctx context.Context, userID string, serviceName string) error
-	GetListSubscriptions(ctx context.Context, userID string) ([]en.Subscription, error)
-	GetTotalCostByPeriod(ctx context.Context, userID string, serviceName string, startDate string, endDate string) (int, error)
+	RestoreSubscription(ctx context.Context, id int64) (en.Subscription, error)
+	GetListSubscriptions(ctx context.Context, userID string, includeDeleted bool) ([]en.Subscription, error)
+	GetTotalCostByPeriod(ctx context.Context, userID string, serviceName string, startDate string, endDate string, mode en.CostMode) (int, error)
+	GetCostBreakdown(ctx context.Context, userID string, startDate string, endDate string) ([]en.ServiceCost, error)
+	ListCharges(ctx context.Context, userID string, serviceName string, startDate string, endDate string) ([]en.Charge, error)
+	MarkCharge(ctx context.Context, subscriptionID int64, period string, mark en.ChargeMark) (en.Charge, error)
+	CreateBudget(ctx context.Context, budget en.Budget) (en.Budget, error)
+	ListBudgets(ctx context.Context, userID string) ([]en.Budget, error)
+	UpdateBudget(ctx context.Context, budget en.Budget) (en.Budget, error)
+	DeleteBudget(ctx context.Context, userID string, id int64) error
+	GetBudgetStatus(ctx context.Context, userID string, period string) ([]en.BudgetStatus, error)
+	GetSubscriptionAudit(ctx context.Context, subscriptionID int64) ([]en.AuditEntry, error)
+	ListAudit(ctx context.Context, filter en.AuditFilter) ([]en.AuditEntry, error)
+}
+
+// ReadinessCheck reports whether a dependency is able to serve traffic.
+type ReadinessCheck func(ctx context.Context) error
+
+// WebhookService manages webhook endpoints of the tenant.
+type WebhookService interface {
+	RegisterWebhook(ctx context.Context, webhook en.Webhook) (en.Webhook, error)
+	ListWebhooks(ctx context.Context, userID string) ([]en.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]en.WebhookDelivery, error)
+}
+
+type FeedService interface {
+	Events(ctx context.Context, userID string, afterID int64, limit int) ([]en.FeedEvent, error)
+	LastEventID(ctx context.Context, userID string) (int64, error)
+	Watch(ctx context.Context, userID string) (<-chan struct{}, func(), error)
+}
+
+type NotificationService interface {
+	GetSettings(ctx context.Context, userID string) (en.NotificationSettings, error)
+	UpdateSettings(ctx context.Context, settings en.NotificationSettings) (en.NotificationSettings, error)
+}
+
+// ChatLinkService issues one-time codes that link a Telegram chat to a user.
+type ChatLinkService interface {
+	CreateLinkCode(ctx context.Context, userID string) (en.ChatLinkCode, error)
+}
+
+// JobService lists and triggers the background jobs.
+type JobService interface {
+	Jobs(ctx context.Context) ([]jobs.Status, error)
+	Runs(ctx context.Context, name string, limit int) ([]jobs.Run, error)
+	Trigger(ctx context.Context, name string) (jobs.Run, error)
 }
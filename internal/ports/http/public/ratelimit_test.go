@@ -0,0 +1,65 @@
+package public
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/100bench/subscription_aggregator/internal/ratelimit"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+// withTwoRequests limits every bucket to two requests; the refill is too slow
+// to matter within a test.
+func withTwoRequests(t *testing.T) Option {
+	t.Helper()
+	limiter, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.001, Burst: 2}, nil)
+	if err != nil {
+		t.Fatalf("NewLimiter: %v", err)
+	}
+	return WithRateLimiter(limiter)
+}
+
+func TestRateLimitIgnoresActorHeader(t *testing.T) {
+	tests := map[string]struct {
+		key  string
+		want int
+	}{
+		"authenticated":   {key: testutil.AcmeKey, want: http.StatusOK},
+		"unauthenticated": {want: http.StatusUnauthorized},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ts, _ := newTestServer(t, withTwoRequests(t))
+
+			for i := 0; i < 3; i++ {
+				resp := call(t, ts, http.MethodGet, "/subscriptions/"+testutil.UserID, tt.key, nil,
+					http.Header{ActorHeader: {uuid.NewString()}})
+				want := tt.want
+				if i == 2 {
+					want = http.StatusTooManyRequests
+				}
+				if resp.StatusCode != want {
+					t.Fatalf("request %d with a fresh actor: status %d, want %d", i+1, resp.StatusCode, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitBucketPerAPIKey(t *testing.T) {
+	ts, _ := newTestServer(t, withTwoRequests(t))
+
+	for i := 0; i < 3; i++ {
+		call(t, ts, http.MethodGet, "/subscriptions/"+testutil.UserID, testutil.AcmeKey, nil, nil)
+	}
+	if resp := call(t, ts, http.MethodGet, "/subscriptions/"+testutil.UserID, testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("acme: status %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	// Every test client shares the loopback address, so a bucket per IP
+	// would have throttled globex as well.
+	if resp := call(t, ts, http.MethodGet, "/subscriptions/"+testutil.UserID, testutil.GlobexKey, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("globex: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -2,30 +2,181 @@ package public
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/100bench/subscription_aggregator/internal/auth"
 	"github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/idempotency"
+	"github.com/100bench/subscription_aggregator/internal/metrics"
+	"github.com/100bench/subscription_aggregator/internal/ratelimit"
 	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
 	"github.com/pkg/errors"
 )
 
 type Server struct {
-	service PublicService
-	router  *chi.Mux
+	service    PublicService
+	router     *chi.Mux
+	adminToken string
+	apiKeys    *auth.Keyring
+	limiter    *ratelimit.Limiter
+	idemStore  idempotency.Store
+	logger     *slog.Logger
+	metrics    *metrics.Metrics
+	cors       *cors.Options
+	mounts     []mount
+	webhooks   WebhookService
+	feed       FeedService
+	notify     NotificationService
+	chatLinks  ChatLinkService
+	jobs       JobService
+	heartbeat  time.Duration
+	closing    chan struct{}
+	closeOnce  sync.Once
+
+	readinessChecks map[string]ReadinessCheck
+	draining        atomic.Bool
+}
+
+// Option customizes optional Server behaviour.
+type Option func(*Server)
+
+// WithLogger replaces slog.Default as the destination of access and error logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics records request durations by route pattern and status.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// WithAdminToken enables admin-only routes for callers presenting token.
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// WithAPIKeys authenticates tenant routes by the API keys of keys; without
+// it every tenant route answers 401.
+func WithAPIKeys(keys *auth.Keyring) Option {
+	return func(s *Server) {
+		s.apiKeys = keys
+	}
+}
+
+// WithRateLimiter limits requests per principal, or per client IP for anonymous callers.
+func WithRateLimiter(limiter *ratelimit.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = limiter
+	}
+}
+
+// WithIdempotency replays the stored response to POST requests repeating an
+// Idempotency-Key; without it the header is ignored.
+func WithIdempotency(store idempotency.Store) Option {
+	return func(s *Server) {
+		s.idemStore = store
+	}
+}
+
+// WithCORS answers preflight requests and sets CORS headers for the given
+// options; without it no CORS headers are sent.
+func WithCORS(opts cors.Options) Option {
+	return func(s *Server) {
+		s.cors = &opts
+	}
+}
+
+// WithWebhooks serves the /webhooks routes backed by service.
+func WithWebhooks(service WebhookService) Option {
+	return func(s *Server) {
+		s.webhooks = service
+	}
+}
+
+// WithFeed streams users' change feeds at /users/{userID}/events, sending a
+// keep-alive comment on streams idle for heartbeat, 15s if not positive.
+func WithFeed(service FeedService, heartbeat time.Duration) Option {
+	return func(s *Server) {
+		if heartbeat <= 0 {
+			heartbeat = 15 * time.Second
+		}
+		s.feed = service
+		s.heartbeat = heartbeat
+	}
+}
+
+// WithNotifications serves the users' notification settings backed by service.
+func WithNotifications(service NotificationService) Option {
+	return func(s *Server) {
+		s.notify = service
+	}
 }
 
-func NewServer(service PublicService) (*Server, error) {
+// WithChatLinks issues codes linking Telegram chats to users.
+func WithChatLinks(service ChatLinkService) Option {
+	return func(s *Server) {
+		s.chatLinks = service
+	}
+}
+
+// WithJobs serves the /admin/jobs routes backed by service.
+func WithJobs(service JobService) Option {
+	return func(s *Server) {
+		s.jobs = service
+	}
+}
+
+type mount struct {
+	pattern string
+	handler http.Handler
+}
+
+// WithMount serves handler at pattern behind the same tenant, request
+// metadata and rate limit middleware as the REST routes.
+func WithMount(pattern string, handler http.Handler) Option {
+	return func(s *Server) {
+		s.mounts = append(s.mounts, mount{pattern: pattern, handler: handler})
+	}
+}
+
+func NewServer(service PublicService, opts ...Option) (*Server, error) {
 	if service == nil {
 		return nil, errors.Wrap(entities.ErrNilDependency, "public server service")
 	}
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
 	s := &Server{
-		service: service,
-		router:  r,
+		service:         service,
+		router:          r,
+		logger:          slog.Default(),
+		readinessChecks: make(map[string]ReadinessCheck),
+		closing:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	r.Use(middleware.RequestID)
+	r.Use(s.tracingMiddleware)
+	r.Use(s.accessLogMiddleware)
+	if s.metrics != nil {
+		r.Use(s.metricsMiddleware)
+	}
+	r.Use(middleware.Recoverer)
+	if s.cors != nil {
+		r.Use(cors.Handler(*s.cors))
 	}
 	s.setupRoutes()
 	return s, nil
@@ -36,22 +187,88 @@ func (s *Server) GetRouter() *chi.Mux {
 }
 
 func (s *Server) setupRoutes() {
-	s.router.Post("/subscriptions", s.handleCreateSubscription)
-	s.router.Get("/subscriptions/{userID}/{serviceName}", s.handleGetSubscription)
-	s.router.Get("/subscriptions/{userID}", s.handleGetAllSubscriptions)
-	s.router.Put("/subscriptions/{userID}/{serviceName}", s.handleUpdateSubscription)
-	s.router.Delete("/subscriptions/{userID}/{serviceName}", s.handleDeleteSubscription)
-	s.router.Get("/subscriptions/total-cost", s.handleGetTotalCost)
+	s.router.Get("/healthz", s.handleHealthz)
+	s.router.Get("/readyz", s.handleReadyz)
+
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.authMiddleware)
+		r.Use(s.rateLimitMiddleware)
+		r.Use(s.tenantMiddleware)
+		r.Use(s.requestMetaMiddleware)
+		r.Use(s.idempotencyMiddleware)
+		r.Post("/subscriptions", s.handleCreateSubscription)
+		r.Get("/subscriptions/{userID}/{serviceName}", s.handleGetSubscription)
+		r.Get("/subscriptions/{userID}", s.handleGetAllSubscriptions)
+		r.Put("/subscriptions/{userID}/{serviceName}", s.handleUpdateSubscription)
+		r.Delete("/subscriptions/{userID}/{serviceName}", s.handleDeleteSubscription)
+		r.Get("/subscriptions/total-cost", s.handleGetTotalCost)
+		r.Get("/subscriptions/by-id/{id}/audit", s.handleGetSubscriptionAudit)
+		r.Post("/subscriptions/by-id/{id}/restore", s.handleRestoreSubscription)
+		r.Get("/users/{userID}/charges", s.handleListCharges)
+		r.Post("/subscriptions/by-id/{id}/charges/{period}/pay", s.handlePayCharge)
+		r.Post("/subscriptions/by-id/{id}/charges/{period}/refund", s.handleMarkCharge(entities.ChargeStatusRefunded))
+		r.Post("/subscriptions/by-id/{id}/charges/{period}/skip", s.handleMarkCharge(entities.ChargeStatusSkipped))
+		r.Post("/subscriptions/by-id/{id}/charges/{period}/dispute", s.handleMarkCharge(entities.ChargeStatusDisputed))
+		r.Post("/users/{userID}/budgets", s.handleCreateBudget)
+		r.Get("/users/{userID}/budgets", s.handleListBudgets)
+		r.Get("/users/{userID}/budgets/status", s.handleGetBudgetStatus)
+		r.Put("/users/{userID}/budgets/{id}", s.handleUpdateBudget)
+		r.Delete("/users/{userID}/budgets/{id}", s.handleDeleteBudget)
+		if s.webhooks != nil {
+			r.Post("/webhooks", s.handleCreateWebhook)
+			r.Get("/webhooks", s.handleListWebhooks)
+			r.Delete("/webhooks/{id}", s.handleDeleteWebhook)
+			r.Get("/webhooks/{id}/deliveries", s.handleListWebhookDeliveries)
+		}
+		if s.feed != nil {
+			r.Get("/users/{userID}/events", s.handleUserEvents)
+		}
+		if s.notify != nil {
+			r.Get("/users/{userID}/notification-settings", s.handleGetNotificationSettings)
+			r.Put("/users/{userID}/notification-settings", s.handleUpdateNotificationSettings)
+		}
+		if s.chatLinks != nil {
+			r.Post("/users/{userID}/telegram/link-code", s.handleCreateTelegramLinkCode)
+		}
+		for _, m := range s.mounts {
+			r.Handle(m.pattern, m.handler)
+		}
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.adminMiddleware)
+			r.Get("/admin/audit", s.handleListAudit)
+		})
+	})
+
+	// Jobs span all tenants, so their routes do not take a tenant. The limiter
+	// comes before the admin check, so that guessing the token is throttled.
+	if s.jobs != nil {
+		s.router.Group(func(r chi.Router) {
+			r.Use(s.requestMetaMiddleware)
+			r.Use(s.rateLimitMiddleware)
+			r.Use(s.adminMiddleware)
+			r.Get("/admin/jobs", s.handleListJobs)
+			r.Get("/admin/jobs/{name}/runs", s.handleListJobRuns)
+			r.Post("/admin/jobs/{name}/run", s.handleTriggerJob)
+		})
+	}
 }
 
 // @Summary Create a new subscription
 // @Description Creates a new subscription for a user
 // @Tags subscriptions
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param Idempotency-Key header string false "Replays the first response to repeats of the request with this key"
 // @Accept json
 // @Produce json
 // @Param subscription body pkg.CreateSubRequest true "Subscription"
 // @Success 201 {object} pkg.SubscriptionDTO
 // @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Failure 422 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
 // @Failure 500 {object} pkg.ErrorResponse
 // @Router /subscriptions [post]
 func (s *Server) handleCreateSubscription(w http.ResponseWriter, r *http.Request) {
@@ -66,24 +283,37 @@ func (s *Server) handleCreateSubscription(w http.ResponseWriter, r *http.Request
 		UserID:      req.UserId,
 		StartDate:   req.StartDate,
 		EndDate:     req.EndDate,
+		AutoRenew:   req.AutoRenew,
 	}
-	if err := s.service.CreateSubscription(r.Context(), sub); err != nil {
+	created, err := s.service.CreateSubscription(r.Context(), sub)
+	if err != nil {
+		if errors.Is(err, entities.ErrSubscriptionExists) {
+			s.respondWithError(w, http.StatusConflict, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if errors.Is(err, entities.ErrInvalidPeriod) {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
 		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	created := pkg.SubscriptionDTO(req)
-	s.respondWithJSON(w, http.StatusCreated, created)
+	s.respondWithJSON(w, http.StatusCreated, toSubscriptionDTO(created))
 }
 
 // @Summary Get a subscription
 // @Description Get subscription by user ID and service name
 // @Tags subscriptions
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
 // @Produce json
 // @Param userID path string true "User ID"
 // @Param serviceName path string true "Service Name"
 // @Success 200 {object} pkg.SubscriptionDTO
 // @Failure 404 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
 // @Failure 500 {object} pkg.ErrorResponse
 // @Router /subscriptions/{userID}/{serviceName} [get]
 func (s *Server) handleGetSubscription(w http.ResponseWriter, r *http.Request) {
@@ -100,27 +330,41 @@ func (s *Server) handleGetSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := pkg.SubscriptionDTO{
-		UserId:      sub.UserID,
-		ServiceName: sub.ServiceName,
-		Price:       sub.Price,
-		StartDate:   sub.StartDate,
-		EndDate:     sub.EndDate,
-	}
-	s.respondWithJSON(w, http.StatusOK, resp)
+	s.respondWithJSON(w, http.StatusOK, toSubscriptionDTO(sub))
 }
 
 // @Summary Get all subscriptions for a user
 // @Tags subscriptions
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
 // @Produce json
 // @Param userID path string true "User ID"
+// @Param include_deleted query bool false "Include soft-deleted subscriptions, admin only"
+// @Param X-Admin-Token header string false "Admin token, required with include_deleted"
 // @Success 200 {object} pkg.GetSubsResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 403 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
 // @Failure 500 {object} pkg.ErrorResponse
 // @Router /subscriptions/{userID} [get]
 func (s *Server) handleGetAllSubscriptions(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "userID")
 
-	subs, err := s.service.GetListSubscriptions(r.Context(), userID)
+	includeDeleted := false
+	if v := r.URL.Query().Get("include_deleted"); v != "" {
+		var err error
+		if includeDeleted, err = strconv.ParseBool(v); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid include_deleted"})
+			return
+		}
+	}
+	if includeDeleted && !s.isAdmin(r) {
+		s.respondWithError(w, http.StatusForbidden, pkg.ErrorResponse{Error: "admin access required"})
+		return
+	}
+
+	subs, err := s.service.GetListSubscriptions(r.Context(), userID, includeDeleted)
 	if err != nil {
 		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
 		return
@@ -128,13 +372,7 @@ func (s *Server) handleGetAllSubscriptions(w http.ResponseWriter, r *http.Reques
 
 	list := make([]pkg.SubscriptionDTO, 0, len(subs))
 	for _, ssub := range subs {
-		list = append(list, pkg.SubscriptionDTO{
-			UserId:      ssub.UserID,
-			ServiceName: ssub.ServiceName,
-			Price:       ssub.Price,
-			StartDate:   ssub.StartDate,
-			EndDate:     ssub.EndDate,
-		})
+		list = append(list, toSubscriptionDTO(ssub))
 	}
 	s.respondWithJSON(w, http.StatusOK, pkg.GetSubsResponse{Subscriptions: list})
 }
@@ -142,6 +380,8 @@ func (s *Server) handleGetAllSubscriptions(w http.ResponseWriter, r *http.Reques
 // @Summary Update a subscription
 // @Description Update by user ID and service name
 // @Tags subscriptions
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
 // @Accept json
 // @Produce json
 // @Param userID path string true "User ID"
@@ -150,6 +390,8 @@ func (s *Server) handleGetAllSubscriptions(w http.ResponseWriter, r *http.Reques
 // @Success 200 {object} pkg.SubscriptionDTO
 // @Failure 400 {object} pkg.ErrorResponse
 // @Failure 404 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
 // @Failure 500 {object} pkg.ErrorResponse
 // @Router /subscriptions/{userID}/{serviceName} [put]
 func (s *Server) handleUpdateSubscription(w http.ResponseWriter, r *http.Request) {
@@ -162,11 +404,15 @@ func (s *Server) handleUpdateSubscription(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := s.service.UpdateSubscription(r.Context(), userID, serviceName, req.Price, req.StartDate, req.EndDate); err != nil {
+	if err := s.service.UpdateSubscription(r.Context(), userID, serviceName, req.Price, req.StartDate, req.EndDate, req.AutoRenew); err != nil {
 		if errors.Is(err, entities.ErrSubscriptionNotFound) {
 			s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
 			return
 		}
+		if errors.Is(err, entities.ErrInvalidPeriod) {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
 		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -177,22 +423,20 @@ func (s *Server) handleUpdateSubscription(w http.ResponseWriter, r *http.Request
 		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
 		return
 	}
-	resp := pkg.SubscriptionDTO{
-		UserId:      sub.UserID,
-		ServiceName: sub.ServiceName,
-		Price:       sub.Price,
-		StartDate:   sub.StartDate,
-		EndDate:     sub.EndDate,
-	}
-	s.respondWithJSON(w, http.StatusOK, resp)
+	s.respondWithJSON(w, http.StatusOK, toSubscriptionDTO(sub))
 }
 
 // @Summary Delete a subscription
+// @Description Soft-deletes the subscription; it can be restored until the retention purge removes it
 // @Tags subscriptions
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
 // @Param userID path string true "User ID"
 // @Param serviceName path string true "Service Name"
 // @Success 204
 // @Failure 404 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
 // @Failure 500 {object} pkg.ErrorResponse
 // @Router /subscriptions/{userID}/{serviceName} [delete]
 func (s *Server) handleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
@@ -212,15 +456,21 @@ func (s *Server) handleDeleteSubscription(w http.ResponseWriter, r *http.Request
 }
 
 // @Summary Get total cost by period
-// @Description Returns total subscription cost for a user in period; service filter optional
+// @Description Sums the charges of the user for the months of the period; service filter optional.
+// @Description The expected mode sums the billed amounts except skipped months, the actual mode what was paid and not refunded.
 // @Tags subscriptions
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
 // @Produce json
 // @Param user_id query string true "User ID"
 // @Param start_date query string true "Start date MM-YYYY"
 // @Param end_date query string true "End date MM-YYYY"
 // @Param service_name query string false "Service name"
+// @Param mode query string false "Cost mode, expected by default" Enums(expected, actual)
 // @Success 200 {object} pkg.GetTotalCostResponse
 // @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
 // @Failure 500 {object} pkg.ErrorResponse
 // @Router /subscriptions/total-cost [get]
 func (s *Server) handleGetTotalCost(w http.ResponseWriter, r *http.Request) {
@@ -229,14 +479,19 @@ func (s *Server) handleGetTotalCost(w http.ResponseWriter, r *http.Request) {
 	startDate := q.Get("start_date")
 	endDate := q.Get("end_date")
 	serviceName := q.Get("service_name")
+	mode := entities.CostMode(q.Get("mode"))
 
 	if userID == "" || startDate == "" || endDate == "" {
 		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "missing required query params: user_id, start_date, end_date"})
 		return
 	}
 
-	total, err := s.service.GetTotalCostByPeriod(r.Context(), userID, serviceName, startDate, endDate)
+	total, err := s.service.GetTotalCostByPeriod(r.Context(), userID, serviceName, startDate, endDate, mode)
 	if err != nil {
+		if errors.Is(err, entities.ErrInvalidPeriod) || errors.Is(err, entities.ErrInvalidCostMode) {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
 		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -244,6 +499,111 @@ func (s *Server) handleGetTotalCost(w http.ResponseWriter, r *http.Request) {
 	s.respondWithJSON(w, http.StatusOK, pkg.GetTotalCostResponse{TotalCost: total})
 }
 
+// @Summary Restore a deleted subscription
+// @Description Undoes a soft delete of the subscription
+// @Tags subscriptions
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param Idempotency-Key header string false "Replays the first response to repeats of the request with this key"
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} pkg.SubscriptionDTO
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Failure 422 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /subscriptions/by-id/{id}/restore [post]
+func (s *Server) handleRestoreSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid subscription id"})
+		return
+	}
+
+	sub, err := s.service.RestoreSubscription(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrSubscriptionNotFound):
+			s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, entities.ErrSubscriptionExists):
+			s.respondWithError(w, http.StatusConflict, pkg.ErrorResponse{Error: err.Error()})
+		default:
+			s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toSubscriptionDTO(sub))
+}
+
+// @Summary Get audit trail of a subscription
+// @Description Returns every create/update/delete recorded for the subscription, newest first
+// @Tags audit
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} pkg.GetAuditResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /subscriptions/by-id/{id}/audit [get]
+func (s *Server) handleGetSubscriptionAudit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid subscription id"})
+		return
+	}
+
+	entries, err := s.service.GetSubscriptionAudit(r.Context(), id)
+	if err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toAuditResponse(entries))
+}
+
+// @Summary Query the audit log
+// @Description Admin-wide audit query across all users of the tenant, newest first
+// @Tags audit
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param X-Admin-Token header string true "Admin token"
+// @Param subscription_id query int false "Subscription ID"
+// @Param user_id query string false "User ID"
+// @Param principal query string false "Authenticated principal, e.g. acme/billing"
+// @Param actor query string false "Actor claimed in X-Actor-ID"
+// @Param action query string false "Action" Enums(create, update, delete, restore, renew, expire)
+// @Param from query string false "From, RFC3339"
+// @Param to query string false "To, RFC3339"
+// @Param before_id query int false "Only entries older than this ID, the last ID of the previous page"
+// @Param limit query int false "Max entries, 100 by default"
+// @Success 200 {object} pkg.GetAuditResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 403 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /admin/audit [get]
+func (s *Server) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	entries, err := s.service.ListAudit(r.Context(), filter)
+	if err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toAuditResponse(entries))
+}
+
 func (s *Server) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
@@ -255,6 +615,18 @@ func (s *Server) respondWithJSON(w http.ResponseWriter, code int, payload interf
 	w.Write(response)
 }
 
+// respondWithProblem writes an RFC 7807 problem document.
+func (s *Server) respondWithProblem(w http.ResponseWriter, problem pkg.ProblemResponse) {
+	response, err := json.Marshal(problem)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	w.Write(response)
+}
+
 func (s *Server) respondWithError(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
@@ -0,0 +1,114 @@
+package public
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+func TestDeletedSubscriptionsAreListedOnlyToAdmins(t *testing.T) {
+	ts, _ := newTestServer(t, WithAdminToken(testutil.AdminToken))
+	createSub(t, ts, testutil.AcmeKey, "Netflix")
+	createSub(t, ts, testutil.AcmeKey, "Spotify")
+	if resp := call(t, ts, http.MethodDelete, "/subscriptions/"+testutil.UserID+"/Spotify", testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	admin := http.Header{AdminTokenHeader: {testutil.AdminToken}}
+
+	tests := map[string]struct {
+		query       string
+		header      http.Header
+		status      int
+		live, total int
+	}{
+		"default":             {"", nil, http.StatusOK, 1, 1},
+		"admin without flag":  {"", admin, http.StatusOK, 1, 1},
+		"admin with flag":     {"?include_deleted=true", admin, http.StatusOK, 1, 2},
+		"flag without token":  {"?include_deleted=true", nil, http.StatusForbidden, 0, 0},
+		"flag with bad token": {"?include_deleted=true", http.Header{AdminTokenHeader: {"wrong"}}, http.StatusForbidden, 0, 0},
+		"flag set to false":   {"?include_deleted=false", nil, http.StatusOK, 1, 1},
+		"flag is not a bool":  {"?include_deleted=yes-please", admin, http.StatusBadRequest, 0, 0},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := call(t, ts, http.MethodGet, "/subscriptions/"+testutil.UserID+tt.query, testutil.AcmeKey, nil, tt.header)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var list pkg.GetSubsResponse
+			if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			live := 0
+			for _, sub := range list.Subscriptions {
+				if sub.DeletedAt == "" {
+					live++
+				} else if sub.ServiceName != "Spotify" {
+					t.Errorf("%s is listed as deleted", sub.ServiceName)
+				}
+			}
+			if live != tt.live || len(list.Subscriptions) != tt.total {
+				t.Errorf("listed %d subscriptions, %d live; want %d, %d live", len(list.Subscriptions), live, tt.total, tt.live)
+			}
+		})
+	}
+}
+
+func TestRestoreSubscription(t *testing.T) {
+	ts, _ := newTestServer(t)
+	sub := createSub(t, ts, testutil.AcmeKey, "Netflix")
+	path := "/subscriptions/" + testutil.UserID + "/Netflix"
+	restore := fmt.Sprintf("/subscriptions/by-id/%d/restore", sub.ID)
+
+	if resp := call(t, ts, http.MethodPost, restore, testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("restore of a live subscription: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if resp := call(t, ts, http.MethodDelete, path, testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp := call(t, ts, http.MethodGet, path, testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("get after delete: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	createSub(t, ts, testutil.AcmeKey, "Netflix")
+	if resp := call(t, ts, http.MethodPost, restore, testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("restore over a live duplicate: status %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+	if resp := call(t, ts, http.MethodDelete, path, testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete of the duplicate: status %d", resp.StatusCode)
+	}
+
+	for _, probe := range []struct {
+		path, key string
+		want      int
+	}{
+		{"/subscriptions/by-id/x/restore", testutil.AcmeKey, http.StatusBadRequest},
+		{restore, testutil.GlobexKey, http.StatusNotFound},
+	} {
+		if resp := call(t, ts, http.MethodPost, probe.path, probe.key, nil, nil); resp.StatusCode != probe.want {
+			t.Errorf("POST %s: status %d, want %d", probe.path, resp.StatusCode, probe.want)
+		}
+	}
+
+	resp := call(t, ts, http.MethodPost, restore, testutil.AcmeKey, nil, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("restore: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var restored pkg.SubscriptionDTO
+	if err := json.NewDecoder(resp.Body).Decode(&restored); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if restored.ID != sub.ID || restored.DeletedAt != "" {
+		t.Errorf("restored = %+v, want live subscription %d", restored, sub.ID)
+	}
+	if resp := call(t, ts, http.MethodGet, path, testutil.AcmeKey, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("get after restore: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,32 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// @Summary Issue a Telegram link code
+// @Description Issues a one-time code the user sends to the bot as /link <code> (or via the t.me/<bot>?start=<code> link) to link a chat. Earlier codes of the user stop working.
+// @Tags telegram
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param Idempotency-Key header string false "Replays the first response to repeats of the request with this key"
+// @Param userID path string true "User ID"
+// @Success 201 {object} pkg.TelegramLinkCodeDTO
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 422 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /users/{userID}/telegram/link-code [post]
+func (s *Server) handleCreateTelegramLinkCode(w http.ResponseWriter, r *http.Request) {
+	code, err := s.chatLinks.CreateLinkCode(r.Context(), chi.URLParam(r, "userID"))
+	if err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusCreated, toTelegramLinkCodeDTO(code))
+}
@@ -0,0 +1,94 @@
+//go:build integration
+
+package public
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/100bench/subscription_aggregator/internal/auth"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// TestTenantCanNotReachOtherTenantsRows goes through the real router to
+// postgres, so the API keys, the tenant context and row-level security are
+// all in the path. The tenants are new on every run.
+func TestTenantCanNotReachOtherTenantsRows(t *testing.T) {
+	keys, err := auth.ParseKeys(testutil.Tenant("acme") + ":billing=" + testutil.AcmeKey + ";" +
+		testutil.Tenant("globex") + ":crm=" + testutil.GlobexKey)
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	ts := serve(t, testutil.Postgres(t), WithAPIKeys(keys))
+	userID := uuid.NewString()
+	path := "/subscriptions/" + userID
+
+	req := testutil.CreateSubRequest("Netflix")
+	req.UserId = userID
+	resp := call(t, ts, http.MethodPost, "/subscriptions", testutil.AcmeKey, req, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d", resp.StatusCode)
+	}
+	var sub pkg.SubscriptionDTO
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	var list pkg.GetSubsResponse
+	resp = call(t, ts, http.MethodGet, path, testutil.GlobexKey, nil, nil)
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Subscriptions) != 0 {
+		t.Errorf("globex lists %d of acme's subscriptions", len(list.Subscriptions))
+	}
+
+	for _, probe := range []struct{ method, path string }{
+		{http.MethodGet, path + "/Netflix"},
+		{http.MethodPut, path + "/Netflix"},
+		{http.MethodDelete, path + "/Netflix"},
+	} {
+		var body interface{}
+		if probe.method == http.MethodPut {
+			price := 1
+			body = pkg.UpdateSubRequest{Price: &price}
+		}
+		resp := call(t, ts, probe.method, probe.path, testutil.GlobexKey, body, nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("globex %s %s: status %d, want %d", probe.method, probe.path, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+
+	var audit pkg.GetAuditResponse
+	resp = call(t, ts, http.MethodGet, fmt.Sprintf("/subscriptions/by-id/%d/audit", sub.ID), testutil.GlobexKey, nil, nil)
+	if err := json.NewDecoder(resp.Body).Decode(&audit); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(audit.Entries) != 0 {
+		t.Errorf("globex reads %d audit entries of acme's subscription", len(audit.Entries))
+	}
+
+	var total pkg.GetTotalCostResponse
+	resp = call(t, ts, http.MethodGet, "/subscriptions/total-cost?user_id="+userID+"&start_date=01-2025&end_date=12-2025",
+		testutil.GlobexKey, nil, nil)
+	if err := json.NewDecoder(resp.Body).Decode(&total); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if total.TotalCost != 0 {
+		t.Errorf("globex sees a total cost of %d for acme's user", total.TotalCost)
+	}
+
+	var got pkg.SubscriptionDTO
+	resp = call(t, ts, http.MethodGet, path+"/Netflix", testutil.AcmeKey, nil, nil)
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.ID != sub.ID || got.Price != sub.Price {
+		t.Errorf("acme reads %+v after globex's attempts, want %+v", got, sub)
+	}
+}
@@ -0,0 +1,151 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/entities"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// maxDeliveriesLimit caps the page size of the delivery log.
+const maxDeliveriesLimit = 500
+
+// @Summary Register a webhook
+// @Description Registers an endpoint for subscription events of one user or, without user_id, of the whole tenant. Deliveries are signed with HMAC-SHA256 using the returned secret, which is shown only once.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param Idempotency-Key header string false "Replays the first response to repeats of the request with this key"
+// @Param webhook body pkg.CreateWebhookRequest true "Webhook"
+// @Success 201 {object} pkg.WebhookDTO
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 422 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /webhooks [post]
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req pkg.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	webhook := entities.Webhook{UserID: req.UserId, URL: req.URL}
+	for _, e := range req.Events {
+		webhook.Events = append(webhook.Events, entities.EventType(e))
+	}
+
+	created, err := s.webhooks.RegisterWebhook(r.Context(), webhook)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidWebhook) {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusCreated, toWebhookDTO(created))
+}
+
+// @Summary List webhooks
+// @Description Lists the tenant's webhooks; with user_id only that user's and the tenant-wide ones. Secrets are not returned.
+// @Tags webhooks
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param user_id query string false "User ID"
+// @Success 200 {object} pkg.GetWebhooksResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /webhooks [get]
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := s.webhooks.ListWebhooks(r.Context(), r.URL.Query().Get("user_id"))
+	if err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	list := make([]pkg.WebhookDTO, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		list = append(list, toWebhookDTO(webhook))
+	}
+	s.respondWithJSON(w, http.StatusOK, pkg.GetWebhooksResponse{Webhooks: list})
+}
+
+// @Summary Delete a webhook
+// @Description Stops deliveries to the endpoint and removes its delivery log
+// @Tags webhooks
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param id path int true "Webhook ID"
+// @Success 204
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /webhooks/{id} [delete]
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid webhook id"})
+		return
+	}
+	if err := s.webhooks.DeleteWebhook(r.Context(), id); err != nil {
+		if errors.Is(err, entities.ErrWebhookNotFound) {
+			s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Webhook delivery log
+// @Description Latest deliveries to the endpoint with their attempts and outcome, newest first. Dead deliveries ran out of retries.
+// @Tags webhooks
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Tenant-ID header string false "Expected tenant, must match the API key's"
+// @Param id path int true "Webhook ID"
+// @Param limit query int false "Max deliveries, 50 by default"
+// @Success 200 {object} pkg.GetWebhookDeliveriesResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 401 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 429 {object} pkg.ProblemResponse
+// @Failure 500 {object} pkg.ErrorResponse
+// @Router /webhooks/{id}/deliveries [get]
+func (s *Server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "invalid webhook id"})
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxDeliveriesLimit {
+			s.respondWithError(w, http.StatusBadRequest, pkg.ErrorResponse{Error: "limit must be between 1 and " + strconv.Itoa(maxDeliveriesLimit)})
+			return
+		}
+	}
+
+	deliveries, err := s.webhooks.ListDeliveries(r.Context(), id, limit)
+	if err != nil {
+		if errors.Is(err, entities.ErrWebhookNotFound) {
+			s.respondWithError(w, http.StatusNotFound, pkg.ErrorResponse{Error: err.Error()})
+			return
+		}
+		s.respondWithError(w, http.StatusInternalServerError, pkg.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, toDeliveriesResponse(deliveries))
+}
@@ -0,0 +1,334 @@
+// Package telegram runs a Telegram bot over the subscription use cases. A
+// chat acts for the user it was linked to with a one-time code, in that
+// user's tenant, and receives the user's renewal reminders.
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+const (
+	// DefaultPollTimeout is how long a getUpdates call waits for updates.
+	DefaultPollTimeout = 30 * time.Second
+
+	minPollBackoff = time.Second
+	maxPollBackoff = 30 * time.Second
+
+	// updateTimeout bounds the handling of a single update.
+	updateTimeout = 30 * time.Second
+
+	periodLayout = "01-2006"
+)
+
+// Bot long-polls the Bot API and answers commands one update at a time.
+type Bot struct {
+	api         *Client
+	service     PublicService
+	links       ChatLinks
+	logger      *slog.Logger
+	pollTimeout time.Duration
+}
+
+// Option customizes optional Bot behaviour.
+type Option func(*Bot)
+
+// WithLogger replaces slog.Default as the destination of bot logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(b *Bot) {
+		b.logger = logger
+	}
+}
+
+// WithPollTimeout replaces DefaultPollTimeout.
+func WithPollTimeout(timeout time.Duration) Option {
+	return func(b *Bot) {
+		b.pollTimeout = timeout
+	}
+}
+
+func NewBot(api *Client, service PublicService, links ChatLinks, opts ...Option) (*Bot, error) {
+	if api == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "telegram bot api client")
+	}
+	if service == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "telegram bot service")
+	}
+	if links == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "telegram bot chat links")
+	}
+	b := &Bot{api: api, service: service, links: links, logger: slog.Default(), pollTimeout: DefaultPollTimeout}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b, nil
+}
+
+// Run polls for updates until ctx is done, backing off while the Bot API is
+// unreachable.
+func (b *Bot) Run(ctx context.Context) {
+	var offset int64
+	backoff := minPollBackoff
+	for {
+		updates, err := b.api.GetUpdates(ctx, offset, b.pollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.logger.WarnContext(ctx, "telegram poll failed", "error", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxPollBackoff)
+			continue
+		}
+		backoff = minPollBackoff
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message != nil {
+				b.handleMessage(ctx, update.UpdateID, *update.Message)
+			}
+		}
+	}
+}
+
+func (b *Bot) handleMessage(ctx context.Context, updateID int64, msg Message) {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	ctx = en.WithRequestID(ctx, "telegram-"+strconv.FormatInt(updateID, 10))
+	ctx = en.WithPrincipal(ctx, "telegram:"+strconv.FormatInt(msg.Chat.ID, 10))
+	defer func() {
+		if v := recover(); v != nil {
+			b.logger.ErrorContext(ctx, "telegram update panicked", "panic", v, "update_id", updateID)
+		}
+	}()
+
+	command, args, ok := parseCommand(msg.Text)
+	if !ok {
+		return
+	}
+	locale := ""
+	if msg.From != nil {
+		locale = localeOf(msg.From.LanguageCode)
+	}
+	reply := b.dispatch(ctx, msg.Chat.ID, locale, command, args)
+	if reply == "" {
+		return
+	}
+	if err := b.api.SendMessage(ctx, msg.Chat.ID, reply); err != nil {
+		b.logger.ErrorContext(ctx, "telegram reply failed", "chat_id", msg.Chat.ID, "error", err)
+	}
+}
+
+// dispatch runs a command and returns the reply. Commands other than linking
+// and help require a linked chat; they run in the linked user's tenant.
+func (b *Bot) dispatch(ctx context.Context, chatID int64, locale, command string, args []string) string {
+	switch command {
+	case "start", "link":
+		if len(args) == 0 {
+			if chat, err := b.links.ChatByID(ctx, chatID); err == nil {
+				return messagesFor(chat.Locale).help
+			}
+			return messagesFor(locale).welcome
+		}
+		return b.link(ctx, chatID, locale, args[0])
+	case "help":
+		if chat, err := b.links.ChatByID(ctx, chatID); err == nil {
+			locale = chat.Locale
+		}
+		return messagesFor(locale).help
+	}
+
+	chat, err := b.links.ChatByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, en.ErrChatNotLinked) {
+			return messagesFor(locale).notLinked
+		}
+		b.logger.ErrorContext(ctx, "telegram chat lookup failed", "chat_id", chatID, "error", err)
+		return messagesFor(locale).failed
+	}
+	ctx = en.WithTenant(ctx, chat.TenantID)
+	m := messagesFor(chat.Locale)
+
+	switch command {
+	case "list":
+		return b.list(ctx, chat, m)
+	case "add":
+		return b.add(ctx, chat, m, args)
+	case "total":
+		return b.total(ctx, chat, m, args)
+	case "cancel":
+		return b.cancel(ctx, chat, m, args)
+	case "unlink":
+		if err := b.links.UnlinkChat(ctx, chatID); err != nil {
+			b.logger.ErrorContext(ctx, "telegram unlink failed", "chat_id", chatID, "error", err)
+			return m.failed
+		}
+		return m.unlinked
+	default:
+		return m.help
+	}
+}
+
+func (b *Bot) link(ctx context.Context, chatID int64, locale, code string) string {
+	if locale == "" {
+		locale = en.Locales[0]
+	}
+	chat, err := b.links.LinkChat(ctx, code, chatID, locale)
+	if err != nil {
+		if errors.Is(err, en.ErrInvalidLinkCode) {
+			return messagesFor(locale).invalidCode
+		}
+		b.logger.ErrorContext(ctx, "telegram link failed", "chat_id", chatID, "error", err)
+		return messagesFor(locale).failed
+	}
+	m := messagesFor(chat.Locale)
+	return m.linked + "\n\n" + m.help
+}
+
+func (b *Bot) list(ctx context.Context, chat en.TelegramChat, m messages) string {
+	subs, err := b.service.GetListSubscriptions(ctx, chat.UserID, false)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "telegram list failed", "user_id", chat.UserID, "error", err)
+		return m.failed
+	}
+	if len(subs) == 0 {
+		return m.noSubs
+	}
+	lines := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		if sub.EndDate != "" {
+			lines = append(lines, fmt.Sprintf(m.subLineUntil, sub.ServiceName, sub.Price, sub.StartDate, sub.EndDate))
+		} else {
+			lines = append(lines, fmt.Sprintf(m.subLine, sub.ServiceName, sub.Price, sub.StartDate))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// add handles /add <service> <price> <start> [end]; the service name may
+// contain spaces, so the arguments are read from the end.
+func (b *Bot) add(ctx context.Context, chat en.TelegramChat, m messages, args []string) string {
+	if len(args) < 3 {
+		return m.addUsage
+	}
+	var endDate string
+	if len(args) >= 4 && isPeriod(args[len(args)-1]) && isPeriod(args[len(args)-2]) {
+		endDate = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	name := strings.Join(args[:len(args)-2], " ")
+	startDate := args[len(args)-1]
+	price, err := strconv.Atoi(args[len(args)-2])
+	if err != nil || price <= 0 {
+		return m.invalidPrice
+	}
+	if !validPeriod(startDate, endDate) {
+		return m.invalidPeriod
+	}
+
+	sub, err := b.service.CreateSubscription(ctx, en.Subscription{
+		ServiceName: name,
+		Price:       price,
+		UserID:      chat.UserID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+	})
+	if err != nil {
+		if errors.Is(err, en.ErrSubscriptionExists) {
+			return fmt.Sprintf(m.exists, name, startDate)
+		}
+		b.logger.ErrorContext(ctx, "telegram add failed", "user_id", chat.UserID, "error", err)
+		return m.failed
+	}
+	return fmt.Sprintf(m.added, sub.ServiceName, sub.Price, sub.StartDate)
+}
+
+// total handles /total <start> <end> [service].
+func (b *Bot) total(ctx context.Context, chat en.TelegramChat, m messages, args []string) string {
+	if len(args) < 2 {
+		return m.totalUsage
+	}
+	startDate, endDate := args[0], args[1]
+	if !validPeriod(startDate, endDate) {
+		return m.invalidPeriod
+	}
+	service := strings.Join(args[2:], " ")
+	cost, err := b.service.GetTotalCostByPeriod(ctx, chat.UserID, service, startDate, endDate, en.CostModeExpected)
+	if err != nil {
+		if errors.Is(err, en.ErrInvalidPeriod) {
+			return m.invalidPeriod
+		}
+		b.logger.ErrorContext(ctx, "telegram total failed", "user_id", chat.UserID, "error", err)
+		return m.failed
+	}
+	return fmt.Sprintf(m.total, startDate, endDate, cost)
+}
+
+// cancel handles /cancel <service>; the name is matched case-insensitively
+// against the user's active subscriptions.
+func (b *Bot) cancel(ctx context.Context, chat en.TelegramChat, m messages, args []string) string {
+	if len(args) == 0 {
+		return m.cancelUsage
+	}
+	name := strings.Join(args, " ")
+	subs, err := b.service.GetListSubscriptions(ctx, chat.UserID, false)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "telegram cancel failed", "user_id", chat.UserID, "error", err)
+		return m.failed
+	}
+	for _, sub := range subs {
+		if strings.EqualFold(sub.ServiceName, name) {
+			name = sub.ServiceName
+			break
+		}
+	}
+	if err := b.service.DeleteSubscription(ctx, chat.UserID, name); err != nil {
+		if errors.Is(err, en.ErrSubscriptionNotFound) {
+			return fmt.Sprintf(m.notFound, name)
+		}
+		b.logger.ErrorContext(ctx, "telegram cancel failed", "user_id", chat.UserID, "error", err)
+		return m.failed
+	}
+	return fmt.Sprintf(m.cancelled, name)
+}
+
+// parseCommand splits "/cmd@BotName arg1 arg2" into the lower-cased command
+// and its arguments; ok is false for messages that are not commands.
+func parseCommand(text string) (command string, args []string, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil, false
+	}
+	command, _, _ = strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+	return strings.ToLower(command), fields[1:], true
+}
+
+func isPeriod(s string) bool {
+	_, err := time.Parse(periodLayout, s)
+	return err == nil
+}
+
+// validPeriod reports whether start, and end if set, are MM-YYYY dates with
+// end not before start.
+func validPeriod(start, end string) bool {
+	from, err := time.Parse(periodLayout, start)
+	if err != nil {
+		return false
+	}
+	if end == "" {
+		return true
+	}
+	to, err := time.Parse(periodLayout, end)
+	return err == nil && !to.Before(from)
+}
@@ -0,0 +1,389 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+)
+
+const botToken = "123456:test-token"
+
+type sentMessage struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// fakeBotAPI serves getUpdates from a queue of messages and records
+// sendMessage calls, like the Bot API does for a bot with botToken.
+type fakeBotAPI struct {
+	mu       sync.Mutex
+	updates  []Update
+	nextID   int64
+	failSend bool
+	sent     chan sentMessage
+}
+
+func newFakeBotAPI(t *testing.T) (*fakeBotAPI, *httptest.Server) {
+	t.Helper()
+	api := &fakeBotAPI{nextID: 1, sent: make(chan sentMessage, 100)}
+	ts := httptest.NewServer(api)
+	t.Cleanup(ts.Close)
+	return api, ts
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot"+botToken+"/")
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+		return
+	}
+	switch method {
+	case "getUpdates":
+		var params struct {
+			Offset int64 `json:"offset"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// A short long-poll: answer as soon as there are updates, or empty.
+		deadline := time.Now().Add(50 * time.Millisecond)
+		for {
+			if updates := f.pending(params.Offset); len(updates) > 0 || time.Now().After(deadline) || r.Context().Err() != nil {
+				respond(w, updates)
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	case "sendMessage":
+		var msg sentMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		fail := f.failSend
+		f.mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+			return
+		}
+		f.sent <- msg
+		respond(w, map[string]interface{}{"message_id": 1, "chat": map[string]int64{"id": msg.ChatID}, "text": msg.Text})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+	}
+}
+
+func respond(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+func (f *fakeBotAPI) pending(offset int64) []Update {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []Update
+	for _, u := range f.updates {
+		if u.UpdateID >= offset {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// send queues a message of a user writing in language to the bot.
+func (f *fakeBotAPI) send(chatID int64, language, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append(f.updates, Update{UpdateID: f.nextID, Message: &Message{
+		MessageID: f.nextID, Chat: Chat{ID: chatID}, From: &User{ID: chatID, LanguageCode: language}, Text: text,
+	}})
+	f.nextID++
+}
+
+// reply returns the next message the bot sent.
+func (f *fakeBotAPI) reply(t *testing.T) sentMessage {
+	t.Helper()
+	select {
+	case msg := <-f.sent:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message sent within 5s")
+		return sentMessage{}
+	}
+}
+
+// ask sends text from the chat and returns the bot's reply to it.
+func (f *fakeBotAPI) ask(t *testing.T, chatID int64, language, text string) string {
+	t.Helper()
+	f.send(chatID, language, text)
+	msg := f.reply(t)
+	if msg.ChatID != chatID {
+		t.Fatalf("reply to %q went to chat %d, want %d", text, msg.ChatID, chatID)
+	}
+	return msg.Text
+}
+
+type linkCode struct {
+	tenantID, userID string
+	expiresAt        time.Time
+}
+
+// memChats stores chats and link codes like the telegram tables do, with a
+// clock the tests move to expire codes.
+type memChats struct {
+	mu    sync.Mutex
+	now   time.Time
+	codes map[string]linkCode
+	chats map[int64]en.TelegramChat
+}
+
+func newMemChats() *memChats {
+	return &memChats{now: time.Now(), codes: map[string]linkCode{}, chats: map[int64]en.TelegramChat{}}
+}
+
+func (m *memChats) advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
+
+func (m *memChats) CreateTelegramLinkCode(ctx context.Context, userID, codeHash string, expiresAt time.Time) error {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, c := range m.codes {
+		if c.tenantID == tenantID && c.userID == userID {
+			delete(m.codes, hash)
+		}
+	}
+	m.codes[codeHash] = linkCode{tenantID, userID, expiresAt}
+	return nil
+}
+
+func (m *memChats) LinkTelegramChat(ctx context.Context, codeHash string, chatID int64, locale string) (en.TelegramChat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code, ok := m.codes[codeHash]
+	if !ok || code.expiresAt.Before(m.now) {
+		return en.TelegramChat{}, en.ErrInvalidLinkCode
+	}
+	delete(m.codes, codeHash)
+	for id, chat := range m.chats {
+		if chat.TenantID == code.tenantID && chat.UserID == code.userID {
+			delete(m.chats, id)
+		}
+	}
+	chat := en.TelegramChat{TenantID: code.tenantID, UserID: code.userID, ChatID: chatID, Locale: locale, LinkedAt: m.now}
+	m.chats[chatID] = chat
+	return chat, nil
+}
+
+func (m *memChats) UnlinkTelegramChat(ctx context.Context, chatID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.chats, chatID)
+	return nil
+}
+
+func (m *memChats) GetTelegramChatByID(ctx context.Context, chatID int64) (en.TelegramChat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chat, ok := m.chats[chatID]
+	if !ok {
+		return en.TelegramChat{}, en.ErrChatNotLinked
+	}
+	return chat, nil
+}
+
+func (m *memChats) GetTelegramChat(ctx context.Context, userID string) (en.TelegramChat, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.TelegramChat{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, chat := range m.chats {
+		if chat.TenantID == tenantID && chat.UserID == userID {
+			return chat, nil
+		}
+	}
+	return en.TelegramChat{}, en.ErrChatNotLinked
+}
+
+const codeTTL = 10 * time.Minute
+
+type botFixture struct {
+	api     *fakeBotAPI
+	client  *Client
+	chats   *memChats
+	links   *cases.ChatLinkService
+	service *cases.ServiceProvider
+}
+
+// runBot runs a bot against the fake Bot API until the test ends.
+func runBot(t *testing.T) botFixture {
+	t.Helper()
+	api, ts := newFakeBotAPI(t)
+	client, err := NewClient(ts.URL, botToken)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	chats := newMemChats()
+	links, err := cases.NewChatLinkService(chats, testutil.Logger(), codeTTL)
+	if err != nil {
+		t.Fatalf("NewChatLinkService: %v", err)
+	}
+	service := testutil.NewService(t, testutil.NewStorage())
+	bot, err := NewBot(client, service, links, WithLogger(testutil.Logger()), WithPollTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewBot: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bot.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return botFixture{api: api, client: client, chats: chats, links: links, service: service}
+}
+
+func (f botFixture) code(t *testing.T, tenantID, userID string) en.ChatLinkCode {
+	t.Helper()
+	code, err := f.links.CreateLinkCode(en.WithTenant(context.Background(), tenantID), userID)
+	if err != nil {
+		t.Fatalf("CreateLinkCode: %v", err)
+	}
+	return code
+}
+
+func TestLinkCodeLinksTheChatToTheUser(t *testing.T) {
+	f := runBot(t)
+	userID := uuid.NewString()
+	acme := en.WithTenant(context.Background(), "acme")
+	ru := messagesFor(en.LocaleRU)
+
+	if got := f.api.ask(t, 42, "ru-RU", "/list"); got != ru.notLinked {
+		t.Errorf("/list of an unlinked chat = %q, want %q", got, ru.notLinked)
+	}
+
+	code := f.code(t, "acme", userID)
+	if got, want := f.api.ask(t, 42, "ru-RU", "/link "+strings.ToLower(code.Code)), ru.linked+"\n\n"+ru.help; got != want {
+		t.Errorf("/link = %q, want %q", got, want)
+	}
+	if got, want := f.api.ask(t, 43, "en", "/link "+code.Code), messagesFor(en.LocaleEN).invalidCode; got != want {
+		t.Errorf("/link with a redeemed code = %q, want %q", got, want)
+	}
+
+	if got, want := f.api.ask(t, 42, "ru-RU", "/add Yandex Plus 400 01-2025"), fmt.Sprintf(ru.added, "Yandex Plus", 400, "01-2025"); got != want {
+		t.Errorf("/add = %q, want %q", got, want)
+	}
+	subs, err := f.service.GetListSubscriptions(acme, userID, false)
+	if err != nil || len(subs) != 1 || subs[0].ServiceName != "Yandex Plus" {
+		t.Errorf("acme's subscriptions = %+v, %v; want the one added from the chat", subs, err)
+	}
+	if subs, _ := f.service.GetListSubscriptions(en.WithTenant(context.Background(), "globex"), userID, false); len(subs) != 0 {
+		t.Errorf("globex's subscriptions = %+v, want none", subs)
+	}
+	if got, want := f.api.ask(t, 42, "ru-RU", "/list"), fmt.Sprintf(ru.subLine, "Yandex Plus", 400, "01-2025"); got != want {
+		t.Errorf("/list = %q, want %q", got, want)
+	}
+
+	if got := f.api.ask(t, 42, "ru-RU", "/unlink"); got != ru.unlinked {
+		t.Errorf("/unlink = %q, want %q", got, ru.unlinked)
+	}
+	if got := f.api.ask(t, 42, "ru-RU", "/list"); got != ru.notLinked {
+		t.Errorf("/list after /unlink = %q, want %q", got, ru.notLinked)
+	}
+}
+
+func TestLinkCodeExpires(t *testing.T) {
+	f := runBot(t)
+	userID := uuid.NewString()
+	m := messagesFor(en.LocaleEN)
+
+	issued := time.Now()
+	code := f.code(t, "acme", userID)
+	if ttl := code.ExpiresAt.Sub(issued); ttl < codeTTL-time.Second || ttl > codeTTL+time.Second {
+		t.Errorf("code expires in %v, want %v", ttl, codeTTL)
+	}
+	f.chats.advance(codeTTL + time.Minute)
+	if got := f.api.ask(t, 42, "en", "/link "+code.Code); got != m.invalidCode {
+		t.Errorf("/link with an expired code = %q, want %q", got, m.invalidCode)
+	}
+
+	// A new code, issued back at the real time, replaces the user's previous one.
+	f.chats.advance(-codeTTL - time.Minute)
+	first := f.code(t, "acme", userID)
+	second := f.code(t, "acme", userID)
+	if got := f.api.ask(t, 42, "en", "/link "+first.Code); got != m.invalidCode {
+		t.Errorf("/link with a replaced code = %q, want %q", got, m.invalidCode)
+	}
+	if got := f.api.ask(t, 42, "en", "/link "+second.Code); got != m.linked+"\n\n"+m.help {
+		t.Errorf("/link with the latest code = %q, want it linked", got)
+	}
+}
+
+func TestNotifierDeliversToTheLinkedChat(t *testing.T) {
+	f := runBot(t)
+	userID := uuid.NewString()
+	code := f.code(t, "acme", userID)
+	f.api.ask(t, 42, "en", "/link "+code.Code)
+
+	notifier, err := NewNotifier(f.client, f.links)
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	acme := en.WithTenant(context.Background(), "acme")
+	notification := en.Notification{UserID: userID, Text: "Netflix renews on 01-2025 for 400 RUB"}
+	if err := notifier.Notify(acme, notification); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if msg := f.api.reply(t); msg.ChatID != 42 || msg.Text != notification.Text {
+		t.Errorf("delivered %+v, want the text to chat 42", msg)
+	}
+
+	if err := notifier.Notify(en.WithTenant(context.Background(), "globex"), notification); !errors.Is(err, en.ErrChatNotLinked) {
+		t.Errorf("Notify of the same user ID in another tenant = %v, want ErrChatNotLinked", err)
+	}
+
+	f.api.mu.Lock()
+	f.api.failSend = true
+	f.api.mu.Unlock()
+	err = notifier.Notify(acme, notification)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Notify to a chat that blocked the bot = %v, want the Bot API error", err)
+	}
+	if err != nil && strings.Contains(err.Error(), botToken) {
+		t.Errorf("Notify error %q leaks the bot token", err)
+	}
+	select {
+	case msg := <-f.api.sent:
+		t.Errorf("unexpected message %+v", msg)
+	default:
+	}
+}
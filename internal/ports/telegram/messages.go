@@ -0,0 +1,105 @@
+package telegram
+
+import (
+	"strings"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// messages are the bot replies in one locale. Entries with verbs are
+// fmt formats.
+type messages struct {
+	help          string
+	welcome       string
+	notLinked     string
+	linked        string
+	invalidCode   string
+	unlinked      string
+	noSubs        string
+	subLine       string
+	subLineUntil  string
+	addUsage      string
+	added         string
+	exists        string
+	invalidPrice  string
+	invalidPeriod string
+	totalUsage    string
+	total         string
+	cancelUsage   string
+	cancelled     string
+	notFound      string
+	failed        string
+}
+
+var catalog = map[string]messages{
+	en.LocaleEN: {
+		help: "Commands:\n" +
+			"/list — your subscriptions\n" +
+			"/add <service> <price> <MM-YYYY> [MM-YYYY] — add a subscription\n" +
+			"/total <MM-YYYY> <MM-YYYY> [service] — total cost for a period\n" +
+			"/cancel <service> — cancel a subscription\n" +
+			"/unlink — disconnect this chat",
+		welcome:       "Hi! Request a link code in the app and send it here as /link <code> to manage your subscriptions and get renewal reminders.",
+		notLinked:     "This chat is not linked yet. Request a link code in the app and send /link <code>.",
+		linked:        "Chat linked. Renewal reminders will arrive here.",
+		invalidCode:   "The code is invalid or expired. Request a new one in the app.",
+		unlinked:      "Chat unlinked. You will no longer get reminders here.",
+		noSubs:        "You have no subscriptions.",
+		subLine:       "• %s — %d RUB/month since %s",
+		subLineUntil:  "• %s — %d RUB/month, %s to %s",
+		addUsage:      "Usage: /add <service> <price> <MM-YYYY> [MM-YYYY], e.g. /add Netflix 799 01-2025",
+		added:         "Added %s for %d RUB/month since %s.",
+		exists:        "You already have %s starting %s.",
+		invalidPrice:  "The price must be a positive whole number.",
+		invalidPeriod: "Dates must look like MM-YYYY, and the end must not precede the start.",
+		totalUsage:    "Usage: /total <MM-YYYY> <MM-YYYY> [service], e.g. /total 01-2025 12-2025",
+		total:         "Total for %s – %s: %d RUB.",
+		cancelUsage:   "Usage: /cancel <service>",
+		cancelled:     "%s cancelled.",
+		notFound:      "You have no %s subscription.",
+		failed:        "Something went wrong, please try again later.",
+	},
+	en.LocaleRU: {
+		help: "Команды:\n" +
+			"/list — ваши подписки\n" +
+			"/add <сервис> <цена> <ММ-ГГГГ> [ММ-ГГГГ] — добавить подписку\n" +
+			"/total <ММ-ГГГГ> <ММ-ГГГГ> [сервис] — сумма за период\n" +
+			"/cancel <сервис> — отменить подписку\n" +
+			"/unlink — отвязать этот чат",
+		welcome:       "Привет! Получите код привязки в приложении и отправьте его сюда командой /link <код>, чтобы управлять подписками и получать напоминания о продлении.",
+		notLinked:     "Чат ещё не привязан. Получите код привязки в приложении и отправьте /link <код>.",
+		linked:        "Чат привязан. Напоминания о продлении будут приходить сюда.",
+		invalidCode:   "Код неверный или устарел. Получите новый в приложении.",
+		unlinked:      "Чат отвязан. Напоминания сюда больше не придут.",
+		noSubs:        "У вас нет подписок.",
+		subLine:       "• %s — %d ₽/мес. с %s",
+		subLineUntil:  "• %s — %d ₽/мес., с %s по %s",
+		addUsage:      "Формат: /add <сервис> <цена> <ММ-ГГГГ> [ММ-ГГГГ], например /add Netflix 799 01-2025",
+		added:         "Подписка %s за %d ₽/мес. с %s добавлена.",
+		exists:        "Подписка %s с %s уже есть.",
+		invalidPrice:  "Цена должна быть целым положительным числом.",
+		invalidPeriod: "Даты указываются как ММ-ГГГГ, конец не может быть раньше начала.",
+		totalUsage:    "Формат: /total <ММ-ГГГГ> <ММ-ГГГГ> [сервис], например /total 01-2025 12-2025",
+		total:         "Сумма за %s – %s: %d ₽.",
+		cancelUsage:   "Формат: /cancel <сервис>",
+		cancelled:     "Подписка %s отменена.",
+		notFound:      "Подписки %s нет.",
+		failed:        "Что-то пошло не так, попробуйте позже.",
+	},
+}
+
+// localeOf picks the supported locale closest to a Telegram language code.
+func localeOf(languageCode string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	if _, ok := catalog[lang]; ok {
+		return lang
+	}
+	return en.Locales[0]
+}
+
+func messagesFor(locale string) messages {
+	if m, ok := catalog[locale]; ok {
+		return m
+	}
+	return catalog[en.Locales[0]]
+}
@@ -0,0 +1,37 @@
+package telegram
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// Notifier delivers notifications to the chat linked to their user. It
+// expects the user's tenant in ctx.
+type Notifier struct {
+	api   *Client
+	links ChatLinks
+}
+
+func NewNotifier(api *Client, links ChatLinks) (*Notifier, error) {
+	if api == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "telegram notifier api client")
+	}
+	if links == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "telegram notifier chat links")
+	}
+	return &Notifier{api: api, links: links}, nil
+}
+
+func (n *Notifier) Notify(ctx context.Context, notification en.Notification) error {
+	chat, err := n.links.ChatForUser(ctx, notification.UserID)
+	if err != nil {
+		return errors.Wrap(err, "Notifier.Notify")
+	}
+	if err := n.api.SendMessage(ctx, chat.ChatID, notification.Text); err != nil {
+		return errors.Wrap(err, "Notifier.Notify")
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package telegram
+
+import (
+	"context"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// PublicService is the use case surface the bot commands act through.
+type PublicService interface {
+	CreateSubscription(ctx context.Context, subscription en.Subscription) (en.Subscription, error)
+	DeleteSubscription(ctx context.Context, userID string, serviceName string) error
+	GetListSubscriptions(ctx context.Context, userID string, includeDeleted bool) ([]en.Subscription, error)
+	GetTotalCostByPeriod(ctx context.Context, userID string, serviceName string, startDate string, endDate string, mode en.CostMode) (int, error)
+}
+
+// ChatLinks resolves which user a chat acts for.
+type ChatLinks interface {
+	LinkChat(ctx context.Context, code string, chatID int64, locale string) (en.TelegramChat, error)
+	UnlinkChat(ctx context.Context, chatID int64) error
+	ChatByID(ctx context.Context, chatID int64) (en.TelegramChat, error)
+	ChatForUser(ctx context.Context, userID string) (en.TelegramChat, error)
+}
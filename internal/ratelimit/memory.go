@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IdleBucketTTL is how long an untouched bucket is kept; a bucket idle for
+// this long has long been refilled, so dropping it changes nothing.
+const IdleBucketTTL = 10 * time.Minute
+
+// MemoryStore keeps buckets in process memory; limits are per replica.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastPrune time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*Bucket)}
+}
+
+func (m *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastPrune) > IdleBucketTTL {
+		for k, b := range m.buckets {
+			if now.Sub(b.Updated) > IdleBucketTTL {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastPrune = now
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		nb := NewBucket(limit, now)
+		b = &nb
+		m.buckets[key] = b
+	}
+	return b.Take(limit, now), nil
+}
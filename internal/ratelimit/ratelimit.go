@@ -0,0 +1,153 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable bucket stores.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+// Limit allows Rate requests per second on average with bursts of up to Burst requests.
+// A zero Rate disables limiting.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+// Result describes the state of a bucket after a request was counted against it.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Bucket is the persisted state of a single token bucket.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// NewBucket returns a full bucket for limit.
+func NewBucket(limit Limit, now time.Time) Bucket {
+	return Bucket{Tokens: float64(limit.Burst), Updated: now}
+}
+
+// Take refills the bucket up to now and tries to consume one token from it.
+func (b *Bucket) Take(limit Limit, now time.Time) Result {
+	burst := float64(limit.Burst)
+	if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+elapsed*limit.Rate)
+		b.Updated = now
+	}
+
+	res := Result{Limit: limit.Burst}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.Tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = secondsToDuration((burst - b.Tokens) / limit.Rate)
+	return res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// Store keeps buckets, possibly shared between replicas.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Limiter picks the limit for a route and counts requests against a Store.
+type Limiter struct {
+	store  Store
+	def    Limit
+	routes map[string]Limit
+	now    func() time.Time
+}
+
+// NewLimiter applies def to every route that has no entry in routes. Route keys
+// have the form "METHOD /chi/{route}/pattern".
+func NewLimiter(store Store, def Limit, routes map[string]Limit) (*Limiter, error) {
+	if store == nil {
+		return nil, errors.Wrap(en.ErrNilDependency, "store")
+	}
+	return &Limiter{store: store, def: def, routes: routes, now: time.Now}, nil
+}
+
+// LimitFor returns the limit configured for the route.
+func (l *Limiter) LimitFor(method, pattern string) Limit {
+	if limit, ok := l.routes[method+" "+pattern]; ok {
+		return limit
+	}
+	return l.def
+}
+
+// Allow counts a request by key against limit.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+	res, err := l.store.Take(ctx, key, limit, l.now())
+	if err != nil {
+		return Result{}, errors.Wrap(err, "store.Take")
+	}
+	return res, nil
+}
+
+// ParseLimit parses "RATE/BURST", e.g. "5/10" for five requests per second with bursts of ten.
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, errors.Errorf("limit %q: want RATE/BURST", s)
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 {
+		return Limit{}, errors.Errorf("limit %q: invalid rate", s)
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 1 {
+		return Limit{}, errors.Errorf("limit %q: burst must be a positive integer", s)
+	}
+	return Limit{Rate: r, Burst: b}, nil
+}
+
+// ParseRouteLimits parses a semicolon-separated list of "METHOD /pattern=RATE/BURST" entries.
+func ParseRouteLimits(s string) (map[string]Limit, error) {
+	routes := make(map[string]Limit)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, limit, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, errors.Errorf("route limit %q: want METHOD /pattern=RATE/BURST", entry)
+		}
+		method, pattern, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok {
+			return nil, errors.Errorf("route limit %q: want METHOD /pattern=RATE/BURST", entry)
+		}
+		l, err := ParseLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(pattern)] = l
+	}
+	return routes, nil
+}
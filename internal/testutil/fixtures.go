@@ -0,0 +1,64 @@
+package testutil
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/100bench/subscription_aggregator/internal/auth"
+	"github.com/100bench/subscription_aggregator/internal/cases"
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// Every test runs as one of two tenants, acme and globex, each with one API
+// key; UserID is the user their subscriptions belong to.
+const (
+	AcmeKey    = "acme-billing-0123456789abcdef"
+	GlobexKey  = "globex-crm-0123456789abcdefgh"
+	AdminToken = "test-admin-token"
+	UserID     = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+)
+
+// APIKeys returns the keyring binding AcmeKey to acme and GlobexKey to
+// globex.
+func APIKeys(t testing.TB) *auth.Keyring {
+	t.Helper()
+	keys, err := auth.ParseKeys("acme:billing=" + AcmeKey + ";globex:crm=" + GlobexKey)
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	return keys
+}
+
+// Logger discards everything it is given.
+func Logger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// NewService wires a ServiceProvider over storage, a new Storage when nil.
+func NewService(t testing.TB, storage cases.SubRepository, opts ...cases.ServiceOption) *cases.ServiceProvider {
+	t.Helper()
+	if storage == nil {
+		storage = NewStorage()
+	}
+	service, err := cases.NewServiceProvider(storage, Logger(), opts...)
+	if err != nil {
+		t.Fatalf("NewServiceProvider: %v", err)
+	}
+	return service
+}
+
+// Subscription is the subscription of UserID to service the tests seed: 400
+// a month through 2025.
+func Subscription(service string) en.Subscription {
+	return en.Subscription{UserID: UserID, ServiceName: service, Price: 400, StartDate: "01-2025", EndDate: "12-2025"}
+}
+
+// CreateSubRequest is Subscription as the body of POST /subscriptions.
+func CreateSubRequest(service string) pkg.CreateSubRequest {
+	sub := Subscription(service)
+	return pkg.CreateSubRequest{
+		UserId: sub.UserID, ServiceName: sub.ServiceName, Price: sub.Price, StartDate: sub.StartDate, EndDate: sub.EndDate,
+	}
+}
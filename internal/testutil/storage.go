@@ -0,0 +1,799 @@
+// Package testutil holds the fixtures shared by tests of the ports and use
+// cases: an in-process cases.SubRepository, fixed tenants with API keys and
+// helpers to wire a service over them. Nothing here is used outside tests;
+// tests of tenant isolation and of SQL run against postgres with the
+// integration tag instead.
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	en "github.com/100bench/subscription_aggregator/internal/entities"
+)
+
+const (
+	monthLayout     = "01-2006"
+	defaultCurrency = "RUB"
+)
+
+// Storage is an in-process SubRepository. It follows the tenant separation
+// and the charge ledger rules of the postgres storage closely enough for the
+// tests of callers, but keeps nothing across a restart and records neither
+// outbox events, except budget alerts, nor feed entries. Each call only sees
+// the rows of the tenant in its context.
+type Storage struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	nextID  int64
+	subs    map[int64]en.Subscription
+	charges map[int64]map[string]en.Charge
+	budgets map[int64]en.Budget
+	audit   []auditRow
+	alerts  []en.Event
+}
+
+// auditRow is an audit entry with the tenant it belongs to.
+type auditRow struct {
+	tenantID string
+	entry    en.AuditEntry
+}
+
+// StorageOption customizes optional Storage behaviour.
+type StorageOption func(*Storage)
+
+// WithClock replaces time.Now, e.g. to bill charges of a fixed month.
+func WithClock(now func() time.Time) StorageOption {
+	return func(s *Storage) {
+		s.now = now
+	}
+}
+
+func NewStorage(opts ...StorageOption) *Storage {
+	s := &Storage{
+		now:     time.Now,
+		subs:    make(map[int64]en.Subscription),
+		charges: make(map[int64]map[string]en.Charge),
+		budgets: make(map[int64]en.Budget),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CreateSub stores the subscription and bills its months; an open-ended one
+// that already started is billed through the current month.
+func (s *Storage) CreateSub(ctx context.Context, sub en.Subscription, audit en.AuditEntry) (en.Subscription, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.Subscription{}, errors.Wrap(err, "testutil.CreateSub")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.subs {
+		if other.TenantID == tenantID && other.UserID == sub.UserID && other.ServiceName == sub.ServiceName &&
+			other.StartDate == sub.StartDate && other.DeletedAt == nil {
+			return en.Subscription{}, errors.Wrap(en.ErrSubscriptionExists, "testutil.CreateSub")
+		}
+	}
+	s.nextID++
+	sub.ID = s.nextID
+	sub.TenantID = tenantID
+	sub.DeletedAt, sub.ExpiredAt, sub.BilledThrough = nil, nil, ""
+	if sub.EndDate == "" {
+		if start, err := time.Parse(monthLayout, sub.StartDate); err == nil && !start.After(s.month()) {
+			sub.BilledThrough = s.month().Format(monthLayout)
+		}
+	}
+	s.subs[sub.ID] = sub
+	s.syncCharges(sub)
+	s.record(tenantID, audit, nil, &sub)
+	return sub, nil
+}
+
+func (s *Storage) GetSub(ctx context.Context, userID, serviceName string) (en.Subscription, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.Subscription{}, errors.Wrap(err, "testutil.GetSub")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range s.sorted(tenantID) {
+		if sub.UserID == userID && sub.ServiceName == serviceName && sub.DeletedAt == nil {
+			return sub, nil
+		}
+	}
+	return en.Subscription{}, errors.Wrap(en.ErrSubscriptionNotFound, "testutil.GetSub")
+}
+
+// UpdateSub changes the non-nil fields. A new end date or switching
+// auto-renewal on clears the expiry.
+func (s *Storage) UpdateSub(ctx context.Context, userID, serviceName string, price *int, startDate *string, endDate *string, autoRenew *bool, audit en.AuditEntry) error {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "testutil.UpdateSub")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := 0
+	for _, sub := range s.sorted(tenantID) {
+		if sub.UserID != userID || sub.ServiceName != serviceName || sub.DeletedAt != nil {
+			continue
+		}
+		before := sub
+		if price != nil {
+			sub.Price = *price
+		}
+		if startDate != nil {
+			sub.StartDate = *startDate
+		}
+		if endDate != nil {
+			sub.EndDate = *endDate
+		}
+		if autoRenew != nil {
+			sub.AutoRenew = *autoRenew
+		}
+		if endDate != nil || (autoRenew != nil && *autoRenew) {
+			sub.ExpiredAt = nil
+		}
+		s.subs[sub.ID] = sub
+		s.syncCharges(sub)
+		s.record(tenantID, audit, &before, &sub)
+		updated++
+	}
+	if updated == 0 {
+		return errors.Wrap(en.ErrSubscriptionNotFound, "testutil.UpdateSub")
+	}
+	return nil
+}
+
+func (s *Storage) DeleteSub(ctx context.Context, userID, serviceName string, audit en.AuditEntry) error {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "testutil.DeleteSub")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, sub := range s.sorted(tenantID) {
+		if sub.UserID != userID || sub.ServiceName != serviceName || sub.DeletedAt != nil {
+			continue
+		}
+		before := sub
+		now := s.now()
+		sub.DeletedAt = &now
+		s.subs[sub.ID] = sub
+		s.syncCharges(sub)
+		s.record(tenantID, audit, &before, nil)
+		deleted++
+	}
+	if deleted == 0 {
+		return errors.Wrap(en.ErrSubscriptionNotFound, "testutil.DeleteSub")
+	}
+	return nil
+}
+
+func (s *Storage) GetListSubs(ctx context.Context, userID string, includeDeleted bool) ([]en.Subscription, error) {
+	subs, err := s.GetListSubsForUsers(ctx, []string{userID}, includeDeleted)
+	if err != nil {
+		return nil, errors.Wrap(err, "testutil.GetListSubs")
+	}
+	return subs[userID], nil
+}
+
+func (s *Storage) GetListSubsForUsers(ctx context.Context, userIDs []string, includeDeleted bool) (map[string][]en.Subscription, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "testutil.GetListSubsForUsers")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+	subs := make(map[string][]en.Subscription, len(userIDs))
+	for _, sub := range s.sorted(tenantID) {
+		if wanted[sub.UserID] && (includeDeleted || sub.DeletedAt == nil) {
+			subs[sub.UserID] = append(subs[sub.UserID], sub)
+		}
+	}
+	return subs, nil
+}
+
+func (s *Storage) RestoreSub(ctx context.Context, id int64, audit en.AuditEntry) (en.Subscription, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.Subscription{}, errors.Wrap(err, "testutil.RestoreSub")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[id]
+	if !ok || sub.TenantID != tenantID || sub.DeletedAt == nil {
+		return en.Subscription{}, errors.Wrap(en.ErrSubscriptionNotFound, "testutil.RestoreSub")
+	}
+	for _, other := range s.subs {
+		if other.TenantID == tenantID && other.UserID == sub.UserID && other.ServiceName == sub.ServiceName &&
+			other.StartDate == sub.StartDate && other.DeletedAt == nil {
+			return en.Subscription{}, errors.Wrap(en.ErrSubscriptionExists, "testutil.RestoreSub")
+		}
+	}
+	before := sub
+	sub.DeletedAt = nil
+	s.subs[id] = sub
+	s.syncCharges(sub)
+	s.record(tenantID, audit, &before, &sub)
+	return sub, nil
+}
+
+// PurgeDeletedSubs removes subscriptions of every tenant soft-deleted before
+// deletedBefore; their charges stay in the ledger, detached from them.
+func (s *Storage) PurgeDeletedSubs(_ context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, sub := range s.subs {
+		if sub.DeletedAt != nil && sub.DeletedAt.Before(deletedBefore) {
+			delete(s.subs, id)
+			for period, c := range s.charges[id] {
+				c.SubscriptionID = 0
+				s.charges[id][period] = c
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *Storage) ListSubsEndingIn(_ context.Context, month string) ([]en.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs []en.Subscription
+	for _, sub := range s.sorted("") {
+		if sub.EndDate == month && sub.DeletedAt == nil {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (s *Storage) ListLapsedSubs(_ context.Context, month string) ([]en.Subscription, error) {
+	m, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return nil, errors.Wrapf(en.ErrInvalidPeriod, "month %q", month)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs []en.Subscription
+	for _, sub := range s.sorted("") {
+		if lapsed(sub, m) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (s *Storage) ExpireSub(ctx context.Context, id int64, month string, audit en.AuditEntry) (en.Subscription, error) {
+	return s.advance(ctx, "testutil.ExpireSub", id, month, audit, func(sub *en.Subscription, _ time.Time) bool {
+		if sub.AutoRenew || sub.EndDate == "" {
+			return false
+		}
+		now := s.now()
+		sub.ExpiredAt = &now
+		return true
+	})
+}
+
+func (s *Storage) RenewSub(ctx context.Context, id int64, month string, audit en.AuditEntry) (en.Subscription, error) {
+	return s.advance(ctx, "testutil.RenewSub", id, month, audit, func(sub *en.Subscription, m time.Time) bool {
+		if sub.EndDate != "" {
+			if !sub.AutoRenew {
+				return false
+			}
+			end, _ := time.Parse(monthLayout, sub.EndDate)
+			sub.EndDate = end.AddDate(0, 1, 0).Format(monthLayout)
+			sub.BilledThrough = sub.EndDate
+			return true
+		}
+		next := m
+		if billed, err := time.Parse(monthLayout, sub.BilledThrough); err == nil {
+			next = billed.AddDate(0, 1, 0)
+		}
+		sub.BilledThrough = next.Format(monthLayout)
+		return true
+	})
+}
+
+// advance applies a lifecycle step to the tenant's lapsed subscription id.
+func (s *Storage) advance(ctx context.Context, op string, id int64, month string, audit en.AuditEntry, step func(sub *en.Subscription, m time.Time) bool) (en.Subscription, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.Subscription{}, errors.Wrap(err, op)
+	}
+	m, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return en.Subscription{}, errors.Wrapf(en.ErrInvalidPeriod, "month %q", month)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[id]
+	if !ok || sub.TenantID != tenantID || !lapsed(sub, m) {
+		return en.Subscription{}, errors.Wrap(en.ErrSubscriptionNotFound, op)
+	}
+	before := sub
+	if !step(&sub, m) {
+		return en.Subscription{}, errors.Wrap(en.ErrSubscriptionNotFound, op)
+	}
+	s.subs[id] = sub
+	s.syncCharges(sub)
+	s.record(tenantID, audit, &before, &sub)
+	return sub, nil
+}
+
+// lapsed mirrors the postgres lapsedCondition.
+func lapsed(sub en.Subscription, month time.Time) bool {
+	if sub.DeletedAt != nil || sub.ExpiredAt != nil {
+		return false
+	}
+	if sub.EndDate != "" {
+		end, err := time.Parse(monthLayout, sub.EndDate)
+		return err == nil && end.Before(month)
+	}
+	start, err := time.Parse(monthLayout, sub.StartDate)
+	if err != nil || start.After(month) {
+		return false
+	}
+	billed, err := time.Parse(monthLayout, sub.BilledThrough)
+	return err != nil || billed.Before(month)
+}
+
+// GetTotalByPeriod sums the user's charges like the postgres storage does.
+func (s *Storage) GetTotalByPeriod(ctx context.Context, userID string, serviceName string, startDate, endDate string, mode en.CostMode) (int, error) {
+	charges, err := s.ListCharges(ctx, userID, serviceName, startDate, endDate)
+	if err != nil {
+		return 0, errors.Wrap(err, "testutil.GetTotalByPeriod")
+	}
+	total := 0
+	for _, c := range charges {
+		switch {
+		case mode == en.CostModeActual:
+			if (c.Status == en.ChargeStatusPaid || c.Status == en.ChargeStatusDisputed) && c.PaidAmount != nil {
+				total += *c.PaidAmount
+			}
+		case c.Status != en.ChargeStatusSkipped:
+			total += c.Amount
+		}
+	}
+	return total, nil
+}
+
+func (s *Storage) GetTotalByService(ctx context.Context, userID string, startDate, endDate string) ([]en.ServiceCost, error) {
+	charges, err := s.ListCharges(ctx, userID, "", startDate, endDate)
+	if err != nil {
+		return nil, errors.Wrap(err, "testutil.GetTotalByService")
+	}
+	totals := make(map[string]int)
+	for _, c := range charges {
+		if c.Status != en.ChargeStatusSkipped {
+			totals[c.ServiceName] += c.Amount
+		}
+	}
+	var costs []en.ServiceCost
+	for name, total := range totals {
+		costs = append(costs, en.ServiceCost{ServiceName: name, TotalCost: total})
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].TotalCost != costs[j].TotalCost {
+			return costs[i].TotalCost > costs[j].TotalCost
+		}
+		return costs[i].ServiceName < costs[j].ServiceName
+	})
+	return costs, nil
+}
+
+func (s *Storage) ListCharges(ctx context.Context, userID, serviceName, startDate, endDate string) ([]en.Charge, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "testutil.ListCharges")
+	}
+	start, err := time.Parse(monthLayout, startDate)
+	if err != nil {
+		return nil, errors.Wrapf(en.ErrInvalidPeriod, "start date %q", startDate)
+	}
+	end, err := time.Parse(monthLayout, endDate)
+	if err != nil {
+		return nil, errors.Wrapf(en.ErrInvalidPeriod, "end date %q", endDate)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	charges := []en.Charge{}
+	for _, byPeriod := range s.charges {
+		for _, c := range byPeriod {
+			period, _ := time.Parse(monthLayout, c.Period)
+			if c.TenantID == tenantID && c.UserID == userID && !period.Before(start) && !period.After(end) &&
+				(serviceName == "" || c.ServiceName == serviceName) && s.live(c) {
+				charges = append(charges, c)
+			}
+		}
+	}
+	sort.Slice(charges, func(i, j int) bool {
+		pi, _ := time.Parse(monthLayout, charges[i].Period)
+		pj, _ := time.Parse(monthLayout, charges[j].Period)
+		if !pi.Equal(pj) {
+			return pi.Before(pj)
+		}
+		if charges[i].ServiceName != charges[j].ServiceName {
+			return charges[i].ServiceName < charges[j].ServiceName
+		}
+		return charges[i].ID < charges[j].ID
+	})
+	return charges, nil
+}
+
+func (s *Storage) GetCharge(ctx context.Context, subscriptionID int64, period string) (en.Charge, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.Charge{}, errors.Wrap(err, "testutil.GetCharge")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.charges[subscriptionID][period]
+	if !ok || c.TenantID != tenantID || c.SubscriptionID != subscriptionID || !s.live(c) {
+		return en.Charge{}, errors.Wrap(en.ErrChargeNotFound, "testutil.GetCharge")
+	}
+	return c, nil
+}
+
+func (s *Storage) UpdateChargeStatus(ctx context.Context, charge en.Charge, from en.ChargeStatus) (en.Charge, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.Charge{}, errors.Wrap(err, "testutil.UpdateChargeStatus")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.charges[charge.SubscriptionID][charge.Period]
+	if !ok || c.TenantID != tenantID || c.ID != charge.ID || c.Status != from {
+		return en.Charge{}, errors.Wrap(en.ErrChargeTransition, "testutil.UpdateChargeStatus")
+	}
+	c.Status, c.PaidAmount, c.PaidOn = charge.Status, charge.PaidAmount, charge.PaidOn
+	c.UpdatedAt = s.now()
+	s.charges[c.SubscriptionID][c.Period] = c
+	return c, nil
+}
+
+// live reports whether c was left by a purged subscription or its
+// subscription is not deleted; only such charges are read, as in the
+// postgres storage.
+func (s *Storage) live(c en.Charge) bool {
+	if c.SubscriptionID == 0 {
+		return true
+	}
+	sub, ok := s.subs[c.SubscriptionID]
+	return ok && sub.DeletedAt == nil
+}
+
+// syncCharges applies the rules of the sync_subscription_charges trigger: the
+// subscription is billed for every month from its start through its end, or
+// the month it is billed through, and no later than the month it was deleted
+// in. Expected charges outside that range are removed and those of the
+// current and later months follow the price.
+func (s *Storage) syncCharges(sub en.Subscription) {
+	first, err := time.Parse(monthLayout, sub.StartDate)
+	if err != nil {
+		return
+	}
+	last := first
+	if end, err := time.Parse(monthLayout, sub.EndDate); err == nil {
+		last = end
+	} else if billed, err := time.Parse(monthLayout, sub.BilledThrough); err == nil {
+		last = billed
+	}
+	if sub.DeletedAt != nil {
+		if deleted := monthOf(*sub.DeletedAt); deleted.Before(last) {
+			last = deleted
+		}
+	}
+
+	byPeriod := s.charges[sub.ID]
+	if byPeriod == nil {
+		byPeriod = make(map[string]en.Charge)
+		s.charges[sub.ID] = byPeriod
+	}
+	current := s.month()
+	for period, c := range byPeriod {
+		if c.Status != en.ChargeStatusExpected {
+			continue
+		}
+		m, _ := time.Parse(monthLayout, period)
+		switch {
+		case m.Before(first) || m.After(last):
+			delete(byPeriod, period)
+		case !m.Before(current) && c.Amount != sub.Price:
+			c.Amount = sub.Price
+			c.UpdatedAt = s.now()
+			byPeriod[period] = c
+		}
+	}
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		period := m.Format(monthLayout)
+		if _, ok := byPeriod[period]; ok {
+			continue
+		}
+		s.nextID++
+		now := s.now()
+		byPeriod[period] = en.Charge{
+			ID:             s.nextID,
+			TenantID:       sub.TenantID,
+			SubscriptionID: sub.ID,
+			UserID:         sub.UserID,
+			ServiceName:    sub.ServiceName,
+			Period:         period,
+			Amount:         sub.Price,
+			Currency:       defaultCurrency,
+			Status:         en.ChargeStatusExpected,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+	}
+}
+
+// CreateBudget fails with en.ErrBudgetExists when the user has a budget of
+// the same name.
+func (s *Storage) CreateBudget(ctx context.Context, budget en.Budget) (en.Budget, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.Budget{}, errors.Wrap(err, "testutil.CreateBudget")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.budgetNameTaken(tenantID, budget) {
+		return en.Budget{}, errors.Wrap(en.ErrBudgetExists, "testutil.CreateBudget")
+	}
+	s.nextID++
+	now := s.now()
+	budget.ID = s.nextID
+	budget.TenantID = tenantID
+	budget.AlertedPeriod = ""
+	budget.CreatedAt, budget.UpdatedAt = now, now
+	s.budgets[budget.ID] = budget
+	return budget, nil
+}
+
+func (s *Storage) ListBudgets(ctx context.Context, userID string) ([]en.Budget, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "testutil.ListBudgets")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.userBudgets(tenantID, userID), nil
+}
+
+func (s *Storage) UpdateBudget(ctx context.Context, budget en.Budget) (en.Budget, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return en.Budget{}, errors.Wrap(err, "testutil.UpdateBudget")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.budgets[budget.ID]
+	if !ok || old.TenantID != tenantID || old.UserID != budget.UserID {
+		return en.Budget{}, errors.Wrap(en.ErrBudgetNotFound, "testutil.UpdateBudget")
+	}
+	if s.budgetNameTaken(tenantID, budget) {
+		return en.Budget{}, errors.Wrap(en.ErrBudgetExists, "testutil.UpdateBudget")
+	}
+	budget.TenantID = tenantID
+	budget.AlertedPeriod = ""
+	budget.CreatedAt, budget.UpdatedAt = old.CreatedAt, s.now()
+	s.budgets[budget.ID] = budget
+	return budget, nil
+}
+
+func (s *Storage) DeleteBudget(ctx context.Context, userID string, id int64) error {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "testutil.DeleteBudget")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.budgets[id]
+	if !ok || b.TenantID != tenantID || b.UserID != userID {
+		return errors.Wrap(en.ErrBudgetNotFound, "testutil.DeleteBudget")
+	}
+	delete(s.budgets, id)
+	return nil
+}
+
+// ListBudgetStatuses adds up the charges of the period covered by each of the
+// user's budgets like the postgres storage does.
+func (s *Storage) ListBudgetStatuses(ctx context.Context, userID, period string) ([]en.BudgetStatus, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "testutil.ListBudgetStatuses")
+	}
+	if _, err := time.Parse(monthLayout, period); err != nil {
+		return nil, errors.Wrapf(en.ErrInvalidPeriod, "period %q", period)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := []en.BudgetStatus{}
+	for _, b := range s.userBudgets(tenantID, userID) {
+		status := en.BudgetStatus{Budget: b, Period: period}
+		for _, byPeriod := range s.charges {
+			c, ok := byPeriod[period]
+			if !ok || c.TenantID != tenantID || c.UserID != userID || c.Currency != b.Currency || !b.Covers(c.ServiceName) || !s.live(c) {
+				continue
+			}
+			switch c.Status {
+			case en.ChargeStatusPaid, en.ChargeStatusDisputed:
+				if c.PaidAmount != nil {
+					status.Spent += *c.PaidAmount
+					status.Projected += *c.PaidAmount
+				}
+			case en.ChargeStatusExpected:
+				status.Projected += c.Amount
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// AlertBudget marks the budget alerted and keeps the event it would record
+// in the outbox; see BudgetAlerts.
+func (s *Storage) AlertBudget(ctx context.Context, status en.BudgetStatus, subscriptionID int64) (bool, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "testutil.AlertBudget")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.budgets[status.ID]
+	if !ok || b.TenantID != tenantID || b.AlertedPeriod == status.Period {
+		return false, nil
+	}
+	sub, ok := s.subs[subscriptionID]
+	if !ok || sub.TenantID != tenantID {
+		return false, errors.Wrap(en.ErrSubscriptionNotFound, "testutil.AlertBudget")
+	}
+	b.AlertedPeriod = status.Period
+	s.budgets[status.ID] = b
+	s.alerts = append(s.alerts, en.Event{
+		ID:           uuid.NewString(),
+		Type:         en.EventBudgetExceeded,
+		TenantID:     tenantID,
+		UserID:       sub.UserID,
+		Subscription: sub,
+		Budget:       &status,
+		OccurredAt:   s.now(),
+	})
+	return true, nil
+}
+
+// BudgetAlerts returns the budget.exceeded events recorded so far, oldest
+// first.
+func (s *Storage) BudgetAlerts() []en.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]en.Event(nil), s.alerts...)
+}
+
+func (s *Storage) budgetNameTaken(tenantID string, budget en.Budget) bool {
+	for _, other := range s.budgets {
+		if other.TenantID == tenantID && other.UserID == budget.UserID && other.Name == budget.Name && other.ID != budget.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) userBudgets(tenantID, userID string) []en.Budget {
+	var budgets []en.Budget
+	for _, b := range s.budgets {
+		if b.TenantID == tenantID && b.UserID == userID {
+			budgets = append(budgets, b)
+		}
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].ID < budgets[j].ID })
+	return budgets
+}
+
+// ListAudit returns the tenant's audit entries matching filter, newest first.
+func (s *Storage) ListAudit(ctx context.Context, filter en.AuditFilter) ([]en.AuditEntry, error) {
+	tenantID, err := en.TenantFromContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "testutil.ListAudit")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []en.AuditEntry{}
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		row := s.audit[i]
+		e := row.entry
+		if row.tenantID != tenantID ||
+			(filter.SubscriptionID != 0 && e.SubscriptionID != filter.SubscriptionID) ||
+			(filter.UserID != "" && e.UserID != filter.UserID) ||
+			(filter.Principal != "" && e.Principal != filter.Principal) ||
+			(filter.Actor != "" && e.Actor != filter.Actor) ||
+			(filter.Action != "" && e.Action != filter.Action) ||
+			(!filter.From.IsZero() && e.CreatedAt.Before(filter.From)) ||
+			(!filter.To.IsZero() && !e.CreatedAt.Before(filter.To)) ||
+			(filter.BeforeID != 0 && e.ID >= filter.BeforeID) {
+			continue
+		}
+		entries = append(entries, e)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// record appends an audit entry with snapshots of the subscription before
+// and after the change.
+func (s *Storage) record(tenantID string, entry en.AuditEntry, before, after *en.Subscription) {
+	s.nextID++
+	entry.ID = s.nextID
+	entry.CreatedAt = s.now()
+	if before != nil {
+		entry.SubscriptionID, entry.UserID = before.ID, before.UserID
+		entry.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		entry.SubscriptionID, entry.UserID = after.ID, after.UserID
+		entry.After, _ = json.Marshal(after)
+	}
+	s.audit = append(s.audit, auditRow{tenantID: tenantID, entry: entry})
+}
+
+// sorted returns the subscriptions of tenantID, or of every tenant when it
+// is empty, ordered by ID.
+func (s *Storage) sorted(tenantID string) []en.Subscription {
+	var subs []en.Subscription
+	for _, sub := range s.subs {
+		if tenantID == "" || sub.TenantID == tenantID {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs
+}
+
+// month is the first day of the current month.
+func (s *Storage) month() time.Time {
+	return monthOf(s.now())
+}
+
+func monthOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -1 +1,3 @@
-DROP TABLE IF EXISTS subscriptions;
\ No newline at end of file
+DROP INDEX IF EXISTS idx_subs_user_service;
+DROP INDEX IF EXISTS idx_subs_user;
+DROP TABLE IF EXISTS subscriptions;
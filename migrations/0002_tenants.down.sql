@@ -0,0 +1,14 @@
+DROP POLICY IF EXISTS subscriptions_tenant_isolation ON subscriptions;
+ALTER TABLE subscriptions NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE subscriptions DISABLE ROW LEVEL SECURITY;
+
+DROP INDEX IF EXISTS idx_subs_tenant_user_service;
+DROP INDEX IF EXISTS idx_subs_tenant_user;
+CREATE INDEX idx_subs_user ON subscriptions(user_id);
+CREATE INDEX idx_subs_user_service ON subscriptions(user_id, service_name);
+
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_tenant_user_service_start_key;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_user_id_service_name_start_date_key
+    UNIQUE (user_id, service_name, start_date);
+
+ALTER TABLE subscriptions DROP COLUMN tenant_id;
@@ -0,0 +1,20 @@
+-- Policies do not apply to superusers or roles with BYPASSRLS: run the service
+-- under a regular role. Queries in PgxStorage also filter on tenant_id explicitly.
+ALTER TABLE subscriptions ADD COLUMN tenant_id text NOT NULL DEFAULT 'default';
+ALTER TABLE subscriptions ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_user_id_service_name_start_date_key;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_tenant_user_service_start_key
+    UNIQUE (tenant_id, user_id, service_name, start_date);
+
+DROP INDEX IF EXISTS idx_subs_user;
+DROP INDEX IF EXISTS idx_subs_user_service;
+CREATE INDEX idx_subs_tenant_user ON subscriptions(tenant_id, user_id);
+CREATE INDEX idx_subs_tenant_user_service ON subscriptions(tenant_id, user_id, service_name);
+
+ALTER TABLE subscriptions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subscriptions FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY subscriptions_tenant_isolation ON subscriptions
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
@@ -0,0 +1,33 @@
+CREATE TABLE subscription_audit(
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL,
+    subscription_id bigint NOT NULL,
+    user_id uuid NOT NULL,
+    action text NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    actor text NOT NULL,
+    request_id text NOT NULL DEFAULT '',
+    before jsonb,
+    after jsonb,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_tenant_subscription ON subscription_audit(tenant_id, subscription_id, created_at);
+CREATE INDEX idx_audit_tenant_user ON subscription_audit(tenant_id, user_id, created_at);
+CREATE INDEX idx_audit_tenant_created ON subscription_audit(tenant_id, created_at);
+
+CREATE FUNCTION subscription_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'subscription_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscription_audit_append_only
+    BEFORE UPDATE OR DELETE ON subscription_audit
+    FOR EACH ROW EXECUTE FUNCTION subscription_audit_append_only();
+
+ALTER TABLE subscription_audit ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subscription_audit FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY subscription_audit_tenant_isolation ON subscription_audit
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
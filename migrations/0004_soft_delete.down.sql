@@ -0,0 +1,28 @@
+-- Restore entries can not be removed from the append-only audit log, so the
+-- old check is only validated when none exist.
+ALTER TABLE subscription_audit DROP CONSTRAINT subscription_audit_action_check;
+ALTER TABLE subscription_audit ADD CONSTRAINT subscription_audit_action_check
+    CHECK (action IN ('create', 'update', 'delete')) NOT VALID;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM subscription_audit WHERE action = 'restore') THEN
+        ALTER TABLE subscription_audit VALIDATE CONSTRAINT subscription_audit_action_check;
+    END IF;
+END;
+$$;
+
+DROP POLICY IF EXISTS subscriptions_retention_purge ON subscriptions;
+
+DROP INDEX IF EXISTS idx_subs_deleted_at;
+DROP INDEX IF EXISTS subscriptions_tenant_user_service_start_key;
+
+-- Soft-deleted rows have no place in the previous schema.
+ALTER TABLE subscriptions DISABLE ROW LEVEL SECURITY;
+ALTER TABLE subscriptions NO FORCE ROW LEVEL SECURITY;
+DELETE FROM subscriptions WHERE deleted_at IS NOT NULL;
+ALTER TABLE subscriptions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subscriptions FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_tenant_user_service_start_key
+    UNIQUE (tenant_id, user_id, service_name, start_date);
+ALTER TABLE subscriptions DROP COLUMN deleted_at;
@@ -0,0 +1,19 @@
+ALTER TABLE subscriptions ADD COLUMN deleted_at timestamptz;
+
+-- A soft-deleted subscription must not block re-creating the same one.
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_tenant_user_service_start_key;
+CREATE UNIQUE INDEX subscriptions_tenant_user_service_start_key
+    ON subscriptions(tenant_id, user_id, service_name, start_date)
+    WHERE deleted_at IS NULL;
+
+CREATE INDEX idx_subs_deleted_at ON subscriptions(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- The retention purge works across tenants, but may only ever remove rows
+-- that were already soft-deleted.
+CREATE POLICY subscriptions_retention_purge ON subscriptions
+    FOR DELETE
+    USING (current_setting('app.retention_purge', true) = 'on' AND deleted_at IS NOT NULL);
+
+ALTER TABLE subscription_audit DROP CONSTRAINT subscription_audit_action_check;
+ALTER TABLE subscription_audit ADD CONSTRAINT subscription_audit_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore'));
@@ -0,0 +1,5 @@
+CREATE UNLOGGED TABLE rate_limit_buckets(
+    key text PRIMARY KEY,
+    tokens double precision NOT NULL,
+    updated_at timestamptz NOT NULL
+);
@@ -0,0 +1 @@
+DROP POLICY IF EXISTS subscriptions_stats_read ON subscriptions;
@@ -0,0 +1,5 @@
+-- Cross-tenant aggregates for monitoring are read in a transaction that sets
+-- app.stats_scope; such transactions only ever run aggregate queries.
+CREATE POLICY subscriptions_stats_read ON subscriptions
+    FOR SELECT
+    USING (current_setting('app.stats_scope', true) = 'on');
@@ -0,0 +1,4 @@
+DROP POLICY IF EXISTS subscriptions_renewal_scan ON subscriptions;
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
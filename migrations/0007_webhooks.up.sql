@@ -0,0 +1,65 @@
+CREATE TABLE webhooks(
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL,
+    -- NULL subscribes to events of every user of the tenant.
+    user_id uuid,
+    url text NOT NULL,
+    secret text NOT NULL,
+    -- Empty subscribes to every event type.
+    events text[] NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhooks_tenant_user ON webhooks(tenant_id, user_id);
+
+CREATE TABLE webhook_deliveries(
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL,
+    webhook_id bigint NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id text NOT NULL,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_attempt_at timestamptz,
+    response_status integer,
+    last_error text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    -- An event is delivered to each endpoint at most once, even if it is
+    -- published again (e.g. renewal announcements on every scan).
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at);
+
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhooks FORCE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY webhooks_tenant_isolation ON webhooks
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY webhook_deliveries_tenant_isolation ON webhook_deliveries
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- The dispatcher works across tenants: it reads endpoints and claims and
+-- updates due deliveries, but never creates or removes anything.
+CREATE POLICY webhooks_dispatch_read ON webhooks
+    FOR SELECT
+    USING (current_setting('app.webhook_dispatch', true) = 'on');
+CREATE POLICY webhook_deliveries_dispatch_read ON webhook_deliveries
+    FOR SELECT
+    USING (current_setting('app.webhook_dispatch', true) = 'on');
+CREATE POLICY webhook_deliveries_dispatch_update ON webhook_deliveries
+    FOR UPDATE
+    USING (current_setting('app.webhook_dispatch', true) = 'on')
+    WITH CHECK (current_setting('app.webhook_dispatch', true) = 'on');
+
+-- Renewal announcements scan subscriptions of every tenant that end this month.
+CREATE POLICY subscriptions_renewal_scan ON subscriptions
+    FOR SELECT
+    USING (current_setting('app.renewal_scan', true) = 'on');
@@ -0,0 +1,41 @@
+-- Events of subscription mutations, written in the mutation's transaction and
+-- published by the relay afterwards. Published rows are kept for a while so
+-- consumers can catch up on recent history.
+CREATE TABLE outbox(
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL,
+    event_id uuid NOT NULL DEFAULT gen_random_uuid() UNIQUE,
+    event_type text NOT NULL,
+    subscription_id bigint NOT NULL,
+    user_id uuid NOT NULL,
+    snapshot jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    published_at timestamptz,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_error text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_outbox_pending ON outbox(next_attempt_at, id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_pending_subscription ON outbox(tenant_id, subscription_id, id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_published ON outbox(published_at) WHERE published_at IS NOT NULL;
+
+ALTER TABLE outbox ENABLE ROW LEVEL SECURITY;
+ALTER TABLE outbox FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY outbox_tenant_isolation ON outbox
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- The relay publishes events of every tenant: it reads and marks pending rows
+-- and removes published ones after the retention period.
+CREATE POLICY outbox_relay_read ON outbox
+    FOR SELECT
+    USING (current_setting('app.outbox_relay', true) = 'on');
+CREATE POLICY outbox_relay_update ON outbox
+    FOR UPDATE
+    USING (current_setting('app.outbox_relay', true) = 'on')
+    WITH CHECK (current_setting('app.outbox_relay', true) = 'on');
+CREATE POLICY outbox_relay_purge ON outbox
+    FOR DELETE
+    USING (current_setting('app.outbox_relay', true) = 'on' AND published_at IS NOT NULL);
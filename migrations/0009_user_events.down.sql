@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS subscriptions_record_event ON subscriptions;
+DROP FUNCTION IF EXISTS record_subscription_event();
+DROP TABLE IF EXISTS user_events;
+DROP FUNCTION IF EXISTS notify_user_event();
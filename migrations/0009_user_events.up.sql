@@ -0,0 +1,74 @@
+-- Per-user change feed streamed to dashboards over SSE. The id is the SSE
+-- event id, so clients resume with Last-Event-ID after a reconnect.
+CREATE TABLE user_events(
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL,
+    event_id uuid NOT NULL DEFAULT gen_random_uuid() UNIQUE,
+    event_type text NOT NULL,
+    user_id uuid NOT NULL,
+    subscription_id bigint NOT NULL,
+    snapshot jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_user_events_user ON user_events(tenant_id, user_id, id);
+CREATE INDEX idx_user_events_created ON user_events(created_at);
+
+ALTER TABLE user_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE user_events FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY user_events_tenant_isolation ON user_events
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- The feed retention removes old events of every tenant.
+CREATE POLICY user_events_retention_purge ON user_events
+    FOR DELETE
+    USING (current_setting('app.feed_retention', true) = 'on');
+
+-- Every change of a subscription lands in its owner's feed, whichever code
+-- path made it. Events of one user are serialized by an advisory lock held
+-- until commit, so they become visible in id order and a reader that has
+-- seen id N never misses a later-committed event below N.
+CREATE FUNCTION record_subscription_event() RETURNS trigger AS $$
+DECLARE
+    kind text;
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD IS NOT DISTINCT FROM NEW THEN
+        RETURN NULL;
+    ELSIF TG_OP = 'INSERT' THEN
+        kind := 'subscription.created';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        kind := 'subscription.deleted';
+    ELSE
+        kind := 'subscription.updated';
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext('user_events'), hashtext(NEW.tenant_id || '/' || NEW.user_id::text));
+    INSERT INTO user_events (tenant_id, event_type, user_id, subscription_id, snapshot)
+    VALUES (NEW.tenant_id, kind, NEW.user_id, NEW.id, to_jsonb(NEW));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscriptions_record_event
+    AFTER INSERT OR UPDATE ON subscriptions
+    FOR EACH ROW
+    EXECUTE FUNCTION record_subscription_event();
+
+-- Listeners on every replica learn which feed has news; NOTIFY is delivered
+-- on commit only, and the readers fetch the events themselves.
+CREATE FUNCTION notify_user_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('user_events', json_build_object(
+        'id', NEW.id,
+        'tenant_id', NEW.tenant_id,
+        'user_id', NEW.user_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER user_events_notify
+    AFTER INSERT ON user_events
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_user_event();
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS renewal_notifications;
+DROP TABLE IF EXISTS notification_settings;
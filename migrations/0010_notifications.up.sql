@@ -0,0 +1,46 @@
+-- Users' preferences for renewal reminders.
+CREATE TABLE notification_settings(
+    tenant_id text NOT NULL,
+    user_id uuid NOT NULL,
+    email text NOT NULL,
+    locale text NOT NULL DEFAULT 'en',
+    lead_days integer NOT NULL DEFAULT 3 CHECK (lead_days BETWEEN 1 AND 28),
+    enabled boolean NOT NULL DEFAULT true,
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, user_id)
+);
+
+-- One row per reminder sent, so a renewal is announced once per channel no
+-- matter how often the scheduler runs or how many replicas run it.
+CREATE TABLE renewal_notifications(
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL,
+    subscription_id bigint NOT NULL,
+    user_id uuid NOT NULL,
+    channel text NOT NULL,
+    renews_on date NOT NULL,
+    sent_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (tenant_id, subscription_id, channel, renews_on)
+);
+
+CREATE INDEX idx_renewal_notifications_user ON renewal_notifications(tenant_id, user_id, sent_at);
+
+ALTER TABLE notification_settings ENABLE ROW LEVEL SECURITY;
+ALTER TABLE notification_settings FORCE ROW LEVEL SECURITY;
+ALTER TABLE renewal_notifications ENABLE ROW LEVEL SECURITY;
+ALTER TABLE renewal_notifications FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY notification_settings_tenant_isolation ON notification_settings
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY renewal_notifications_tenant_isolation ON renewal_notifications
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- The reminder scan looks for due renewals of every tenant.
+CREATE POLICY notification_settings_renewal_scan ON notification_settings
+    FOR SELECT
+    USING (current_setting('app.renewal_scan', true) = 'on');
+CREATE POLICY renewal_notifications_renewal_scan ON renewal_notifications
+    FOR SELECT
+    USING (current_setting('app.renewal_scan', true) = 'on');
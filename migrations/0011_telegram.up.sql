@@ -0,0 +1,44 @@
+-- Telegram chats linked to users, at most one chat per user and one user per chat.
+CREATE TABLE telegram_chats(
+    tenant_id text NOT NULL,
+    user_id uuid NOT NULL,
+    chat_id bigint NOT NULL UNIQUE,
+    locale text NOT NULL DEFAULT 'en',
+    linked_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, user_id)
+);
+
+-- One-time codes a user sends to the bot to link a chat; only hashes are stored.
+CREATE TABLE telegram_link_codes(
+    code_hash text PRIMARY KEY,
+    tenant_id text NOT NULL,
+    user_id uuid NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_telegram_link_codes_user ON telegram_link_codes(tenant_id, user_id);
+
+ALTER TABLE telegram_chats ENABLE ROW LEVEL SECURITY;
+ALTER TABLE telegram_chats FORCE ROW LEVEL SECURITY;
+ALTER TABLE telegram_link_codes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE telegram_link_codes FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY telegram_chats_tenant_isolation ON telegram_chats
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY telegram_link_codes_tenant_isolation ON telegram_link_codes
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- A chat is not bound to a tenant until it is linked: the bot resolves
+-- chats and redeems codes across tenants.
+CREATE POLICY telegram_chats_chat_link ON telegram_chats
+    USING (current_setting('app.chat_link', true) = 'on')
+    WITH CHECK (current_setting('app.chat_link', true) = 'on');
+CREATE POLICY telegram_link_codes_chat_link ON telegram_link_codes
+    USING (current_setting('app.chat_link', true) = 'on');
+
+-- The reminder scan finds users reachable through Telegram.
+CREATE POLICY telegram_chats_renewal_scan ON telegram_chats
+    FOR SELECT
+    USING (current_setting('app.renewal_scan', true) = 'on');
@@ -0,0 +1,18 @@
+-- History of background job runs on all replicas. The scheduler also uses it
+-- to run each scheduled time of a job once: a replica skips the run when a
+-- run for that time or a later one is already recorded.
+CREATE TABLE job_runs(
+    id bigserial PRIMARY KEY,
+    job text NOT NULL,
+    trigger text NOT NULL CHECK (trigger IN ('schedule', 'manual')),
+    scheduled_at timestamptz NOT NULL,
+    started_at timestamptz NOT NULL DEFAULT now(),
+    finished_at timestamptz,
+    status text NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed')),
+    error text NOT NULL DEFAULT '',
+    instance text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_job_runs_job ON job_runs(job, id);
+CREATE INDEX idx_job_runs_scheduled ON job_runs(job, scheduled_at) WHERE trigger = 'schedule';
+CREATE INDEX idx_job_runs_started ON job_runs(started_at);
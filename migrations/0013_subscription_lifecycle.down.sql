@@ -0,0 +1,38 @@
+CREATE OR REPLACE FUNCTION record_subscription_event() RETURNS trigger AS $$
+DECLARE
+    kind text;
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD IS NOT DISTINCT FROM NEW THEN
+        RETURN NULL;
+    ELSIF TG_OP = 'INSERT' THEN
+        kind := 'subscription.created';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        kind := 'subscription.deleted';
+    ELSE
+        kind := 'subscription.updated';
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext('user_events'), hashtext(NEW.tenant_id || '/' || NEW.user_id::text));
+    INSERT INTO user_events (tenant_id, event_type, user_id, subscription_id, snapshot)
+    VALUES (NEW.tenant_id, kind, NEW.user_id, NEW.id, to_jsonb(NEW));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Like restore entries in 0004, renewals and expiries stay in the
+-- append-only audit log, so the old check is only validated without them.
+ALTER TABLE subscription_audit DROP CONSTRAINT subscription_audit_action_check;
+ALTER TABLE subscription_audit ADD CONSTRAINT subscription_audit_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore')) NOT VALID;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM subscription_audit WHERE action IN ('renew', 'expire')) THEN
+        ALTER TABLE subscription_audit VALIDATE CONSTRAINT subscription_audit_action_check;
+    END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS idx_subs_lifecycle;
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS billed_through,
+    DROP COLUMN IF EXISTS expired_at,
+    DROP COLUMN IF EXISTS auto_renew;
@@ -0,0 +1,37 @@
+-- A subscription past its end month expires, unless it renews automatically
+-- by one month at a time. billed_through is the last month the lifecycle job
+-- charged: the new end month of a renewal, or the current month of an
+-- open-ended subscription.
+ALTER TABLE subscriptions
+    ADD COLUMN auto_renew boolean NOT NULL DEFAULT false,
+    ADD COLUMN expired_at timestamptz,
+    ADD COLUMN billed_through date;
+
+CREATE INDEX idx_subs_lifecycle ON subscriptions(id)
+    WHERE deleted_at IS NULL AND expired_at IS NULL;
+
+ALTER TABLE subscription_audit DROP CONSTRAINT subscription_audit_action_check;
+ALTER TABLE subscription_audit ADD CONSTRAINT subscription_audit_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore', 'renew', 'expire'));
+
+-- The lifecycle job names the feed event of its changes in the
+-- app.subscription_event setting of its transaction.
+CREATE OR REPLACE FUNCTION record_subscription_event() RETURNS trigger AS $$
+DECLARE
+    kind text;
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD IS NOT DISTINCT FROM NEW THEN
+        RETURN NULL;
+    ELSIF TG_OP = 'INSERT' THEN
+        kind := 'subscription.created';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        kind := 'subscription.deleted';
+    ELSE
+        kind := COALESCE(NULLIF(current_setting('app.subscription_event', true), ''), 'subscription.updated');
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext('user_events'), hashtext(NEW.tenant_id || '/' || NEW.user_id::text));
+    INSERT INTO user_events (tenant_id, event_type, user_id, subscription_id, snapshot)
+    VALUES (NEW.tenant_id, kind, NEW.user_id, NEW.id, to_jsonb(NEW));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS subscriptions_sync_charges ON subscriptions;
+DROP FUNCTION IF EXISTS subscription_charges_trigger();
+DROP FUNCTION IF EXISTS sync_subscription_charges(subscriptions);
+DROP TABLE IF EXISTS charges;
@@ -0,0 +1,82 @@
+-- The charge ledger: one row per subscription per billed month. Totals and
+-- breakdowns add up charges, so a price change only affects the current and
+-- later months, and charges of past months keep their amount.
+CREATE TABLE charges(
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL,
+    subscription_id bigint NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL,
+    service_name text NOT NULL,
+    -- The first day of the billed month.
+    period date NOT NULL,
+    amount integer NOT NULL,
+    currency text NOT NULL DEFAULT 'RUB',
+    status text NOT NULL DEFAULT 'expected' CHECK (status IN ('expected')),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (subscription_id, period)
+);
+
+CREATE INDEX idx_charges_tenant_user_period ON charges(tenant_id, user_id, period);
+
+ALTER TABLE charges ENABLE ROW LEVEL SECURITY;
+ALTER TABLE charges FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY charges_tenant_isolation ON charges
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- A subscription is billed for every month from its start month through its
+-- end month or, when open-ended, the month it is billed through; a deleted
+-- one no later than the month it was deleted in. Expected charges outside
+-- that range are removed and those of the current and later months follow
+-- the price.
+CREATE FUNCTION sync_subscription_charges(s subscriptions) RETURNS void AS $$
+DECLARE
+    first_period date := to_date(s.start_date, 'MM-YYYY');
+    last_period date := COALESCE(to_date(NULLIF(s.end_date, ''), 'MM-YYYY'), s.billed_through, to_date(s.start_date, 'MM-YYYY'));
+BEGIN
+    IF s.deleted_at IS NOT NULL THEN
+        last_period := LEAST(last_period, date_trunc('month', s.deleted_at)::date);
+    END IF;
+    DELETE FROM charges
+    WHERE subscription_id = s.id AND status = 'expected'
+      AND (period < first_period OR period > last_period);
+    UPDATE charges SET amount = s.price, updated_at = now()
+    WHERE subscription_id = s.id AND status = 'expected'
+      AND period >= date_trunc('month', now()) AND amount <> s.price;
+    INSERT INTO charges (tenant_id, subscription_id, user_id, service_name, period, amount)
+    SELECT s.tenant_id, s.id, s.user_id, s.service_name, p::date, s.price
+    FROM generate_series(first_period, last_period, interval '1 month') p
+    ON CONFLICT (subscription_id, period) DO NOTHING;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION subscription_charges_trigger() RETURNS trigger AS $$
+BEGIN
+    PERFORM sync_subscription_charges(NEW);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Open-ended subscriptions that started are billed through the current month
+-- from now on, like new ones. The backfill runs across tenants and is not a
+-- change users should see in their feeds.
+ALTER TABLE subscriptions NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE charges NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE subscriptions DISABLE TRIGGER subscriptions_record_event;
+
+UPDATE subscriptions SET billed_through = date_trunc('month', now())::date
+WHERE COALESCE(end_date, '') = '' AND expired_at IS NULL
+  AND to_date(start_date, 'MM-YYYY') <= date_trunc('month', now())
+  AND (billed_through IS NULL OR billed_through < date_trunc('month', now()));
+SELECT sync_subscription_charges(s) FROM subscriptions s;
+
+ALTER TABLE subscriptions ENABLE TRIGGER subscriptions_record_event;
+ALTER TABLE subscriptions FORCE ROW LEVEL SECURITY;
+ALTER TABLE charges FORCE ROW LEVEL SECURITY;
+
+CREATE TRIGGER subscriptions_sync_charges
+    AFTER INSERT OR UPDATE OF start_date, end_date, price, deleted_at, billed_through ON subscriptions
+    FOR EACH ROW
+    EXECUTE FUNCTION subscription_charges_trigger();
@@ -0,0 +1,11 @@
+ALTER TABLE charges
+    DROP COLUMN IF EXISTS paid_on,
+    DROP COLUMN IF EXISTS paid_amount;
+
+-- Reconciled charges of every tenant go back to expected.
+ALTER TABLE charges NO FORCE ROW LEVEL SECURITY;
+UPDATE charges SET status = 'expected' WHERE status <> 'expected';
+ALTER TABLE charges FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE charges DROP CONSTRAINT charges_status_check;
+ALTER TABLE charges ADD CONSTRAINT charges_status_check CHECK (status IN ('expected'));
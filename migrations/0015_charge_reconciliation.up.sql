@@ -0,0 +1,8 @@
+-- Users reconcile expected charges with what actually happened: a paid
+-- charge records the amount and the day it was paid.
+ALTER TABLE charges DROP CONSTRAINT charges_status_check;
+ALTER TABLE charges ADD CONSTRAINT charges_status_check
+    CHECK (status IN ('expected', 'paid', 'refunded', 'skipped', 'disputed'));
+ALTER TABLE charges
+    ADD COLUMN paid_amount integer CHECK (paid_amount >= 0),
+    ADD COLUMN paid_on date;
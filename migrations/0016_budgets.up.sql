@@ -0,0 +1,24 @@
+-- Monthly spending limits of a user, over all subscriptions or only those of
+-- the listed services. Spend is read from the charge ledger.
+CREATE TABLE budgets(
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL,
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    monthly_limit integer NOT NULL CHECK (monthly_limit > 0),
+    currency text NOT NULL DEFAULT 'RUB',
+    -- Empty covers every subscription of the user.
+    services text[] NOT NULL DEFAULT '{}',
+    -- The first day of the last month an overspend alert was published for.
+    alerted_period date,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (tenant_id, user_id, name)
+);
+
+ALTER TABLE budgets ENABLE ROW LEVEL SECURITY;
+ALTER TABLE budgets FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY budgets_tenant_isolation ON budgets
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
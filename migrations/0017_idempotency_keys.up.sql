@@ -0,0 +1,26 @@
+-- Responses to POST requests by their Idempotency-Key, replayed to retries of
+-- the same request. status_code is NULL while the first request still runs.
+CREATE TABLE idempotency_keys(
+    tenant_id text NOT NULL,
+    key text NOT NULL,
+    fingerprint text NOT NULL,
+    status_code integer,
+    content_type text NOT NULL DEFAULT '',
+    body bytea,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, key)
+);
+
+CREATE INDEX idx_idempotency_keys_created ON idempotency_keys(created_at);
+
+ALTER TABLE idempotency_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE idempotency_keys FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY idempotency_keys_tenant_isolation ON idempotency_keys
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- The purge job removes old keys of every tenant.
+CREATE POLICY idempotency_keys_purge ON idempotency_keys
+    FOR DELETE
+    USING (current_setting('app.idempotency_purge', true) = 'on');
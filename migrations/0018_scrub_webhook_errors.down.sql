@@ -0,0 +1,2 @@
+-- The scrubbed response bodies are not restored.
+SELECT 1;
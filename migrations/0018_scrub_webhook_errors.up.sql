@@ -0,0 +1,13 @@
+-- Delivery errors used to keep up to 512 bytes of the receiver's response,
+-- which showed webhook owners what internal endpoints answered. Keep only the
+-- status code, as the sender now does.
+ALTER TABLE webhook_deliveries NO FORCE ROW LEVEL SECURITY;
+
+UPDATE webhook_deliveries
+SET last_error = CASE
+        WHEN response_status IS NOT NULL AND response_status > 0 THEN 'receiver answered HTTP ' || response_status
+        ELSE 'send webhook: receiver could not be reached'
+    END
+WHERE last_error <> '';
+
+ALTER TABLE webhook_deliveries FORCE ROW LEVEL SECURITY;
@@ -0,0 +1,8 @@
+ALTER TABLE charges NO FORCE ROW LEVEL SECURITY;
+DELETE FROM charges WHERE subscription_id IS NULL;
+ALTER TABLE charges FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE charges DROP CONSTRAINT charges_subscription_id_fkey;
+ALTER TABLE charges ADD CONSTRAINT charges_subscription_id_fkey
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE;
+ALTER TABLE charges ALTER COLUMN subscription_id SET NOT NULL;
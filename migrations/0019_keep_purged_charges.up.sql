@@ -0,0 +1,8 @@
+-- The ledger outlives its subscriptions: purging a soft-deleted subscription
+-- detaches its charges instead of deleting them. Totals, breakdowns and
+-- budgets count detached charges and those of subscriptions that are not
+-- deleted.
+ALTER TABLE charges ALTER COLUMN subscription_id DROP NOT NULL;
+ALTER TABLE charges DROP CONSTRAINT charges_subscription_id_fkey;
+ALTER TABLE charges ADD CONSTRAINT charges_subscription_id_fkey
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP INDEX idx_audit_tenant_id;
+DROP INDEX idx_audit_tenant_principal;
+ALTER TABLE subscription_audit DROP COLUMN principal;
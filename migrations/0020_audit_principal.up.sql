@@ -0,0 +1,7 @@
+-- The actor is whatever the caller put in X-Actor-ID. The authenticated
+-- principal gets its own column; entries written before it existed keep an
+-- empty principal, as it can not be told apart from a claimed actor.
+ALTER TABLE subscription_audit ADD COLUMN principal text NOT NULL DEFAULT '';
+
+CREATE INDEX idx_audit_tenant_principal ON subscription_audit(tenant_id, principal, id);
+CREATE INDEX idx_audit_tenant_id ON subscription_audit(tenant_id, id);
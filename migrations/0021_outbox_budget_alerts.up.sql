@@ -0,0 +1,4 @@
+-- Budget alerts go through the outbox too: they are written in the
+-- transaction that marks the budget alerted and carry the budget with its
+-- spending, keyed by the subscription whose change triggered them.
+ALTER TABLE outbox ADD COLUMN budget jsonb;
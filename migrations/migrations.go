@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL schema migrations so the binary can apply
+// them regardless of its working directory.
+package migrations
+
+import "embed"
+
+// FS holds the golang-migrate NNNN_name.{up,down}.sql files.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,288 @@
+// Package client is a typed Go client for the subscription aggregator REST API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// Header names understood by the server.
+const (
+	AuthorizationHeader  = "Authorization"
+	TenantHeader         = "X-Tenant-ID"
+	ActorHeader          = "X-Actor-ID"
+	AdminTokenHeader     = "X-Admin-Token"
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// Client calls the API on behalf of one tenant. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	tenantID   string
+	actor      string
+	apiKey     string
+	adminToken string
+	userAgent  string
+
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option customizes optional Client behaviour.
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithActor identifies the caller in the server's audit log.
+func WithActor(actor string) Option {
+	return func(c *Client) {
+		c.actor = actor
+	}
+}
+
+// WithAPIKey authenticates the client with a key issued for its tenant; the
+// server rejects tenant calls without one.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithAdminToken is required for admin-only calls such as ListAudit.
+func WithAdminToken(token string) Option {
+	return func(c *Client) {
+		c.adminToken = token
+	}
+}
+
+// WithUserAgent sets the User-Agent header.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithRetries retries 5xx and 429 responses, POSTs whose idempotency key is
+// still in use and transport errors up to max times with exponential backoff
+// between min and max delay; 0 disables retries.
+func WithRetries(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New creates a client for the API at baseURL acting within tenantID.
+func New(baseURL, tenantID string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, "parse base url")
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("base url %q must be absolute", baseURL)
+	}
+	if tenantID == "" {
+		return nil, ErrTenantRequired
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		tenantID:   tenantID,
+		userAgent:  "subscription-aggregator-go-client",
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes the next POST use key instead of a generated one,
+// e.g. to keep the key stable across application-level retries.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// NewIdempotencyKey returns a random 128-bit key.
+func NewIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// do sends the request, retrying transient failures, and decodes a 2xx JSON
+// body into out when out is not nil. POST requests carry one idempotency key
+// across all attempts.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var (
+		body []byte
+		err  error
+	)
+	if in != nil {
+		if body, err = json.Marshal(in); err != nil {
+			return errors.Wrap(err, "encode request")
+		}
+	}
+	// path segments are already escaped by the callers.
+	u, err := url.Parse(c.baseURL.String() + path)
+	if err != nil {
+		return errors.Wrap(err, "build url")
+	}
+	u.RawQuery = query.Encode()
+
+	var idemKey string
+	if method == http.MethodPost {
+		idemKey, _ = ctx.Value(idempotencyKey{}).(string)
+		if idemKey == "" {
+			idemKey = NewIdempotencyKey()
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return errors.Wrap(err, "build request")
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set(TenantHeader, c.tenantID)
+		if c.apiKey != "" {
+			req.Header.Set(AuthorizationHeader, "Bearer "+c.apiKey)
+		}
+		if c.actor != "" {
+			req.Header.Set(ActorHeader, c.actor)
+		}
+		if c.adminToken != "" {
+			req.Header.Set(AdminTokenHeader, c.adminToken)
+		}
+		if idemKey != "" {
+			req.Header.Set(IdempotencyKeyHeader, idemKey)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		var retryAfter time.Duration
+		if err == nil {
+			if resp.StatusCode < 300 {
+				return decode(resp, out)
+			}
+			apiErr := readAPIError(resp)
+			if !retryable(apiErr) {
+				return apiErr
+			}
+			err, retryAfter = apiErr, apiErr.RetryAfter
+		} else if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), method+" "+path)
+		}
+
+		if attempt >= c.maxRetries {
+			return errors.Wrap(err, method+" "+path)
+		}
+		delay := c.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Wrap(ctx.Err(), method+" "+path)
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable also covers a repeated key whose first request still runs: the
+// retry gets its response once it completes.
+func retryable(err *APIError) bool {
+	return err.StatusCode == http.StatusTooManyRequests || err.StatusCode >= 500 ||
+		errors.Is(err, ErrIdempotencyKeyInUse)
+}
+
+// backoff doubles the delay per attempt, capped at maxBackoff, with full jitter
+// over the upper half so concurrent clients spread out.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := float64(c.minBackoff) * math.Pow(2, float64(attempt))
+	if d > float64(c.maxBackoff) {
+		d = float64(c.maxBackoff)
+	}
+	return time.Duration(d/2 + mrand.Float64()*d/2)
+}
+
+func decode(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrap(err, "decode response")
+	}
+	return nil
+}
+
+// readAPIError reads either an ErrorResponse or an RFC 7807 problem document.
+func readAPIError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var payload struct {
+		pkg.ErrorResponse
+		pkg.ProblemResponse
+	}
+	if json.Unmarshal(data, &payload) == nil {
+		switch {
+		case payload.Error != "":
+			apiErr.Message = payload.Error
+		case payload.Detail != "":
+			apiErr.Message = payload.Detail
+		case payload.Title != "":
+			apiErr.Message = payload.Title
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/100bench/subscription_aggregator/internal/idempotency"
+	"github.com/100bench/subscription_aggregator/internal/ports/http/public"
+	"github.com/100bench/subscription_aggregator/internal/testutil"
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// newRouter is the real public router over a testutil.Storage.
+func newRouter(t *testing.T) http.Handler {
+	t.Helper()
+	server, err := public.NewServer(testutil.NewService(t, nil),
+		public.WithLogger(testutil.Logger()),
+		public.WithAPIKeys(testutil.APIKeys(t)),
+		public.WithIdempotency(idempotency.NewMemoryStore()),
+	)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return server.GetRouter()
+}
+
+func newClient(t *testing.T, h http.Handler, key string) *Client {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	c, err := New(ts.URL, "acme", WithAPIKey(key), WithHTTPClient(ts.Client()), WithRetries(3, time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+// losingFirstResponse serves the first POST but answers it with 502, as a
+// proxy whose connection to the server broke after the request went through.
+func losingFirstResponse(next http.Handler) http.Handler {
+	var once sync.Once
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lost := false
+		if r.Method == http.MethodPost {
+			once.Do(func() { lost = true })
+		}
+		if !lost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(httptest.NewRecorder(), r)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+}
+
+func TestSubscriptionRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newRouter(t), testutil.AcmeKey)
+
+	created, err := c.CreateSubscription(ctx, testutil.CreateSubRequest("Netflix"))
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	price := 899
+	if _, err := c.UpdateSubscription(ctx, testutil.UserID, "Netflix", pkg.UpdateSubRequest{Price: &price}); err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+	got, err := c.GetSubscription(ctx, testutil.UserID, "Netflix")
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if got.ID != created.ID || got.Price != price {
+		t.Errorf("got id %d price %d, want id %d price %d", got.ID, got.Price, created.ID, price)
+	}
+	if err := c.DeleteSubscription(ctx, testutil.UserID, "Netflix"); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+	if subs, err := c.ListSubscriptions(ctx, testutil.UserID, ListOptions{}); err != nil || len(subs) != 0 {
+		t.Fatalf("ListSubscriptions after delete = %v, %v; want none", subs, err)
+	}
+	if _, err := c.RestoreSubscription(ctx, created.ID); err != nil {
+		t.Fatalf("RestoreSubscription: %v", err)
+	}
+	if subs, err := c.ListSubscriptions(ctx, testutil.UserID, ListOptions{}); err != nil || len(subs) != 1 {
+		t.Fatalf("ListSubscriptions after restore = %v, %v; want one", subs, err)
+	}
+}
+
+func TestRetriedCreateReturnsFirstResult(t *testing.T) {
+	ctx := context.Background()
+	router := newRouter(t)
+	c := newClient(t, losingFirstResponse(router), testutil.AcmeKey)
+
+	created, err := c.CreateSubscription(ctx, testutil.CreateSubRequest("Netflix"))
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	subs, err := newClient(t, router, testutil.AcmeKey).ListSubscriptions(ctx, testutil.UserID, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListSubscriptions: %v", err)
+	}
+	if len(subs) != 1 || subs[0].ID != created.ID {
+		t.Errorf("subscriptions = %+v, want only %d", subs, created.ID)
+	}
+}
+
+func TestRetriedRestoreReturnsFirstResult(t *testing.T) {
+	ctx := context.Background()
+	router := newRouter(t)
+	direct := newClient(t, router, testutil.AcmeKey)
+	created, err := direct.CreateSubscription(ctx, testutil.CreateSubRequest("Netflix"))
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	if err := direct.DeleteSubscription(ctx, testutil.UserID, "Netflix"); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+
+	restored, err := newClient(t, losingFirstResponse(router), testutil.AcmeKey).RestoreSubscription(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("RestoreSubscription: %v", err)
+	}
+	if restored.ID != created.ID || restored.DeletedAt != "" {
+		t.Errorf("restored = %+v, want live subscription %d", restored, created.ID)
+	}
+}
+
+func TestErrorsMatchTheServersError(t *testing.T) {
+	ctx := context.Background()
+	router := newRouter(t)
+	c := newClient(t, router, testutil.AcmeKey)
+	if _, err := c.CreateSubscription(ctx, testutil.CreateSubRequest("Netflix")); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	tests := map[string]struct {
+		call    func(c *Client) error
+		client  *Client
+		want    []error
+		notWant []error
+	}{
+		"missing subscription": {
+			call: func(c *Client) error {
+				_, err := c.GetSubscription(ctx, testutil.UserID, "Spotify")
+				return err
+			},
+			want: []error{ErrSubscriptionNotFound, ErrNotFound},
+		},
+		"unknown route": {
+			call: func(c *Client) error {
+				return c.do(ctx, http.MethodGet, "/no-such-route", nil, nil, nil)
+			},
+			want:    []error{ErrNotFound},
+			notWant: []error{ErrSubscriptionNotFound},
+		},
+		"duplicate": {
+			call: func(c *Client) error {
+				_, err := c.CreateSubscription(ctx, testutil.CreateSubRequest("Netflix"))
+				return err
+			},
+			want: []error{ErrSubscriptionExists, ErrConflict},
+		},
+		"invalid key": {
+			call: func(c *Client) error {
+				_, err := c.ListSubscriptions(ctx, testutil.UserID, ListOptions{})
+				return err
+			},
+			client:  newClient(t, router, "not-a-configured-key-0123456789"),
+			want:    []error{ErrUnauthenticated},
+			notWant: []error{ErrTenantRequired},
+		},
+		"admin only": {
+			call: func(c *Client) error {
+				_, err := c.ListAudit(ctx, AuditQuery{})
+				return err
+			},
+			want: []error{ErrForbidden},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := c
+			if tt.client != nil {
+				client = tt.client
+			}
+			err := tt.call(client)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want an *APIError", err)
+			}
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("errors.Is(%v, %v) = false", err, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if errors.Is(err, notWant) {
+					t.Errorf("errors.Is(%v, %v) = true", err, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestRetriesUnavailableServer(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	router := newRouter(t)
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
+
+	if _, err := newClient(t, flaky, testutil.AcmeKey).Health(context.Background()); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sentinel errors mirroring the server's domain errors; match them with errors.Is.
+// They are matched by the error the server reports, so that an unknown route
+// or a missing budget does not pass for a missing subscription.
+var (
+	ErrSubscriptionNotFound = fmt.Errorf("subscription not found")
+	ErrSubscriptionExists   = fmt.Errorf("subscription already exists")
+	ErrTenantRequired       = fmt.Errorf("tenant is required")
+	ErrUnauthenticated      = fmt.Errorf("a valid API key is required")
+	ErrTenantMismatch       = fmt.Errorf("API key does not belong to the requested tenant")
+	ErrForbidden            = fmt.Errorf("admin access required")
+	ErrIdempotencyKeyInUse  = fmt.Errorf("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused = fmt.Errorf("idempotency key was already used for a different request")
+)
+
+// Status errors classify every response with the code; match them with
+// errors.Is when the cause does not matter.
+var (
+	ErrBadRequest  = fmt.Errorf("bad request")
+	ErrNotFound    = fmt.Errorf("not found")
+	ErrConflict    = fmt.Errorf("conflict")
+	ErrRateLimited = fmt.Errorf("rate limit exceeded")
+)
+
+// domainErrors are told apart by the suffix of the server's message, which
+// ends with the domain error however the server wrapped it.
+var domainErrors = map[int][]error{
+	http.StatusNotFound:            {ErrSubscriptionNotFound},
+	http.StatusConflict:            {ErrSubscriptionExists, ErrIdempotencyKeyInUse},
+	http.StatusUnauthorized:        {ErrUnauthenticated},
+	http.StatusForbidden:           {ErrTenantMismatch, ErrForbidden},
+	http.StatusUnprocessableEntity: {ErrIdempotencyKeyReused},
+}
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:      ErrBadRequest,
+	http.StatusNotFound:        ErrNotFound,
+	http.StatusConflict:        ErrConflict,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
+
+// APIError is returned for every non-2xx response that survived retries.
+type APIError struct {
+	StatusCode int
+	// Message is the error or problem detail reported by the server.
+	Message string
+	// RetryAfter is set from the Retry-After header of 429 responses.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("subscription api: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("subscription api: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Unwrap maps the response onto the domain error named by its message and
+// the status error of its code.
+func (e *APIError) Unwrap() []error {
+	var errs []error
+	for _, err := range domainErrors[e.StatusCode] {
+		if strings.HasSuffix(e.Message, err.Error()) {
+			errs = append(errs, err)
+			break
+		}
+	}
+	if err, ok := statusErrors[e.StatusCode]; ok {
+		errs = append(errs, err)
+	}
+	return errs
+}
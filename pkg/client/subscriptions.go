@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	pkg "github.com/100bench/subscription_aggregator/pkg/dto"
+)
+
+// CreateSubscription creates a subscription. A create retried after a lost
+// response carries the same idempotency key, so it returns the subscription
+// the first attempt created rather than ErrSubscriptionExists.
+func (c *Client) CreateSubscription(ctx context.Context, req pkg.CreateSubRequest) (pkg.SubscriptionDTO, error) {
+	var sub pkg.SubscriptionDTO
+	err := c.do(ctx, http.MethodPost, "/subscriptions", nil, req, &sub)
+	return sub, err
+}
+
+func (c *Client) GetSubscription(ctx context.Context, userID, serviceName string) (pkg.SubscriptionDTO, error) {
+	var sub pkg.SubscriptionDTO
+	err := c.do(ctx, http.MethodGet, subscriptionPath(userID, serviceName), nil, nil, &sub)
+	return sub, err
+}
+
+// ListOptions narrow ListSubscriptions.
+type ListOptions struct {
+	// IncludeDeleted also returns soft-deleted subscriptions; requires WithAdminToken.
+	IncludeDeleted bool
+}
+
+func (c *Client) ListSubscriptions(ctx context.Context, userID string, opts ListOptions) ([]pkg.SubscriptionDTO, error) {
+	query := url.Values{}
+	if opts.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+	var resp pkg.GetSubsResponse
+	if err := c.do(ctx, http.MethodGet, "/subscriptions/"+url.PathEscape(userID), query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Subscriptions, nil
+}
+
+// UpdateSubscription changes the non-nil fields of req and returns the updated subscription.
+func (c *Client) UpdateSubscription(ctx context.Context, userID, serviceName string, req pkg.UpdateSubRequest) (pkg.SubscriptionDTO, error) {
+	var sub pkg.SubscriptionDTO
+	err := c.do(ctx, http.MethodPut, subscriptionPath(userID, serviceName), nil, req, &sub)
+	return sub, err
+}
+
+// DeleteSubscription soft-deletes a subscription; RestoreSubscription undoes it.
+func (c *Client) DeleteSubscription(ctx context.Context, userID, serviceName string) error {
+	return c.do(ctx, http.MethodDelete, subscriptionPath(userID, serviceName), nil, nil, nil)
+}
+
+// TotalCost sums subscription prices for the user over the MM-YYYY period.
+func (c *Client) TotalCost(ctx context.Context, req pkg.GetTotalCostRequest) (int, error) {
+	query := url.Values{
+		"user_id":    {req.UserId},
+		"start_date": {req.StartDate},
+		"end_date":   {req.EndDate},
+	}
+	if req.ServiceName != nil {
+		query.Set("service_name", *req.ServiceName)
+	}
+	if req.Mode != "" {
+		query.Set("mode", req.Mode)
+	}
+	var resp pkg.GetTotalCostResponse
+	if err := c.do(ctx, http.MethodGet, "/subscriptions/total-cost", query, nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.TotalCost, nil
+}
+
+func (c *Client) RestoreSubscription(ctx context.Context, id int64) (pkg.SubscriptionDTO, error) {
+	var sub pkg.SubscriptionDTO
+	err := c.do(ctx, http.MethodPost, byIDPath(id)+"/restore", nil, nil, &sub)
+	return sub, err
+}
+
+// SubscriptionAudit returns the audit trail of one subscription, newest first.
+func (c *Client) SubscriptionAudit(ctx context.Context, id int64) ([]pkg.AuditEntryDTO, error) {
+	var resp pkg.GetAuditResponse
+	if err := c.do(ctx, http.MethodGet, byIDPath(id)+"/audit", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+// AuditQuery filters ListAudit; zero fields are not filtered on. Entries
+// come newest first; set BeforeID to the ID of the last entry of a page to
+// get the next one.
+type AuditQuery struct {
+	SubscriptionID int64
+	UserID         string
+	Principal      string
+	Actor          string
+	Action         string
+	From, To       time.Time
+	BeforeID       int64
+	Limit          int
+}
+
+// ListAudit queries the tenant-wide audit log; requires WithAdminToken.
+func (c *Client) ListAudit(ctx context.Context, q AuditQuery) ([]pkg.AuditEntryDTO, error) {
+	query := url.Values{}
+	if q.SubscriptionID != 0 {
+		query.Set("subscription_id", strconv.FormatInt(q.SubscriptionID, 10))
+	}
+	if q.UserID != "" {
+		query.Set("user_id", q.UserID)
+	}
+	if q.Principal != "" {
+		query.Set("principal", q.Principal)
+	}
+	if q.Actor != "" {
+		query.Set("actor", q.Actor)
+	}
+	if q.Action != "" {
+		query.Set("action", q.Action)
+	}
+	if !q.From.IsZero() {
+		query.Set("from", q.From.UTC().Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.UTC().Format(time.RFC3339))
+	}
+	if q.BeforeID != 0 {
+		query.Set("before_id", strconv.FormatInt(q.BeforeID, 10))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	var resp pkg.GetAuditResponse
+	if err := c.do(ctx, http.MethodGet, "/admin/audit", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+// Health calls the liveness probe.
+func (c *Client) Health(ctx context.Context) (pkg.HealthResponse, error) {
+	var resp pkg.HealthResponse
+	err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, &resp)
+	return resp, err
+}
+
+// Ready calls the readiness probe. A server that stays unready through all
+// retries yields an *APIError with status 503.
+func (c *Client) Ready(ctx context.Context) (pkg.HealthResponse, error) {
+	var resp pkg.HealthResponse
+	err := c.do(ctx, http.MethodGet, "/readyz", nil, nil, &resp)
+	return resp, err
+}
+
+func subscriptionPath(userID, serviceName string) string {
+	return "/subscriptions/" + url.PathEscape(userID) + "/" + url.PathEscape(serviceName)
+}
+
+func byIDPath(id int64) string {
+	return "/subscriptions/by-id/" + strconv.FormatInt(id, 10)
+}
@@ -1,11 +1,21 @@
 package pkg
 
+import "encoding/json"
+
 type SubscriptionDTO struct {
+	ID          int64  `json:"id" example:"42"`
 	UserId      string `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
 	ServiceName string `json:"service_name" example:"Yandex Plus"`
 	Price       int    `json:"price" example:"400"`
 	StartDate   string `json:"start_date" example:"07-2025"`
 	EndDate     string `json:"end_date,omitempty" example:"07-2026"`
+	DeletedAt   string `json:"deleted_at,omitempty" example:"2025-09-01T10:00:00Z"`
+	// AutoRenew extends end_date by a month when it passes instead of expiring.
+	AutoRenew bool `json:"auto_renew" example:"false"`
+	// ExpiredAt is set once the end month has passed without a renewal.
+	ExpiredAt string `json:"expired_at,omitempty" example:"2026-08-01T00:00:00Z"`
+	// BilledThrough is the last month charged by a renewal.
+	BilledThrough string `json:"billed_through,omitempty" example:"08-2026"`
 }
 
 type GetSubsResponse struct {
@@ -16,6 +26,7 @@ type UpdateSubRequest struct {
 	Price     *int    `json:"price,omitempty" example:"500"`
 	StartDate *string `json:"start_date,omitempty" example:"08-2025"`
 	EndDate   *string `json:"end_date,omitempty" example:"08-2026"`
+	AutoRenew *bool   `json:"auto_renew,omitempty" example:"true"`
 }
 
 type CreateSubRequest struct {
@@ -24,6 +35,7 @@ type CreateSubRequest struct {
 	Price       int    `json:"price" example:"400"`
 	StartDate   string `json:"start_date" example:"07-2025"`
 	EndDate     string `json:"end_date,omitempty" example:"07-2026"`
+	AutoRenew   bool   `json:"auto_renew,omitempty" example:"false"`
 }
 
 type DeleteSubRequest struct {
@@ -39,13 +51,204 @@ type ErrorResponse struct {
 	Error string `json:"error" example:"Subscription not found"`
 }
 
+// ProblemResponse is an RFC 7807 problem document.
+type ProblemResponse struct {
+	Type   string `json:"type" example:"about:blank"`
+	Title  string `json:"title" example:"Too Many Requests"`
+	Status int    `json:"status" example:"429"`
+	Detail string `json:"detail,omitempty" example:"rate limit exceeded, retry in 2 seconds"`
+}
+
 type GetTotalCostRequest struct {
 	UserId      string  `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
 	ServiceName *string `json:"service_name,omitempty" example:"Netflix"`
 	StartDate   string  `json:"start_date" example:"01-2025"`
 	EndDate     string  `json:"end_date" example:"12-2025"`
+	Mode        string  `json:"mode,omitempty" example:"actual" enums:"expected,actual"`
 }
 
 type GetTotalCostResponse struct {
 	TotalCost int `json:"total_cost" example:"1200"`
 }
+
+// ChargeDTO is the ledger entry of one billed month of a subscription.
+type ChargeDTO struct {
+	ID             int64  `json:"id" example:"311"`
+	SubscriptionID int64  `json:"subscription_id,omitempty" example:"42"`
+	ServiceName    string `json:"service_name" example:"Yandex Plus"`
+	Period         string `json:"period" example:"07-2025"`
+	Amount         int    `json:"amount" example:"400"`
+	Currency       string `json:"currency" example:"RUB"`
+	Status         string `json:"status" example:"paid" enums:"expected,paid,refunded,skipped,disputed"`
+	PaidAmount     *int   `json:"paid_amount,omitempty" example:"399"`
+	PaidOn         string `json:"paid_on,omitempty" example:"2025-07-03"`
+}
+
+// PayChargeRequest records a payment; the charge's amount paid today when empty.
+type PayChargeRequest struct {
+	Amount *int   `json:"amount,omitempty" example:"399"`
+	PaidOn string `json:"paid_on,omitempty" example:"2025-07-03"`
+}
+
+type GetChargesResponse struct {
+	Charges []ChargeDTO `json:"charges"`
+}
+
+// BudgetRequest creates or replaces a budget. Without services it covers
+// every subscription of the user.
+type BudgetRequest struct {
+	Name     string   `json:"name" example:"Стриминг"`
+	Limit    int      `json:"limit" example:"3000"`
+	Currency string   `json:"currency,omitempty" example:"RUB"`
+	Services []string `json:"services,omitempty" example:"Netflix,Kinopoisk"`
+}
+
+type BudgetDTO struct {
+	ID        int64    `json:"id" example:"5"`
+	UserId    string   `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Name      string   `json:"name" example:"Стриминг"`
+	Limit     int      `json:"limit" example:"3000"`
+	Currency  string   `json:"currency" example:"RUB"`
+	Services  []string `json:"services" example:"Netflix,Kinopoisk"`
+	CreatedAt string   `json:"created_at" example:"2025-07-01T12:00:00Z"`
+	UpdatedAt string   `json:"updated_at" example:"2025-07-01T12:00:00Z"`
+}
+
+type GetBudgetsResponse struct {
+	Budgets []BudgetDTO `json:"budgets"`
+}
+
+// BudgetStatusDTO is the spend of a budget in a month: spent is what was
+// paid so far, projected adds the charges still expected.
+type BudgetStatusDTO struct {
+	Budget    BudgetDTO `json:"budget"`
+	Spent     int       `json:"spent" example:"1200"`
+	Projected int       `json:"projected" example:"3400"`
+	Remaining int       `json:"remaining" example:"-400"`
+	Exceeded  bool      `json:"exceeded" example:"true"`
+}
+
+type GetBudgetStatusResponse struct {
+	Period  string            `json:"period" example:"07-2025"`
+	Budgets []BudgetStatusDTO `json:"budgets"`
+}
+
+type AuditEntryDTO struct {
+	ID             int64           `json:"id" example:"7"`
+	SubscriptionID int64           `json:"subscription_id" example:"42"`
+	UserId         string          `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Action         string          `json:"action" example:"update"`
+	Principal      string          `json:"principal" example:"acme/billing"`
+	Actor          string          `json:"actor,omitempty" example:"support@example.com"`
+	RequestID      string          `json:"request_id,omitempty" example:"host/abc123-000001"`
+	Before         json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After          json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	CreatedAt      string          `json:"created_at" example:"2025-07-01T12:00:00Z"`
+}
+
+type GetAuditResponse struct {
+	Entries []AuditEntryDTO `json:"entries"`
+}
+
+type HealthResponse struct {
+	Status string            `json:"status" example:"ok"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type CreateWebhookRequest struct {
+	// UserId limits the webhook to one user; empty receives events of the whole tenant.
+	UserId string   `json:"user_id,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	URL    string   `json:"url" example:"https://example.com/hooks/subscriptions"`
+	Events []string `json:"events,omitempty" example:"subscription.created,subscription.deleted"`
+}
+
+type WebhookDTO struct {
+	ID     int64    `json:"id" example:"3"`
+	UserId string   `json:"user_id,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	URL    string   `json:"url" example:"https://example.com/hooks/subscriptions"`
+	Events []string `json:"events" example:"subscription.created,subscription.deleted"`
+	// Secret is only returned when the webhook is created.
+	Secret    string `json:"secret,omitempty" example:"whsec_5f2b..."`
+	CreatedAt string `json:"created_at" example:"2025-07-01T12:00:00Z"`
+}
+
+type GetWebhooksResponse struct {
+	Webhooks []WebhookDTO `json:"webhooks"`
+}
+
+type WebhookDeliveryDTO struct {
+	ID             int64           `json:"id" example:"118"`
+	EventID        string          `json:"event_id" example:"2f7a4f1e-6a55-4f0c-9f44-2f0c1b6f3d1a"`
+	EventType      string          `json:"event_type" example:"subscription.created"`
+	Status         string          `json:"status" example:"pending" enums:"pending,delivered,dead"`
+	Attempts       int             `json:"attempts" example:"2"`
+	ResponseStatus int             `json:"response_status,omitempty" example:"503"`
+	LastError      string          `json:"last_error,omitempty" example:"receiver answered 503"`
+	NextAttemptAt  string          `json:"next_attempt_at,omitempty" example:"2025-07-01T12:00:40Z"`
+	LastAttemptAt  string          `json:"last_attempt_at,omitempty" example:"2025-07-01T12:00:20Z"`
+	CreatedAt      string          `json:"created_at" example:"2025-07-01T12:00:00Z"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+}
+
+type GetWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryDTO `json:"deliveries"`
+}
+
+// FeedEventDTO is the data of one Server-Sent Event of a user's change feed;
+// the SSE id and event fields carry the feed position and the type.
+type FeedEventDTO struct {
+	ID           string          `json:"id" example:"2f7a4f1e-6a55-4f0c-9f44-2f0c1b6f3d1a"`
+	Type         string          `json:"type" example:"subscription.updated" enums:"subscription.created,subscription.updated,subscription.deleted,subscription.renewing,subscription.charged,subscription.expired"`
+	OccurredAt   string          `json:"occurred_at" example:"2025-07-01T12:00:00Z"`
+	Subscription SubscriptionDTO `json:"subscription"`
+}
+
+// UpdateNotificationSettingsRequest replaces a user's reminder settings;
+// omitted fields take their defaults.
+type UpdateNotificationSettingsRequest struct {
+	Email    string `json:"email" example:"user@example.com"`
+	Locale   string `json:"locale,omitempty" example:"ru" enums:"en,ru"`
+	LeadDays int    `json:"lead_days,omitempty" example:"3"`
+	Enabled  *bool  `json:"enabled,omitempty" example:"true"`
+}
+
+type NotificationSettingsDTO struct {
+	UserId    string `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Email     string `json:"email" example:"user@example.com"`
+	Locale    string `json:"locale" example:"ru"`
+	LeadDays  int    `json:"lead_days" example:"3"`
+	Enabled   bool   `json:"enabled" example:"true"`
+	UpdatedAt string `json:"updated_at" example:"2025-07-01T12:00:00Z"`
+}
+
+type TelegramLinkCodeDTO struct {
+	Code      string `json:"code" example:"MFRGGZDF"`
+	ExpiresAt string `json:"expires_at" example:"2025-07-01T12:15:00Z"`
+}
+
+type JobRunDTO struct {
+	ID          int64  `json:"id" example:"42"`
+	Job         string `json:"job" example:"reminders.email"`
+	Trigger     string `json:"trigger" example:"schedule" enums:"schedule,manual"`
+	Status      string `json:"status" example:"succeeded" enums:"running,succeeded,failed"`
+	Error       string `json:"error,omitempty" example:"storage.ListDueRenewalReminders: timeout"`
+	Instance    string `json:"instance" example:"sub-aggregator-7d9f8c6b5-x2kqp"`
+	ScheduledAt string `json:"scheduled_at" example:"2025-07-01T12:00:00Z"`
+	StartedAt   string `json:"started_at" example:"2025-07-01T12:00:00Z"`
+	FinishedAt  string `json:"finished_at,omitempty" example:"2025-07-01T12:00:02Z"`
+}
+
+type JobDTO struct {
+	Name      string     `json:"name" example:"reminders.email"`
+	Schedule  string     `json:"schedule" example:"@every 1h0m0s"`
+	NextRunAt string     `json:"next_run_at,omitempty" example:"2025-07-01T13:00:00Z"`
+	LastRun   *JobRunDTO `json:"last_run,omitempty"`
+}
+
+type GetJobsResponse struct {
+	Jobs []JobDTO `json:"jobs"`
+}
+
+type GetJobRunsResponse struct {
+	Runs []JobRunDTO `json:"runs"`
+}
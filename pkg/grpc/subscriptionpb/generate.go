@@ -0,0 +1,5 @@
+// Package subscriptionpb holds the protobuf messages and gRPC stubs of the
+// subscription service, generated from subscription.proto.
+package subscriptionpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative subscription.proto